@@ -0,0 +1,22 @@
+package packagebug
+
+import "time"
+
+// Advisory represents the security advisory of the package, e.g. the known
+// vulnerability of its Go module.
+type Advisory struct {
+	// Id is the id of the advisory database, e.g. GO-2023-1571
+	Id string
+	// Aliases are the ids of the same advisory in other databases, e.g. the
+	// CVE and GHSA ids
+	Aliases []string
+	Summary string
+	// Severity is the severity rated by the advisory database, e.g. HIGH,
+	// empty if not rated
+	Severity    string
+	Url         string
+	PublishedAt time.Time
+	ModifiedAt  time.Time
+	// WithdrawnAt is set if the advisory was withdrawn
+	WithdrawnAt *time.Time
+}
@@ -0,0 +1,102 @@
+package packagebug
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Budget limits a single sync of a package, so one giant repository doesn't
+// consume the whole rate limit. The zero limits are unlimited. The sync that
+// spills over the budget is continued by another message, see Cursor. Every
+// provider honours the budget.
+type Budget struct {
+	// Pages is the maximum pages of the issue lists
+	Pages int
+	// Issues is the maximum issues, checked page by page
+	Issues int
+}
+
+// PagesLeft returns how many more pages the budget allows after pages and
+// issues were fetched, perPage is the number of issues of a full page. It
+// returns math.MaxInt if unlimited.
+func (b Budget) PagesLeft(pages, issues, perPage int) int {
+	left := math.MaxInt
+	if b.Pages > 0 {
+		left = b.Pages - pages
+	}
+	if b.Issues > 0 {
+		n := b.Issues - issues
+		if perPage > 0 {
+			// the last page may be partially used
+			n = (n + perPage - 1) / perPage
+		}
+		if n < left {
+			left = n
+		}
+	}
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Left returns the budget left after pages and issues were fetched. The zero
+// limits are unlimited, so check PagesLeft first: a spent limit is no limit.
+func (b Budget) Left(pages, issues int) Budget {
+	if b.Pages > 0 {
+		b.Pages -= pages
+	}
+	if b.Issues > 0 {
+		b.Issues -= issues
+	}
+	return b
+}
+
+// Cursor is where the sync that spilled over the budget continues: the page
+// of the label query of the package. The zero cursor is the start of the
+// sync.
+type Cursor struct {
+	Query int
+	Page  int
+	// After is the end cursor of the GraphQL page the sync continues
+	// after, the base64 cursors of GitHub and SourceHut never have a comma
+	// or a dot
+	After string
+}
+
+// IsZero returns true if c is the start of the sync.
+func (c Cursor) IsZero() bool {
+	return c.Query == 0 && c.Page <= 1 && c.After == ""
+}
+
+// String returns the cursor formatted as query.page, e.g. 0.11, followed by
+// the end cursor of GraphQL if any, e.g. 0.11.Y3Vyc29yOnYyOpK5.
+func (c Cursor) String() string {
+	s := fmt.Sprintf("%d.%d", c.Query, c.Page)
+	if c.After != "" {
+		s += "." + c.After
+	}
+	return s
+}
+
+// ParseCursor parses the cursor formatted by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	query, page, ok := strings.Cut(s, ".")
+	if !ok {
+		return c, fmt.Errorf("invalid cursor %q", s)
+	}
+	page, c.After, _ = strings.Cut(page, ".")
+	var err error
+	c.Query, err = strconv.Atoi(query)
+	if err != nil || c.Query < 0 {
+		return c, fmt.Errorf("invalid cursor %q", s)
+	}
+	c.Page, err = strconv.Atoi(page)
+	if err != nil || c.Page < 1 {
+		return c, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
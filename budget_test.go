@@ -0,0 +1,56 @@
+package packagebug
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBudgetPagesLeft(t *testing.T) {
+	cases := []struct {
+		budget                 Budget
+		pages, issues, perPage int
+		expected               int
+	}{
+		{Budget{}, 10, 300, 30, math.MaxInt},
+		{Budget{Pages: 5}, 3, 90, 30, 2},
+		{Budget{Pages: 5}, 6, 180, 30, 0},
+		{Budget{Issues: 100}, 1, 30, 30, 3},
+		{Budget{Issues: 100}, 4, 120, 30, 0},
+		{Budget{Pages: 2, Issues: 100}, 1, 30, 30, 1},
+		// the size of the page is unknown
+		{Budget{Issues: 100}, 1, 10, 0, 90},
+	}
+	for _, c := range cases {
+		left := c.budget.PagesLeft(c.pages, c.issues, c.perPage)
+		if left != c.expected {
+			t.Errorf("expected %d pages left of %+v after %d pages %d issues got: %d\n",
+				c.expected, c.budget, c.pages, c.issues, left)
+		}
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	c, err := ParseCursor(Cursor{Query: 1, Page: 11}.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Query != 1 || c.Page != 11 {
+		t.Errorf("unexpected cursor: %+v\n", c)
+	}
+	if !(Cursor{}).IsZero() || !(Cursor{Page: 1}).IsZero() || c.IsZero() {
+		t.Errorf("unexpected zero cursor\n")
+	}
+	c, err = ParseCursor(Cursor{Page: 3, After: "Y3Vyc29yOnYyOpK5"}.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Page != 3 || c.After != "Y3Vyc29yOnYyOpK5" || c.IsZero() {
+		t.Errorf("unexpected cursor of GraphQL: %+v\n", c)
+	}
+	for _, s := range []string{"", "1", "a.1", "0.0", "-1.2", "0.a.Y3Vy"} {
+		_, err = ParseCursor(s)
+		if err == nil {
+			t.Errorf("expected error for %q\n", s)
+		}
+	}
+}
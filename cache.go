@@ -0,0 +1,46 @@
+package packagebug
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// CacheTTL is how long the cached responses are used for the conditional
+// requests, the older ones are pruned.
+const CacheTTL = 7 * 24 * time.Hour
+
+// CachedResponse holds the validators of a page fetched from the host, so
+// the next request of the page is conditional. The page not modified since
+// is already stored.
+type CachedResponse struct {
+	// Url is the key of the page, see CacheKey
+	Url          string `json:"url"`
+	Etag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Link is the Link header of the page, the not modified response has
+	// no pagination links
+	Link string `json:"link,omitempty"`
+}
+
+// ResponseCache stores the cached responses by their url.
+type ResponseCache interface {
+	// GetResponse returns the cached response of the url, false if none.
+	GetResponse(ctx context.Context, url string) (CachedResponse, bool, error)
+	// SaveResponses stores the responses of the stored result.
+	SaveResponses(ctx context.Context, responses []CachedResponse) error
+}
+
+// CacheKey returns the key of the page url in the response cache, the url
+// without the OAuth app credentials.
+func CacheKey(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	query := u.Query()
+	query.Del("client_id")
+	query.Del("client_secret")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
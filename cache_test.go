@@ -0,0 +1,11 @@
+package packagebug
+
+import "testing"
+
+func TestCacheKey(t *testing.T) {
+	u := "https://api.github.com/repos/pyk/byten/issues?client_id=id&client_secret=secret&labels=bug&page=2"
+	expected := "https://api.github.com/repos/pyk/byten/issues?labels=bug&page=2"
+	if key := CacheKey(u); key != expected {
+		t.Errorf("expected %q got: %q\n", expected, key)
+	}
+}
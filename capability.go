@@ -0,0 +1,126 @@
+package packagebug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capability is a bitmask of optional data fetched and stored per issue.
+// Every capability except bodies and reactions costs extra API requests.
+type Capability uint
+
+const (
+	CapBodies Capability = 1 << iota
+	CapComments
+	CapEvents
+	CapReactions
+
+	// CapNone fetches only the issue list.
+	CapNone Capability = 0
+	// CapAll fetches all optional data.
+	CapAll = CapBodies | CapComments | CapEvents | CapReactions
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapBodies, "bodies"},
+	{CapComments, "comments"},
+	{CapEvents, "events"},
+	{CapReactions, "reactions"},
+}
+
+// ParseCapabilities parses comma separated capability names (bodies,
+// comments, events, reactions), "all", "none" or the numeric bitmask.
+func ParseCapabilities(s string) (Capability, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		if Capability(n)&^CapAll != 0 {
+			return CapNone, fmt.Errorf("invalid capabilities %d", n)
+		}
+		return Capability(n), nil
+	}
+
+	var c Capability
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			c |= CapAll
+			continue
+		}
+		found := false
+		for _, cn := range capabilityNames {
+			if cn.name == name {
+				c |= cn.cap
+				found = true
+			}
+		}
+		if !found {
+			return CapNone, fmt.Errorf("unknown capability %q", name)
+		}
+	}
+	return c, nil
+}
+
+// Has returns true if c contains all of x.
+func (c Capability) Has(x Capability) bool {
+	return c&x == x
+}
+
+func (c Capability) String() string {
+	var names []string
+	for _, cn := range capabilityNames {
+		if c.Has(cn.cap) {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// Reactions represents the reaction counts of an issue.
+type Reactions struct {
+	Total    int `json:"total_count"`
+	PlusOne  int `json:"+1"`
+	MinusOne int `json:"-1"`
+}
+
+// Comment represents a comment of an issue.
+type Comment struct {
+	GithubId  int64     `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	User      struct {
+		Username string `json:"login"`
+	} `json:"user"`
+}
+
+// Event represents an event on an issue e.g. labeled, closed or reopened.
+type Event struct {
+	GithubId  int64     `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Label     struct {
+		Name string `json:"name"`
+	} `json:"label"`
+}
+
+// Project removes the data of the issues that is not part of capabilities.
+func (r *Result) Project(caps Capability) {
+	for i := range r.Issues {
+		if !caps.Has(CapBodies) {
+			r.Issues[i].Body = ""
+		}
+		if !caps.Has(CapReactions) {
+			r.Issues[i].Reactions = Reactions{}
+		}
+	}
+}
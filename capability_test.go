@@ -0,0 +1,45 @@
+package packagebug
+
+import (
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	cases := map[string]Capability{
+		"":                  CapNone,
+		"none":              CapNone,
+		"all":               CapAll,
+		"bodies, reactions": CapBodies | CapReactions,
+		"Comments":          CapComments,
+		"5":                 CapBodies | CapEvents,
+	}
+	for s, expected := range cases {
+		c, err := ParseCapabilities(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != expected {
+			t.Errorf("%q expected: %s got: %s\n", s, expected, c)
+		}
+	}
+	for _, s := range []string{"labels", "64"} {
+		_, err := ParseCapabilities(s)
+		if err == nil {
+			t.Errorf("%q expected error\n", s)
+		}
+	}
+}
+
+func TestResultProject(t *testing.T) {
+	r := &Result{Issues: []Issue{{
+		Body:      "body",
+		Reactions: Reactions{Total: 2, PlusOne: 2},
+	}}}
+	r.Project(CapBodies)
+	if r.Issues[0].Body != "body" {
+		t.Errorf("expected body kept got: %q\n", r.Issues[0].Body)
+	}
+	if r.Issues[0].Reactions.PlusOne != 0 {
+		t.Errorf("expected reactions removed got: %d\n", r.Issues[0].Reactions.PlusOne)
+	}
+}
@@ -0,0 +1,776 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/export"
+	"github.com/pyk/packagebug-worker/internal/notify"
+	"github.com/pyk/packagebug-worker/internal/osv"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/vanity"
+	"github.com/pyk/packagebug-worker/internal/worker"
+)
+
+// Config represents the settings of the worker.
+type Config struct {
+	// Mode is worker (default), export, scheduler or webhook
+	Mode        string
+	DatabaseUrl string
+	Queue       QueueConfig
+	GitHub      GitHubConfig
+	Bitbucket   BitbucketConfig
+	Gitea       GiteaConfig
+	SourceHut   SourceHutConfig
+	Export      ExportConfig
+	Outbox      OutboxConfig
+	Notify      NotifyConfig
+	Webhook     WebhookConfig
+	Admin       AdminConfig
+	Control     ControlConfig
+	Tracing     TracingConfig
+	// Tenants crawl their packages with their own credentials and page
+	// budget, see worker.Tenant
+	Tenants []TenantConfig
+	// DatabaseDriver is postgres (default) or sqlite, the DatabaseUrl of
+	// sqlite is the path of the database file
+	DatabaseDriver string
+	// ReplicaUrl is the read replica of Postgres, the read-only queries run
+	// on the primary if empty
+	ReplicaUrl string
+	// Pool is the connection pool of postgres, sqlite has a single
+	// connection
+	Pool PoolConfig
+	// HTTPTimeout is the timeout of a single API request
+	HTTPTimeout time.Duration
+	// PaceMaxWait is the longest wait for the X-Poll-Interval or the
+	// Retry-After of the host before the message is delayed instead
+	PaceMaxWait time.Duration
+	// ResponseCache stores the responses of the pages for the conditional
+	// requests: database, redis or empty to disable
+	ResponseCache string
+	CacheRedisUrl string
+	// ScheduleInterval is the interval of the due packages scan of the
+	// scheduler mode
+	ScheduleInterval time.Duration
+	// ScheduleJitter spreads the fetches of the packages due at once
+	ScheduleJitter time.Duration
+	// GoneGrace is how long the scheduler keeps the issues of the packages
+	// whose repository is gone, zero keeps them forever
+	GoneGrace time.Duration
+
+	BufferDir  string
+	BufferSize int
+	HealthAddr string
+	LogLevel   slog.Level
+	// LogSampleInterval is the window of the repeated warnings and errors,
+	// at most LogSampleBurst of each are logged per window. zero disables.
+	LogSampleInterval time.Duration
+	LogSampleBurst    int
+	Verifiers         []github.Verifier
+	// Capabilities is the maximum capabilities of every package
+	Capabilities packagebug.Capability
+	// Query is the issue list query of the packages without their own
+	Query packagebug.Query
+	// Severity classifies the issues, the rules not configured are the
+	// defaults
+	Severity         packagebug.SeverityRules
+	RetryMaxAttempts int
+	ForkPolicy       packagebug.ForkPolicy
+	// VisibilityTimeout of the messages, it's extended while the message is
+	// processed
+	VisibilityTimeout time.Duration
+	// ReceiveWait is the long poll of the receives, at most 20s of SQS
+	ReceiveWait time.Duration
+	// IdleBackoff is the maximum sleep between the empty receives, zero
+	// disables the idle backoff
+	IdleBackoff time.Duration
+	// DedupeWindow skips the packages fetched successfully within it, zero
+	// disables
+	DedupeWindow time.Duration
+	// Shard is the partition of the packages processed by this worker
+	Shard worker.Shard
+	// Concurrency is the maximum concurrent processes, the effective limit
+	// shrinks while the database is slower than SlowSave or the hosts
+	// throttle the worker
+	Concurrency int
+	SlowSave    time.Duration
+	// Budget limits the pages and the issues of a single sync of a package,
+	// zero is unlimited
+	Budget packagebug.Budget
+	// BreakerThreshold consecutive failures of the host or the database
+	// open its circuit breaker for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// PullRequests stores the pull requests labeled as bug
+	PullRequests bool
+	// Skip skips the archived repositories and the forks with few stars
+	Skip worker.SkipRules
+	// Advisories fetches the security advisories of every synced package
+	// from OSV.dev at OSVRoot
+	Advisories bool
+	OSVRoot    string
+	// GoProxy is the Go module proxy that resolves the module paths of the
+	// messages to their repository, empty if off
+	GoProxy string
+	// DryRun fetches the issues without writing to the database or
+	// deleting the messages
+	DryRun bool
+	// AutoMigrate applies the pending migrations on start
+	AutoMigrate bool
+}
+
+// GitHubConfig contains the settings of the GitHub API client.
+type GitHubConfig struct {
+	Root         string
+	ClientId     string
+	ClientSecret string
+	Tokens       []string
+	// TokenLimit is the maximum concurrent requests per token
+	TokenLimit int
+	// API is rest or graphql, the API used to fetch the issues
+	API string
+	// Hosts are the GitHub Enterprise Server instances
+	Hosts []GitHubHost
+}
+
+// BitbucketConfig contains the settings of the Bitbucket API client.
+type BitbucketConfig struct {
+	Root     string
+	Username string
+	Password string
+}
+
+// GiteaConfig contains the settings of the Gitea API client.
+type GiteaConfig struct {
+	// Hosts are the Gitea and Forgejo instances
+	Hosts []string
+}
+
+// SourceHutConfig contains the settings of the todo.sr.ht API client.
+type SourceHutConfig struct {
+	Root string
+	// Token is the personal access token, the packages of git.sr.ht are
+	// not fetched if empty
+	Token string
+}
+
+// PoolConfig contains the settings of the database connection pool.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout cancels the statements running longer on the
+	// server, zero disables
+	StatementTimeout time.Duration
+}
+
+// OutboxConfig contains the settings of the outbox publisher of the worker
+// mode.
+type OutboxConfig struct {
+	// QueueUrl is the SQS queue of the events, the publisher is disabled if
+	// empty
+	QueueUrl string
+	Region   string
+	Interval time.Duration
+	// Retention is how long the published events are kept
+	Retention time.Duration
+}
+
+// NotifyConfig contains the settings of the sync events of the worker mode.
+type NotifyConfig struct {
+	// Target is the SNS topic or the EventBridge event bus, the events are
+	// disabled if its ARN is empty
+	Target notify.Target
+	// Endpoint is optional, e.g. the url of LocalStack
+	Endpoint string
+}
+
+// ExportConfig contains the settings of the export mode.
+type ExportConfig struct {
+	Bucket   string
+	Prefix   string
+	Region   string
+	Interval time.Duration
+	// Format is parquet, jsonl or csv
+	Format string
+	// PerPackage writes the issues of every package to its own file
+	PerPackage bool
+}
+
+// WebhookConfig contains the settings of the webhook mode.
+type WebhookConfig struct {
+	Addr string
+	// Secret is the secret of the GitHub webhooks
+	Secret string
+}
+
+// AdminConfig contains the settings of the admin API of the worker mode.
+type AdminConfig struct {
+	Addr string
+	// Token is the bearer token of the API, the API is disabled if empty
+	Token string
+}
+
+// ControlConfig contains the settings of the gRPC control plane of the
+// worker mode.
+type ControlConfig struct {
+	Addr string
+	// Token is the bearer token of the calls, the control plane is disabled
+	// if empty
+	Token string
+}
+
+// TenantConfig contains the settings of a tenant, the settings of tenant
+// acme-web are PACKAGEBUG_TENANT_ACME_WEB_*.
+type TenantConfig struct {
+	Name string
+	// GitHubTokens are the tokens of github.com of the tenant, the tenant
+	// shares the tokens of the worker if empty
+	GitHubTokens []string
+	// PagesPerHour is the page budget of the tenant per worker, zero is
+	// unlimited. The budget requires GitHubTokens: the workers don't share
+	// their counts, only the own tokens keep the tenant off the quota of the
+	// others
+	PagesPerHour int
+}
+
+// tenantName matches the tenant names, they are part of the messages and
+// of the setting keys.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ConfigError lists every missing or invalid setting.
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e, "\n\t")
+}
+
+// LoadConfig loads the config from the environment variables. If path is not
+// empty, the settings are also read from the file in the format of
+// setup.env.sample; the environment variables take precedence over the file.
+// The returned error is ConfigError if any setting is missing or invalid.
+func LoadConfig(path string) (Config, error) {
+	file := map[string]string{}
+	if path != "" {
+		var err error
+		file, err = ReadConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return loadConfig(func(key string) string {
+		if v, ok := os.LookupEnv(key); ok {
+			return v
+		}
+		return file[key]
+	})
+}
+
+// ReadConfigFile reads KEY=VALUE lines of the file, the lines may be prefixed
+// with export and the values may be quoted. Empty lines and comments are
+// ignored.
+func ReadConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		settings[strings.TrimSpace(key)] = value
+	}
+	return settings, scanner.Err()
+}
+
+// loadConfig loads and validates the config using getenv.
+func loadConfig(getenv func(string) string) (Config, error) {
+	var errs ConfigError
+	invalid := func(key string, err error) {
+		errs = append(errs, fmt.Sprintf("%s: %s", key, err))
+	}
+	required := func(key string) string {
+		v := getenv(key)
+		if v == "" {
+			errs = append(errs, key+": required")
+		}
+		return v
+	}
+	number := func(key string, def int) int {
+		s := getenv(key)
+		if s == "" {
+			return def
+		}
+		n, err := strconv.Atoi(s)
+		if err == nil && n < 1 {
+			err = fmt.Errorf("must be positive, got %d", n)
+		}
+		if err != nil {
+			invalid(key, err)
+		}
+		return n
+	}
+	// limit parses the limits whose zero is unlimited
+	limit := func(key string, def int) int {
+		s := getenv(key)
+		if s == "" {
+			return def
+		}
+		n, err := strconv.Atoi(s)
+		if err == nil && n < 0 {
+			err = fmt.Errorf("must not be negative, got %d", n)
+		}
+		if err != nil {
+			invalid(key, err)
+		}
+		return n
+	}
+	or := func(key, def string) string {
+		if v := getenv(key); v != "" {
+			return v
+		}
+		return def
+	}
+
+	var c Config
+	var err error
+	c.Mode = or("PACKAGEBUG_MODE", "worker")
+	switch c.Mode {
+	case "worker", "export", "scheduler", "webhook":
+	default:
+		invalid("PACKAGEBUG_MODE", fmt.Errorf("unknown mode %q", c.Mode))
+	}
+	c.DatabaseUrl = required("DATABASE_URL")
+	c.DatabaseDriver = or("PACKAGEBUG_DB_DRIVER", "postgres")
+	switch c.DatabaseDriver {
+	case "postgres":
+	case "sqlite":
+		// the export and the scheduler query Postgres directly
+		if c.Mode == "export" || c.Mode == "scheduler" {
+			errs = append(errs, fmt.Sprintf("PACKAGEBUG_DB_DRIVER: sqlite doesn't support %s mode", c.Mode))
+		}
+	default:
+		invalid("PACKAGEBUG_DB_DRIVER", fmt.Errorf("unknown database driver %q", c.DatabaseDriver))
+	}
+	c.ReplicaUrl = getenv("PACKAGEBUG_DB_REPLICA_URL")
+	if c.ReplicaUrl != "" && c.DatabaseDriver == "sqlite" {
+		errs = append(errs, "PACKAGEBUG_DB_REPLICA_URL: sqlite has no replica")
+	}
+	c.Pool = PoolConfig{
+		MaxOpenConns: limit("PACKAGEBUG_DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns: number("PACKAGEBUG_DB_MAX_IDLE_CONNS", 10),
+	}
+	if c.Pool.MaxOpenConns > 0 && c.Pool.MaxIdleConns > c.Pool.MaxOpenConns {
+		errs = append(errs, "PACKAGEBUG_DB_MAX_IDLE_CONNS: must not exceed PACKAGEBUG_DB_MAX_OPEN_CONNS")
+	}
+	// the export reads every issue in a single query
+	timeout := "60s"
+	if c.Mode == "export" {
+		timeout = "0"
+	}
+	for _, d := range []struct {
+		key string
+		def string
+		v   *time.Duration
+	}{
+		{"PACKAGEBUG_DB_CONN_MAX_LIFETIME", "30m", &c.Pool.ConnMaxLifetime},
+		{"PACKAGEBUG_DB_CONN_MAX_IDLE_TIME", "5m", &c.Pool.ConnMaxIdleTime},
+		{"PACKAGEBUG_DB_STATEMENT_TIMEOUT", timeout, &c.Pool.StatementTimeout},
+	} {
+		*d.v, err = time.ParseDuration(or(d.key, d.def))
+		if err == nil && *d.v < 0 {
+			err = fmt.Errorf("must not be negative, got %s", *d.v)
+		}
+		if err != nil {
+			invalid(d.key, err)
+		}
+	}
+
+	c.GitHub = GitHubConfig{
+		Root:         or("PACKAGEBUG_GITHUB_ROOT_ENDPOINT", "https://api.github.com"),
+		ClientId:     getenv("PACKAGEBUG_GITHUB_CLIENT_ID"),
+		ClientSecret: getenv("PACKAGEBUG_GITHUB_CLIENT_SECRET"),
+		Tokens:       packagebug.ParseTokens(getenv("PACKAGEBUG_GITHUB_TOKENS")),
+		// GitHub penalizes more than 100 concurrent requests per token
+		TokenLimit: number("PACKAGEBUG_GITHUB_TOKEN_LIMIT", 100),
+		API:        or("PACKAGEBUG_GITHUB_API", "rest"),
+	}
+	switch c.GitHub.API {
+	case "rest":
+	case "graphql":
+		// the GraphQL API doesn't accept the OAuth app credentials
+		if len(c.GitHub.Tokens) == 0 {
+			errs = append(errs, "PACKAGEBUG_GITHUB_API: graphql requires PACKAGEBUG_GITHUB_TOKENS")
+		}
+	default:
+		invalid("PACKAGEBUG_GITHUB_API", fmt.Errorf("unknown API %q", c.GitHub.API))
+	}
+	c.GitHub.Hosts, err = ParseGitHubHosts(getenv("PACKAGEBUG_GITHUB_HOSTS"),
+		getenv("PACKAGEBUG_GITHUB_HOST_TOKENS"))
+	if err != nil {
+		invalid("PACKAGEBUG_GITHUB_HOSTS", err)
+	}
+	for _, h := range c.GitHub.Hosts {
+		if c.GitHub.API == "graphql" && len(h.Tokens) == 0 {
+			errs = append(errs, fmt.Sprintf("PACKAGEBUG_GITHUB_API: graphql requires PACKAGEBUG_GITHUB_HOST_TOKENS of %s", h.Host))
+		}
+	}
+	if (c.GitHub.ClientId == "") != (c.GitHub.ClientSecret == "") {
+		errs = append(errs, "PACKAGEBUG_GITHUB_CLIENT_ID and PACKAGEBUG_GITHUB_CLIENT_SECRET: both or none required")
+	}
+	c.Bitbucket = BitbucketConfig{
+		Root:     or("PACKAGEBUG_BITBUCKET_ROOT_ENDPOINT", "https://api.bitbucket.org/2.0"),
+		Username: getenv("PACKAGEBUG_BITBUCKET_USERNAME"),
+		Password: getenv("PACKAGEBUG_BITBUCKET_APP_PASSWORD"),
+	}
+	c.Gitea = GiteaConfig{
+		Hosts: packagebug.ParseTokens(or("PACKAGEBUG_GITEA_HOSTS", "codeberg.org")),
+	}
+	c.SourceHut = SourceHutConfig{
+		Root:  or("PACKAGEBUG_SOURCEHUT_ROOT_ENDPOINT", "https://todo.sr.ht"),
+		Token: getenv("PACKAGEBUG_SOURCEHUT_TOKEN"),
+	}
+
+	switch c.Mode {
+	case "webhook":
+		c.Webhook = WebhookConfig{
+			Addr:   or("PACKAGEBUG_WEBHOOK_ADDR", ":8090"),
+			Secret: required("PACKAGEBUG_WEBHOOK_SECRET"),
+		}
+	case "export":
+		c.Export = ExportConfig{
+			Bucket: required("PACKAGEBUG_EXPORT_BUCKET"),
+			Prefix: getenv("PACKAGEBUG_EXPORT_PREFIX"),
+			Region: required("PACKAGEBUG_EXPORT_REGION"),
+		}
+		c.Export.Interval, err = time.ParseDuration(or("PACKAGEBUG_EXPORT_INTERVAL", "24h"))
+		if err != nil {
+			invalid("PACKAGEBUG_EXPORT_INTERVAL", err)
+		}
+		c.Export.Format, err = export.ParseFormat(getenv("PACKAGEBUG_EXPORT_FORMAT"))
+		if err != nil {
+			invalid("PACKAGEBUG_EXPORT_FORMAT", err)
+		}
+		if s := getenv("PACKAGEBUG_EXPORT_PER_PACKAGE"); s != "" {
+			c.Export.PerPackage, err = strconv.ParseBool(s)
+			if err != nil {
+				invalid("PACKAGEBUG_EXPORT_PER_PACKAGE", err)
+			}
+		}
+	default:
+		if c.Mode == "scheduler" {
+			c.ScheduleInterval, err = time.ParseDuration(or("PACKAGEBUG_SCHEDULE_INTERVAL", "1m"))
+			if err != nil {
+				invalid("PACKAGEBUG_SCHEDULE_INTERVAL", err)
+			}
+			c.GoneGrace, err = time.ParseDuration(or("PACKAGEBUG_GONE_GRACE", "720h"))
+			if err != nil {
+				invalid("PACKAGEBUG_GONE_GRACE", err)
+			}
+			c.ScheduleJitter, err = time.ParseDuration(or("PACKAGEBUG_SCHEDULE_JITTER", "15m"))
+			if err == nil && c.ScheduleJitter < 0 {
+				err = fmt.Errorf("must not be negative, got %s", c.ScheduleJitter)
+			}
+			if err != nil {
+				invalid("PACKAGEBUG_SCHEDULE_JITTER", err)
+			}
+		}
+		c.Queue = QueueConfig{
+			Driver:   or("PACKAGEBUG_QUEUE_DRIVER", "sqs"),
+			RedisKey: getenv("PACKAGEBUG_REDIS_QUEUE"),
+		}
+		switch c.Queue.Driver {
+		case "sqs":
+			c.Queue.SQSQueues, err = ParseSQSQueues(getenv("PACKAGEBUG_SQS_QUEUES"))
+			if err != nil {
+				invalid("PACKAGEBUG_SQS_QUEUES", err)
+			}
+			c.Queue.SQSEndpoint = getenv("PACKAGEBUG_SQS_ENDPOINT")
+			if len(c.Queue.SQSQueues) == 0 {
+				queueUrl := or("PACKAGEBUG_SQS_QUEUE_URL", getenv("PACKAGEBUG_SQS_QUEUE_NAME"))
+				c.Queue.SQSEndpoint, c.Queue.SQSQueue, err = ParseSQSEndpoint(
+					c.Queue.SQSEndpoint, queueUrl)
+				if err != nil {
+					invalid("PACKAGEBUG_SQS_QUEUE_URL", err)
+				}
+			}
+			c.Queue.SQSRegion = required("PACKAGEBUG_SQS_REGION")
+		case "redis":
+			c.Queue.RedisUrl = required("PACKAGEBUG_REDIS_URL")
+		case "memory":
+			c.Queue.MemoryFile = getenv("PACKAGEBUG_QUEUE_FILE")
+			c.Queue.MemoryAddr = getenv("PACKAGEBUG_QUEUE_ADDR")
+		default:
+			invalid("PACKAGEBUG_QUEUE_DRIVER",
+				fmt.Errorf("unknown queue driver %q", c.Queue.Driver))
+		}
+	}
+
+	c.Outbox = OutboxConfig{
+		QueueUrl: getenv("PACKAGEBUG_OUTBOX_QUEUE_URL"),
+		Region:   or("PACKAGEBUG_OUTBOX_REGION", getenv("PACKAGEBUG_SQS_REGION")),
+	}
+	if c.Outbox.QueueUrl != "" {
+		if c.Outbox.Region == "" {
+			errs = append(errs, "PACKAGEBUG_OUTBOX_REGION: required by PACKAGEBUG_OUTBOX_QUEUE_URL")
+		}
+		c.Outbox.Interval, err = time.ParseDuration(or("PACKAGEBUG_OUTBOX_INTERVAL", "5s"))
+		if err == nil && c.Outbox.Interval <= 0 {
+			err = fmt.Errorf("must be positive, got %s", c.Outbox.Interval)
+		}
+		if err != nil {
+			invalid("PACKAGEBUG_OUTBOX_INTERVAL", err)
+		}
+		c.Outbox.Retention, err = time.ParseDuration(or("PACKAGEBUG_OUTBOX_RETENTION", "168h"))
+		if err != nil {
+			invalid("PACKAGEBUG_OUTBOX_RETENTION", err)
+		}
+	}
+	if arn := getenv("PACKAGEBUG_NOTIFY_ARN"); arn != "" {
+		c.Notify.Target, err = notify.ParseTarget(arn)
+		if err != nil {
+			invalid("PACKAGEBUG_NOTIFY_ARN", err)
+		}
+		c.Notify.Endpoint = getenv("PACKAGEBUG_NOTIFY_ENDPOINT")
+	}
+
+	c.BufferDir = or("PACKAGEBUG_BUFFER_DIR",
+		filepath.Join(os.TempDir(), "packagebug-buffer"))
+	c.BufferSize = number("PACKAGEBUG_BUFFER_SIZE", 1000)
+	c.HealthAddr = or("PACKAGEBUG_HEALTH_ADDR", ":8080")
+	c.Tracing = TracingConfig{
+		Endpoint:    getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		ServiceName: or("OTEL_SERVICE_NAME", "packagebug-worker"),
+	}
+	c.Admin = AdminConfig{
+		Addr:  or("PACKAGEBUG_ADMIN_ADDR", ":8081"),
+		Token: getenv("PACKAGEBUG_ADMIN_TOKEN"),
+	}
+	c.Control = ControlConfig{
+		Addr:  or("PACKAGEBUG_CONTROL_ADDR", ":8082"),
+		Token: getenv("PACKAGEBUG_CONTROL_TOKEN"),
+	}
+	for _, name := range packagebug.ParseTokens(getenv("PACKAGEBUG_TENANTS")) {
+		if !tenantName.MatchString(name) {
+			invalid("PACKAGEBUG_TENANTS", fmt.Errorf("invalid tenant name %q", name))
+			continue
+		}
+		if slices.ContainsFunc(c.Tenants, func(t TenantConfig) bool { return t.Name == name }) {
+			invalid("PACKAGEBUG_TENANTS", fmt.Errorf("duplicate tenant %q", name))
+			continue
+		}
+		prefix := "PACKAGEBUG_TENANT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		tc := TenantConfig{
+			Name:         name,
+			GitHubTokens: packagebug.ParseTokens(getenv(prefix + "GITHUB_TOKENS")),
+			PagesPerHour: limit(prefix+"PAGES_PER_HOUR", 0),
+		}
+		if tc.PagesPerHour > 0 && len(tc.GitHubTokens) == 0 {
+			invalid(prefix+"PAGES_PER_HOUR",
+				fmt.Errorf("requires %sGITHUB_TOKENS", prefix))
+		}
+		c.Tenants = append(c.Tenants, tc)
+	}
+	c.RetryMaxAttempts = number("PACKAGEBUG_RETRY_MAX_ATTEMPTS", packagebug.Retry.MaxAttempts)
+
+	c.LogLevel, err = packagebug.ParseLevel(getenv("PACKAGEBUG_LOG_LEVEL"))
+	if err != nil {
+		invalid("PACKAGEBUG_LOG_LEVEL", err)
+	}
+	c.LogSampleInterval, err = time.ParseDuration(or("PACKAGEBUG_LOG_SAMPLE_INTERVAL", "1m"))
+	if err == nil && c.LogSampleInterval < 0 {
+		err = fmt.Errorf("must not be negative, got %s", c.LogSampleInterval)
+	}
+	if err != nil {
+		invalid("PACKAGEBUG_LOG_SAMPLE_INTERVAL", err)
+	}
+	c.LogSampleBurst = number("PACKAGEBUG_LOG_SAMPLE_BURST", 10)
+	c.Verifiers, err = github.ParseVerifiers(or("PACKAGEBUG_VERIFY_METHODS",
+		"wellknown,permission"), c.GitHub.Root)
+	if err != nil {
+		invalid("PACKAGEBUG_VERIFY_METHODS", err)
+	}
+	// bodies and reactions are free
+	c.Capabilities, err = packagebug.ParseCapabilities(or("PACKAGEBUG_CAPABILITIES",
+		"bodies,reactions"))
+	if err != nil {
+		invalid("PACKAGEBUG_CAPABILITIES", err)
+	}
+	c.Query, err = packagebug.ParseQuery(getenv("PACKAGEBUG_ISSUE_STATE"),
+		getenv("PACKAGEBUG_ISSUE_LABELS"), getenv("PACKAGEBUG_ISSUE_SORT"),
+		getenv("PACKAGEBUG_ISSUE_DIRECTION"))
+	if err != nil {
+		invalid("PACKAGEBUG_ISSUE_*", err)
+	}
+	c.Severity = append(packagebug.SeverityRules(nil), packagebug.DefaultSeverityRules...)
+	for i, r := range c.Severity {
+		key := "PACKAGEBUG_SEVERITY_" + strings.ToUpper(r.Severity.String())
+		if getenv(key) == "" {
+			continue
+		}
+		c.Severity[i], err = packagebug.ParseSeverityRule(r.Severity, getenv(key))
+		if err != nil {
+			invalid(key, err)
+		}
+	}
+	c.ForkPolicy, err = packagebug.ParseForkPolicy(getenv("PACKAGEBUG_FORK_POLICY"))
+	if err != nil {
+		invalid("PACKAGEBUG_FORK_POLICY", err)
+	}
+
+	c.VisibilityTimeout, err = time.ParseDuration(or("PACKAGEBUG_VISIBILITY_TIMEOUT", "30s"))
+	if err == nil && c.VisibilityTimeout < 3*time.Second {
+		err = fmt.Errorf("must be at least 3s, got %s", c.VisibilityTimeout)
+	}
+	if err != nil {
+		invalid("PACKAGEBUG_VISIBILITY_TIMEOUT", err)
+	}
+	c.Queue.VisibilityTimeout = c.VisibilityTimeout
+	c.ReceiveWait, err = time.ParseDuration(or("PACKAGEBUG_RECEIVE_WAIT", "10s"))
+	if err == nil && (c.ReceiveWait < time.Second || c.ReceiveWait > 20*time.Second) {
+		err = fmt.Errorf("must be between 1s and 20s, got %s", c.ReceiveWait)
+	}
+	if err != nil {
+		invalid("PACKAGEBUG_RECEIVE_WAIT", err)
+	}
+	c.IdleBackoff, err = time.ParseDuration(or("PACKAGEBUG_IDLE_BACKOFF", "30s"))
+	if err != nil {
+		invalid("PACKAGEBUG_IDLE_BACKOFF", err)
+	}
+	c.DedupeWindow, err = time.ParseDuration(or("PACKAGEBUG_DEDUPE_WINDOW", "0"))
+	if err == nil && c.DedupeWindow < 0 {
+		err = fmt.Errorf("must not be negative, got %s", c.DedupeWindow)
+	}
+	if err != nil {
+		invalid("PACKAGEBUG_DEDUPE_WINDOW", err)
+	}
+
+	c.HTTPTimeout, err = time.ParseDuration(or("PACKAGEBUG_HTTP_TIMEOUT", "30s"))
+	if err != nil {
+		invalid("PACKAGEBUG_HTTP_TIMEOUT", err)
+	}
+	c.PaceMaxWait, err = time.ParseDuration(or("PACKAGEBUG_PACE_MAX_WAIT", "10s"))
+	if err != nil {
+		invalid("PACKAGEBUG_PACE_MAX_WAIT", err)
+	}
+	c.ResponseCache = getenv("PACKAGEBUG_RESPONSE_CACHE")
+	switch c.ResponseCache {
+	case "", "database":
+	case "redis":
+		c.CacheRedisUrl = required("PACKAGEBUG_REDIS_URL")
+	default:
+		invalid("PACKAGEBUG_RESPONSE_CACHE",
+			fmt.Errorf("unknown response cache %q", c.ResponseCache))
+	}
+
+	c.Concurrency = number("PACKAGEBUG_CONCURRENCY", 10)
+	// every process holds a connection for the lock of its package
+	if c.DatabaseDriver == "postgres" && c.Pool.MaxOpenConns > 0 &&
+		c.Pool.MaxOpenConns <= worker.PoolReserve {
+		errs = append(errs, fmt.Sprintf("PACKAGEBUG_DB_MAX_OPEN_CONNS: must exceed %d or be 0",
+			worker.PoolReserve))
+	} else if c.DatabaseDriver == "postgres" && c.Pool.MaxOpenConns > 0 &&
+		c.Concurrency > c.Pool.MaxOpenConns-worker.PoolReserve {
+		errs = append(errs, fmt.Sprintf("PACKAGEBUG_CONCURRENCY: must not exceed PACKAGEBUG_DB_MAX_OPEN_CONNS minus %d",
+			worker.PoolReserve))
+	}
+	c.Budget = packagebug.Budget{
+		Pages:  limit("PACKAGEBUG_MAX_PAGES", 0),
+		Issues: limit("PACKAGEBUG_MAX_ISSUES", 0),
+	}
+	c.SlowSave, err = time.ParseDuration(or("PACKAGEBUG_SLOW_SAVE", "2s"))
+	if err != nil {
+		invalid("PACKAGEBUG_SLOW_SAVE", err)
+	}
+
+	c.BreakerThreshold = number("PACKAGEBUG_BREAKER_THRESHOLD", 5)
+	c.BreakerCooldown, err = time.ParseDuration(or("PACKAGEBUG_BREAKER_COOLDOWN", "1m"))
+	if err != nil {
+		invalid("PACKAGEBUG_BREAKER_COOLDOWN", err)
+	}
+
+	c.Shard, err = worker.ParseShard(getenv("PACKAGEBUG_SHARD"))
+	if err != nil {
+		invalid("PACKAGEBUG_SHARD", err)
+	}
+
+	if s := getenv("PACKAGEBUG_PULL_REQUESTS"); s != "" {
+		c.PullRequests, err = strconv.ParseBool(s)
+		if err != nil {
+			invalid("PACKAGEBUG_PULL_REQUESTS", err)
+		}
+	}
+
+	if s := getenv("PACKAGEBUG_SKIP_ARCHIVED"); s != "" {
+		c.Skip.Archived, err = strconv.ParseBool(s)
+		if err != nil {
+			invalid("PACKAGEBUG_SKIP_ARCHIVED", err)
+		}
+	}
+	c.Skip.ForkMinStars, err = strconv.Atoi(or("PACKAGEBUG_SKIP_FORK_MIN_STARS", "0"))
+	if err == nil && c.Skip.ForkMinStars < 0 {
+		err = fmt.Errorf("must not be negative, got %d", c.Skip.ForkMinStars)
+	}
+	if err != nil {
+		invalid("PACKAGEBUG_SKIP_FORK_MIN_STARS", err)
+	}
+
+	if s := getenv("PACKAGEBUG_ADVISORIES"); s != "" {
+		c.Advisories, err = strconv.ParseBool(s)
+		if err != nil {
+			invalid("PACKAGEBUG_ADVISORIES", err)
+		}
+	}
+	c.OSVRoot = strings.TrimSuffix(or("PACKAGEBUG_OSV_ROOT", osv.DefaultRoot), "/")
+	c.GoProxy = strings.TrimSuffix(or("PACKAGEBUG_GO_PROXY", vanity.DefaultProxy), "/")
+	if c.GoProxy == "off" {
+		c.GoProxy = ""
+	}
+
+	if s := getenv("PACKAGEBUG_DRY_RUN"); s != "" {
+		c.DryRun, err = strconv.ParseBool(s)
+		if err != nil {
+			invalid("PACKAGEBUG_DRY_RUN", err)
+		}
+	}
+
+	if s := getenv("PACKAGEBUG_AUTO_MIGRATE"); s != "" {
+		c.AutoMigrate, err = strconv.ParseBool(s)
+		if err != nil {
+			invalid("PACKAGEBUG_AUTO_MIGRATE", err)
+		}
+	}
+	if c.AutoMigrate && c.DryRun {
+		errs = append(errs, "PACKAGEBUG_AUTO_MIGRATE: not allowed in dry run")
+	}
+
+	if len(errs) > 0 {
+		return c, errs
+	}
+	return c, nil
+}
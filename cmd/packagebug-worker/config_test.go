@@ -0,0 +1,335 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func getenvTest(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	c, err := loadConfig(getenvTest(map[string]string{
+		"DATABASE_URL":             "postgres://localhost/packagebug",
+		"PACKAGEBUG_SQS_QUEUE_URL": "https://sqs.local/1/queue",
+		"PACKAGEBUG_SQS_REGION":    "us-east-1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Mode != "worker" {
+		t.Errorf("expected worker mode got: %s\n", c.Mode)
+	}
+	if c.GitHub.Root != "https://api.github.com" {
+		t.Errorf("unexpected github root: %s\n", c.GitHub.Root)
+	}
+	if c.GitHub.TokenLimit != 100 || c.BufferSize != 1000 {
+		t.Errorf("unexpected defaults: %+v\n", c)
+	}
+	if c.Capabilities != packagebug.CapBodies|packagebug.CapReactions {
+		t.Errorf("unexpected capabilities: %s\n", c.Capabilities)
+	}
+	if len(c.Gitea.Hosts) != 1 || c.Gitea.Hosts[0] != "codeberg.org" {
+		t.Errorf("unexpected gitea hosts: %v\n", c.Gitea.Hosts)
+	}
+	if c.HTTPTimeout != 30*time.Second {
+		t.Errorf("expected http timeout 30s got: %s\n", c.HTTPTimeout)
+	}
+	if c.Pool.MaxOpenConns != 25 || c.Pool.MaxIdleConns != 10 || c.Pool.StatementTimeout != time.Minute {
+		t.Errorf("unexpected pool: %+v\n", c.Pool)
+	}
+	if c.Control.Addr != ":8082" || c.Control.Token != "" {
+		t.Errorf("expected control plane disabled got: %+v\n", c.Control)
+	}
+	if c.LogSampleInterval != time.Minute || c.LogSampleBurst != 10 {
+		t.Errorf("unexpected log sampling: %s %d\n", c.LogSampleInterval, c.LogSampleBurst)
+	}
+	if len(c.Verifiers) != 2 || c.ForkPolicy != packagebug.ForkBoth {
+		t.Errorf("unexpected defaults: %+v\n", c)
+	}
+	if len(c.Severity) != 4 || c.Severity.Classify(packagebug.Issue{Title: "panic on nil"}) != packagebug.SeverityHigh {
+		t.Errorf("expected default severity rules got: %+v\n", c.Severity)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	_, err := loadConfig(getenvTest(map[string]string{
+		"PACKAGEBUG_QUEUE_DRIVER":       "redis",
+		"PACKAGEBUG_BUFFER_SIZE":        "-1",
+		"PACKAGEBUG_LOG_LEVEL":          "loud",
+		"PACKAGEBUG_GITHUB_TOKEN_LIMIT": "many",
+	}))
+	errs, ok := err.(ConfigError)
+	if !ok {
+		t.Fatalf("expected ConfigError got: %v\n", err)
+	}
+	for _, key := range []string{"DATABASE_URL", "PACKAGEBUG_REDIS_URL",
+		"PACKAGEBUG_BUFFER_SIZE", "PACKAGEBUG_LOG_LEVEL",
+		"PACKAGEBUG_GITHUB_TOKEN_LIMIT"} {
+		if !strings.Contains(errs.Error(), key) {
+			t.Errorf("expected error of %s got: %s\n", key, errs)
+		}
+	}
+	if len(errs) != 5 {
+		t.Errorf("expected 5 errors got: %d\n", len(errs))
+	}
+}
+
+func TestLoadConfigLimits(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":                          "postgres://localhost/packagebug",
+		"PACKAGEBUG_QUEUE_DRIVER":               "memory",
+		"PACKAGEBUG_DB_MAX_OPEN_CONNS":          "0",
+		"PACKAGEBUG_MAX_PAGES":                  "0",
+		"PACKAGEBUG_MAX_ISSUES":                 "0",
+		"PACKAGEBUG_TENANTS":                    "acme",
+		"PACKAGEBUG_TENANT_ACME_PAGES_PER_HOUR": "0",
+	}
+	// zero is unlimited
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Pool.MaxOpenConns != 0 || c.Budget != (packagebug.Budget{}) || c.Tenants[0].PagesPerHour != 0 {
+		t.Errorf("expected unlimited got: %+v %+v %+v\n", c.Pool, c.Budget, c.Tenants)
+	}
+
+	env["PACKAGEBUG_MAX_PAGES"] = "-1"
+	_, err = loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_MAX_PAGES") {
+		t.Errorf("expected negative pages error got: %v\n", err)
+	}
+}
+
+func TestLoadConfigPool(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":                 "postgres://localhost/packagebug",
+		"PACKAGEBUG_QUEUE_DRIVER":      "memory",
+		"PACKAGEBUG_CONCURRENCY":       "20",
+		"PACKAGEBUG_DB_MAX_OPEN_CONNS": "25",
+	}
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := concurrencyCeiling(c); n != 20 {
+		t.Errorf("expected ceiling 20 got: %d\n", n)
+	}
+
+	// the locks of the processes would hold every connection
+	env["PACKAGEBUG_CONCURRENCY"] = "21"
+	_, err = loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_CONCURRENCY") {
+		t.Errorf("expected concurrency error got: %v\n", err)
+	}
+
+	// the reserve alone would take the whole pool
+	env["PACKAGEBUG_CONCURRENCY"] = "1"
+	env["PACKAGEBUG_DB_MAX_OPEN_CONNS"] = "5"
+	env["PACKAGEBUG_DB_MAX_IDLE_CONNS"] = "5"
+	_, err = loadConfig(getenvTest(env))
+	errs, ok := err.(ConfigError)
+	if !ok || len(errs) != 1 || !strings.Contains(errs[0], "PACKAGEBUG_DB_MAX_OPEN_CONNS") {
+		t.Errorf("expected pool error got: %v\n", err)
+	}
+	c.Pool.MaxOpenConns = 5
+	if n := concurrencyCeiling(c); n != 1 {
+		t.Errorf("expected ceiling 1 got: %d\n", n)
+	}
+}
+
+func TestLoadConfigSQLite(t *testing.T) {
+	env := map[string]string{
+		"PACKAGEBUG_DB_DRIVER":    "sqlite",
+		"DATABASE_URL":            "/var/lib/packagebug/packagebug.db",
+		"PACKAGEBUG_QUEUE_DRIVER": "memory",
+	}
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DatabaseDriver != "sqlite" {
+		t.Errorf("expected sqlite driver got: %s\n", c.DatabaseDriver)
+	}
+
+	env["PACKAGEBUG_MODE"] = "scheduler"
+	_, err = loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_DB_DRIVER") {
+		t.Errorf("expected sqlite scheduler error got: %v\n", err)
+	}
+
+	env["PACKAGEBUG_MODE"] = "worker"
+	env["PACKAGEBUG_DB_REPLICA_URL"] = "postgres://replica/packagebug"
+	_, err = loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_DB_REPLICA_URL") {
+		t.Errorf("expected sqlite replica error got: %v\n", err)
+	}
+}
+
+func TestLoadConfigScheduler(t *testing.T) {
+	env := map[string]string{
+		"PACKAGEBUG_MODE":          "scheduler",
+		"DATABASE_URL":             "postgres://localhost/packagebug",
+		"PACKAGEBUG_SQS_QUEUE_URL": "https://sqs.local/1/queue",
+		"PACKAGEBUG_SQS_REGION":    "us-east-1",
+	}
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ScheduleInterval != time.Minute || c.ScheduleJitter != 15*time.Minute {
+		t.Errorf("unexpected schedule: %s %s\n", c.ScheduleInterval, c.ScheduleJitter)
+	}
+
+	env["PACKAGEBUG_SCHEDULE_JITTER"] = "-1m"
+	_, err = loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_SCHEDULE_JITTER") {
+		t.Errorf("expected error of PACKAGEBUG_SCHEDULE_JITTER got: %v\n", err)
+	}
+}
+
+func TestLoadConfigExport(t *testing.T) {
+	c, err := loadConfig(getenvTest(map[string]string{
+		"PACKAGEBUG_MODE":            "export",
+		"DATABASE_URL":               "postgres://localhost/packagebug",
+		"PACKAGEBUG_EXPORT_BUCKET":   "bucket",
+		"PACKAGEBUG_EXPORT_REGION":   "us-east-1",
+		"PACKAGEBUG_EXPORT_INTERVAL": "1h",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Export.Interval != time.Hour {
+		t.Errorf("expected 1h interval got: %s\n", c.Export.Interval)
+	}
+	if c.Pool.StatementTimeout != 0 {
+		t.Errorf("expected export without statement timeout got: %s\n", c.Pool.StatementTimeout)
+	}
+	if c.Export.Format != "parquet" || c.Export.PerPackage {
+		t.Errorf("expected full parquet export got: %+v\n", c.Export)
+	}
+
+	_, err = loadConfig(getenvTest(map[string]string{
+		"PACKAGEBUG_MODE":          "export",
+		"DATABASE_URL":             "postgres://localhost/packagebug",
+		"PACKAGEBUG_EXPORT_BUCKET": "bucket",
+		"PACKAGEBUG_EXPORT_REGION": "us-east-1",
+		"PACKAGEBUG_EXPORT_FORMAT": "xml",
+	}))
+	if err == nil {
+		t.Errorf("expected error for unknown format\n")
+	}
+}
+
+func TestLoadConfigWebhook(t *testing.T) {
+	c, err := loadConfig(getenvTest(map[string]string{
+		"PACKAGEBUG_MODE":           "webhook",
+		"DATABASE_URL":              "postgres://localhost/packagebug",
+		"PACKAGEBUG_WEBHOOK_SECRET": "secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Webhook.Addr != ":8090" || c.Webhook.Secret != "secret" {
+		t.Errorf("unexpected webhook config: %+v\n", c.Webhook)
+	}
+
+	// the secret is required
+	_, err = loadConfig(getenvTest(map[string]string{
+		"PACKAGEBUG_MODE": "webhook",
+		"DATABASE_URL":    "postgres://localhost/packagebug",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_WEBHOOK_SECRET") {
+		t.Errorf("expected error of PACKAGEBUG_WEBHOOK_SECRET got: %v\n", err)
+	}
+}
+
+func TestLoadConfigGraphQL(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":             "postgres://localhost/packagebug",
+		"PACKAGEBUG_SQS_QUEUE_URL": "https://sqs.local/1/queue",
+		"PACKAGEBUG_SQS_REGION":    "us-east-1",
+		"PACKAGEBUG_GITHUB_API":    "graphql",
+	}
+	_, err := loadConfig(getenvTest(env))
+	if err == nil || !strings.Contains(err.Error(), "PACKAGEBUG_GITHUB_TOKENS") {
+		t.Errorf("expected graphql to require tokens got: %v\n", err)
+	}
+	env["PACKAGEBUG_GITHUB_TOKENS"] = "a,b"
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GitHub.API != "graphql" {
+		t.Errorf("expected graphql got: %s\n", c.GitHub.API)
+	}
+}
+
+func TestLoadConfigTenants(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_URL":                             "postgres://localhost/packagebug",
+		"PACKAGEBUG_SQS_QUEUE_URL":                 "https://sqs.local/1/queue",
+		"PACKAGEBUG_SQS_REGION":                    "us-east-1",
+		"PACKAGEBUG_TENANTS":                       "acme-web, globex",
+		"PACKAGEBUG_TENANT_ACME_WEB_GITHUB_TOKENS": "a,b",
+		"PACKAGEBUG_TENANT_GLOBEX_GITHUB_TOKENS":   "c",
+		"PACKAGEBUG_TENANT_GLOBEX_PAGES_PER_HOUR":  "500",
+	}
+	c, err := loadConfig(getenvTest(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Tenants) != 2 {
+		t.Fatalf("expected 2 tenants got: %+v\n", c.Tenants)
+	}
+	if c.Tenants[0].Name != "acme-web" || len(c.Tenants[0].GitHubTokens) != 2 || c.Tenants[0].PagesPerHour != 0 {
+		t.Errorf("unexpected tenant: %+v\n", c.Tenants[0])
+	}
+	if c.Tenants[1].Name != "globex" || len(c.Tenants[1].GitHubTokens) != 1 || c.Tenants[1].PagesPerHour != 500 {
+		t.Errorf("unexpected tenant: %+v\n", c.Tenants[1])
+	}
+
+	// the budget is counted per worker, the tenant needs its own tokens
+	delete(env, "PACKAGEBUG_TENANT_GLOBEX_GITHUB_TOKENS")
+	_, err = loadConfig(getenvTest(env))
+	errs, ok := err.(ConfigError)
+	if !ok || len(errs) != 1 || !strings.Contains(errs[0], "PACKAGEBUG_TENANT_GLOBEX_PAGES_PER_HOUR") {
+		t.Errorf("expected the budget without tokens invalid got: %v\n", err)
+	}
+
+	env["PACKAGEBUG_TENANTS"] = "acme,Acme Corp,acme"
+	_, err = loadConfig(getenvTest(env))
+	errs, ok = err.(ConfigError)
+	if !ok || len(errs) != 2 {
+		t.Errorf("expected invalid and duplicate tenant got: %v\n", err)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "setup.env")
+	content := `# comment
+export DATABASE_URL="postgres://localhost/packagebug"
+
+PACKAGEBUG_LOG_LEVEL=debug
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["DATABASE_URL"] != "postgres://localhost/packagebug" {
+		t.Errorf("unexpected DATABASE_URL: %q\n", settings["DATABASE_URL"])
+	}
+	if settings["PACKAGEBUG_LOG_LEVEL"] != "debug" {
+		t.Errorf("unexpected PACKAGEBUG_LOG_LEVEL: %q\n", settings["PACKAGEBUG_LOG_LEVEL"])
+	}
+}
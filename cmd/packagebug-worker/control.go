@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pyk/packagebug-worker/internal/worker"
+)
+
+// Controller calls the control plane of a worker, see controlplane.Client.
+type Controller interface {
+	Pause(ctx context.Context) (worker.State, error)
+	Resume(ctx context.Context) (worker.State, error)
+	SetConcurrency(ctx context.Context, max int) (worker.State, error)
+	Status(ctx context.Context) (worker.State, error)
+}
+
+// Control runs the control subcommand: it calls the control plane of the
+// worker at -addr and writes its state as JSON to out, e.g.
+//
+//	packagebug-worker control -addr 10.0.1.7:8082 pause
+//	packagebug-worker control -addr 10.0.1.7:8082 concurrency 4
+//
+// The commands are pause, resume, concurrency and status. The worker is
+// drained once the status reports no package in flight.
+func Control(ctx context.Context, dial func(addr string) (Controller, error), args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("control", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8082", "address of the control plane of the worker")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("missing command: pause, resume, concurrency or status")
+	}
+	c, err := dial(*addr)
+	if err != nil {
+		return err
+	}
+
+	var st worker.State
+	switch cmd := fs.Arg(0); cmd {
+	case "pause":
+		st, err = c.Pause(ctx)
+	case "resume":
+		st, err = c.Resume(ctx)
+	case "status":
+		st, err = c.Status(ctx)
+	case "concurrency":
+		max, perr := strconv.Atoi(fs.Arg(1))
+		if perr != nil {
+			return fmt.Errorf("invalid concurrency %q", fs.Arg(1))
+		}
+		st, err = c.SetConcurrency(ctx, max)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(st)
+}
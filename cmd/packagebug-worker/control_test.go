@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/worker"
+)
+
+// fakeController records the calls of the control subcommand.
+type fakeController struct {
+	calls []string
+}
+
+func (c *fakeController) Pause(ctx context.Context) (worker.State, error) {
+	c.calls = append(c.calls, "pause")
+	return worker.State{Paused: true, InFlight: []string{"github.com/pyk/byten"}}, nil
+}
+
+func (c *fakeController) Resume(ctx context.Context) (worker.State, error) {
+	c.calls = append(c.calls, "resume")
+	return worker.State{}, nil
+}
+
+func (c *fakeController) SetConcurrency(ctx context.Context, max int) (worker.State, error) {
+	c.calls = append(c.calls, "concurrency")
+	return worker.State{MaxConcurrency: max}, nil
+}
+
+func (c *fakeController) Status(ctx context.Context) (worker.State, error) {
+	c.calls = append(c.calls, "status")
+	return worker.State{}, nil
+}
+
+func TestControl(t *testing.T) {
+	c := &fakeController{}
+	var addr string
+	dial := func(a string) (Controller, error) {
+		addr = a
+		return c, nil
+	}
+	ctx := context.Background()
+	var out bytes.Buffer
+	err := Control(ctx, dial, []string{"-addr", "10.0.1.7:8082", "pause"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "10.0.1.7:8082" || !strings.Contains(out.String(), `"github.com/pyk/byten"`) {
+		t.Errorf("unexpected pause of %s: %s\n", addr, out.String())
+	}
+	out.Reset()
+	err = Control(ctx, dial, []string{"concurrency", "4"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"max_concurrency": 4`) {
+		t.Errorf("expected concurrency 4 got: %s\n", out.String())
+	}
+
+	for _, args := range [][]string{{}, {"drain"}, {"concurrency", "many"}} {
+		err = Control(ctx, dial, args, &out)
+		if err == nil {
+			t.Errorf("expected error of %v\n", args)
+		}
+	}
+	if strings.Join(c.calls, ",") != "pause,concurrency" {
+		t.Errorf("unexpected calls: %v\n", c.calls)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/cache"
+	"github.com/pyk/packagebug-worker/internal/outbox"
+	"github.com/pyk/packagebug-worker/internal/store/postgres"
+	"github.com/pyk/packagebug-worker/internal/store/sqlite"
+	"github.com/pyk/packagebug-worker/internal/worker"
+)
+
+// Store is the store of the worker, the webhook and the admin API
+// implemented by every database driver.
+type Store interface {
+	worker.Store
+	packagebug.ResponseCache
+	// GetStatus returns the sync state of the package.
+	GetStatus(p packagebug.Package) (packagebug.Status, error)
+	PackageLister
+	outbox.Store
+}
+
+// OpenDatabase opens the database of the configured driver and its store.
+// The database of the dry run is read-only, any write fails. The Postgres
+// connections are pooled by cfg.Pool and their statements are canceled
+// after its StatementTimeout. The replica of OpenReplica is optional, the
+// store runs its read-only queries on it.
+func OpenDatabase(cfg Config, replica *sql.DB) (*sql.DB, Store, error) {
+	if cfg.DatabaseDriver == "sqlite" {
+		dbconn, err := sqlite.Open(cfg.DatabaseUrl, cfg.DryRun)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dbconn, &sqlite.Store{DB: dbconn}, nil
+	}
+	dsn := cfg.DatabaseUrl
+	if cfg.DryRun {
+		dsn = postgres.ReadOnlyDSN(dsn)
+	}
+	dbconn, err := openPostgres(cfg, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dbconn, &postgres.Store{DB: dbconn, Replica: replica}, nil
+}
+
+// OpenReplica opens the read replica of Postgres, nil if not configured.
+// Its sessions are read-only and pooled like the primary.
+func OpenReplica(cfg Config) (*sql.DB, error) {
+	if cfg.ReplicaUrl == "" {
+		return nil, nil
+	}
+	return openPostgres(cfg, postgres.ReadOnlyDSN(cfg.ReplicaUrl))
+}
+
+// openPostgres opens the pool of dsn.
+func openPostgres(cfg Config, dsn string) (*sql.DB, error) {
+	dsn = postgres.StatementTimeoutDSN(dsn, cfg.Pool.StatementTimeout)
+	dbconn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	dbconn.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	dbconn.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	dbconn.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	dbconn.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+	return dbconn, nil
+}
+
+// MigrateDatabase applies the pending migrations of the configured driver.
+func MigrateDatabase(ctx context.Context, cfg Config, dbconn *sql.DB) (int, error) {
+	if cfg.DatabaseDriver == "sqlite" {
+		return sqlite.Migrate(ctx, dbconn)
+	}
+	return postgres.Migrate(ctx, dbconn)
+}
+
+// MigrationStatus returns the applied and the latest version of the schema
+// of the configured driver, see MigrateDatabase.
+func MigrationStatus(ctx context.Context, cfg Config, dbconn *sql.DB) (int, int, error) {
+	if cfg.DatabaseDriver == "sqlite" {
+		migrations, err := sqlite.Migrations()
+		if err != nil {
+			return 0, 0, err
+		}
+		applied, err := sqlite.AppliedVersion(ctx, dbconn)
+		return applied, migrations[len(migrations)-1].Version, err
+	}
+	migrations, err := postgres.Migrations()
+	if err != nil {
+		return 0, 0, err
+	}
+	applied, err := postgres.AppliedVersion(ctx, dbconn)
+	return applied, migrations[len(migrations)-1].Version, err
+}
+
+// OpenCache returns the configured response cache, the store itself for the
+// database cache and nil if disabled.
+func OpenCache(cfg Config, store Store) (packagebug.ResponseCache, error) {
+	switch cfg.ResponseCache {
+	case "database":
+		return store, nil
+	case "redis":
+		return cache.NewRedis(cfg.CacheRedisUrl)
+	}
+	return nil, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// DiagnoseTimeout bounds the checks of Diagnose.
+const DiagnoseTimeout = time.Minute
+
+// Check is the result of a dependency checked by Diagnose.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the report of Diagnose.
+type Report struct {
+	Build  BuildInfo `json:"build"`
+	Mode   string    `json:"mode"`
+	Checks []Check   `json:"checks"`
+}
+
+// Pinger checks that the database is reachable, e.g. *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Diagnostics are the dependencies checked by Diagnose. The nil ones are
+// not checked.
+type Diagnostics struct {
+	Mode    string
+	DB      Pinger
+	Replica Pinger
+	// Migrations returns the applied and the latest version of the schema,
+	// see MigrationStatus
+	Migrations func(ctx context.Context) (int, int, error)
+	// Queue & QueueErr are the queue of the configured driver and the
+	// error setting it up, see NewQueue
+	Queue    queue.Queue
+	QueueErr error
+	// GitHub are the clients whose tokens are checked by their name, e.g.
+	// github.com or tenant acme github.com
+	GitHub map[string]*github.Client
+}
+
+// Diagnose runs the diagnose subcommand: it checks the database and the
+// state of its migrations, the replica, the queue and the tokens of GitHub
+// with their remaining quota, and writes the report as JSON to out, e.g.
+//
+//	packagebug-worker -config setup.env diagnose
+//
+// It returns an error if any check failed, so the misconfigured environment
+// is fixed before the worker starts.
+func Diagnose(ctx context.Context, d *Diagnostics, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, DiagnoseTimeout)
+	defer cancel()
+	r := Report{Build: ReadBuildInfo(), Mode: d.Mode}
+	if d.DB != nil {
+		r.Checks = append(r.Checks, pingDatabase(ctx, "database", d.DB))
+	}
+	if d.Migrations != nil {
+		r.Checks = append(r.Checks, checkMigrations(ctx, d.Migrations))
+	}
+	if d.Replica != nil {
+		r.Checks = append(r.Checks, pingDatabase(ctx, "replica", d.Replica))
+	}
+	if d.Queue != nil || d.QueueErr != nil {
+		r.Checks = append(r.Checks, pingQueue(ctx, d.Queue, d.QueueErr))
+	}
+	names := make([]string, 0, len(d.GitHub))
+	for name := range d.GitHub {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.Checks = append(r.Checks, checkTokens(ctx, name, d.GitHub[name])...)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(r)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(r.Checks))
+	}
+	return nil
+}
+
+// pingDatabase pings the database.
+func pingDatabase(ctx context.Context, name string, db Pinger) Check {
+	err := db.PingContext(ctx)
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+	return Check{Name: name, OK: true, Detail: "reachable"}
+}
+
+// checkMigrations compares the applied version of the schema to the latest
+// migration of the binary.
+func checkMigrations(ctx context.Context, status func(ctx context.Context) (int, int, error)) Check {
+	c := Check{Name: "migrations"}
+	applied, latest, err := status(ctx)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("version %d of %d", applied, latest)
+	switch {
+	case applied < latest:
+		c.Error = fmt.Sprintf("%d pending migrations, run -migrate", latest-applied)
+	case applied > latest:
+		c.OK = true
+		c.Detail += ", the schema is newer than the binary"
+	default:
+		c.OK = true
+	}
+	return c
+}
+
+// pingQueue pings the queue if it can.
+func pingQueue(ctx context.Context, q queue.Queue, err error) Check {
+	c := Check{Name: "queue"}
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	p, ok := q.(queue.Pinger)
+	if !ok {
+		c.OK, c.Detail = true, "set up, not pinged"
+		return c
+	}
+	err = p.Ping(ctx)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.OK, c.Detail = true, "reachable"
+	return c
+}
+
+// checkTokens checks every token of the client, the exhausted tokens fail
+// until their reset.
+func checkTokens(ctx context.Context, name string, client *github.Client) []Check {
+	var checks []Check
+	for _, t := range client.CheckTokens(ctx) {
+		c := Check{Name: fmt.Sprintf("%s token %s", name, t.Id)}
+		switch {
+		case t.Err != nil:
+			c.Error = t.Err.Error()
+		case t.Disabled:
+			c.OK, c.Detail = true, "rate limiting disabled"
+		case t.Remaining <= 0:
+			c.Error = fmt.Sprintf("rate limit exhausted until %s", t.Reset.UTC().Format(time.RFC3339))
+		default:
+			c.OK = true
+			c.Detail = fmt.Sprintf("%d requests remaining until %s", t.Remaining,
+				t.Reset.UTC().Format(time.RFC3339))
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// diagnosticClients returns the GitHub clients of the worker whose tokens
+// are checked by Diagnose: github.com, the GitHub Enterprise Server hosts
+// and the tenants with their own tokens.
+func diagnosticClients(cfg Config) map[string]*github.Client {
+	httpc := packagebug.NewHTTPClient(cfg.HTTPTimeout)
+	client := github.NewClient(cfg.GitHub.Tokens, cfg.GitHub.TokenLimit)
+	client.HTTP = httpc
+	client.Root = cfg.GitHub.Root
+	client.ClientId = cfg.GitHub.ClientId
+	client.ClientSecret = cfg.GitHub.ClientSecret
+	clients := map[string]*github.Client{"github.com": client}
+	for _, h := range cfg.GitHub.Hosts {
+		ghe := github.NewHostClient(h.Host, h.Root, h.Tokens, cfg.GitHub.TokenLimit)
+		ghe.HTTP = httpc
+		clients[h.Host] = ghe
+	}
+	for _, tc := range cfg.Tenants {
+		if len(tc.GitHubTokens) == 0 {
+			continue
+		}
+		t := github.NewClient(tc.GitHubTokens, cfg.GitHub.TokenLimit)
+		t.HTTP = httpc
+		t.Root = cfg.GitHub.Root
+		clients["tenant "+tc.Name+" github.com"] = t
+	}
+	return clients
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+type pingerTest struct {
+	err error
+}
+
+func (p pingerTest) PingContext(ctx context.Context) error {
+	return p.err
+}
+
+func TestDiagnose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1500000000")
+	}))
+	defer ts.Close()
+	client := github.NewClient([]string{"good"}, 1)
+	client.Root = ts.URL
+
+	d := &Diagnostics{
+		Mode:    "worker",
+		DB:      pingerTest{},
+		Replica: pingerTest{err: errors.New("connection refused")},
+		Migrations: func(ctx context.Context) (int, int, error) {
+			return 27, 29, nil
+		},
+		Queue:  fake.NewQueue(),
+		GitHub: map[string]*github.Client{"github.com": client},
+	}
+	var out bytes.Buffer
+	err := Diagnose(context.Background(), d, &out)
+	if err == nil || err.Error() != "2 of 5 checks failed" {
+		t.Errorf("expected 2 failed checks got: %v\n", err)
+	}
+	var r Report
+	err = json.Unmarshal(out.Bytes(), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Mode != "worker" || r.Build.Version == "" {
+		t.Errorf("unexpected report: %+v\n", r)
+	}
+	expected := []Check{
+		{Name: "database", OK: true, Detail: "reachable"},
+		{Name: "migrations", Detail: "version 27 of 29", Error: "2 pending migrations, run -migrate"},
+		{Name: "replica", Error: "connection refused"},
+		{Name: "queue", OK: true, Detail: "set up, not pinged"},
+	}
+	if len(r.Checks) != 5 {
+		t.Fatalf("expected 5 checks got: %+v\n", r.Checks)
+	}
+	for i, c := range expected {
+		if r.Checks[i] != c {
+			t.Errorf("expected check %+v got: %+v\n", c, r.Checks[i])
+		}
+	}
+	if c := r.Checks[4]; !c.OK || c.Detail != "4999 requests remaining until 2017-07-14T02:40:00Z" {
+		t.Errorf("unexpected check of the token: %+v\n", c)
+	}
+
+	// the rejected token fails
+	client = github.NewClient([]string{"bad"}, 1)
+	client.Root = ts.URL
+	d = &Diagnostics{GitHub: map[string]*github.Client{"github.com": client}}
+	out.Reset()
+	err = Diagnose(context.Background(), d, &out)
+	if err == nil {
+		t.Errorf("expected error of the rejected token got: %s\n", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/producer"
+)
+
+// PackageFinder finds the stored package of the path crawled for the tenant.
+type PackageFinder interface {
+	FindPackage(tenant, path string) (packagebug.Package, bool, error)
+}
+
+// Enqueue runs the enqueue subcommand: it enqueues the packages of the paths
+// in args, or of the lines of stdin if args has no path, e.g.
+//
+//	packagebug-worker enqueue -priority 10 github.com/pyk/byten
+//
+// The packages are found in the store by their path, those of a tenant with
+// -tenant. It returns the number of
+// enqueued packages, every path is tried and the first error is returned.
+func Enqueue(ctx context.Context, store PackageFinder, p *producer.Producer, args []string, stdin io.Reader) (int, error) {
+	fs := flag.NewFlagSet("enqueue", flag.ContinueOnError)
+	priority := fs.Int("priority", 0, "priority of the messages")
+	action := fs.String("action", "", "action of the messages: fetch, delete or rescan_etag_reset")
+	jitter := fs.Duration("jitter", 0, "random delay of the messages, at most 15m")
+	tenant := fs.String("tenant", "", "tenant of the packages, empty for the deployment")
+	err := fs.Parse(args)
+	if err != nil {
+		return 0, err
+	}
+	opts := producer.Options{Priority: *priority, Action: packagebug.Action(*action),
+		Jitter: *jitter}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if path := strings.TrimSpace(scanner.Text()); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		err = scanner.Err()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	var first error
+	for _, path := range paths {
+		err := enqueuePath(ctx, store, p, *tenant, path, opts)
+		if err != nil {
+			slog.Error("enqueue failed", "package_path", path, "error", err)
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		n++
+	}
+	return n, first
+}
+
+// enqueuePath enqueues the stored package of the path crawled for the tenant.
+func enqueuePath(ctx context.Context, store PackageFinder, p *producer.Producer, tenant, path string, opts producer.Options) error {
+	pkg, ok, err := store.FindPackage(tenant, path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("package %s not found", path)
+	}
+	return p.Enqueue(ctx, pkg, opts)
+}
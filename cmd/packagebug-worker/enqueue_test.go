@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/producer"
+)
+
+func TestEnqueue(t *testing.T) {
+	store := fake.NewStore()
+	p := fake.Package
+	p.Id = "1"
+	store.AddPackage(p)
+	q := fake.NewQueue()
+	ctx := context.Background()
+
+	n, err := Enqueue(ctx, store, producer.New(q),
+		[]string{"-priority", "10", "github.com/pyk/byten"}, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 package enqueued got: %d %v\n", n, err)
+	}
+	// the paths are read from stdin without args, the unknown paths fail
+	n, err = Enqueue(ctx, store, producer.New(q), []string{"-action", "delete"},
+		strings.NewReader("github.com/pyk/byten\n\ngithub.com/pyk/other\n"))
+	if err == nil || n != 1 {
+		t.Errorf("expected 1 package enqueued and error got: %d %v\n", n, err)
+	}
+	// the package of the deployment is not a package of the tenant
+	n, err = Enqueue(ctx, store, producer.New(q), []string{"-tenant", "acme", "github.com/pyk/byten"}, nil)
+	if err == nil || n != 0 {
+		t.Errorf("expected no package of acme enqueued got: %d %v\n", n, err)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 2 || msgs[0] != "1,github.com,pyk,byten,10" || msgs[1] != "1,github.com,pyk,byten,0,delete" {
+		t.Errorf("unexpected messages: %v\n", msgs)
+	}
+}
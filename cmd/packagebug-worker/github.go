@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GitHubHost is the GitHub Enterprise Server instance crawled alongside
+// github.com.
+type GitHubHost struct {
+	// Host of the packages, e.g. ghe.corp.example.com
+	Host string
+	// Root is the root endpoint of the API, e.g.
+	// https://ghe.corp.example.com/api/v3
+	Root   string
+	Tokens []string
+}
+
+// ParseGitHubHosts parses comma separated host=root GitHub Enterprise Server
+// hosts, e.g. ghe.corp.example.com=https://ghe.corp.example.com/api/v3. The
+// root defaults to https://<host>/api/v3 if only the host given. The tokens
+// are comma separated host=token, a host may have several tokens.
+func ParseGitHubHosts(hosts, tokens string) ([]GitHubHost, error) {
+	var ghosts []GitHubHost
+	find := func(host string) int {
+		for i, h := range ghosts {
+			if strings.EqualFold(h.Host, host) {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, token := range packagebug.ParseTokens(hosts) {
+		host, root, _ := strings.Cut(token, "=")
+		host = strings.TrimSpace(host)
+		root = strings.TrimSpace(root)
+		if host == "" || strings.ContainsAny(host, "/:") {
+			return nil, fmt.Errorf("expected host=root got %q", token)
+		}
+		if strings.EqualFold(host, "github.com") {
+			return nil, fmt.Errorf("github.com is configured by PACKAGEBUG_GITHUB_ROOT_ENDPOINT")
+		}
+		if find(host) >= 0 {
+			return nil, fmt.Errorf("duplicate host %q", host)
+		}
+		if root == "" {
+			root = "https://" + host + "/api/v3"
+		}
+		u, err := url.Parse(root)
+		if err != nil {
+			return nil, err
+		}
+		if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("expected http(s) root of %s got %q", host, root)
+		}
+		ghosts = append(ghosts, GitHubHost{Host: host, Root: strings.TrimSuffix(root, "/")})
+	}
+	for _, token := range packagebug.ParseTokens(tokens) {
+		host, value, ok := strings.Cut(token, "=")
+		// the token itself is never reported
+		if !ok || value == "" {
+			return nil, fmt.Errorf("expected host=token")
+		}
+		i := find(strings.TrimSpace(host))
+		if i < 0 {
+			return nil, fmt.Errorf("token of unknown host %q", host)
+		}
+		ghosts[i].Tokens = append(ghosts[i].Tokens, value)
+	}
+	return ghosts, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGitHubHosts(t *testing.T) {
+	hosts, err := ParseGitHubHosts("ghe.corp.example.com, git.example.org=http://10.0.0.1/api/v3/",
+		"ghe.corp.example.com=a,git.example.org=b,ghe.corp.example.com=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts got: %+v\n", hosts)
+	}
+	if hosts[0].Root != "https://ghe.corp.example.com/api/v3" || strings.Join(hosts[0].Tokens, ",") != "a,c" {
+		t.Errorf("unexpected host: %+v\n", hosts[0])
+	}
+	if hosts[1].Root != "http://10.0.0.1/api/v3" || len(hosts[1].Tokens) != 1 {
+		t.Errorf("unexpected host: %+v\n", hosts[1])
+	}
+
+	for _, c := range [][2]string{
+		{"github.com", ""},
+		{"ghe.corp.example.com,ghe.corp.example.com", ""},
+		{"ghe.corp.example.com=ftp://ghe", ""},
+		{"https://ghe.corp.example.com", ""},
+		{"ghe.corp.example.com", "other.example.com=a"},
+		{"ghe.corp.example.com", "a"},
+	} {
+		_, err = ParseGitHubHosts(c[0], c[1])
+		if err == nil {
+			t.Errorf("expected error of %q\n", c)
+		}
+	}
+}
@@ -0,0 +1,446 @@
+// Command packagebug-worker consumes the package messages from the queue and
+// stores the bugs of the packages. It also runs the export, scheduler and
+// webhook modes, see setup.env.sample, and the enqueue, rescan, control and
+// diagnose subcommands, see Enqueue, Rescan, Control and Diagnose.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/admin"
+	"github.com/pyk/packagebug-worker/internal/controlplane"
+	"github.com/pyk/packagebug-worker/internal/export"
+	"github.com/pyk/packagebug-worker/internal/notify"
+	"github.com/pyk/packagebug-worker/internal/osv"
+	"github.com/pyk/packagebug-worker/internal/outbox"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/bitbucket"
+	"github.com/pyk/packagebug-worker/internal/provider/gitea"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/provider/sourcehut"
+	"github.com/pyk/packagebug-worker/internal/queue"
+	"github.com/pyk/packagebug-worker/internal/queue/memory"
+	"github.com/pyk/packagebug-worker/internal/queue/sqs"
+	"github.com/pyk/packagebug-worker/internal/scheduler"
+	"github.com/pyk/packagebug-worker/internal/vanity"
+	"github.com/pyk/packagebug-worker/internal/webhook"
+	"github.com/pyk/packagebug-worker/internal/worker"
+	"github.com/pyk/packagebug-worker/producer"
+)
+
+func main() {
+	configPath := flag.String("config", os.Getenv("PACKAGEBUG_CONFIG"),
+		"path of the config file, see setup.env.sample")
+	migrate := flag.Bool("migrate", false,
+		"apply the pending database migrations and exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(ReadBuildInfo())
+		return
+	}
+
+	// load & validate all settings before doing anything
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// set up structured logger
+	logger := packagebug.NewLogger(os.Stderr, cfg.LogLevel)
+	if cfg.LogSampleInterval > 0 {
+		logger = slog.New(packagebug.NewSampler(logger.Handler(),
+			cfg.LogSampleInterval, cfg.LogSampleBurst))
+	}
+	slog.SetDefault(logger)
+
+	// the modes stop on SIGINT & SIGTERM: the worker finishes the packages
+	// in flight and deletes their messages, the servers are shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the control subcommand calls the control plane of a running worker
+	if flag.Arg(0) == "control" {
+		dial := func(addr string) (Controller, error) {
+			return controlplane.Dial(addr, cfg.Control.Token)
+		}
+		err := Control(ctx, dial, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			fatal("control", err)
+		}
+		return
+	}
+
+	// retry policy of transient GitHub & database errors
+	packagebug.Retry.MaxAttempts = cfg.RetryMaxAttempts
+
+	// connect to the database, dry run never writes to it
+	if cfg.DryRun {
+		slog.Warn("dry run: the database is read-only and messages are kept")
+	}
+	replica, err := OpenReplica(cfg)
+	if err != nil {
+		fatal("open replica", err)
+	}
+	dbconn, store, err := OpenDatabase(cfg, replica)
+	if err != nil {
+		fatal("open database", err)
+	}
+
+	// the diagnose subcommand checks the dependencies of the mode and
+	// exits, the unreachable ones are reported instead of fatal
+	if flag.Arg(0) == "diagnose" {
+		d := &Diagnostics{
+			Mode: cfg.Mode,
+			DB:   dbconn,
+			Migrations: func(ctx context.Context) (int, int, error) {
+				return MigrationStatus(ctx, cfg, dbconn)
+			},
+		}
+		if replica != nil {
+			d.Replica = replica
+		}
+		if cfg.Mode == "worker" || cfg.Mode == "scheduler" {
+			d.Queue, d.QueueErr = NewQueue(cfg.Queue)
+		}
+		if cfg.Mode == "worker" {
+			d.GitHub = diagnosticClients(cfg)
+		}
+		err := Diagnose(ctx, d, os.Stdout)
+		if err != nil {
+			fatal("diagnose", err)
+		}
+		return
+	}
+
+	// make sure the database up
+	err = dbconn.Ping()
+	if err != nil {
+		fatal("ping database", err)
+	}
+	// the analytic reads run on the replica if configured
+	readconn := dbconn
+	if replica != nil {
+		err = replica.Ping()
+		if err != nil {
+			fatal("ping replica", err)
+		}
+		readconn = replica
+		slog.Info("read replica enabled")
+	}
+	if cfg.DatabaseDriver == "postgres" {
+		slog.Info("database pool", "max_open_conns", dbconn.Stats().MaxOpenConnections,
+			"max_idle_conns", cfg.Pool.MaxIdleConns,
+			"conn_max_lifetime", cfg.Pool.ConnMaxLifetime,
+			"conn_max_idle_time", cfg.Pool.ConnMaxIdleTime,
+			"statement_timeout", cfg.Pool.StatementTimeout)
+	}
+
+	// upgrade the schema to the version of the binary
+	if *migrate || cfg.AutoMigrate {
+		n, err := MigrateDatabase(ctx, cfg, dbconn)
+		if err != nil {
+			fatal("migrate database", err)
+		}
+		slog.Info("database migrated", "applied", n)
+		if *migrate {
+			return
+		}
+	}
+
+	// export mode writes the snapshots to S3 instead of consuming the queue
+	if cfg.Mode == "export" {
+		exporter, err := export.New(readconn, cfg.Export.Region,
+			cfg.Export.Bucket, cfg.Export.Prefix)
+		if err != nil {
+			fatal("set up exporter", err)
+		}
+		exporter.Format = cfg.Export.Format
+		exporter.PerPackage = cfg.Export.PerPackage
+		slog.Info("export started", "interval", cfg.Export.Interval,
+			"format", cfg.Export.Format)
+		exporter.Run(ctx, cfg.Export.Interval)
+		slog.Info("export stopped")
+		return
+	}
+
+	// webhook mode stores the issues of the GitHub webhooks as they arrive
+	if cfg.Mode == "webhook" {
+		h := &webhook.Handler{
+			Secret:       []byte(cfg.Webhook.Secret),
+			Store:        store,
+			Capabilities: cfg.Capabilities,
+			Query:        cfg.Query,
+			Severity:     cfg.Severity,
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/webhook", h)
+		slog.Info("webhook server started", "addr", cfg.Webhook.Addr)
+		err = serve(ctx, &http.Server{Addr: cfg.Webhook.Addr, Handler: mux})
+		if err != nil {
+			fatal("serve webhook", err)
+		}
+		slog.Info("webhook server stopped")
+		return
+	}
+
+	// set up the queue of the configured driver
+	q, err := NewQueue(cfg.Queue)
+	if err != nil {
+		fatal("set up queue", err)
+	}
+
+	// the enqueue subcommand sends the packages to the queue and exits
+	if flag.Arg(0) == "enqueue" {
+		sender, ok := q.(queue.Sender)
+		if !ok {
+			fatal("enqueue", errors.New("queue can't send messages"))
+		}
+		n, err := Enqueue(ctx, store, producer.New(sender), flag.Args()[1:], os.Stdin)
+		slog.Info("packages enqueued", "count", n)
+		if err != nil {
+			fatal("enqueue", err)
+		}
+		return
+	}
+
+	// the rescan subcommand enqueues every stored package and exits
+	if flag.Arg(0) == "rescan" {
+		sender, ok := q.(queue.Sender)
+		if !ok {
+			fatal("rescan", errors.New("queue can't send messages"))
+		}
+		n, err := Rescan(ctx, store, producer.New(sender), flag.Args()[1:])
+		slog.Info("packages rescanned", "count", n)
+		if err != nil {
+			fatal("rescan", err)
+		}
+		return
+	}
+
+	// scheduler mode enqueues the due packages instead of consuming them
+	if cfg.Mode == "scheduler" {
+		sender, ok := q.(queue.Sender)
+		if !ok {
+			fatal("set up scheduler", errors.New("queue can't send messages"))
+		}
+		s := &scheduler.Scheduler{DB: dbconn, Sender: sender, Batch: 1000,
+			Grace: cfg.GoneGrace, Jitter: cfg.ScheduleJitter}
+		slog.Info("scheduler started", "interval", cfg.ScheduleInterval)
+		s.Run(ctx, cfg.ScheduleInterval)
+		return
+	}
+
+	// set up GitHub, Bitbucket, Gitea & SourceHut clients shared by all workers, they
+	// share a single HTTP client to reuse the connections. The requests of
+	// the providers are paced by the hints of the hosts.
+	httpc := packagebug.NewHTTPClient(cfg.HTTPTimeout)
+	apic := &http.Client{
+		Transport: provider.NewPacer(httpc.Transport, cfg.PaceMaxWait),
+		Timeout:   httpc.Timeout,
+	}
+	client := github.NewClient(cfg.GitHub.Tokens, cfg.GitHub.TokenLimit)
+	client.HTTP = apic
+	client.Root = cfg.GitHub.Root
+	client.ClientId = cfg.GitHub.ClientId
+	client.ClientSecret = cfg.GitHub.ClientSecret
+	client.GraphQL = cfg.GitHub.API == "graphql"
+	bb := bitbucket.New(cfg.Bitbucket.Root, cfg.Bitbucket.Username,
+		cfg.Bitbucket.Password)
+	bb.HTTP = apic
+	gt := gitea.New(cfg.Gitea.Hosts)
+	gt.HTTP = apic
+	providers := []provider.Provider{client, bb, gt}
+	// the GitHub Enterprise Server hosts have their own roots and tokens
+	for _, h := range cfg.GitHub.Hosts {
+		ghe := github.NewHostClient(h.Host, h.Root, h.Tokens, cfg.GitHub.TokenLimit)
+		ghe.HTTP = apic
+		ghe.GraphQL = client.GraphQL
+		providers = append(providers, ghe)
+	}
+	// the SourceHut API rejects the anonymous requests
+	if cfg.SourceHut.Token != "" {
+		sh := sourcehut.New(cfg.SourceHut.Root, cfg.SourceHut.Token)
+		sh.HTTP = apic
+		providers = append(providers, sh)
+	}
+	resolver := vanity.New()
+	resolver.HTTP = httpc
+	resolver.Proxy = cfg.GoProxy
+	var advisories worker.AdvisoryFetcher
+	if cfg.Advisories {
+		oc := osv.New(cfg.OSVRoot, cfg.HTTPTimeout)
+		oc.HTTP = httpc
+		advisories = oc
+	}
+
+	// cache the responses of the pages for the conditional requests if
+	// enabled
+	rc, err := OpenCache(cfg, store)
+	if err != nil {
+		fatal("set up response cache", err)
+	}
+	for _, prov := range providers {
+		if c, ok := prov.(*github.Client); ok {
+			c.Cache = rc
+		}
+	}
+
+	// the tenants with their own tokens have their own client of
+	// github.com, the other providers are shared
+	tenants := make(map[string]*worker.Tenant)
+	for _, tc := range cfg.Tenants {
+		t := &worker.Tenant{Name: tc.Name, PagesPerHour: tc.PagesPerHour}
+		if len(tc.GitHubTokens) > 0 {
+			t.GitHub = github.NewClient(tc.GitHubTokens, cfg.GitHub.TokenLimit)
+			t.GitHub.HTTP = apic
+			t.GitHub.Root = cfg.GitHub.Root
+			t.GitHub.GraphQL = client.GraphQL
+			t.GitHub.Cache = rc
+			t.Providers = append([]provider.Provider{t.GitHub}, providers[1:]...)
+		}
+		tenants[tc.Name] = t
+	}
+
+	// trace the processing of the messages if the OTLP endpoint is set
+	if cfg.Tracing.Endpoint != "" {
+		shutdown, err := setupTracing(ctx, cfg.Tracing, httpc)
+		if err != nil {
+			fatal("set up tracing", err)
+		}
+		defer shutdown(context.Background())
+		slog.Info("tracing enabled", "endpoint", cfg.Tracing.Endpoint)
+	}
+
+	// set up local buffer for the results while the database is unavailable
+	buf, err := worker.NewBuffer(cfg.BufferDir, cfg.BufferSize)
+	if err != nil {
+		fatal("create buffer", err)
+	}
+
+	// publish the completed syncs if enabled
+	var notifier worker.Notifier
+	if cfg.Notify.Target.Arn != "" && !cfg.DryRun {
+		notifier, err = notify.New(ctx, cfg.Notify.Endpoint, cfg.Notify.Target)
+		if err != nil {
+			fatal("set up notifier", err)
+		}
+	}
+
+	w := &worker.Worker{
+		GitHub:             client,
+		Providers:          providers,
+		Resolver:           resolver,
+		Advisories:         advisories,
+		Notifier:           notifier,
+		Store:              store,
+		Queue:              q,
+		Buffer:             buf,
+		Limiter:            worker.NewLimiter(cfg.Concurrency, cfg.SlowSave),
+		ConcurrencyCeiling: concurrencyCeiling(cfg),
+		RateLimits:         worker.NewRateLimits(providers),
+		Tenants:            tenants,
+		Breakers:           worker.NewBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		Verifiers:          cfg.Verifiers,
+		Capabilities:       cfg.Capabilities,
+		Query:              cfg.Query,
+		Severity:           cfg.Severity,
+		ForkPolicy:         cfg.ForkPolicy,
+		DryRun:             cfg.DryRun,
+		PullRequests:       cfg.PullRequests,
+		Shard:              cfg.Shard,
+		Skip:               cfg.Skip,
+		Budget:             cfg.Budget,
+		Cache:              rc,
+
+		VisibilityTimeout: cfg.VisibilityTimeout,
+		ReceiveWait:       cfg.ReceiveWait,
+		IdleBackoff:       cfg.IdleBackoff,
+		DedupeWindow:      cfg.DedupeWindow,
+	}
+
+	// serve liveness & readiness probes alongside the worker loop
+	health := &worker.Health{
+		DB:      dbconn,
+		Replica: replica,
+		Queue:   q,
+	}
+	go func() {
+		fatal("health server", health.ListenAndServe(cfg.HealthAddr))
+	}()
+
+	// serve the enqueue endpoint of the memory queue for the local
+	// development if enabled
+	if mq, ok := q.(*memory.Queue); ok && cfg.Queue.MemoryAddr != "" {
+		go func() {
+			fatal("enqueue server", http.ListenAndServe(cfg.Queue.MemoryAddr, mq))
+		}()
+	}
+
+	// serve the admin API to refresh & inspect the packages if enabled
+	if cfg.Admin.Token != "" {
+		sender, _ := q.(queue.Sender)
+		a := admin.New(cfg.Admin.Token, store, sender)
+		go func() {
+			fatal("admin server", http.ListenAndServe(cfg.Admin.Addr, a))
+		}()
+	}
+	// serve the control plane to drain the worker before the deploys if
+	// enabled
+	if cfg.Control.Token != "" {
+		lis, err := net.Listen("tcp", cfg.Control.Addr)
+		if err != nil {
+			fatal("control plane", err)
+		}
+		cp := controlplane.New(cfg.Control.Token, w)
+		go func() {
+			fatal("control plane", cp.Serve(lis))
+		}()
+	}
+	// forward the changes of the bugs written to the outbox if enabled
+	if cfg.Outbox.QueueUrl != "" && !cfg.DryRun {
+		oq, err := sqs.New(cfg.Queue.SQSEndpoint, cfg.Outbox.Region, cfg.Outbox.QueueUrl)
+		if err != nil {
+			fatal("set up outbox", err)
+		}
+		pub := &outbox.Publisher{Store: store, Sender: oq, Batch: 100,
+			Retention: cfg.Outbox.Retention}
+		go pub.Run(ctx, cfg.Outbox.Interval)
+	}
+	slog.Info("service started", "version", ReadBuildInfo().Version,
+		"shard", cfg.Shard.String())
+
+	err = w.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal("worker stopped", err)
+	}
+	slog.Info("service stopped")
+}
+
+// concurrencyCeiling returns the concurrency allowed by the database pool,
+// zero if unbounded, see worker.PoolReserve. It is at least 1, the config
+// rejects the pool too small for the reserve.
+func concurrencyCeiling(cfg Config) int {
+	if cfg.DatabaseDriver != "postgres" || cfg.Pool.MaxOpenConns <= 0 {
+		return 0
+	}
+	return max(cfg.Pool.MaxOpenConns-worker.PoolReserve, 1)
+}
+
+// fatal logs the error and exit the process.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
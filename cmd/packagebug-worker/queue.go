@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+	"github.com/pyk/packagebug-worker/internal/queue/memory"
+	"github.com/pyk/packagebug-worker/internal/queue/redis"
+	"github.com/pyk/packagebug-worker/internal/queue/sqs"
+)
+
+// QueueConfig contains the settings of all queue drivers.
+type QueueConfig struct {
+	Driver string
+	// SQSEndpoint is the optional endpoint of the service, e.g. LocalStack
+	// or ElasticMQ
+	SQSEndpoint string
+	SQSRegion   string
+	// SQSQueue is the url or the name of the queue, the name is resolved to
+	// its url
+	SQSQueue string
+	// SQSQueues are the queues consumed in the order of priority instead of
+	// SQSQueue if set
+	SQSQueues []SQSQueue
+	RedisUrl  string
+	RedisKey  string
+	// MemoryFile is the JSONL file of the messages loaded into the memory
+	// queue and MemoryAddr the address of its enqueue endpoint, both
+	// optional
+	MemoryFile string
+	MemoryAddr string
+	// VisibilityTimeout of the received messages if the driver doesn't
+	// store it in the queue itself
+	VisibilityTimeout time.Duration
+}
+
+// SQSQueue is a named SQS queue of the multi-queue consumption.
+type SQSQueue struct {
+	Name string
+	// Url is the url or the name of the queue
+	Url string
+}
+
+// ParseSQSQueues parses comma separated name:url queues ordered by priority,
+// e.g. high:https://sqs.local/fast,low:https://sqs.local/bulk. The url may
+// be the name of the queue, e.g. high:fast.
+func ParseSQSQueues(s string) ([]SQSQueue, error) {
+	var queues []SQSQueue
+	for _, token := range packagebug.ParseTokens(s) {
+		name, urls, ok := strings.Cut(token, ":")
+		if !ok || name == "" || urls == "" {
+			return nil, fmt.Errorf("expected name:url got %q", token)
+		}
+		err := checkQueue(urls)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queues {
+			if q.Name == name {
+				return nil, fmt.Errorf("duplicate queue %q", name)
+			}
+		}
+		queues = append(queues, SQSQueue{Name: name, Url: urls})
+	}
+	return queues, nil
+}
+
+// ParseSQSEndpoint validates the endpoint of the service and the url of the
+// queue and returns them. The endpoint with the path of a queue and no
+// queue set is the queue url of the configs before PACKAGEBUG_SQS_QUEUE_URL,
+// it is split into the endpoint and the queue url.
+func ParseSQSEndpoint(endpoint, queueUrl string) (string, string, error) {
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", "", err
+		}
+		if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return "", "", fmt.Errorf("expected http(s) url got %q", endpoint)
+		}
+		if strings.Trim(u.Path, "/") != "" {
+			if queueUrl != "" {
+				return "", "", fmt.Errorf("expected the endpoint without path got %q", endpoint)
+			}
+			queueUrl = endpoint
+			endpoint = u.Scheme + "://" + u.Host
+		}
+	}
+	if queueUrl == "" {
+		return "", "", fmt.Errorf("required")
+	}
+	return endpoint, queueUrl, checkQueue(queueUrl)
+}
+
+// checkQueue returns error if queue is neither the url of a queue, e.g.
+// https://sqs.us-east-1.amazonaws.com/1/packagebug, nor the name of a queue.
+func checkQueue(queue string) error {
+	if !sqs.IsQueueUrl(queue) {
+		name := strings.TrimSuffix(queue, ".fifo")
+		valid := name != ""
+		for _, r := range name {
+			valid = valid && (r == '-' || r == '_' || r >= '0' && r <= '9' ||
+				r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		}
+		if !valid {
+			return fmt.Errorf("invalid queue name %q", queue)
+		}
+		return nil
+	}
+	u, err := url.Parse(queue)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("expected queue url got %q", queue)
+	}
+	return nil
+}
+
+// NewQueue creates the queue of the configured driver: sqs, redis or memory.
+func NewQueue(c QueueConfig) (queue.Queue, error) {
+	switch c.Driver {
+	case "", "sqs":
+		if len(c.SQSQueues) == 0 {
+			return sqs.New(c.SQSEndpoint, c.SQSRegion, c.SQSQueue)
+		}
+		m := &queue.Multi{}
+		for _, sq := range c.SQSQueues {
+			q, err := sqs.New(c.SQSEndpoint, c.SQSRegion, sq.Url)
+			if err != nil {
+				return nil, err
+			}
+			m.Lanes = append(m.Lanes, queue.Lane{Name: sq.Name, Queue: q})
+		}
+		return m, nil
+	case "redis":
+		q, err := redis.New(c.RedisUrl, c.RedisKey)
+		if err != nil {
+			return nil, err
+		}
+		if c.VisibilityTimeout > 0 {
+			q.VisibilityTimeout = c.VisibilityTimeout
+		}
+		return q, nil
+	case "memory":
+		q := memory.New()
+		if c.VisibilityTimeout > 0 {
+			q.VisibilityTimeout = c.VisibilityTimeout
+		}
+		if c.MemoryFile != "" {
+			_, err := q.Load(c.MemoryFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return q, nil
+	}
+	return nil, fmt.Errorf("unknown queue driver %q", c.Driver)
+}
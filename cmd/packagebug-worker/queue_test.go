@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/queue/memory"
+	"github.com/pyk/packagebug-worker/internal/queue/redis"
+)
+
+func TestNewQueue(t *testing.T) {
+	_, err := NewQueue(QueueConfig{Driver: "kafka"})
+	if err == nil {
+		t.Error("expected error for unknown driver")
+	}
+
+	q, err := NewQueue(QueueConfig{Driver: "redis", RedisUrl: "redis://localhost:6379/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rq, ok := q.(*redis.Queue)
+	if !ok {
+		t.Fatalf("expected *redis.Queue got: %T\n", q)
+	}
+	if rq.Key != "packagebug" {
+		t.Errorf("expected default key got: %s\n", rq.Key)
+	}
+
+	q, err = NewQueue(QueueConfig{Driver: "memory", MemoryFile: "missing.jsonl"})
+	if err == nil {
+		t.Errorf("expected error of missing file got: %T\n", q)
+	}
+	q, err = NewQueue(QueueConfig{Driver: "memory"})
+	if _, ok := q.(*memory.Queue); !ok || err != nil {
+		t.Errorf("expected *memory.Queue got: %T %v\n", q, err)
+	}
+}
+
+func TestParseSQSQueues(t *testing.T) {
+	queues, err := ParseSQSQueues("high:https://sqs.local/fast, low:https://sqs.local/bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 2 || queues[0].Name != "high" || queues[1].Url != "https://sqs.local/bulk" {
+		t.Errorf("unexpected queues: %+v\n", queues)
+	}
+	queues, err = ParseSQSQueues("high:fast")
+	if err != nil || queues[0].Url != "fast" {
+		t.Errorf("expected the queue name got: %+v %v\n", queues, err)
+	}
+	for _, s := range []string{"https://sqs.local/fast", "high:", "a:http://x/1/a,a:http://y/1/a", "high:https://sqs.local"} {
+		_, err = ParseSQSQueues(s)
+		if err == nil {
+			t.Errorf("expected error of %q\n", s)
+		}
+	}
+}
+
+func TestParseSQSEndpoint(t *testing.T) {
+	endpoint, queueUrl, err := ParseSQSEndpoint("http://localhost:4566", "http://localhost:4566/000000000000/packagebug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != "http://localhost:4566" || queueUrl != "http://localhost:4566/000000000000/packagebug" {
+		t.Errorf("unexpected endpoint %s and queue %s\n", endpoint, queueUrl)
+	}
+	endpoint, queueUrl, err = ParseSQSEndpoint("", "packagebug")
+	if err != nil || endpoint != "" || queueUrl != "packagebug" {
+		t.Errorf("expected the queue name got: %s %s %v\n", endpoint, queueUrl, err)
+	}
+
+	// the endpoint that is the queue url is split
+	endpoint, queueUrl, err = ParseSQSEndpoint("https://sqs.us-east-1.amazonaws.com/1/packagebug", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != "https://sqs.us-east-1.amazonaws.com" || queueUrl != "https://sqs.us-east-1.amazonaws.com/1/packagebug" {
+		t.Errorf("unexpected endpoint %s and queue %s\n", endpoint, queueUrl)
+	}
+
+	for _, c := range [][2]string{
+		{"", ""},
+		{"localhost:4566", "packagebug"},
+		{"http://localhost:4566/1/a", "http://localhost:4566/1/b"},
+		{"", "https://sqs.us-east-1.amazonaws.com"},
+	} {
+		_, _, err = ParseSQSEndpoint(c[0], c[1])
+		if err == nil {
+			t.Errorf("expected error of %q\n", c)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/producer"
+)
+
+// PackageLister lists the stored packages in batches.
+type PackageLister interface {
+	ListPackages(after string, limit int) ([]packagebug.Package, error)
+}
+
+// Rescan runs the rescan subcommand: it enqueues every stored package in
+// batches ordered by id, e.g.
+//
+//	packagebug-worker rescan -rate 5 -action rescan_etag_reset
+//
+// The messages are paced by -rate per second so the rescan of the whole
+// corpus doesn't exhaust the API quota or flood the queue. The rescan that
+// stopped is resumed by -after, the last id logged. It returns the number
+// of enqueued packages.
+func Rescan(ctx context.Context, store PackageLister, p *producer.Producer, args []string) (int, error) {
+	fs := flag.NewFlagSet("rescan", flag.ContinueOnError)
+	priority := fs.Int("priority", 0, "priority of the messages")
+	action := fs.String("action", "", "action of the messages: fetch or rescan_etag_reset")
+	batch := fs.Int("batch", 500, "number of packages read per batch")
+	rate := fs.Float64("rate", 10, "packages enqueued per second, 0 unpaced")
+	after := fs.String("after", "0", "resume after the package id")
+	jitter := fs.Duration("jitter", 0, "random delay of the messages, at most 15m")
+	err := fs.Parse(args)
+	if err != nil {
+		return 0, err
+	}
+	opts := producer.Options{Priority: *priority, Action: packagebug.Action(*action),
+		Jitter: *jitter}
+	if *batch < 1 {
+		*batch = 1
+	}
+
+	var pace <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+	n := 0
+	last := *after
+	for {
+		packages, err := store.ListPackages(last, *batch)
+		if err != nil {
+			return n, err
+		}
+		for _, pkg := range packages {
+			if pace != nil {
+				select {
+				case <-pace:
+				case <-ctx.Done():
+					return n, ctx.Err()
+				}
+			}
+			err = p.Enqueue(ctx, pkg, opts)
+			if err != nil {
+				slog.Error("rescan failed", "after", last, "error", err)
+				return n, err
+			}
+			last = pkg.Id
+			n++
+		}
+		if len(packages) < *batch {
+			return n, nil
+		}
+		slog.Info("rescan batch enqueued", "enqueued", n, "after", last)
+	}
+}
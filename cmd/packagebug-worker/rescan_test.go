@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/producer"
+)
+
+func TestRescan(t *testing.T) {
+	store := fake.NewStore()
+	for _, id := range []string{"3", "1", "2"} {
+		p := fake.Package
+		p.Id = id
+		p.Repo = "byten" + id
+		store.AddPackage(p)
+	}
+	q := fake.NewQueue()
+	ctx := context.Background()
+
+	n, err := Rescan(ctx, store, producer.New(q), []string{"-batch", "2", "-rate", "1000"})
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 packages enqueued got: %d %v\n", n, err)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 3 || msgs[0] != "1,github.com,pyk,byten1,0" || msgs[2] != "3,github.com,pyk,byten3,0" {
+		t.Errorf("unexpected messages: %v\n", msgs)
+	}
+
+	// the rescan is resumed after the last id
+	n, err = Rescan(ctx, store, producer.New(q), []string{"-after", "2", "-rate", "0"})
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 package enqueued got: %d %v\n", n, err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ShutdownTimeout bounds the shutdown of the servers once the process is
+// signaled.
+const ShutdownTimeout = 10 * time.Second
+
+// serve serves srv until ctx is done, then shuts it down: the requests in
+// flight are completed within ShutdownTimeout. It returns nil once shut
+// down.
+func serve(ctx context.Context, srv *http.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(sctx)
+}
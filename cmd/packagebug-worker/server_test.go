@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	srv := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serve(ctx, srv) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the server shut down got: %v\n", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server shut down once ctx is done")
+	}
+
+	// the server that can't listen fails right away
+	srv = &http.Server{Addr: "127.0.0.1:-1"}
+	err := serve(context.Background(), srv)
+	if err == nil {
+		t.Error("expected error of the invalid address")
+	}
+}
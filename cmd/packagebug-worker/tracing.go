@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig contains the settings of the OpenTelemetry tracing.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP endpoint, tracing is disabled if empty
+	Endpoint    string
+	ServiceName string
+}
+
+// setupTracing exports the spans to the OTLP endpoint and propagates the
+// trace context into the outgoing requests of httpc. The returned shutdown
+// flushes the pending spans.
+func setupTracing(ctx context.Context, cfg TracingConfig, httpc *http.Client) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	httpc.Transport = traceTransport(httpc.Transport, tp)
+	return tp.Shutdown, nil
+}
+
+// traceTransport records the requests of rt in the spans of tp with their
+// url, the clients never put the credentials in the url, e.g. the OAuth app
+// of github.Client is sent as basic auth.
+func traceTransport(rt http.RoundTripper, tp trace.TracerProvider) http.RoundTripper {
+	return otelhttp.NewTransport(rt, otelhttp.WithTracerProvider(tp))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceTransportCredentials(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	global := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(global)
+
+	f := &fake.GitHub{Issues: fake.Issues(3), PerPage: 2, Etag: `"v1"`}
+	var authorized bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		authorized = authorized || (ok && id == "id" && secret == "s3cret")
+		f.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := github.NewClient(nil, 1)
+	client.HTTP = &http.Client{Transport: traceTransport(http.DefaultTransport, tp)}
+	client.Root = ts.URL
+	client.ClientId = "id"
+	client.ClientSecret = "s3cret"
+
+	_, err := github.FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authorized {
+		t.Errorf("expected the basic auth of the OAuth app\n")
+	}
+
+	urls := 0
+	for _, span := range recorder.Ended() {
+		for _, a := range span.Attributes() {
+			if a.Key == "url.full" {
+				urls++
+			}
+			v := a.Value.Emit()
+			if strings.Contains(v, "client_secret") || strings.Contains(v, "s3cret") {
+				t.Errorf("expected no credentials in span %s got: %s=%s\n", span.Name(), a.Key, v)
+			}
+		}
+	}
+	if urls == 0 {
+		t.Errorf("expected the url of the requests in the spans\n")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// version is set by the release builds, e.g.
+//
+//	go build -ldflags "-X main.version=v1.4.0" ./cmd/packagebug-worker
+//
+// The other builds report the version of their module, (devel) if unknown.
+var version = ""
+
+// BuildInfo describes the build of the binary.
+type BuildInfo struct {
+	Version string `json:"version"`
+	// Revision & Time of the commit the binary is built from
+	Revision string `json:"revision,omitempty"`
+	Time     string `json:"time,omitempty"`
+	// Modified is true if the tree had uncommitted changes
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo returns the build of the running binary from version and
+// the build info embedded by the go command.
+func ReadBuildInfo() BuildInfo {
+	b := BuildInfo{Version: version}
+	info, ok := debug.ReadBuildInfo()
+	if b.Version == "" && ok {
+		b.Version = info.Main.Version
+	}
+	if b.Version == "" {
+		b.Version = "(devel)"
+	}
+	if !ok {
+		return b
+	}
+	b.GoVersion = info.GoVersion
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+// String returns the build as printed by -version, e.g.
+// packagebug-worker v1.4.0 (3db6591, 2026-10-16T08:00:00Z) go1.22.5.
+func (b BuildInfo) String() string {
+	s := "packagebug-worker " + b.Version
+	if b.Revision != "" {
+		revision := b.Revision
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+		if b.Modified {
+			revision += "-dirty"
+		}
+		s += fmt.Sprintf(" (%s, %s)", revision, b.Time)
+	}
+	if b.GoVersion != "" {
+		s += " " + b.GoVersion
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	b := BuildInfo{
+		Version:   "v1.4.0",
+		Revision:  "3db65918a2c4",
+		Time:      "2026-10-16T08:00:00Z",
+		Modified:  true,
+		GoVersion: "go1.22.5",
+	}
+	expected := "packagebug-worker v1.4.0 (3db6591-dirty, 2026-10-16T08:00:00Z) go1.22.5"
+	if s := b.String(); s != expected {
+		t.Errorf("expected: %s got: %s\n", expected, s)
+	}
+	b = BuildInfo{Version: "(devel)"}
+	if s := b.String(); s != "packagebug-worker (devel)" {
+		t.Errorf("expected devel version got: %s\n", s)
+	}
+	if b := ReadBuildInfo(); b.Version == "" {
+		t.Errorf("expected version of the test binary got: %+v\n", b)
+	}
+}
@@ -0,0 +1,121 @@
+package packagebug
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// The classes of the failures, check them with errors.Is.
+var (
+	// ErrRateLimited is returned when the host rejects the request because
+	// of its rate limit.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNotFound is returned when the repository or its issue tracker is
+	// gone.
+	ErrNotFound = errors.New("not found")
+	// ErrDB is returned when the database operation fails.
+	ErrDB = errors.New("database")
+	// ErrDecode is returned when the response of the host can't be decoded.
+	ErrDecode = errors.New("decode")
+	// ErrPanic is returned when the process of the package panicked, see
+	// PanicError.
+	ErrPanic = errors.New("panic")
+	// ErrPackageExists is returned when the package moves to the path of
+	// another package of its tenant, e.g. both names of the repository were
+	// registered.
+	ErrPackageExists = errors.New("package exists")
+)
+
+// Is reports the failure class of the status: 404 & 410 are ErrNotFound, 429
+// and 403 that asks to retry later (the secondary or exhausted rate limit of
+// GitHub) are ErrRateLimited.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests ||
+			(e.StatusCode == http.StatusForbidden && e.RetryAfter > 0)
+	}
+	return false
+}
+
+// MovedError is returned when the repository of the package moved to Owner
+// and Repo, i.e. it was renamed or transferred.
+type MovedError struct {
+	Owner string
+	Repo  string
+}
+
+func (e *MovedError) Error() string {
+	return "moved to " + e.Owner + "/" + e.Repo
+}
+
+// DBError wraps err of the database as ErrDB, nil stays nil.
+func DBError(err error) error {
+	if err == nil || errors.Is(err, ErrDB) {
+		return err
+	}
+	return &classError{class: ErrDB, err: err}
+}
+
+// DecodeError wraps err of the response decoding as ErrDecode, nil stays nil.
+func DecodeError(err error) error {
+	if err == nil || errors.Is(err, ErrDecode) {
+		return err
+	}
+	return &classError{class: ErrDecode, err: err}
+}
+
+// PanicError returns the recovered value of the panic as ErrPanic.
+func PanicError(v any) error {
+	return &classError{class: ErrPanic, err: fmt.Errorf("%v", v)}
+}
+
+// classError is err of the failure class.
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string {
+	return e.class.Error() + ": " + e.err.Error()
+}
+
+func (e *classError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// ErrorClass returns the failure class of err used as the metric label:
+// rate_limited, not_found, moved, db, decode, panic, upstream_5xx,
+// upstream_4xx, network or other. It returns empty string for nil.
+func ErrorClass(err error) string {
+	var serr *StatusError
+	var merr *MovedError
+	var nerr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.As(err, &merr):
+		return "moved"
+	case errors.Is(err, ErrDB):
+		return "db"
+	case errors.Is(err, ErrDecode):
+		return "decode"
+	case errors.Is(err, ErrPanic):
+		return "panic"
+	case errors.As(err, &serr) && serr.StatusCode >= 500:
+		return "upstream_5xx"
+	case errors.As(err, &serr):
+		return "upstream_4xx"
+	case errors.As(err, &nerr):
+		return "network"
+	}
+	return "other"
+}
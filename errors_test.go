@@ -0,0 +1,51 @@
+package packagebug
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	cases := []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, "rate_limited"},
+		{fmt.Errorf("fetch: %w", &StatusError{StatusCode: http.StatusNotFound}), "not_found"},
+		{&StatusError{StatusCode: http.StatusGone}, "not_found"},
+		{&StatusError{StatusCode: http.StatusBadGateway}, "upstream_5xx"},
+		{&StatusError{StatusCode: http.StatusUnauthorized}, "upstream_4xx"},
+		{&StatusError{StatusCode: http.StatusForbidden}, "upstream_4xx"},
+		{&StatusError{StatusCode: http.StatusForbidden, RetryAfter: time.Minute}, "rate_limited"},
+		{fmt.Errorf("save: %w", DBError(io.ErrUnexpectedEOF)), "db"},
+		{DecodeError(io.ErrUnexpectedEOF), "decode"},
+		{fmt.Errorf("fetch: %w", &MovedError{Owner: "pyk", Repo: "byten"}), "moved"},
+		{ErrRateLimited, "rate_limited"},
+		{fmt.Errorf("fetch: %w", PanicError("nil map")), "panic"},
+		{errors.New("host not supported"), "other"},
+	}
+	for _, c := range cases {
+		if class := ErrorClass(c.err); class != c.class {
+			t.Errorf("expected class %q of %v got: %q\n", c.class, c.err, class)
+		}
+	}
+}
+
+func TestDBErrorRetryable(t *testing.T) {
+	// the wrapped error is still classified by Retryable
+	err := DBError(io.ErrUnexpectedEOF)
+	if !Retryable(err) {
+		t.Errorf("expected retryable got: %v\n", err)
+	}
+	if DBError(nil) != nil || DecodeError(nil) != nil {
+		t.Error("expected nil error")
+	}
+	if err.Error() != "database: unexpected EOF" {
+		t.Errorf("unexpected message: %s\n", err)
+	}
+}
@@ -0,0 +1,43 @@
+package packagebug
+
+import (
+	"context"
+	"sync"
+)
+
+type fetchStatsKey struct{}
+
+// FetchStats counts the pages fetched by the providers for the fetch log.
+type FetchStats struct {
+	mu         sync.Mutex
+	pages      int
+	statusCode int
+}
+
+// WithFetchStats returns a copy of ctx that carries new stats, the pages of
+// the requests made with the returned context are recorded in the stats.
+func WithFetchStats(ctx context.Context) (context.Context, *FetchStats) {
+	s := new(FetchStats)
+	return context.WithValue(ctx, fetchStatsKey{}, s), s
+}
+
+// RecordPage records a page response of statusCode in the stats carried by
+// ctx, if any.
+func RecordPage(ctx context.Context, statusCode int) {
+	s, ok := ctx.Value(fetchStatsKey{}).(*FetchStats)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.pages++
+	s.statusCode = statusCode
+	s.mu.Unlock()
+}
+
+// Pages returns the number of fetched pages and the status code of the last
+// page, zero if no page was fetched.
+func (s *FetchStats) Pages() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pages, s.statusCode
+}
@@ -0,0 +1,19 @@
+package packagebug
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFetchStats(t *testing.T) {
+	// recording without stats is a no-op
+	RecordPage(context.Background(), 200)
+
+	ctx, stats := WithFetchStats(context.Background())
+	RecordPage(ctx, 200)
+	RecordPage(ctx, 304)
+	pages, status := stats.Pages()
+	if pages != 2 || status != 304 {
+		t.Errorf("expected 2 pages of last status 304 got: %d %d\n", pages, status)
+	}
+}
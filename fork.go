@@ -0,0 +1,41 @@
+package packagebug
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ForkPolicy decides how a package whose repository is a fork is fetched.
+type ForkPolicy string
+
+const (
+	// ForkSkip doesn't fetch the fork, the bugs usually live upstream.
+	ForkSkip ForkPolicy = "skip"
+	// ForkParent fetches the issues of the parent repository instead and
+	// stores them as the issues of the fork.
+	ForkParent ForkPolicy = "parent"
+	// ForkBoth fetches the fork as usual and its parent too if the parent is
+	// a package itself.
+	ForkBoth ForkPolicy = "both"
+)
+
+// ParseForkPolicy parses the fork policy, empty string is ForkBoth.
+func ParseForkPolicy(s string) (ForkPolicy, error) {
+	switch policy := ForkPolicy(strings.TrimSpace(s)); policy {
+	case "":
+		return ForkBoth, nil
+	case ForkSkip, ForkParent, ForkBoth:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown fork policy %q", s)
+}
+
+// Fork represents the fork relationship of the package.
+type Fork struct {
+	// Parent is the path of the parent repository, empty if not a fork
+	Parent string
+	// Policy is the policy of the package, empty uses the global policy
+	Policy    ForkPolicy
+	CheckedAt time.Time
+}
@@ -0,0 +1,50 @@
+package packagebug
+
+import (
+	"testing"
+)
+
+func TestParseForkPolicy(t *testing.T) {
+	policy, err := ParseForkPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy != ForkBoth {
+		t.Errorf("expected %q got: %q\n", ForkBoth, policy)
+	}
+	policy, err = ParseForkPolicy("parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy != ForkParent {
+		t.Errorf("expected %q got: %q\n", ForkParent, policy)
+	}
+	_, err = ParseForkPolicy("mirror")
+	if err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	p, err := ParsePath("github.com/pyk/byten")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Host != "github.com" || p.Owner != "pyk" || p.Repo != "byten" {
+		t.Errorf("unexpected package: %+v\n", p)
+	}
+	_, err = ParsePath("github.com/pyk")
+	if err == nil {
+		t.Error("expected error for invalid path")
+	}
+
+	// the rest of the path is the subpath
+	p, err = ParsePath("github.com/aws/aws-sdk-go/service/sqs")
+	if err != nil || p.Repo != "aws-sdk-go" || p.Subpath != "service/sqs" {
+		t.Errorf("unexpected subpackage: %+v %v\n", p, err)
+	}
+	if p.Path() != "github.com/aws/aws-sdk-go/service/sqs" ||
+		p.Repository().Path() != "github.com/aws/aws-sdk-go" {
+		t.Errorf("unexpected paths %q %q\n", p.Path(), p.Repository().Path())
+	}
+}
@@ -0,0 +1,32 @@
+package packagebug
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewHTTPClient returns the HTTP client shared by the API clients. The
+// connections are reused across the worker processes, HTTP/2 is used if the
+// server supports it and the requests go through the proxy of HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY environment variables. The timeout covers the
+// whole request including reading the body, zero means no timeout.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DialContext:       dialer.DialContext,
+		ForceAttemptHTTP2: true,
+		// the worker runs up to 10 processes against the same hosts
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
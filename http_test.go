@@ -0,0 +1,27 @@
+package packagebug
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	c := NewHTTPClient(time.Minute)
+	if c.Timeout != time.Minute {
+		t.Errorf("expected timeout 1m got: %s\n", c.Timeout)
+	}
+	transport, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport got: %T\n", c.Transport)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Errorf("expected HTTP/2 enabled\n")
+	}
+	if transport.MaxIdleConnsPerHost < 10 {
+		t.Errorf("expected at least 10 idle connections per host got: %d\n", transport.MaxIdleConnsPerHost)
+	}
+	if transport.Proxy == nil {
+		t.Errorf("expected proxy from environment\n")
+	}
+}
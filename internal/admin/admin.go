@@ -0,0 +1,122 @@
+// Package admin serves the operator API to inspect the packages and to
+// refresh them without hand-crafting the queue messages.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// RefreshPriority is the priority of the refresh messages, they are received
+// before the scheduled packages.
+const RefreshPriority = 10
+
+// Store reads the packages and their sync state.
+type Store interface {
+	// FindPackage returns the package of the path crawled for the tenant,
+	// false if not a package.
+	FindPackage(tenant, path string) (packagebug.Package, bool, error)
+	// GetStatus returns the sync state of the package.
+	GetStatus(p packagebug.Package) (packagebug.Status, error)
+}
+
+// Handler serves the admin API authenticated with the bearer Token:
+//
+//	POST /packages/{host}/{owner}/{repo}/refresh enqueues the package
+//	GET /packages/{host}/{owner}/{repo} returns the package status
+//
+// The package of a tenant is selected with the tenant query parameter.
+type Handler struct {
+	Token  string
+	Store  Store
+	Sender queue.Sender
+
+	mux *http.ServeMux
+}
+
+// New creates the handler of the admin API.
+func New(token string, store Store, sender queue.Sender) *Handler {
+	h := &Handler{Token: token, Store: store, Sender: sender}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("POST /packages/{host}/{owner}/{repo}/refresh", h.refresh)
+	h.mux.HandleFunc("GET /packages/{host}/{owner}/{repo}", h.status)
+	return h
+}
+
+// authorized returns true if the request has the bearer token. Empty token
+// never authorizes.
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// find writes the error response and returns false if the package of the
+// request path is not found.
+func (h *Handler) find(w http.ResponseWriter, r *http.Request) (packagebug.Package, bool) {
+	path := r.PathValue("host") + "/" + r.PathValue("owner") + "/" + r.PathValue("repo")
+	p, ok, err := h.Store.FindPackage(r.URL.Query().Get("tenant"), path)
+	if err != nil {
+		slog.Error("admin: find package failed", "package_path", path, "error", err)
+		http.Error(w, "store failed", http.StatusInternalServerError)
+		return p, false
+	}
+	if !ok {
+		http.Error(w, "package not found", http.StatusNotFound)
+		return p, false
+	}
+	return p, true
+}
+
+// refresh enqueues the package with RefreshPriority.
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) {
+	if h.Sender == nil {
+		http.Error(w, "queue can't send messages", http.StatusNotImplemented)
+		return
+	}
+	p, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+	err := h.Sender.Send(r.Context(), packagebug.FormatMessage(p, RefreshPriority),
+		RefreshPriority)
+	if err != nil {
+		slog.Error("admin: enqueue failed", "package_path", p.Path(), "error", err)
+		http.Error(w, "enqueue failed", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("admin: package enqueued", "package_path", p.Path())
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// status writes the sync state of the package as JSON.
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+	st, err := h.Store.GetStatus(p)
+	if err != nil {
+		slog.Error("admin: get status failed", "package_path", p.Path(), "error", err)
+		http.Error(w, "store failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func request(method, path, token string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestHandlerUnauthorized(t *testing.T) {
+	h := New("secret", fake.NewStore(), fake.NewQueue())
+	for _, token := range []string{"", "other"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request("GET", "/packages/github.com/pyk/byten", token))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 of token %q got: %d\n", token, w.Code)
+		}
+	}
+}
+
+func TestHandlerRefresh(t *testing.T) {
+	store := fake.NewStore()
+	p := fake.Package
+	p.Id = "1"
+	store.AddPackage(p)
+	q := fake.NewQueue()
+	h := New("secret", store, q)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("POST", "/packages/github.com/pyk/byten/refresh", "secret"))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202 got: %d %s\n", w.Code, w.Body)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 1 || msgs[0] != "1,github.com,pyk,byten,10" {
+		t.Errorf("expected refresh message got: %v\n", msgs)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request("POST", "/packages/github.com/pyk/other/refresh", "secret"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 of unknown package got: %d\n", w.Code)
+	}
+}
+
+func TestHandlerStatus(t *testing.T) {
+	store := fake.NewStore()
+	p := fake.Package
+	p.Id = "1"
+	store.AddPackage(p)
+	started := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.Save(&packagebug.Result{Package: p, Etag: "etag", Issues: []packagebug.Issue{
+		{Number: 1, State: "open"},
+		{Number: 2, State: "closed"},
+	}})
+	store.SaveFetchLog(packagebug.FetchLog{PackageId: "1", StartedAt: started, Error: "timeout"})
+	h := New("secret", store, nil)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("GET", "/packages/github.com/pyk/byten", "secret"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got: %d %s\n", w.Code, w.Body)
+	}
+	var st packagebug.Status
+	err := json.NewDecoder(w.Body).Decode(&st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Etag != "etag" || st.OpenBugs != 1 || st.ClosedBugs != 1 {
+		t.Errorf("unexpected status: %+v\n", st)
+	}
+	if !st.LastFetchAt.Equal(started) || st.LastError != "timeout" {
+		t.Errorf("expected last fetch got: %+v\n", st)
+	}
+
+	// refresh needs a queue that can send
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request("POST", "/packages/github.com/pyk/byten/refresh", "secret"))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected status 501 without sender got: %d\n", w.Code)
+	}
+}
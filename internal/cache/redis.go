@@ -0,0 +1,62 @@
+// Package cache stores the cached responses of the hosts outside of the
+// database, see packagebug.ResponseCache.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/pyk/packagebug-worker"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Redis stores the cached responses as JSON under Prefix and the url. The
+// responses expire after packagebug.CacheTTL.
+type Redis struct {
+	Redis  *goredis.Client
+	Prefix string
+}
+
+// NewRedis creates the response cache of the Redis url.
+func NewRedis(rawurl string) (*Redis, error) {
+	opt, err := goredis.ParseURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{Redis: goredis.NewClient(opt), Prefix: "packagebug:cache:"}, nil
+}
+
+// GetResponse implements packagebug.ResponseCache.
+func (c *Redis) GetResponse(ctx context.Context, url string) (packagebug.CachedResponse, bool, error) {
+	var r packagebug.CachedResponse
+	b, err := c.Redis.Get(ctx, c.Prefix+url).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, err
+	}
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return r, false, err
+	}
+	return r, true, nil
+}
+
+// SaveResponses implements packagebug.ResponseCache.
+func (c *Redis) SaveResponses(ctx context.Context, responses []packagebug.CachedResponse) error {
+	if len(responses) == 0 {
+		return nil
+	}
+	pipe := c.Redis.Pipeline()
+	for _, r := range responses {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, c.Prefix+r.Url, b, packagebug.CacheTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
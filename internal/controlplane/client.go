@@ -0,0 +1,62 @@
+package controlplane
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// Client calls the control plane of a worker.
+type Client struct {
+	Token string
+
+	conn *grpc.ClientConn
+}
+
+// Dial creates the client of the control plane at addr, e.g. the address of
+// the worker in the private network of the fleet.
+func Dial(addr, token string) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(Codec{}.Name())))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Token: token, conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// invoke calls the method of the service with the bearer token.
+func (c *Client) invoke(ctx context.Context, method string, req any) (worker.State, error) {
+	var st worker.State
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.Token)
+	err := c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, req, &st)
+	return st, err
+}
+
+// Pause stops the worker receiving the messages.
+func (c *Client) Pause(ctx context.Context) (worker.State, error) {
+	return c.invoke(ctx, "Pause", &Empty{})
+}
+
+// Resume receives the messages again.
+func (c *Client) Resume(ctx context.Context) (worker.State, error) {
+	return c.invoke(ctx, "Resume", &Empty{})
+}
+
+// SetConcurrency changes the maximum of the concurrent processes.
+func (c *Client) SetConcurrency(ctx context.Context, max int) (worker.State, error) {
+	return c.invoke(ctx, "SetConcurrency", &ConcurrencyRequest{Max: max})
+}
+
+// Status returns the state of the worker.
+func (c *Client) Status(ctx context.Context) (worker.State, error) {
+	return c.invoke(ctx, "Status", &Empty{})
+}
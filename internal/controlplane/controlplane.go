@@ -0,0 +1,148 @@
+// Package controlplane serves the gRPC control plane of a worker, so the
+// operators of a fleet can drain a node before a deploy without killing its
+// syncs in flight: pause and resume the consumption, change the concurrency
+// at runtime and report the packages in flight and the rate limits.
+//
+// The messages are JSON coded, see Codec, so the service needs no generated
+// code. The clients call it with the json content subtype and the bearer
+// token in the authorization metadata, see Client.
+package controlplane
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+
+	"github.com/pyk/packagebug-worker/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the full name of the gRPC service.
+const ServiceName = "packagebug.controlplane.v1.Control"
+
+// Worker is the worker controlled by the service, see worker.Worker.
+type Worker interface {
+	Pause()
+	Resume()
+	SetConcurrency(max int) error
+	State() worker.State
+}
+
+// Empty is the message of the requests and responses without fields.
+type Empty struct{}
+
+// ConcurrencyRequest is the request of SetConcurrency.
+type ConcurrencyRequest struct {
+	Max int `json:"max"`
+}
+
+// Codec is the JSON codec of the messages, registered as the json content
+// subtype.
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (Codec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (Codec) Name() string                       { return "json" }
+
+func init() {
+	encoding.RegisterCodec(Codec{})
+}
+
+// Server is the control plane of the worker.
+type Server struct {
+	Worker Worker
+}
+
+// Pause stops the worker receiving the messages, see worker.Worker.Pause.
+func (s *Server) Pause(ctx context.Context, req *Empty) (*worker.State, error) {
+	s.Worker.Pause()
+	st := s.Worker.State()
+	return &st, nil
+}
+
+// Resume receives the messages again.
+func (s *Server) Resume(ctx context.Context, req *Empty) (*worker.State, error) {
+	s.Worker.Resume()
+	st := s.Worker.State()
+	return &st, nil
+}
+
+// SetConcurrency changes the maximum of the concurrent processes.
+func (s *Server) SetConcurrency(ctx context.Context, req *ConcurrencyRequest) (*worker.State, error) {
+	err := s.Worker.SetConcurrency(req.Max)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	st := s.Worker.State()
+	return &st, nil
+}
+
+// Status returns the state of the worker: the packages in flight, the
+// concurrency and the rate limits of the hosts.
+func (s *Server) Status(ctx context.Context, req *Empty) (*worker.State, error) {
+	st := s.Worker.State()
+	return &st, nil
+}
+
+// unary returns the handler of the method of the server that decodes its
+// request of type Req.
+func unary[Req any](name string, call func(*Server, context.Context, *Req) (*worker.State, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			err := dec(req)
+			if err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				return call(srv.(*Server), ctx, req.(*Req))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// ServiceDesc describes the service for grpc.Server.RegisterService.
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Pause", (*Server).Pause),
+		unary("Resume", (*Server).Resume),
+		unary("SetConcurrency", (*Server).SetConcurrency),
+		unary("Status", (*Server).Status),
+	},
+}
+
+// Authorize returns the interceptor that rejects the calls without the
+// bearer token in the authorization metadata. Empty token never authorizes.
+func Authorize(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get("authorization") {
+			t, ok := strings.CutPrefix(v, "Bearer ")
+			if ok && token != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return handler(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+}
+
+// New creates the gRPC server of the control plane of w authenticated with
+// the bearer token.
+func New(token string, w Worker) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(Authorize(token)))
+	s.RegisterService(&ServiceDesc, &Server{Worker: w})
+	return s
+}
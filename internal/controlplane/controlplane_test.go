@@ -0,0 +1,97 @@
+package controlplane
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeWorker records the calls of the control plane.
+type fakeWorker struct {
+	state worker.State
+}
+
+func (w *fakeWorker) Pause()  { w.state.Paused = true }
+func (w *fakeWorker) Resume() { w.state.Paused = false }
+
+func (w *fakeWorker) SetConcurrency(max int) error {
+	if max < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	w.state.MaxConcurrency = max
+	return nil
+}
+
+func (w *fakeWorker) State() worker.State { return w.state }
+
+// call calls the method of the service with the JSON request and the
+// authorization metadata as the grpc server does.
+func call(t *testing.T, w Worker, method, req, auth string) (*worker.State, error) {
+	t.Helper()
+	var desc *grpc.MethodDesc
+	for i := range ServiceDesc.Methods {
+		if ServiceDesc.Methods[i].MethodName == method {
+			desc = &ServiceDesc.Methods[i]
+		}
+	}
+	if desc == nil {
+		t.Fatalf("expected method %s\n", method)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("authorization", auth))
+	dec := func(v any) error { return Codec{}.Unmarshal([]byte(req), v) }
+	resp, err := desc.Handler(&Server{Worker: w}, ctx, dec, Authorize("secret"))
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*worker.State), nil
+}
+
+func TestServer(t *testing.T) {
+	w := &fakeWorker{state: worker.State{InFlight: []string{"github.com/pyk/byten"}}}
+
+	st, err := call(t, w, "Pause", `{}`, "Bearer secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Paused || len(st.InFlight) != 1 {
+		t.Errorf("expected paused worker with 1 package in flight got: %+v\n", st)
+	}
+	st, err = call(t, w, "SetConcurrency", `{"max":3}`, "Bearer secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.MaxConcurrency != 3 {
+		t.Errorf("expected concurrency 3 got: %d\n", st.MaxConcurrency)
+	}
+	_, err = call(t, w, "SetConcurrency", `{"max":0}`, "Bearer secret")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected invalid argument got: %v\n", err)
+	}
+	st, err = call(t, w, "Resume", `{}`, "Bearer secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Paused {
+		t.Errorf("expected resumed worker got: %+v\n", st)
+	}
+
+	// the calls without the token are rejected
+	_, err = call(t, w, "Pause", `{}`, "Bearer wrong")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated got: %v\n", err)
+	}
+	if w.state.Paused {
+		t.Errorf("expected the unauthorized pause ignored\n")
+	}
+	_, err = call(t, w, "Status", `{}`, "")
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated got: %v\n", err)
+	}
+}
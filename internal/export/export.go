@@ -0,0 +1,258 @@
+// Package export writes the issues and the fetch history as parquet, JSON
+// lines or CSV files to S3.
+package export
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// IssueRow is the schema of exported issue, the json names are the columns
+// of the JSON lines and CSV files.
+type IssueRow struct {
+	GithubId  int64  `parquet:"name=github_id, type=INT64" json:"github_id"`
+	PackageId string `parquet:"name=package_id, type=BYTE_ARRAY, convertedtype=UTF8" json:"package_id"`
+	Number    int32  `parquet:"name=number, type=INT32" json:"number"`
+	Title     string `parquet:"name=title, type=BYTE_ARRAY, convertedtype=UTF8" json:"title"`
+	Url       string `parquet:"name=url, type=BYTE_ARRAY, convertedtype=UTF8" json:"url"`
+}
+
+// FetchLogRow is the schema of exported fetch history.
+type FetchLogRow struct {
+	PackageId      string `parquet:"name=package_id, type=BYTE_ARRAY, convertedtype=UTF8" json:"package_id"`
+	StartedAt      int64  `parquet:"name=started_at, type=INT64, convertedtype=TIMESTAMP_MILLIS" json:"started_at"`
+	DurationMs     int64  `parquet:"name=duration_ms, type=INT64" json:"duration_ms"`
+	IssuesUpserted int32  `parquet:"name=issues_upserted, type=INT32" json:"issues_upserted"`
+	Error          string `parquet:"name=error, type=BYTE_ARRAY, convertedtype=UTF8" json:"error"`
+	StatusCode     int32  `parquet:"name=status_code, type=INT32" json:"status_code"`
+	Pages          int32  `parquet:"name=pages, type=INT32" json:"pages"`
+}
+
+// Exporter writes the issues and the fetch history as files of Format to
+// S3, partitioned by table and export date, e.g.
+// prefix/issues/dt=2016-01-02/part-1451692800.parquet.
+type Exporter struct {
+	DB     *sql.DB
+	S3     *s3.Client
+	Bucket string
+	Prefix string
+	// Format is parquet, jsonl or csv, parquet if empty
+	Format string
+	// PerPackage writes the issues of every package to its own file under
+	// the partition, see PackageKey. The fetch history is a single file.
+	PerPackage bool
+
+	// fetch history is exported incrementally since the last export
+	last time.Time
+}
+
+// New creates an exporter that uploads to the bucket in region using the
+// AWS credentials of the default chain, e.g. the environment or the IAM role
+// of the instance or the service account.
+func New(dbconn *sql.DB, region, bucket, prefix string) (*Exporter, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		DB:     dbconn,
+		S3:     s3.NewFromConfig(cfg),
+		Bucket: bucket,
+		Prefix: prefix,
+	}, nil
+}
+
+// Key returns the S3 key of the table partition exported at t.
+func (e *Exporter) Key(table string, t time.Time) string {
+	t = t.UTC()
+	return path.Join(e.Prefix, table, "dt="+t.Format("2006-01-02"),
+		fmt.Sprintf("part-%d.%s", t.Unix(), e.format()))
+}
+
+// PackageKey returns the S3 key of the issues of the package exported at
+// t, e.g. prefix/issues/dt=2016-01-02/package_id=1/part-1451692800.jsonl.
+func (e *Exporter) PackageKey(id string, t time.Time) string {
+	t = t.UTC()
+	return path.Join(e.Prefix, "issues", "dt="+t.Format("2006-01-02"),
+		"package_id="+id, fmt.Sprintf("part-%d.%s", t.Unix(), e.format()))
+}
+
+func (e *Exporter) format() string {
+	if e.Format == "" {
+		return FormatParquet
+	}
+	return e.Format
+}
+
+// Export writes a snapshot of issues and the fetch history since the last
+// export.
+func (e *Exporter) Export(ctx context.Context, now time.Time) error {
+	err := e.exportIssues(ctx, now)
+	if err != nil {
+		return fmt.Errorf("issues: %s", err)
+	}
+	err = e.exportFetchLog(ctx, now)
+	if err != nil {
+		return fmt.Errorf("fetch_log: %s", err)
+	}
+	e.last = now
+	return nil
+}
+
+func (e *Exporter) exportIssues(ctx context.Context, now time.Time) error {
+	query := `
+	SELECT issue_github_id, package_id, issue_number, issue_title, issue_url
+	FROM issues
+	WHERE issue_removed_at IS NULL
+	ORDER BY package_id`
+	rows, err := e.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	rw, err := newRowWriter(e.format(), &buf, new(IssueRow))
+	if err != nil {
+		return err
+	}
+	var pkg string
+	n := 0
+	for rows.Next() {
+		var r IssueRow
+		err = rows.Scan(&r.GithubId, &r.PackageId, &r.Number, &r.Title, &r.Url)
+		if err != nil {
+			return err
+		}
+		// the issues are ordered by package, the file of the previous
+		// package is complete
+		if e.PerPackage && n > 0 && r.PackageId != pkg {
+			err = e.finish(ctx, rw, &buf, e.PackageKey(pkg, now))
+			if err != nil {
+				return err
+			}
+			rw, err = newRowWriter(e.format(), &buf, new(IssueRow))
+			if err != nil {
+				return err
+			}
+		}
+		pkg = r.PackageId
+		n++
+		err = rw.Write(r)
+		if err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if !e.PerPackage {
+		return e.finish(ctx, rw, &buf, e.Key("issues", now))
+	}
+	if n == 0 {
+		return nil
+	}
+	return e.finish(ctx, rw, &buf, e.PackageKey(pkg, now))
+}
+
+func (e *Exporter) exportFetchLog(ctx context.Context, now time.Time) error {
+	query := `
+	SELECT package_id, started_at, duration_ms, issues_upserted,
+		coalesce(error, ''), coalesce(status_code, 0), coalesce(pages, 0)
+	FROM fetch_log
+	WHERE started_at >= $1 AND started_at < $2`
+	rows, err := e.DB.QueryContext(ctx, query, e.last, now)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	rw, err := newRowWriter(e.format(), &buf, new(FetchLogRow))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r FetchLogRow
+		var startedAt time.Time
+		err = rows.Scan(&r.PackageId, &startedAt, &r.DurationMs,
+			&r.IssuesUpserted, &r.Error, &r.StatusCode, &r.Pages)
+		if err != nil {
+			return err
+		}
+		r.StartedAt = startedAt.UnixNano() / int64(time.Millisecond)
+		err = rw.Write(r)
+		if err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return e.finish(ctx, rw, &buf, e.Key("fetch_log", now))
+}
+
+// finish flushes the rows of rw to buf, uploads the file to key and resets
+// buf for the next file.
+func (e *Exporter) finish(ctx context.Context, rw rowWriter, buf *bytes.Buffer, key string) error {
+	err := rw.Close()
+	if err != nil {
+		return err
+	}
+	err = e.upload(ctx, key, buf)
+	buf.Reset()
+	return err
+}
+
+func (e *Exporter) upload(ctx context.Context, key string, buf *bytes.Buffer) error {
+	_, err := e.S3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(e.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String(contentType(e.format())),
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("export: uploaded", "key", key, "size", buf.Len())
+	return nil
+}
+
+// Run exports right away and then every interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	e.last = time.Now().Add(-interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := e.Export(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			slog.Error("export failed", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func newParquetWriter(buf *bytes.Buffer, schema interface{}) (*writer.ParquetWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(buf, schema, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	return pw, nil
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestExporterKey(t *testing.T) {
+	e := &Exporter{Prefix: "packagebug"}
+	now := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	expected := "packagebug/issues/dt=2016-01-02/part-1451692800.parquet"
+	key := e.Key("issues", now)
+	if key != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, key)
+	}
+}
+
+func TestExporterPackageKey(t *testing.T) {
+	e := &Exporter{Prefix: "packagebug", Format: FormatJSONL}
+	now := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	expected := "packagebug/issues/dt=2016-01-02/package_id=1/part-1451692800.jsonl"
+	key := e.PackageKey("1", now)
+	if key != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, key)
+	}
+}
+
+func TestRowWriter(t *testing.T) {
+	row := IssueRow{GithubId: 7, PackageId: "1", Number: 2, Title: "crash, again", Url: "https://github.com/pyk/byten/issues/2"}
+	expected := map[string]string{
+		FormatJSONL: `{"github_id":7,"package_id":"1","number":2,"title":"crash, again","url":"https://github.com/pyk/byten/issues/2"}` + "\n",
+		FormatCSV:   "github_id,package_id,number,title,url\n7,1,2,\"crash, again\",https://github.com/pyk/byten/issues/2\n",
+	}
+	for format, want := range expected {
+		var buf bytes.Buffer
+		rw, err := newRowWriter(format, &buf, new(IssueRow))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rw.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("expected %s: %q got: %q\n", format, want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// The formats of the exported files.
+const (
+	FormatParquet = "parquet"
+	// FormatJSONL writes a JSON object per row.
+	FormatJSONL = "jsonl"
+	// FormatCSV writes a header of the column names and a line per row.
+	FormatCSV = "csv"
+)
+
+// ParseFormat parses the export format, empty string is FormatParquet.
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(s)); format {
+	case "":
+		return FormatParquet, nil
+	case FormatParquet, FormatJSONL, FormatCSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown export format %q", s)
+}
+
+// contentType returns the content type of the files of the format.
+func contentType(format string) string {
+	switch format {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv"
+	}
+	return "application/octet-stream"
+}
+
+// rowWriter writes the rows of a table to the buffer in the export format.
+type rowWriter interface {
+	Write(row interface{}) error
+	// Close flushes the written rows to the buffer.
+	Close() error
+}
+
+// newRowWriter creates the row writer of the format for the rows of schema,
+// a pointer to the row struct.
+func newRowWriter(format string, buf *bytes.Buffer, schema interface{}) (rowWriter, error) {
+	switch format {
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(buf)}, nil
+	case FormatCSV:
+		w := &csvWriter{w: csv.NewWriter(buf)}
+		return w, w.w.Write(columns(reflect.TypeOf(schema).Elem()))
+	}
+	pw, err := newParquetWriter(buf, schema)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetWriter{pw}, nil
+}
+
+type parquetWriter struct {
+	*writer.ParquetWriter
+}
+
+func (w *parquetWriter) Close() error {
+	return w.WriteStop()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(row interface{}) error {
+	return w.enc.Encode(row)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) Write(row interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	record := make([]string, v.NumField())
+	for i := range record {
+		record[i] = fmt.Sprint(v.Field(i).Interface())
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// columns returns the column names of the row struct, its json names.
+func columns(t reflect.Type) []string {
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return names
+}
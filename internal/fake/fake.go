@@ -0,0 +1,680 @@
+// Package fake provides in-memory implementations of the worker dependencies
+// for the tests.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Package is the package served by GitHub.
+var Package = packagebug.Package{
+	Host:  "github.com",
+	Owner: "pyk",
+	Repo:  "byten",
+}
+
+// Store is the in-memory store. Err is returned by every method if set.
+type Store struct {
+	Err error
+
+	mu       sync.Mutex
+	etags    map[string]string
+	since    map[string]time.Time
+	saved    []*packagebug.Result
+	logs     []packagebug.FetchLog
+	forks    map[string]packagebug.Fork
+	settings map[string]packagebug.Settings
+	rates    map[string]packagebug.RateState
+	packages map[string]packagebug.Package
+	locked   map[string]bool
+	writes   int
+	repos    map[string]packagebug.Repo
+	aliases  map[string]string
+	roots    map[string]string
+
+	advisories map[string][]packagebug.Advisory
+	releases   map[string]packagebug.Releases
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		etags:    make(map[string]string),
+		since:    make(map[string]time.Time),
+		forks:    make(map[string]packagebug.Fork),
+		settings: make(map[string]packagebug.Settings),
+		rates:    make(map[string]packagebug.RateState),
+		packages: make(map[string]packagebug.Package),
+		locked:   make(map[string]bool),
+		repos:    make(map[string]packagebug.Repo),
+		aliases:  make(map[string]string),
+		roots:    make(map[string]string),
+
+		advisories: make(map[string][]packagebug.Advisory),
+		releases:   make(map[string]packagebug.Releases),
+	}
+}
+
+func (s *Store) GetEtag(p packagebug.Package) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.etags[p.Path()], s.Err
+}
+
+func (s *Store) GetSince(p packagebug.Package) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.since[p.Path()], s.Err
+}
+
+func (s *Store) Save(r *packagebug.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.Err != nil {
+		return s.Err
+	}
+	s.etags[r.Package.Path()] = r.Etag
+	if since := r.Since(); since.After(s.since[r.Package.Path()]) {
+		s.since[r.Package.Path()] = since
+	}
+	s.saved = append(s.saved, r)
+	return nil
+}
+
+// SaveIssues saves the result without the sync state.
+func (s *Store) SaveIssues(r *packagebug.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.Err != nil {
+		return s.Err
+	}
+	s.saved = append(s.saved, r)
+	return nil
+}
+
+// Writes returns the number of calls of the methods that write to the
+// store.
+func (s *Store) Writes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+// Saved returns the saved results.
+func (s *Store) Saved() []*packagebug.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved
+}
+
+// SetSettings sets the custom settings of the package.
+func (s *Store) SetSettings(p packagebug.Package, settings packagebug.Settings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[p.Path()] = settings
+}
+
+func (s *Store) GetSettings(p packagebug.Package) (packagebug.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings[p.Path()], s.Err
+}
+
+func (s *Store) SetVerified(p packagebug.Package, verified bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	settings := s.settings[p.Path()]
+	settings.Verified = verified
+	settings.VerifiedAt = time.Now()
+	s.settings[p.Path()] = settings
+	return s.Err
+}
+
+func (s *Store) GetCapabilities(p packagebug.Package, max packagebug.Capability) (packagebug.Capability, error) {
+	return max, s.Err
+}
+
+func (s *Store) GetFork(p packagebug.Package) (packagebug.Fork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forks[p.Path()], s.Err
+}
+
+func (s *Store) SetForkParent(p packagebug.Package, parent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	f := s.forks[p.Path()]
+	f.Parent = parent
+	f.CheckedAt = time.Now()
+	s.forks[p.Path()] = f
+	return s.Err
+}
+
+// AddPackage adds the package found by FindPackage.
+func (s *Store) AddPackage(p packagebug.Package) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.packages[p.Path()] = p
+}
+
+// ListPackages returns the added packages with the numeric id greater than
+// after ordered by id, the subpackages linked to their root are left out.
+func (s *Store) ListPackages(after string, limit int) ([]packagebug.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	min, _ := strconv.Atoi(after)
+	var packages []packagebug.Package
+	for path, p := range s.packages {
+		id, _ := strconv.Atoi(p.Id)
+		if _, ok := s.roots[path]; ok || id <= min {
+			continue
+		}
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		a, _ := strconv.Atoi(packages[i].Id)
+		b, _ := strconv.Atoi(packages[j].Id)
+		return a < b
+	})
+	if len(packages) > limit {
+		packages = packages[:limit]
+	}
+	return packages, s.Err
+}
+
+// SetPackageRoot links the subpackage to its root, see Root.
+func (s *Store) SetPackageRoot(p, root packagebug.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	s.roots[p.Path()] = root.Id
+	return s.Err
+}
+
+// Root returns the id of the root package of the subpackage.
+func (s *Store) Root(p packagebug.Package) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.roots[p.Path()]
+}
+
+func (s *Store) FindPackage(tenant, path string) (packagebug.Package, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.packages[path]; ok && p.Tenant == tenant {
+		return p, true, s.Err
+	}
+	if p, ok := s.packages[s.aliases[path]]; ok && p.Tenant == tenant {
+		return p, true, s.Err
+	}
+	p, err := packagebug.ParsePath(path)
+	return p, false, err
+}
+
+func (s *Store) SaveFetchLog(l packagebug.FetchLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	s.logs = append(s.logs, l)
+	return s.Err
+}
+
+// FetchLogs returns the saved fetch logs.
+func (s *Store) FetchLogs() []packagebug.FetchLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logs
+}
+
+func (s *Store) GetStatus(p packagebug.Package) (packagebug.Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := packagebug.Status{
+		Path:  p.Path(),
+		Etag:  s.etags[p.Path()],
+		Since: s.since[p.Path()],
+	}
+	for _, l := range s.logs {
+		if l.PackageId == p.Id {
+			st.LastFetchAt = l.StartedAt
+			st.LastError = l.Error
+			switch {
+			case l.Gone && st.GoneAt == nil:
+				goneAt := l.StartedAt
+				st.GoneAt = &goneAt
+			case l.Error == "":
+				st.GoneAt = nil
+			}
+		}
+	}
+	for _, r := range s.saved {
+		if r.Package.Path() != p.Path() {
+			continue
+		}
+		for _, issue := range r.Issues {
+			if issue.State == "closed" {
+				st.ClosedBugs++
+			} else {
+				st.OpenBugs++
+			}
+		}
+	}
+	return st, s.Err
+}
+
+func (s *Store) LoadRateStates(ids []string) (map[string]packagebug.RateState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]packagebug.RateState)
+	for _, id := range ids {
+		if state, ok := s.rates[id]; ok {
+			states[id] = state
+		}
+	}
+	return states, s.Err
+}
+
+func (s *Store) SaveRateStates(states map[string]packagebug.RateState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	for id, state := range states {
+		s.rates[id] = state
+	}
+	return s.Err
+}
+
+func (s *Store) GetRepoEtag(p packagebug.Package) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repos[p.Path()].Etag, s.Err
+}
+
+func (s *Store) SaveRepo(p packagebug.Package, r packagebug.Repo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	// the stores set the fetch time like the database
+	if r.FetchedAt.IsZero() {
+		r.FetchedAt = time.Now()
+	}
+	s.repos[p.Path()] = r
+	return s.Err
+}
+
+func (s *Store) GetRepo(p packagebug.Package) (packagebug.Repo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repos[p.Path()], s.Err
+}
+
+// Repo returns the saved repository metadata of the package.
+func (s *Store) Repo(p packagebug.Package) packagebug.Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.repos[p.Path()]
+}
+
+func (s *Store) GetReleasesEtag(p packagebug.Package) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.releases[p.Path()].Etag, s.Err
+}
+
+func (s *Store) SaveReleases(p packagebug.Package, r packagebug.Releases) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	s.releases[p.Path()] = r
+	return s.Err
+}
+
+// Releases returns the saved releases of the package.
+func (s *Store) Releases(p packagebug.Package) packagebug.Releases {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.releases[p.Path()]
+}
+
+func (s *Store) SaveAdvisories(p packagebug.Package, advisories []packagebug.Advisory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	s.advisories[p.Path()] = advisories
+	return s.Err
+}
+
+// Advisories returns the saved advisories of the package.
+func (s *Store) Advisories(p packagebug.Package) []packagebug.Advisory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.advisories[p.Path()]
+}
+
+func (s *Store) DeleteIssues(p packagebug.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.Err != nil {
+		return s.Err
+	}
+	saved := s.saved[:0]
+	for _, r := range s.saved {
+		if r.Package.Path() != p.Path() {
+			saved = append(saved, r)
+		}
+	}
+	s.saved = saved
+	s.reset(p)
+	return nil
+}
+
+func (s *Store) ResetSync(p packagebug.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.Err != nil {
+		return s.Err
+	}
+	s.reset(p)
+	return nil
+}
+
+// MovePackage moves the package and its sync state to the path of to.
+func (s *Store) MovePackage(p, to packagebug.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	if s.Err != nil {
+		return s.Err
+	}
+	from := p.Path()
+	if other, ok := s.packages[to.Path()]; ok && other.Id != p.Id && other.Tenant == p.Tenant {
+		return packagebug.ErrPackageExists
+	}
+	s.aliases[from] = to.Path()
+	if pkg, ok := s.packages[from]; ok {
+		pkg.Host, pkg.Owner, pkg.Repo = to.Host, to.Owner, to.Repo
+		s.packages[to.Path()] = pkg
+		delete(s.packages, from)
+	}
+	if etag, ok := s.etags[from]; ok {
+		s.etags[to.Path()] = etag
+		delete(s.etags, from)
+	}
+	if since, ok := s.since[from]; ok {
+		s.since[to.Path()] = since
+		delete(s.since, from)
+	}
+	return nil
+}
+
+// reset clears the sync state of the package.
+func (s *Store) reset(p packagebug.Package) {
+	delete(s.etags, p.Path())
+	delete(s.since, p.Path())
+	if r, ok := s.repos[p.Path()]; ok {
+		r.Etag = ""
+		s.repos[p.Path()] = r
+	}
+}
+
+func (s *Store) Lock(ctx context.Context, p packagebug.Package) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, false, s.Err
+	}
+	if s.locked[p.Key()] {
+		return nil, false, nil
+	}
+	s.locked[p.Key()] = true
+	return func() {
+		s.mu.Lock()
+		delete(s.locked, p.Key())
+		s.mu.Unlock()
+	}, true, nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.Err
+}
+
+// Queue is the in-memory queue.
+type Queue struct {
+	mu       sync.Mutex
+	messages []*queue.Message
+	inflight map[string]*queue.Message
+	deleted  []*queue.Message
+}
+
+// NewQueue creates a queue of the messages with bodies.
+func NewQueue(bodies ...string) *Queue {
+	q := &Queue{inflight: make(map[string]*queue.Message)}
+	for i, body := range bodies {
+		id := fmt.Sprint(i)
+		q.messages = append(q.messages, &queue.Message{Id: id, Body: body, Handle: id})
+	}
+	return q
+}
+
+func (q *Queue) Receive(ctx context.Context, max int, wait time.Duration) ([]*queue.Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if max > len(q.messages) {
+		max = len(q.messages)
+	}
+	msgs := q.messages[:max]
+	q.messages = q.messages[max:]
+	for _, m := range msgs {
+		q.inflight[m.Handle] = m
+	}
+	return msgs, nil
+}
+
+func (q *Queue) Delete(ctx context.Context, m *queue.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inflight, m.Handle)
+	q.deleted = append(q.deleted, m)
+	return nil
+}
+
+func (q *Queue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	for _, m := range msgs {
+		err := q.Delete(ctx, m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// visible right away, otherwise it stays in flight
+	if timeout == 0 {
+		delete(q.inflight, m.Handle)
+		q.messages = append(q.messages, m)
+	}
+	return nil
+}
+
+func (q *Queue) Send(ctx context.Context, body string, priority int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := fmt.Sprint(len(q.messages) + len(q.inflight) + len(q.deleted))
+	q.messages = append(q.messages, &queue.Message{Id: id, Body: body, Handle: id})
+	return nil
+}
+
+// Messages returns the bodies of the visible messages.
+func (q *Queue) Messages() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var bodies []string
+	for _, m := range q.messages {
+		bodies = append(bodies, m.Body)
+	}
+	return bodies
+}
+
+// Deleted returns the deleted messages.
+func (q *Queue) Deleted() []*queue.Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.deleted
+}
+
+// Inflight returns the number of received messages that are not deleted.
+func (q *Queue) Inflight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.inflight)
+}
+
+// Client is the GitHub API client that serves the requests with Handler
+// without network.
+type Client struct {
+	Handler http.Handler
+	Root    string
+}
+
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	c.Handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+func (c *Client) Endpoint() string {
+	return c.Root
+}
+
+// GitHub serves canned GitHub issue list of pyk/byten in pages of PerPage
+// issues with the next and last page links. The first page has the etag
+// Etag. The issues with labels are only
+// served if they have the requested label.
+type GitHub struct {
+	Issues  []packagebug.Issue
+	PerPage int
+	Etag    string
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+// Requests returns the received requests.
+func (f *GitHub) Requests() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *GitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+	if r.URL.Path != "/repos/pyk/byten/issues" {
+		http.NotFound(w, r)
+		return
+	}
+
+	page := 1
+	fmt.Sscan(r.URL.Query().Get("page"), &page)
+	if page == 1 {
+		if r.Header.Get("If-None-Match") == f.Etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", f.Etag)
+	} else if f.Etag != "" {
+		// the other pages have their own etag
+		etag := fmt.Sprintf(`"%s-%d"`, strings.Trim(f.Etag, `"`), page)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+	issues := f.filter(r.URL.Query().Get("labels"), r.URL.Query().Get("state"))
+	start := (page - 1) * f.PerPage
+	end := start + f.PerPage
+	if end < len(issues) {
+		query := r.URL.Query()
+		query.Set("page", fmt.Sprint(page+1))
+		next := query.Encode()
+		query.Set("page", fmt.Sprint((len(issues)+f.PerPage-1)/f.PerPage))
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?%s>; rel="next", <http://%s%s?%s>; rel="last"`,
+			r.Host, r.URL.Path, next, r.Host, r.URL.Path, query.Encode()))
+	} else {
+		end = len(issues)
+	}
+	json.NewEncoder(w).Encode(issues[start:end])
+}
+
+// filter returns the issues without labels or with the label.
+func (f *GitHub) filter(label, state string) []packagebug.Issue {
+	issues := make([]packagebug.Issue, 0, len(f.Issues))
+	for _, issue := range f.Issues {
+		if state != "" && state != "all" && issue.State != state {
+			continue
+		}
+		match := len(issue.Labels) == 0
+		for _, l := range issue.Labels {
+			match = match || strings.EqualFold(l.Name, label)
+		}
+		if match {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// Cache is the in-memory packagebug.ResponseCache.
+type Cache struct {
+	mu        sync.Mutex
+	responses map[string]packagebug.CachedResponse
+}
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{responses: make(map[string]packagebug.CachedResponse)}
+}
+
+// GetResponse implements packagebug.ResponseCache.
+func (c *Cache) GetResponse(ctx context.Context, url string) (packagebug.CachedResponse, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.responses[url]
+	return r, ok, nil
+}
+
+// SaveResponses implements packagebug.ResponseCache.
+func (c *Cache) SaveResponses(ctx context.Context, responses []packagebug.CachedResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range responses {
+		c.responses[r.Url] = r
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package fake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Issues returns n labelled issues of a large repository with their users
+// and bodies, every third one closed, e.g. the payload of the benchmarks.
+func Issues(n int) []packagebug.Issue {
+	created := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	labels := []packagebug.Label{{Name: "bug"}, {Name: "priority/P1"},
+		{Name: "area/runtime"}, {Name: "needs-triage"}}
+	issues := make([]packagebug.Issue, n)
+	for i := range issues {
+		number := i + 1
+		issue := packagebug.Issue{
+			GithubId: int64(100000 + number),
+			Number:   number,
+			Title:    fmt.Sprintf("runtime: panic in the scheduler when the worker %d is stopped", number),
+			State:    "open",
+			Url:      fmt.Sprintf("https://github.com/pyk/byten/issues/%d", number),
+			ApiUrl:   fmt.Sprintf("https://api.github.com/repos/pyk/byten/issues/%d", number),
+			User: packagebug.IssueCreator{Username: fmt.Sprintf("user%d", number%50),
+				GithubId: int64(number%50 + 1)},
+			Labels:    labels[:1+number%len(labels)],
+			Body:      "The worker panics when it is stopped while the scheduler is running.\n\n```go\nw.Stop()\n```\n\nSee https://example.com/trace for the trace of the panic.",
+			CreatedAt: created.Add(time.Duration(number) * time.Hour),
+			UpdatedAt: created.Add(time.Duration(number+1) * time.Hour),
+		}
+		if number%3 == 0 {
+			closed := issue.UpdatedAt
+			issue.State = "closed"
+			issue.ClosedAt = &closed
+		}
+		issues[i] = issue
+	}
+	return issues
+}
@@ -0,0 +1,210 @@
+//go:build integration
+
+// Package integration runs the worker against Postgres and ElasticMQ in
+// containers and a stubbed GitHub. It needs Docker:
+//
+//	go test -tags integration ./internal/integration/
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/queue/sqs"
+	"github.com/pyk/packagebug-worker/internal/store/postgres"
+	"github.com/pyk/packagebug-worker/internal/worker"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// startPostgres starts Postgres and returns its migrated database.
+func startPostgres(ctx context.Context, t *testing.T) *sql.DB {
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:16-alpine",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "packagebug",
+				"POSTGRES_PASSWORD": "packagebug",
+				"POSTGRES_DB":       "packagebug",
+			},
+			// the server restarts once after the init scripts
+			WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Terminate(context.Background()) })
+	addr, err := c.PortEndpoint(ctx, "5432/tcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := fmt.Sprintf("postgres://packagebug:packagebug@%s/packagebug?sslmode=disable", addr)
+	dbconn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbconn.Close() })
+	_, err = postgres.Migrate(ctx, dbconn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbconn
+}
+
+// startQueue starts ElasticMQ and returns the SQS queue of a new queue.
+func startQueue(ctx context.Context, t *testing.T) *sqs.Queue {
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "softwaremill/elasticmq-native:1.5.7",
+			ExposedPorts: []string{"9324/tcp"},
+			WaitingFor:   wait.ForListeningPort("9324/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Terminate(context.Background()) })
+	endpoint, err := c.PortEndpoint(ctx, "9324/tcp", "http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(endpoint + "/?Action=CreateQueue&QueueName=packagebug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the queue created got: %s\n", resp.Status)
+	}
+
+	// ElasticMQ accepts any credentials
+	t.Setenv("AWS_ACCESS_KEY_ID", "x")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "x")
+	q, err := sqs.New(endpoint, "elasticmq", endpoint+"/000000000000/packagebug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// newGitHub returns the client of the stubbed GitHub with a known rate
+// limit, so the worker doesn't ask for it.
+func newGitHub(root string) *github.Client {
+	client := github.NewClient(nil, 1)
+	client.Root = root
+	states := make(map[string]packagebug.RateState)
+	for _, id := range client.TokenIds() {
+		states[id] = packagebug.RateState{
+			Remaining: 5000,
+			Reset:     time.Now().Add(time.Hour),
+			Known:     true,
+		}
+	}
+	client.SetRateStates(states)
+	return client
+}
+
+func TestWorkerSync(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	dbconn := startPostgres(ctx, t)
+	q := startQueue(ctx, t)
+
+	gh := &fake.GitHub{
+		Issues: []packagebug.Issue{
+			{Number: 1, Title: "crash", State: "open"},
+			{Number: 2, Title: "leak", State: "closed"},
+		},
+		PerPage: 1,
+		Etag:    `"v1"`,
+	}
+	ts := httptest.NewServer(gh)
+	defer ts.Close()
+	client := newGitHub(ts.URL)
+
+	p := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "byten"}
+	err := dbconn.QueryRow(`
+	INSERT INTO packages(package_path, package_host, package_owner, package_repo)
+	VALUES($1, $2, $3, $4)
+	RETURNING package_id`, p.Path(), p.Host, p.Owner, p.Repo).Scan(&p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.Send(ctx, fmt.Sprintf("%s,%s,%s,%s", p.Id, p.Host, p.Owner, p.Repo), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := worker.NewBuffer(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &postgres.Store{DB: dbconn}
+	w := &worker.Worker{
+		GitHub:     client,
+		Providers:  []provider.Provider{client},
+		Store:      store,
+		Queue:      q,
+		Acker:      worker.NewAcker(q, 100*time.Millisecond),
+		Buffer:     buf,
+		ForkPolicy: packagebug.ForkBoth,
+
+		VisibilityTimeout: 30 * time.Second,
+		ReceiveWait:       time.Second,
+	}
+	wctx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- w.Run(wctx) }()
+
+	var st packagebug.Status
+	for st.LastFetchAt.IsZero() && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+		st, err = store.GetStatus(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected canceled got: %v\n", err)
+	}
+
+	if st.LastError != "" || st.OpenBugs != 1 || st.ClosedBugs != 1 {
+		t.Errorf("expected 1 open and 1 closed bug got: %+v\n", st)
+	}
+	if st.Etag != `"v1"` {
+		t.Errorf("expected the etag of the first page got: %s\n", st.Etag)
+	}
+	var issues int
+	err = dbconn.QueryRow(`SELECT count(*) FROM issues WHERE package_id=$1`, p.Id).Scan(&issues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues != 2 {
+		t.Errorf("expected 2 stored issues got: %d\n", issues)
+	}
+
+	// the message is deleted once the result is stored
+	msgs, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("expected empty queue got: %d messages\n", len(msgs))
+	}
+}
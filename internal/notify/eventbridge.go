@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/pyk/packagebug-worker"
+)
+
+// The source and the detail type of the EventBridge events, the rules of
+// the bus match them.
+const (
+	Source     = "packagebug"
+	DetailType = "Sync Completed"
+)
+
+// EventBridge puts the events to the EventBridge event bus, the detail of
+// the event is the JSON of the sync event.
+type EventBridge struct {
+	EventBridge *eventbridge.Client
+	// Bus is the name or the ARN of the event bus
+	Bus string
+}
+
+// NotifySync puts the sync event.
+func (b *EventBridge) NotifySync(ctx context.Context, e packagebug.SyncEvent) error {
+	detail, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	out, err := b.EventBridge.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{{
+			EventBusName: aws.String(b.Bus),
+			Source:       aws.String(Source),
+			DetailType:   aws.String(DetailType),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(e.CompletedAt),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	// the failed entries are reported in the response, not as error
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("put event: %s: %s", aws.ToString(out.Entries[0].ErrorCode),
+			aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Package notify publishes the completed syncs of the packages to Amazon SNS
+// or Amazon EventBridge, so the other services don't poll the store.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/pyk/packagebug-worker"
+)
+
+// Notifier publishes the sync events.
+type Notifier interface {
+	NotifySync(ctx context.Context, e packagebug.SyncEvent) error
+}
+
+// Target is the SNS topic or the EventBridge event bus of the events.
+type Target struct {
+	Arn string
+	// Service is sns or events
+	Service string
+	Region  string
+}
+
+// ParseTarget parses the ARN of the SNS topic, e.g.
+// arn:aws:sns:us-east-1:123456789012:packagebug, or of the EventBridge event
+// bus, e.g. arn:aws:events:us-east-1:123456789012:event-bus/packagebug.
+func ParseTarget(arn string) (Target, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[3] == "" || parts[5] == "" {
+		return Target{}, fmt.Errorf("expected ARN got %q", arn)
+	}
+	t := Target{Arn: arn, Service: parts[2], Region: parts[3]}
+	switch t.Service {
+	case "sns":
+	case "events":
+		if !strings.HasPrefix(parts[5], "event-bus/") {
+			return t, fmt.Errorf("expected event bus ARN got %q", arn)
+		}
+	default:
+		return t, fmt.Errorf("expected ARN of sns or events got %q", t.Service)
+	}
+	return t, nil
+}
+
+// New creates the notifier of the target using the credentials of the
+// default chain, see sqs.New. The endpoint of the service is optional, e.g.
+// the url of LocalStack.
+func New(ctx context.Context, endpoint string, t Target) (Notifier, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(t.Region))
+	if err != nil {
+		return nil, err
+	}
+	if t.Service == "sns" {
+		client := sns.NewFromConfig(cfg, func(o *sns.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = &endpoint
+			}
+		})
+		return &SNS{SNS: client, TopicArn: t.Arn}, nil
+	}
+	client := eventbridge.NewFromConfig(cfg, func(o *eventbridge.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = &endpoint
+		}
+	})
+	return &EventBridge{EventBridge: client, Bus: t.Arn}, nil
+}
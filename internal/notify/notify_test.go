@@ -0,0 +1,33 @@
+package notify
+
+import "testing"
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		arn     string
+		service string
+		region  string
+	}{
+		{"arn:aws:sns:us-east-1:123456789012:packagebug", "sns", "us-east-1"},
+		{"arn:aws:events:eu-west-1:123456789012:event-bus/packagebug", "events", "eu-west-1"},
+		{"arn:aws:events:eu-west-1:123456789012:rule/packagebug", "", ""},
+		{"arn:aws:sqs:us-east-1:123456789012:packagebug", "", ""},
+		{"packagebug", "", ""},
+	}
+	for _, c := range cases {
+		target, err := ParseTarget(c.arn)
+		if c.service == "" {
+			if err == nil {
+				t.Errorf("%s: expected error\n", c.arn)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s\n", c.arn, err)
+			continue
+		}
+		if target.Service != c.service || target.Region != c.region || target.Arn != c.arn {
+			t.Errorf("%s: unexpected target: %+v\n", c.arn, target)
+		}
+	}
+}
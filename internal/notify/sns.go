@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/pyk/packagebug-worker"
+)
+
+// SNS publishes the events to the SNS topic as JSON messages. The event
+// and the tenant are message attributes too, so the subscriptions filter
+// by them.
+type SNS struct {
+	SNS      *sns.Client
+	TopicArn string
+}
+
+// NotifySync publishes the sync event.
+func (s *SNS) NotifySync(ctx context.Context, e packagebug.SyncEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	attrs := map[string]types.MessageAttributeValue{
+		"event": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.Event),
+		},
+	}
+	if e.Tenant != "" {
+		attrs["tenant"] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(e.Tenant),
+		}
+	}
+	_, err = s.SNS.Publish(ctx, &sns.PublishInput{
+		TopicArn:          aws.String(s.TopicArn),
+		Message:           aws.String(string(body)),
+		MessageAttributes: attrs,
+	})
+	return err
+}
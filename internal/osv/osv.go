@@ -0,0 +1,107 @@
+// Package osv fetches the security advisories of the Go modules from the
+// OSV.dev vulnerability database.
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// DefaultRoot is the root of the OSV.dev API.
+const DefaultRoot = "https://api.osv.dev"
+
+// Client queries the advisories of the packages.
+type Client struct {
+	HTTP *http.Client
+	Root string
+}
+
+// New creates the client of the API root.
+func New(root string, timeout time.Duration) *Client {
+	return &Client{HTTP: packagebug.NewHTTPClient(timeout), Root: root}
+}
+
+// query is the body of the query by package.
+type query struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+// vuln is the OSV entry of the advisory.
+type vuln struct {
+	Id               string     `json:"id"`
+	Summary          string     `json:"summary"`
+	Aliases          []string   `json:"aliases"`
+	Published        time.Time  `json:"published"`
+	Modified         time.Time  `json:"modified"`
+	Withdrawn        *time.Time `json:"withdrawn"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// FetchAdvisories returns every advisory of the Go module of the package
+// path, including the withdrawn ones. The path that isn't a module has none.
+func (c *Client) FetchAdvisories(ctx context.Context, p packagebug.Package) ([]packagebug.Advisory, error) {
+	q := query{}
+	q.Package.Name = p.Path()
+	q.Package.Ecosystem = "Go"
+	var advisories []packagebug.Advisory
+	for {
+		var resp struct {
+			Vulns         []vuln `json:"vulns"`
+			NextPageToken string `json:"next_page_token"`
+		}
+		err := c.post(ctx, "/v1/query", q, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range resp.Vulns {
+			advisories = append(advisories, packagebug.Advisory{
+				Id:          v.Id,
+				Aliases:     v.Aliases,
+				Summary:     v.Summary,
+				Severity:    v.DatabaseSpecific.Severity,
+				Url:         "https://osv.dev/vulnerability/" + v.Id,
+				PublishedAt: v.Published,
+				ModifiedAt:  v.Modified,
+				WithdrawnAt: v.Withdrawn,
+			})
+		}
+		if resp.NextPageToken == "" {
+			return advisories, nil
+		}
+		q.PageToken = resp.NextPageToken
+	}
+}
+
+// post sends the JSON body to the path and decodes the response into v.
+func (c *Client) post(ctx context.Context, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Root+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pyk")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return packagebug.NewStatusError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,66 @@
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestFetchAdvisories(t *testing.T) {
+	pages := map[string]string{
+		"": `{"vulns": [{"id": "GO-2023-1571", "summary": "Denial of service",
+			"aliases": ["CVE-2022-41723", "GHSA-vvpx-j8f3-3w6h"],
+			"published": "2023-02-16T22:23:00Z", "modified": "2023-06-12T18:45:41Z"}],
+			"next_page_token": "2"}`,
+		"2": `{"vulns": [{"id": "GHSA-vvpx-j8f3-3w6h", "database_specific": {"severity": "HIGH"},
+			"published": "2023-02-16T22:23:00Z", "modified": "2023-06-12T18:45:41Z",
+			"withdrawn": "2023-07-01T00:00:00Z"}]}`,
+	}
+	var names []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q query
+		err := json.NewDecoder(r.Body).Decode(&q)
+		if r.URL.Path != "/v1/query" || err != nil || q.Package.Ecosystem != "Go" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		names = append(names, q.Package.Name)
+		fmt.Fprint(w, pages[q.PageToken])
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, 0)
+	advisories, err := c.FetchAdvisories(context.Background(), fake.Package)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != fake.Package.Path() {
+		t.Errorf("expected 2 queries of %s got: %v\n", fake.Package.Path(), names)
+	}
+	if len(advisories) != 2 {
+		t.Fatalf("expected 2 advisories got: %+v\n", advisories)
+	}
+	a := advisories[0]
+	if a.Id != "GO-2023-1571" || len(a.Aliases) != 2 || a.Url != "https://osv.dev/vulnerability/GO-2023-1571" || a.WithdrawnAt != nil {
+		t.Errorf("unexpected advisory: %+v\n", a)
+	}
+	if a := advisories[1]; a.Severity != "HIGH" || a.WithdrawnAt == nil {
+		t.Errorf("expected the withdrawn advisory rated high got: %+v\n", a)
+	}
+}
+
+func TestFetchAdvisoriesError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	_, err := New(ts.URL, 0).FetchAdvisories(context.Background(), fake.Package)
+	if err == nil {
+		t.Error("expected error of the unavailable API")
+	}
+}
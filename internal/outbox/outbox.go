@@ -0,0 +1,85 @@
+// Package outbox forwards the changes of the bugs written to the outbox of
+// the store to the queue of the other services.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Store is the outbox of the store, the events are written by the saves of
+// the issues.
+type Store interface {
+	// PublishOutbox passes at most limit unpublished events to publish in
+	// order and marks the published ones.
+	PublishOutbox(ctx context.Context, limit int, publish func(packagebug.OutboxEvent) error) (int, error)
+	// PruneOutbox deletes the events published before t.
+	PruneOutbox(ctx context.Context, t time.Time) (int64, error)
+}
+
+// Publisher sends the events of the outbox to the queue as JSON messages.
+// An event is marked published only after its message was sent, so every
+// event is delivered at least once.
+type Publisher struct {
+	Store  Store
+	Sender queue.Sender
+	// Batch is the maximum number of events published per transaction
+	Batch int
+	// Retention is how long the published events are kept, forever if
+	// zero
+	Retention time.Duration
+}
+
+// Publish sends the unpublished events until the outbox is drained. It
+// returns the number of published events.
+func (p *Publisher) Publish(ctx context.Context) (int, error) {
+	batch := p.Batch
+	if batch < 1 {
+		batch = 100
+	}
+	total := 0
+	for {
+		n, err := p.Store.PublishOutbox(ctx, batch, func(e packagebug.OutboxEvent) error {
+			body, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			return p.Sender.Send(ctx, string(body), 0)
+		})
+		total += n
+		if err != nil || n < batch {
+			return total, err
+		}
+	}
+}
+
+// Run publishes the events every interval until ctx is done. The events
+// published longer than Retention ago are pruned.
+func (p *Publisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		n, err := p.Publish(ctx)
+		if err != nil {
+			slog.Error("publish outbox failed", "error", err, "published", n)
+		} else if n > 0 {
+			slog.Debug("outbox published", "published", n)
+		}
+		if p.Retention > 0 {
+			_, err = p.Store.PruneOutbox(ctx, time.Now().Add(-p.Retention))
+			if err != nil {
+				slog.Warn("prune outbox failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+// memoryStore is the outbox of the events in memory.
+type memoryStore struct {
+	events    []packagebug.OutboxEvent
+	published int
+}
+
+func (s *memoryStore) PublishOutbox(ctx context.Context, limit int, publish func(packagebug.OutboxEvent) error) (int, error) {
+	n := 0
+	for s.published < len(s.events) && n < limit {
+		err := publish(s.events[s.published])
+		if err != nil {
+			return n, err
+		}
+		s.published++
+		n++
+	}
+	return n, nil
+}
+
+func (s *memoryStore) PruneOutbox(ctx context.Context, t time.Time) (int64, error) {
+	return 0, nil
+}
+
+// failingSender fails every send.
+type failingSender struct{}
+
+func (failingSender) Send(ctx context.Context, body string, priority int) error {
+	return errors.New("queue unavailable")
+}
+
+func TestPublisherPublish(t *testing.T) {
+	store := &memoryStore{}
+	for i := 1; i <= 5; i++ {
+		store.events = append(store.events, packagebug.OutboxEvent{Id: int64(i),
+			Event: packagebug.IssueCreated, PackagePath: "github.com/pyk/byten", IssueNumber: i})
+	}
+	q := fake.NewQueue()
+	p := &Publisher{Store: store, Sender: q, Batch: 2}
+	n, err := p.Publish(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("expected 5 events published got: %d %v\n", n, err)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 5 || !strings.Contains(msgs[0], `"event":"issue_created"`) ||
+		!strings.Contains(msgs[0], `"package_path":"github.com/pyk/byten"`) {
+		t.Errorf("unexpected messages: %v\n", msgs)
+	}
+
+	// the events that failed are kept for the next publish
+	store.events = append(store.events, packagebug.OutboxEvent{Id: 6})
+	p.Sender = failingSender{}
+	n, err = p.Publish(context.Background())
+	if err == nil || n != 0 || store.published != 5 {
+		t.Errorf("expected the event kept got: %d %v\n", n, err)
+	}
+}
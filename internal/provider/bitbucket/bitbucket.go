@@ -0,0 +1,220 @@
+// Package bitbucket fetches the issues of the packages hosted on Bitbucket
+// Cloud.
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// PageSize is the number of issues requested per page.
+const PageSize = 50
+
+// Client fetches the issues of packages hosted on Bitbucket Cloud.
+// Bitbucket doesn't expose the remaining rate limit, so the client stops
+// sending requests once it receives 429 until the limit window is over.
+type Client struct {
+	Root     string
+	Username string
+	Password string
+	HTTP     *http.Client
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+// New creates Bitbucket client. Username and app password are
+// optional, they're only required for private repositories.
+func New(root, username, password string) *Client {
+	if root == "" {
+		root = "https://api.bitbucket.org/2.0"
+	}
+	return &Client{
+		Root:     root,
+		Username: username,
+		Password: password,
+		HTTP:     &http.Client{},
+	}
+}
+
+// bitbucketIssue represents the issue returned by Bitbucket API.
+type bitbucketIssue struct {
+	Id        int       `json:"id"`
+	Title     string    `json:"title"`
+	Kind      string    `json:"kind"`
+	State     string    `json:"state"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+	Votes     int       `json:"votes"`
+	Content   struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Assignee *struct {
+		Nickname string `json:"nickname"`
+	} `json:"assignee"`
+	Milestone *struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"milestone"`
+	Links struct {
+		Self     struct{ Href string } `json:"self"`
+		Html     struct{ Href string } `json:"html"`
+		Comments struct{ Href string } `json:"comments"`
+	} `json:"links"`
+}
+
+// Issue maps the Bitbucket issue onto the shared Issue model. Bitbucket
+// doesn't record when the issue is closed, the last update time is used
+// instead. The votes are counted as +1 reactions.
+func (i bitbucketIssue) Issue() packagebug.Issue {
+	issue := packagebug.Issue{
+		ApiUrl:         i.Links.Self.Href,
+		ApiCommentsUrl: i.Links.Comments.Href,
+		Url:            i.Links.Html.Href,
+		Number:         i.Id,
+		Title:          i.Title,
+		Body:           i.Content.Raw,
+		Reactions:      packagebug.Reactions{Total: i.Votes, PlusOne: i.Votes},
+		State:          "open",
+		CreatedAt:      i.CreatedOn,
+		UpdatedAt:      i.UpdatedOn,
+	}
+	switch i.State {
+	case "resolved", "invalid", "duplicate", "wontfix", "closed":
+		issue.State = "closed"
+		closedAt := i.UpdatedOn
+		issue.ClosedAt = &closedAt
+	}
+	if i.Assignee != nil {
+		issue.Assignees = []packagebug.IssueCreator{{Username: i.Assignee.Nickname}}
+	}
+	if i.Milestone != nil {
+		issue.Milestone = &packagebug.Milestone{Number: i.Milestone.Id,
+			Title: i.Milestone.Name}
+	}
+	return issue
+}
+
+// IssuesUrl returns the url of the first page of bugs of the package.
+func (b *Client) IssuesUrl(p packagebug.Package) string {
+	query := url.Values{}
+	query.Add("q", `kind="bug"`)
+	query.Add("pagelen", strconv.Itoa(PageSize))
+	src := p.Source()
+	return fmt.Sprintf("%s/repositories/%s/%s/issues?%s", b.Root, src.Owner,
+		src.Repo, query.Encode())
+}
+
+// Name implements provider.Provider.
+func (b *Client) Name() string {
+	return "bitbucket"
+}
+
+// Has implements provider.Provider.
+func (b *Client) Has(host string) bool {
+	return host == "bitbucket.org"
+}
+
+// RateLimit implements provider.Provider. It returns 0 and the reset time if
+// the client is blocked by the rate limit, otherwise 1.
+func (b *Client) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Now().Before(b.blockedUntil) {
+		return 0, b.blockedUntil.Unix(), nil
+	}
+	return 1, 0, nil
+}
+
+// block stops the requests until the rate limit window is over.
+func (b *Client) block(resp *http.Response) {
+	// the rate limit of Bitbucket is an hourly rolling window
+	wait := time.Hour
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(s) * time.Second
+	}
+	b.mu.Lock()
+	b.blockedUntil = time.Now().Add(wait)
+	b.mu.Unlock()
+}
+
+// FetchIssues implements provider.Provider. It fetches all pages of bugs of
+// the package, Bitbucket has neither etag nor since, so every fetch is full.
+// It returns empty result if the repository has no issue tracker. The fetch
+// starts at the page of the package cursor and stops once the budget of the
+// package is spent, Next of the result is where the sync continues then.
+func (b *Client) FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	logger := packagebug.Logger(ctx)
+	result := &packagebug.Result{Package: p}
+	next := b.IssuesUrl(p)
+	start := 1
+	if p.Cursor.Page > 1 {
+		start = p.Cursor.Page
+		next += "&page=" + strconv.Itoa(start)
+	}
+	fetched := 0
+	for n := start; next != ""; n++ {
+		if p.Budget.PagesLeft(n-start, fetched, PageSize) == 0 {
+			result.Next = &packagebug.Cursor{Page: n}
+			break
+		}
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Add("User-Agent", "pyk")
+		if b.Username != "" {
+			req.SetBasicAuth(b.Username, b.Password)
+		}
+
+		start := time.Now()
+		resp, err := b.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("fetch", "status_code", resp.StatusCode,
+			"duration", time.Since(start))
+		packagebug.RecordPage(ctx, resp.StatusCode)
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			// the issue tracker is disabled
+			resp.Body.Close()
+			return result, nil
+		case http.StatusTooManyRequests:
+			b.block(resp)
+			resp.Body.Close()
+			return nil, packagebug.ErrRateLimited
+		default:
+			resp.Body.Close()
+			return nil, packagebug.NewStatusError(resp)
+		}
+
+		var page struct {
+			Values []bitbucketIssue `json:"values"`
+			Next   string           `json:"next"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, packagebug.DecodeError(err)
+		}
+		fetched += len(page.Values)
+		for _, i := range page.Values {
+			result.Issues = append(result.Issues, i.Issue())
+		}
+		next = page.Next
+	}
+	result.Project(p.Capabilities)
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+var bitbucketPkgTest = packagebug.Package{
+	Id:    "1",
+	Host:  "bitbucket.org",
+	Owner: "pyk",
+	Repo:  "byten",
+}
+
+func TestBitbucketIssuesUrl(t *testing.T) {
+	b := New("root", "", "")
+	expected := "root/repositories/pyk/byten/issues?pagelen=50&q=kind%3D%22bug%22"
+	urls := b.IssuesUrl(bitbucketPkgTest)
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestBitbucketFetchIssues(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"values":[{"id":2,"title":"crash","kind":"bug",
+				"votes":3,"content":{"raw":"it crashes"},
+				"assignee":{"nickname":"pyk"},"milestone":{"id":1,"name":"v1.0"}}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"values":[{"id":1,"title":"panic","kind":"bug",
+			"links":{"html":{"href":"https://bitbucket.org/pyk/byten/issues/1"}}}],
+			"next":"%s/repositories/pyk/byten/issues?page=2"}`, ts.URL)
+	}))
+	defer ts.Close()
+
+	b := New(ts.URL, "", "")
+	p := bitbucketPkgTest
+	p.Capabilities = packagebug.CapAll
+	result, err := b.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 2 {
+		t.Fatalf("expected 2 issues got: %d\n", len(result.Issues))
+	}
+	issue := result.Issues[0]
+	if issue.Number != 1 || issue.Title != "panic" || issue.Url != "https://bitbucket.org/pyk/byten/issues/1" {
+		t.Errorf("got: %+v\n", issue)
+	}
+	issue = result.Issues[1]
+	if issue.Body != "it crashes" || issue.Reactions.PlusOne != 3 {
+		t.Errorf("expected body and votes got: %+v\n", issue)
+	}
+	if len(issue.Assignees) != 1 || issue.Assignees[0].Username != "pyk" ||
+		issue.Milestone == nil || issue.Milestone.Title != "v1.0" {
+		t.Errorf("expected assignee and milestone got: %+v\n", issue)
+	}
+
+	// the body and the votes are dropped without their capabilities
+	p.Capabilities = packagebug.CapComments
+	result, err = b.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue = result.Issues[1]; issue.Body != "" || issue.Reactions.PlusOne != 0 {
+		t.Errorf("expected no body and votes got: %+v\n", issue)
+	}
+}
+
+func TestBitbucketFetchIssuesBudget(t *testing.T) {
+	var pages []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		n, _ := strconv.Atoi(page)
+		fmt.Fprintf(w, `{"values":[{"id":%d,"title":"crash","kind":"bug"}],
+			"next":"%s/repositories/pyk/byten/issues?page=%d"}`, n, ts.URL, n+1)
+	}))
+	defer ts.Close()
+
+	b := New(ts.URL, "", "")
+	p := bitbucketPkgTest
+	p.Budget = packagebug.Budget{Issues: 2}
+	p.Cursor = packagebug.Cursor{Page: 3}
+	result, err := b.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[0] != "3" || pages[1] != "4" {
+		t.Errorf("expected pages 3 and 4 got: %v\n", pages)
+	}
+	if result.Next == nil || *result.Next != (packagebug.Cursor{Page: 5}) {
+		t.Errorf("expected the sync continued at page 5 got: %+v\n", result.Next)
+	}
+	if len(result.Issues) != 2 || result.Issues[0].Number != 3 {
+		t.Errorf("expected issues 3 and 4 got: %+v\n", result.Issues)
+	}
+}
+
+func TestBitbucketIssueState(t *testing.T) {
+	i := bitbucketIssue{Id: 1, State: "resolved", UpdatedOn: time.Now()}
+	issue := i.Issue()
+	if issue.State != "closed" || issue.ClosedAt == nil {
+		t.Errorf("expected closed got: %+v\n", issue)
+	}
+	i.State = "on hold"
+	issue = i.Issue()
+	if issue.State != "open" || issue.ClosedAt != nil {
+		t.Errorf("expected open got: %+v\n", issue)
+	}
+}
+
+func TestBitbucketRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	b := New(ts.URL, "", "")
+	rate, _, _ := b.RateLimit(context.Background(), "bitbucket.org")
+	if rate != 1 {
+		t.Fatalf("expected available rate limit got: %d\n", rate)
+	}
+	_, err := b.FetchIssues(context.Background(), bitbucketPkgTest, time.Time{}, "")
+	if err == nil {
+		t.Fatal("expected rate limit error")
+	}
+	rate, reset, _ := b.RateLimit(context.Background(), "bitbucket.org")
+	if rate != 0 || reset == 0 {
+		t.Errorf("expected blocked got: %d %d\n", rate, reset)
+	}
+}
@@ -0,0 +1,244 @@
+// Package gitea fetches the issues of the packages hosted on self-hosted
+// Gitea and Forgejo instances.
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// PageSize is the number of issues requested per page.
+const PageSize = 50
+
+// Client fetches the issues from the Gitea API of the configured hosts.
+// Gitea doesn't expose the remaining rate limit, so the client stops sending
+// requests to the host once it receives 429 until the limit window is over.
+type Client struct {
+	// Hosts are the Gitea and Forgejo instances, e.g. codeberg.org
+	Hosts []string
+	// Scheme of the API of the hosts, https if empty
+	Scheme string
+	HTTP   *http.Client
+
+	mu           sync.Mutex
+	blockedUntil map[string]time.Time
+}
+
+// New creates the client of the hosts.
+func New(hosts []string) *Client {
+	return &Client{
+		Hosts:        hosts,
+		Scheme:       "https",
+		HTTP:         &http.Client{},
+		blockedUntil: make(map[string]time.Time),
+	}
+}
+
+// Name implements provider.Provider.
+func (c *Client) Name() string {
+	return "gitea"
+}
+
+// Has implements provider.Provider, it returns true if host is one of the
+// Gitea hosts.
+func (c *Client) Has(host string) bool {
+	for _, h := range c.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// giteaIssue represents the issue returned by Gitea API.
+type giteaIssue struct {
+	Id        int64      `json:"id"`
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	State     string     `json:"state"`
+	Url       string     `json:"url"`
+	HtmlUrl   string     `json:"html_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	User      struct {
+		Login     string `json:"login"`
+		AvatarUrl string `json:"avatar_url"`
+		HtmlUrl   string `json:"html_url"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	Milestone *struct {
+		Id    int        `json:"id"`
+		Title string     `json:"title"`
+		State string     `json:"state"`
+		DueOn *time.Time `json:"due_on"`
+	} `json:"milestone"`
+}
+
+// Issue maps the Gitea issue onto the shared Issue model. The user has no
+// GitHub id, so it's not stored as user.
+func (i giteaIssue) Issue() packagebug.Issue {
+	issue := packagebug.Issue{
+		ApiUrl:    i.Url,
+		Url:       i.HtmlUrl,
+		Number:    i.Number,
+		Title:     i.Title,
+		Body:      i.Body,
+		State:     i.State,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+		User: packagebug.IssueCreator{
+			Username:   i.User.Login,
+			AvatarUrl:  i.User.AvatarUrl,
+			ProfileUrl: i.User.HtmlUrl,
+		},
+	}
+	if i.State == "closed" {
+		issue.ClosedAt = i.ClosedAt
+	}
+	for _, a := range i.Assignees {
+		issue.Assignees = append(issue.Assignees, packagebug.IssueCreator{Username: a.Login})
+	}
+	if m := i.Milestone; m != nil {
+		issue.Milestone = &packagebug.Milestone{Number: m.Id, Title: m.Title,
+			State: m.State, DueOn: m.DueOn}
+	}
+	for _, l := range i.Labels {
+		issue.Labels = append(issue.Labels, packagebug.Label{Name: l.Name})
+	}
+	return issue
+}
+
+// IssuesUrl returns the url of the page of bugs of the package. Only issues
+// updated after p.Since are fetched if set.
+func (c *Client) IssuesUrl(p packagebug.Package, page int) string {
+	query := url.Values{}
+	query.Add("state", p.Query.IssueState())
+	query.Add("type", "issues")
+	// the label filter matches any of the labels
+	labels := strings.Join(p.Query.BugLabels(), ",")
+	if len(p.Labels) > 0 {
+		labels = strings.Join(p.Labels, ",")
+	}
+	query.Add("labels", labels)
+	if !p.Since.IsZero() {
+		query.Add("since", p.Since.UTC().Format(time.RFC3339))
+	}
+	query.Add("limit", strconv.Itoa(PageSize))
+	query.Add("page", strconv.Itoa(page))
+	src := p.Source()
+	return fmt.Sprintf("%s://%s/api/v1/repos/%s/%s/issues?%s", c.Scheme,
+		src.Host, src.Owner, src.Repo, query.Encode())
+}
+
+// RateLimit implements provider.Provider. It returns 0 and the reset time
+// if the client is blocked by the rate limit of host, otherwise 1.
+func (c *Client) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until := c.blockedUntil[host]; time.Now().Before(until) {
+		return 0, until.Unix(), nil
+	}
+	return 1, 0, nil
+}
+
+// block stops the requests to host until the rate limit window is over.
+func (c *Client) block(host string, resp *http.Response) {
+	wait := time.Minute
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(s) * time.Second
+	}
+	c.mu.Lock()
+	c.blockedUntil[host] = time.Now().Add(wait)
+	c.mu.Unlock()
+}
+
+// FetchIssues implements provider.Provider. It fetches all pages of bugs of
+// the package updated after since, Gitea has no etag so every fetch is
+// unconditional. It returns empty result if the repository has no issue
+// tracker. The fetch starts at the page of the package cursor and stops once
+// the budget of the package is spent, Next of the result is where the sync
+// continues then.
+func (c *Client) FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	logger := packagebug.Logger(ctx)
+	p.Since = since
+
+	start := 1
+	if p.Cursor.Page > 1 {
+		start = p.Cursor.Page
+	}
+
+	result := &packagebug.Result{Package: p}
+	fetched := 0
+	for page := start; ; page++ {
+		if p.Budget.PagesLeft(page-start, fetched, PageSize) == 0 {
+			result.Next = &packagebug.Cursor{Page: page}
+			break
+		}
+		req, err := http.NewRequest("GET", c.IssuesUrl(p, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Add("User-Agent", "pyk")
+		req.Header.Add("Accept", "application/json")
+		if p.Token != "" {
+			req.Header.Set("Authorization", "token "+p.Token)
+		}
+
+		start := time.Now()
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("fetch", "status_code", resp.StatusCode,
+			"duration", time.Since(start))
+		packagebug.RecordPage(ctx, resp.StatusCode)
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound:
+			// the repository or its issue tracker is gone
+			resp.Body.Close()
+			return result, nil
+		case http.StatusTooManyRequests:
+			c.block(p.Source().Host, resp)
+			resp.Body.Close()
+			return nil, packagebug.ErrRateLimited
+		default:
+			resp.Body.Close()
+			return nil, packagebug.NewStatusError(resp)
+		}
+
+		var issues []giteaIssue
+		err = json.NewDecoder(resp.Body).Decode(&issues)
+		resp.Body.Close()
+		if err != nil {
+			return nil, packagebug.DecodeError(err)
+		}
+		fetched += len(issues)
+		for _, i := range issues {
+			result.Issues = append(result.Issues, i.Issue())
+		}
+		if len(issues) < PageSize {
+			break
+		}
+	}
+	result.Project(p.Capabilities)
+	return result, nil
+}
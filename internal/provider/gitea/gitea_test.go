@@ -0,0 +1,158 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+var giteaPkgTest = packagebug.Package{
+	Id:    "1",
+	Host:  "codeberg.org",
+	Owner: "pyk",
+	Repo:  "byten",
+}
+
+func TestGiteaIssuesUrl(t *testing.T) {
+	c := New([]string{"codeberg.org"})
+	p := giteaPkgTest
+	p.Labels = []string{"kind/bug"}
+	expected := "https://codeberg.org/api/v1/repos/pyk/byten/issues?labels=kind%2Fbug&limit=50&page=2&state=all&type=issues"
+	urls := c.IssuesUrl(p, 2)
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestGiteaIssuesUrlQuery(t *testing.T) {
+	c := New([]string{"codeberg.org"})
+	p := giteaPkgTest
+	p.Query = packagebug.Query{State: "open", Labels: []string{"bug", "defect"}}
+	expected := "https://codeberg.org/api/v1/repos/pyk/byten/issues?labels=bug%2Cdefect&limit=50&page=1&state=open&type=issues"
+	urls := c.IssuesUrl(p, 1)
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestGiteaHas(t *testing.T) {
+	c := New([]string{"codeberg.org", "gitea.example.com"})
+	if !c.Has("Codeberg.org") || c.Has("github.com") {
+		t.Error("unexpected hosts")
+	}
+}
+
+func TestGiteaFetchIssues(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/pyk/byten/issues" {
+			http.NotFound(w, r)
+			return
+		}
+		// the first page is full, the second is the last one
+		n := PageSize
+		if r.URL.Query().Get("page") == "2" {
+			n = 1
+		}
+		var issues []map[string]interface{}
+		for i := 0; i < n; i++ {
+			issues = append(issues, map[string]interface{}{
+				"number":     i + 1,
+				"title":      "crash",
+				"state":      "closed",
+				"created_at": "2016-01-01T00:00:00Z",
+				"updated_at": "2016-01-03T00:00:00Z",
+				"closed_at":  "2016-01-02T00:00:00Z",
+				"labels":     []map[string]string{{"name": "bug"}},
+			})
+		}
+		json.NewEncoder(w).Encode(issues)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := New([]string{u.Host})
+	c.Scheme = "http"
+	p := giteaPkgTest
+	p.Host = u.Host
+	result, err := c.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != PageSize+1 {
+		t.Fatalf("expected %d issues got: %d\n", PageSize+1, len(result.Issues))
+	}
+	issue := result.Issues[0]
+	closedAt := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	if issue.State != "closed" || issue.ClosedAt == nil || !issue.ClosedAt.Equal(closedAt) {
+		t.Errorf("expected closed issue got: %+v\n", issue)
+	}
+	if len(issue.Labels) != 1 || issue.Labels[0].Name != "bug" {
+		t.Errorf("unexpected labels: %+v\n", issue.Labels)
+	}
+}
+
+func TestGiteaFetchIssuesBudget(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every page is full
+		pages = append(pages, r.URL.Query().Get("page"))
+		issues := make([]map[string]interface{}, PageSize)
+		for i := range issues {
+			issues[i] = map[string]interface{}{"number": i + 1, "state": "open"}
+		}
+		json.NewEncoder(w).Encode(issues)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := New([]string{u.Host})
+	c.Scheme = "http"
+	p := giteaPkgTest
+	p.Host = u.Host
+	p.Budget = packagebug.Budget{Pages: 2}
+	p.Cursor = packagebug.Cursor{Page: 3}
+	result, err := c.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[0] != "3" || pages[1] != "4" {
+		t.Errorf("expected pages 3 and 4 got: %v\n", pages)
+	}
+	if result.Next == nil || *result.Next != (packagebug.Cursor{Page: 5}) {
+		t.Errorf("expected the sync continued at page 5 got: %+v\n", result.Next)
+	}
+	if len(result.Issues) != 2*PageSize {
+		t.Errorf("expected %d issues got: %d\n", 2*PageSize, len(result.Issues))
+	}
+}
+
+func TestGiteaRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := New([]string{u.Host})
+	c.Scheme = "http"
+	p := giteaPkgTest
+	p.Host = u.Host
+	_, err := c.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err == nil {
+		t.Fatal("expected rate limit error")
+	}
+	rate, reset, _ := c.RateLimit(context.Background(), u.Host)
+	if rate != 0 || reset == 0 {
+		t.Errorf("expected blocked got: %d %d\n", rate, reset)
+	}
+	if rate, _, _ := c.RateLimit(context.Background(), "codeberg.org"); rate != 1 {
+		t.Errorf("expected other host available got: %d\n", rate)
+	}
+}
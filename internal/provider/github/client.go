@@ -0,0 +1,200 @@
+// Package github fetches the issues of the packages hosted on GitHub.
+package github
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Token represents a credential used to access the GitHub API. The number
+// of concurrent requests using the token is bounded by its semaphore.
+type Token struct {
+	Value string
+	sem   chan struct{}
+	// host is the GitHub Enterprise Server of the token, empty for
+	// github.com
+	host string
+
+	mu    sync.Mutex
+	state packagebug.RateState
+	// next is the next request slot, see delay
+	next time.Time
+	// paused is the end of the Retry-After of the secondary rate limit
+	paused time.Time
+}
+
+// API sends the requests to the GitHub API.
+type API interface {
+	Do(req *http.Request) (*http.Response, error)
+	// Endpoint returns the root endpoint of the API.
+	Endpoint() string
+}
+
+// Client is the GitHub API client shared by all workers. Each token has its
+// own concurrent-request ceiling independent of the number of workers.
+type Client struct {
+	HTTP *http.Client
+	// Host is the GitHub Enterprise Server host served by the client, e.g.
+	// ghe.corp.example.com, empty for github.com
+	Host string
+	// Root is the root endpoint of the API
+	Root string
+	// ClientId & ClientSecret of OAuth app, used for requests without token.
+	// They are sent as the basic auth of the request, never in the url that
+	// ends up in the traces and the cache keys.
+	ClientId     string
+	ClientSecret string
+	// GraphQL fetches the issues with the GraphQL API instead of REST, see
+	// FetchIssuesGraphQL. It requires tokens.
+	GraphQL bool
+	// Cache is optional, the pages of the incremental syncs are
+	// conditional on their cached responses
+	Cache packagebug.ResponseCache
+
+	tokens []*Token
+	next   uint32
+
+	mu sync.Mutex
+	// rateEtag is the etag of the last rate limit response
+	rateEtag string
+}
+
+// NewClient creates a client using tokens, each allowed to have at most
+// limit concurrent requests. If no token given, the requests are sent
+// without token (e.g. using client_id & client_secret).
+func NewClient(tokens []string, limit int) *Client {
+	if limit < 1 {
+		limit = 1
+	}
+	if len(tokens) == 0 {
+		tokens = []string{""}
+	}
+	c := &Client{HTTP: &http.Client{}, Root: "https://api.github.com"}
+	for _, t := range tokens {
+		c.tokens = append(c.tokens, &Token{
+			Value: t,
+			sem:   make(chan struct{}, limit),
+		})
+	}
+	return c
+}
+
+// NewHostClient creates the client of the GitHub Enterprise Server host
+// with the API root, e.g. https://ghe.corp.example.com/api/v3, see
+// NewClient.
+func NewHostClient(host, root string, tokens []string, limit int) *Client {
+	c := NewClient(tokens, limit)
+	c.Host = host
+	c.Root = root
+	for _, t := range c.tokens {
+		t.host = host
+	}
+	return c
+}
+
+// Endpoint implements API.
+func (c *Client) Endpoint() string {
+	return c.Root
+}
+
+// responseCache implements cacher.
+func (c *Client) responseCache() packagebug.ResponseCache {
+	return c.Cache
+}
+
+// token returns the token with the most remaining requests, not counting
+// the requests in flight. The tokens with unknown state or passed reset are
+// considered full, the ties are broken in round-robin order. The exhausted
+// and paused tokens are parked until their reset; if every token is parked,
+// the one that resets first is returned and its throttle waits for the
+// reset.
+func (c *Client) token() *Token {
+	now := time.Now()
+	n := int(atomic.AddUint32(&c.next, 1) - 1)
+	var best, parked *Token
+	bestRemaining := 0
+	var parkedReset time.Time
+	for i := range c.tokens {
+		t := c.tokens[(n+i)%len(c.tokens)]
+		if paused := t.pausedUntil(); now.Before(paused) {
+			if parked == nil || paused.Before(parkedReset) {
+				parked, parkedReset = t, paused
+			}
+			continue
+		}
+		s := t.State()
+		remaining := math.MaxInt
+		if s.Known && now.Before(s.Reset) {
+			if s.Remaining <= 0 {
+				if parked == nil || s.Reset.Before(parkedReset) {
+					parked, parkedReset = t, s.Reset
+				}
+				continue
+			}
+			remaining = s.Remaining
+		}
+		remaining -= len(t.sem)
+		if best == nil || remaining > bestRemaining {
+			best, bestRemaining = t, remaining
+		}
+	}
+	if best == nil {
+		return parked
+	}
+	return best
+}
+
+// Do sends the request using the token with the most remaining requests, see
+// token. It blocks until the request is allowed by the throttle of the token
+// and while the token already has limit requests in flight. The slot is
+// released when the response body is closed. The request that already has
+// its credentials, e.g. the private token of the package, is sent as is: its
+// quota isn't the quota of the tokens.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return c.HTTP.Do(req)
+	}
+	t := c.token()
+	err := t.throttle(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	t.sem <- struct{}{}
+	if t.Value != "" {
+		req.Header.Set("Authorization", "token "+t.Value)
+	} else if c.ClientId != "" {
+		req.SetBasicAuth(c.ClientId, c.ClientSecret)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		<-t.sem
+		return nil, err
+	}
+	t.update(resp.Header)
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if d := packagebug.ParseRetryAfter(resp.Header, time.Now()); d > 0 {
+			t.pause(time.Now().Add(d))
+		}
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, sem: t.sem}
+	return resp, nil
+}
+
+// releaseBody releases the token slot once the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	sem  chan struct{}
+	once sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { <-b.sem })
+	return err
+}
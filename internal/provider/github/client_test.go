@@ -0,0 +1,118 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestClientTokenConcurrency(t *testing.T) {
+	var inflight, max int32
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		mu.Lock()
+		if n > max {
+			max = n
+		}
+		seen[r.Header.Get("Authorization")] = true
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inflight, -1)
+	}))
+	defer ts.Close()
+
+	// two tokens with one slot each: at most 2 requests in flight
+	c := NewClient([]string{"a", "b"}, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", ts.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Errorf("expected at most 2 concurrent requests got: %d\n", max)
+	}
+	if !seen["token a"] || !seen["token b"] {
+		t.Errorf("expected both tokens used got: %v\n", seen)
+	}
+}
+
+func TestClientTokenMostRemaining(t *testing.T) {
+	c := NewClient([]string{"a", "b", "c"}, 1)
+	now := time.Now()
+	reset := now.Add(time.Hour)
+	c.tokens[0].setState(packagebug.RateState{Remaining: 10, Reset: reset, Known: true})
+	c.tokens[1].setState(packagebug.RateState{Remaining: 100, Reset: reset, Known: true})
+	c.tokens[2].setState(packagebug.RateState{Remaining: 0, Reset: now.Add(time.Minute), Known: true})
+	for i := 0; i < 3; i++ {
+		if tok := c.token(); tok.Value != "b" {
+			t.Errorf("expected token with most remaining got: %s\n", tok.Value)
+		}
+	}
+
+	// the exhausted token is parked until its reset
+	c.tokens[1].setState(packagebug.RateState{Remaining: 0, Reset: reset, Known: true})
+	if tok := c.token(); tok.Value != "a" {
+		t.Errorf("expected token a got: %s\n", tok.Value)
+	}
+
+	// every token is exhausted, the earliest reset wins
+	c.tokens[0].setState(packagebug.RateState{Remaining: 0, Reset: reset, Known: true})
+	if tok := c.token(); tok.Value != "c" {
+		t.Errorf("expected token of earliest reset got: %s\n", tok.Value)
+	}
+
+	// the token with passed reset is full again
+	c.tokens[0].setState(packagebug.RateState{Remaining: 0, Reset: now.Add(-time.Second), Known: true})
+	if tok := c.token(); tok.Value != "a" {
+		t.Errorf("expected token of passed reset got: %s\n", tok.Value)
+	}
+}
+
+func TestClientOwnCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "1")
+		w.Header().Set("X-RateLimit-Reset", "1500000000")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	// the quota of the private token of the package is not the quota of
+	// the pool tokens
+	c := NewClient([]string{"a"}, 1)
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Authorization", "token private")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	tok := c.tokens[0]
+	if s := tok.State(); s.Known {
+		t.Errorf("expected the state of the pool token unknown got: %+v\n", s)
+	}
+	if !tok.pausedUntil().IsZero() {
+		t.Errorf("expected the pool token not paused got: %s\n", tok.pausedUntil())
+	}
+	if len(tok.sem) != 0 {
+		t.Errorf("expected no slot of the pool token taken got: %d\n", len(tok.sem))
+	}
+}
@@ -0,0 +1,135 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// FetchDetails fetches comments and events of every issue in the result if
+// enabled in capabilities. The events are read from the timeline of the
+// issue: only the timeline events are kept, the bug timeline of the issue is
+// set from them, see packagebug.Issue.BugTimeline, and the cross-references
+// are the links of the issue. The "Duplicate of #N" comments link the issue
+// to the canonical one.
+func FetchDetails(ctx context.Context, api API, r *packagebug.Result, caps packagebug.Capability) error {
+	isBug := func(label string) bool { return IsBugLabel(r.Package, label) }
+	repo := r.Package.Source().Repository()
+	for i := range r.Issues {
+		issue := &r.Issues[i]
+		if caps.Has(packagebug.CapComments) && issue.ApiCommentsUrl != "" {
+			err := getJSON(ctx, api, issue.ApiCommentsUrl, r.Package.Token, &issue.Comments)
+			if err != nil {
+				return fmt.Errorf("comments of #%d: %w", issue.Number, err)
+			}
+			issue.DuplicateLinks(repo.Path())
+		}
+		if caps.Has(packagebug.CapEvents) && issue.ApiEventsUrl != "" {
+			err := getTimeline(ctx, api, TimelineUrl(issue.ApiEventsUrl),
+				r.Package.Token, repo, issue)
+			if err != nil {
+				return fmt.Errorf("events of #%d: %w", issue.Number, err)
+			}
+			issue.BugTimeline(isBug)
+		}
+	}
+	return nil
+}
+
+// TimelineUrl returns the timeline url of the issue from its events url, the
+// timeline has the cross-references the events don't.
+func TimelineUrl(eventsUrl string) string {
+	return strings.TrimSuffix(eventsUrl, "/events") + "/timeline"
+}
+
+// timelineItem is the item of the issue timeline, the event or the
+// cross-reference of the issue from another issue or pull request.
+type timelineItem struct {
+	packagebug.Event
+	Source struct {
+		Issue *struct {
+			Number     int `json:"number"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		} `json:"issue"`
+	} `json:"source"`
+}
+
+// getTimeline fetches every page of the timeline of urls, it sets the
+// timeline events of the issue, see packagebug.Event.Timeline, and adds its
+// cross-referenced links. repo is the repository of the issue.
+func getTimeline(ctx context.Context, api API, urls, token string, repo packagebug.Package, issue *packagebug.Issue) error {
+	u, err := url.Parse(urls)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("per_page", "100")
+	u.RawQuery = query.Encode()
+
+	issue.Events = nil
+	for next := u.String(); next != ""; {
+		var page []timelineItem
+		next, err = getJSONPage(ctx, api, next, token, &page)
+		if err != nil {
+			return err
+		}
+		for _, item := range page {
+			if item.Timeline() {
+				issue.Events = append(issue.Events, item.Event)
+			}
+			source := item.Source.Issue
+			if item.Event.Event != "cross-referenced" || source == nil {
+				continue
+			}
+			issue.AddLink(repo.Path(), packagebug.IssueLink{
+				Kind:      packagebug.LinkReference,
+				Path:      repo.Host + "/" + source.Repository.FullName,
+				Number:    source.Number,
+				CreatedAt: item.CreatedAt,
+			})
+		}
+	}
+	return nil
+}
+
+// getJSON decodes the GitHub API response of urls to v. The private token is
+// used if not empty.
+func getJSON(ctx context.Context, api API, urls, token string, v interface{}) error {
+	_, err := getJSONPage(ctx, api, urls, token, v)
+	return err
+}
+
+// getJSONPage decodes the page of urls to v like getJSON and returns the
+// url of the next page, empty if it is the last page.
+func getJSONPage(ctx context.Context, api API, urls, token string, v interface{}) (string, error) {
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", packagebug.NewStatusError(resp)
+	}
+	err = packagebug.DecodeError(json.NewDecoder(resp.Body).Decode(v))
+	if err != nil {
+		return "", err
+	}
+	return NextPage(resp.Header), nil
+}
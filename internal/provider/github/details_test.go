@@ -0,0 +1,74 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestFetchDetailsEvents(t *testing.T) {
+	pages := map[string]string{
+		"1": `[{"id": 1, "event": "labeled", "created_at": "2016-01-01T00:00:00Z", "label": {"name": "bug"}},
+			{"id": 2, "event": "mentioned", "created_at": "2016-01-02T00:00:00Z"}]`,
+		"2": `[{"id": 3, "event": "unlabeled", "created_at": "2016-01-03T00:00:00Z", "label": {"name": "bug"}},
+			{"id": 4, "event": "closed", "created_at": "2016-01-04T00:00:00Z"},
+			{"event": "cross-referenced", "created_at": "2016-01-05T00:00:00Z",
+				"source": {"type": "issue", "issue": {"number": 7, "repository": {"full_name": "pyk/other"}}}}]`,
+	}
+	client := &fake.Client{
+		Root: "http://github.test",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			page := r.URL.Query().Get("page")
+			if page == "" {
+				page = "1"
+				w.Header().Set("Link", `<http://github.test/repos/pyk/byten/issues/1/events?page=2>; rel="next"`)
+			}
+			fmt.Fprint(w, pages[page])
+		}),
+	}
+	r := &packagebug.Result{Package: fake.Package, Issues: []packagebug.Issue{{
+		Number:       1,
+		ApiEventsUrl: "http://github.test/repos/pyk/byten/issues/1/events",
+	}}}
+	err := FetchDetails(context.Background(), client, r, packagebug.CapEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := r.Issues[0]
+	if len(issue.Events) != 3 {
+		t.Fatalf("expected 3 timeline events got: %+v\n", issue.Events)
+	}
+	unlabeled := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	if issue.BugLabeledAt == nil || issue.BugUnlabeledAt == nil || !issue.BugUnlabeledAt.Equal(unlabeled) {
+		t.Errorf("unexpected bug timeline: %v %v\n", issue.BugLabeledAt, issue.BugUnlabeledAt)
+	}
+	if len(issue.Links) != 1 || issue.Links[0].Path != "github.com/pyk/other" || issue.Links[0].Number != 7 {
+		t.Errorf("expected the cross-reference of pyk/other#7 got: %+v\n", issue.Links)
+	}
+}
+
+func TestFetchDetailsDuplicate(t *testing.T) {
+	client := &fake.Client{
+		Root: "http://github.test",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[{"id": 1, "body": "Duplicate of #3", "created_at": "2016-01-01T00:00:00Z"}]`)
+		}),
+	}
+	r := &packagebug.Result{Package: fake.Package, Issues: []packagebug.Issue{{
+		Number:         1,
+		ApiCommentsUrl: "http://github.test/repos/pyk/byten/issues/1/comments",
+	}}}
+	err := FetchDetails(context.Background(), client, r, packagebug.CapComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := r.Issues[0].Links
+	if len(links) != 1 || links[0].Kind != packagebug.LinkDuplicate || links[0].Path != "github.com/pyk/byten" || links[0].Number != 3 {
+		t.Errorf("expected the duplicate of #3 got: %+v\n", links)
+	}
+}
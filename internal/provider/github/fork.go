@@ -0,0 +1,29 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// FetchForkParent returns the path of the parent repository from the GitHub
+// repository metadata, or empty string if the repository is not a fork.
+func FetchForkParent(ctx context.Context, api API, p packagebug.Package) (string, error) {
+	var repo struct {
+		Fork   bool `json:"fork"`
+		Parent struct {
+			FullName string `json:"full_name"`
+		} `json:"parent"`
+	}
+	root := api.Endpoint()
+	urls := fmt.Sprintf("%s/repos/%s/%s", root, p.Owner, p.Repo)
+	err := getJSON(ctx, api, urls, p.Token, &repo)
+	if err != nil {
+		return "", err
+	}
+	if !repo.Fork || repo.Parent.FullName == "" {
+		return "", nil
+	}
+	return p.Host + "/" + repo.Parent.FullName, nil
+}
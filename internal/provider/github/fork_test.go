@@ -0,0 +1,42 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestFetchForkParent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/pyk/byten" {
+			fmt.Fprint(w, `{"fork":true,"parent":{"full_name":"upstream/byten"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"fork":false}`)
+	}))
+	defer ts.Close()
+
+	client := NewClient(nil, 1)
+	client.Root = ts.URL
+	parent, err := FetchForkParent(context.Background(), client, fake.Package)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != "github.com/upstream/byten" {
+		t.Errorf("expected github.com/upstream/byten got: %s\n", parent)
+	}
+
+	p := fake.Package
+	p.Repo = "other"
+	parent, err = FetchForkParent(context.Background(), client, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != "" {
+		t.Errorf("expected no parent got: %s\n", parent)
+	}
+}
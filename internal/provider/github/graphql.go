@@ -0,0 +1,299 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// issuesQuery fetches a page of the issues with their labels, author,
+// assignees, milestone and reaction counts.
+const issuesQuery = `
+query($owner: String!, $repo: String!, $labels: [String!], $states: [IssueState!], $since: DateTime, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    nameWithOwner
+    issues(first: 100, after: $cursor, labels: $labels, states: $states, filterBy: {since: $since},
+        orderBy: {field: UPDATED_AT, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        databaseId number title state url body createdAt updatedAt closedAt
+        author { login avatarUrl url ... on User { databaseId } }
+        labels(first: 100) { nodes { name } }
+        assignees(first: 10) { nodes { login } }
+        milestone { number title state dueOn }
+        reactions { totalCount }
+        plusOne: reactions(content: THUMBS_UP) { totalCount }
+        minusOne: reactions(content: THUMBS_DOWN) { totalCount }
+      }
+    }
+  }
+}`
+
+// graphqlPageSize is the number of issues of a full page of issuesQuery.
+const graphqlPageSize = 100
+
+// GraphQLUrl returns the GraphQL endpoint of the API root, e.g.
+// https://api.github.com/graphql. The GitHub Enterprise Server serves it
+// next to the REST API, e.g. https://ghe.corp.example.com/api/graphql.
+func GraphQLUrl(root string) string {
+	root = strings.TrimSuffix(root, "/")
+	if strings.HasSuffix(root, "/api/v3") {
+		return strings.TrimSuffix(root, "/v3") + "/graphql"
+	}
+	return root + "/graphql"
+}
+
+// count is the totalCount of a connection.
+type count struct {
+	TotalCount int `json:"totalCount"`
+}
+
+// graphqlIssue is the issue node of issuesQuery.
+type graphqlIssue struct {
+	DatabaseId int64      `json:"databaseId"`
+	Number     int        `json:"number"`
+	Title      string     `json:"title"`
+	State      string     `json:"state"`
+	Url        string     `json:"url"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ClosedAt   *time.Time `json:"closedAt"`
+	Author     *struct {
+		Login      string `json:"login"`
+		AvatarUrl  string `json:"avatarUrl"`
+		Url        string `json:"url"`
+		DatabaseId int64  `json:"databaseId"`
+	} `json:"author"`
+	Labels struct {
+		Nodes []packagebug.Label `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []struct {
+			Login string `json:"login"`
+		} `json:"nodes"`
+	} `json:"assignees"`
+	Milestone *struct {
+		Number int        `json:"number"`
+		Title  string     `json:"title"`
+		State  string     `json:"state"`
+		DueOn  *time.Time `json:"dueOn"`
+	} `json:"milestone"`
+	Reactions count `json:"reactions"`
+	PlusOne   count `json:"plusOne"`
+	MinusOne  count `json:"minusOne"`
+}
+
+// issuesApiUrl returns the REST API url of the issues of the repository of
+// src, formatted once per fetch instead of once per issue.
+func issuesApiUrl(root string, src packagebug.Package) string {
+	return root + "/repos/" + src.Owner + "/" + src.Repo + "/issues"
+}
+
+// Issue maps the node to the issue of the REST API, the API urls are set so
+// the comments and events are fetched the same way. issuesUrl is the API url
+// of the issues of the repository, see issuesApiUrl.
+func (g graphqlIssue) Issue(issuesUrl string) packagebug.Issue {
+	apiUrl := issuesUrl + "/" + strconv.Itoa(g.Number)
+	issue := packagebug.Issue{
+		ApiUrl:         apiUrl,
+		ApiLabelsUrl:   apiUrl + "/labels{/name}",
+		ApiCommentsUrl: apiUrl + "/comments",
+		ApiEventsUrl:   apiUrl + "/events",
+		Url:            g.Url,
+		GithubId:       g.DatabaseId,
+		Number:         g.Number,
+		Title:          g.Title,
+		State:          strings.ToLower(g.State),
+		CreatedAt:      g.CreatedAt,
+		UpdatedAt:      g.UpdatedAt,
+		ClosedAt:       g.ClosedAt,
+		Labels:         g.Labels.Nodes,
+		Body:           g.Body,
+		Reactions: packagebug.Reactions{
+			Total:    g.Reactions.TotalCount,
+			PlusOne:  g.PlusOne.TotalCount,
+			MinusOne: g.MinusOne.TotalCount,
+		},
+	}
+	// the author of the deleted account is null
+	if g.Author != nil {
+		issue.User = packagebug.IssueCreator{
+			Username:   g.Author.Login,
+			GithubId:   g.Author.DatabaseId,
+			AvatarUrl:  g.Author.AvatarUrl,
+			ProfileUrl: g.Author.Url,
+		}
+	}
+	for _, a := range g.Assignees.Nodes {
+		issue.Assignees = append(issue.Assignees, packagebug.IssueCreator{Username: a.Login})
+	}
+	if g.Milestone != nil {
+		issue.Milestone = &packagebug.Milestone{
+			Number: g.Milestone.Number,
+			Title:  g.Milestone.Title,
+			State:  strings.ToLower(g.Milestone.State),
+			DueOn:  g.Milestone.DueOn,
+		}
+	}
+	return issue
+}
+
+// graphqlResponse is the response of issuesQuery.
+type graphqlResponse struct {
+	Data struct {
+		Repository *struct {
+			NameWithOwner string `json:"nameWithOwner"`
+			Issues        struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []graphqlIssue `json:"nodes"`
+			} `json:"issues"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// FetchIssuesGraphQL fetches bugs of the package updated after since with a
+// single GraphQL query per page instead of the REST issue list. The label
+// filter of GraphQL matches any of the labels, so every label of the label
+// queries is requested at once and the issues are matched with IsBug. The
+// state filter of the query applies but not its sort order, the issues are
+// always in the order of update. The GraphQL API has no conditional requests
+// and no pull requests in the issues, the result is never nil and its etag
+// is empty. The fetch starts after the end cursor of the package cursor and
+// stops once the budget of the package is spent, Next of the result is where
+// the sync continues then. The repository that moved is resolved by its old
+// name, MovedError is returned then.
+func FetchIssuesGraphQL(ctx context.Context, api API, p packagebug.Package, since time.Time) (*packagebug.Result, error) {
+	logger := packagebug.Logger(ctx)
+	root := api.Endpoint()
+	src := p.Source()
+	var labels []string
+	for _, q := range LabelQueries(p) {
+		labels = append(labels, q...)
+	}
+	variables := map[string]interface{}{
+		"owner":  src.Owner,
+		"repo":   src.Repo,
+		"labels": labels,
+	}
+	if !since.IsZero() {
+		variables["since"] = since.UTC().Format(time.RFC3339)
+	}
+	if !p.Query.Complete() {
+		variables["states"] = []string{strings.ToUpper(p.Query.IssueState())}
+	}
+
+	start := 1
+	if p.Cursor.After != "" {
+		variables["cursor"] = p.Cursor.After
+		start = p.Cursor.Page
+	}
+
+	result := &packagebug.Result{Package: p, Issues: []packagebug.Issue{}}
+	issuesUrl := issuesApiUrl(root, src)
+	fetched := 0
+	for n := start; ; n++ {
+		if p.Budget.PagesLeft(n-start, fetched, graphqlPageSize) == 0 {
+			after, _ := variables["cursor"].(string)
+			result.Next = &packagebug.Cursor{Page: n, After: after}
+			break
+		}
+		resp, err := queryGraphQL(ctx, api, p, issuesQuery, variables)
+		if err != nil {
+			return nil, err
+		}
+		repo := resp.Data.Repository
+		if repo == nil {
+			return nil, packagebug.ErrNotFound
+		}
+		if err := movedTo(src, repo.NameWithOwner); err != nil {
+			return nil, err
+		}
+		logger.Debug("page fetched", "page", n, "issues", len(repo.Issues.Nodes))
+		fetched += len(repo.Issues.Nodes)
+		for _, node := range repo.Issues.Nodes {
+			issue := node.Issue(issuesUrl)
+			if IsBug(p, issue) {
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+		if !repo.Issues.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = repo.Issues.PageInfo.EndCursor
+	}
+
+	result.Project(p.Capabilities)
+	err := FetchDetails(ctx, api, result, p.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// queryGraphQL sends the query with variables. The private token of the
+// package is used if set. The errors of the response are returned as error,
+// except NOT_FOUND of the repository that is reported as nil repository.
+func queryGraphQL(ctx context.Context, api API, p packagebug.Package, query string, variables map[string]interface{}) (*graphqlResponse, error) {
+	logger := packagebug.Logger(ctx)
+	root := api.Endpoint()
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", GraphQLUrl(root), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "token "+p.Token)
+	}
+
+	start := time.Now()
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	logger.Info("fetch", "status_code", resp.StatusCode,
+		"duration", time.Since(start))
+	packagebug.RecordPage(ctx, resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, packagebug.NewStatusError(resp)
+	}
+	var gr graphqlResponse
+	err = json.NewDecoder(resp.Body).Decode(&gr)
+	if err != nil {
+		return nil, packagebug.DecodeError(err)
+	}
+	for _, e := range gr.Errors {
+		switch e.Type {
+		case "NOT_FOUND":
+		case "RATE_LIMITED":
+			return nil, fmt.Errorf("graphql: %s: %w", e.Message, packagebug.ErrRateLimited)
+		default:
+			return nil, fmt.Errorf("graphql: %s", e.Message)
+		}
+	}
+	return &gr, nil
+}
@@ -0,0 +1,148 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+const graphqlPageTest = `{"data": {"repository": {"issues": {
+	"pageInfo": {"hasNextPage": %t, "endCursor": "c%d"},
+	"nodes": [{
+		"databaseId": %d, "number": %d, "title": "crash", "state": "OPEN",
+		"url": "https://github.com/pyk/byten/issues/%d",
+		"updatedAt": "2016-01-0%dT00:00:00Z",
+		"author": {"login": "pyk", "databaseId": 1},
+		"labels": {"nodes": [{"name": "%s"}]},
+		"assignees": {"nodes": [{"login": "octocat"}]},
+		"milestone": {"number": 1, "title": "v1.0", "state": "OPEN"},
+		"reactions": {"totalCount": 3}, "plusOne": {"totalCount": 2},
+		"minusOne": {"totalCount": 1}
+	}]
+}}}}`
+
+func TestFetchIssuesGraphQL(t *testing.T) {
+	var variables []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql" {
+			t.Errorf("unexpected request %s %s\n", r.Method, r.URL)
+		}
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		variables = append(variables, body.Variables)
+		// the second page has an issue of other label, filtered by IsBug
+		if body.Variables["cursor"] == nil {
+			fmt.Fprintf(w, graphqlPageTest, true, 1, 10, 1, 1, 1, "bug")
+		} else {
+			fmt.Fprintf(w, graphqlPageTest, false, 2, 20, 2, 2, 2, "question")
+		}
+	}))
+	defer ts.Close()
+	client := NewClient([]string{"token"}, 1)
+	client.Root = ts.URL
+	client.GraphQL = true
+
+	p := fake.Package
+	p.BugLabels = []string{"kind/bug"}
+	p.Capabilities = packagebug.CapBodies | packagebug.CapReactions
+	since := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := client.FetchIssues(context.Background(), p, since, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variables) != 2 || variables[1]["cursor"] != "c1" {
+		t.Fatalf("expected 2 pages got: %v\n", variables)
+	}
+	if variables[0]["since"] != "2016-01-01T00:00:00Z" || fmt.Sprint(variables[0]["labels"]) != "[bug kind/bug]" {
+		t.Errorf("unexpected variables: %v\n", variables[0])
+	}
+	if len(result.Issues) != 1 {
+		t.Fatalf("expected 1 bug got: %+v\n", result.Issues)
+	}
+	issue := result.Issues[0]
+	if issue.Number != 1 || issue.GithubId != 10 || issue.State != "open" || issue.User.Username != "pyk" {
+		t.Errorf("unexpected issue: %+v\n", issue)
+	}
+	if issue.Reactions.Total != 3 || issue.Reactions.PlusOne != 2 || issue.Reactions.MinusOne != 1 {
+		t.Errorf("unexpected reactions: %+v\n", issue.Reactions)
+	}
+	if len(issue.Assignees) != 1 || issue.Milestone == nil || issue.Milestone.Title != "v1.0" {
+		t.Errorf("expected assignee and milestone got: %+v\n", issue)
+	}
+	if issue.ApiCommentsUrl != ts.URL+"/repos/pyk/byten/issues/1/comments" {
+		t.Errorf("unexpected comments url: %s\n", issue.ApiCommentsUrl)
+	}
+}
+
+func TestFetchIssuesGraphQLBudget(t *testing.T) {
+	var cursors []interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		cursors = append(cursors, body.Variables["cursor"])
+		n := len(cursors)
+		fmt.Fprintf(w, graphqlPageTest, true, n, n, n, n, n, "bug")
+	}))
+	defer ts.Close()
+	client := NewClient([]string{"token"}, 1)
+	client.Root = ts.URL
+
+	p := fake.Package
+	p.Budget = packagebug.Budget{Pages: 2}
+	result, err := FetchIssuesGraphQL(context.Background(), client, p, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 2 || len(result.Issues) != 2 {
+		t.Fatalf("expected 2 pages got: %v %d issues\n", cursors, len(result.Issues))
+	}
+	if result.Next == nil || result.Next.Page != 3 || result.Next.After != "c2" {
+		t.Fatalf("expected continuation after c2 got: %+v\n", result.Next)
+	}
+
+	// the continuation starts after the end cursor
+	cursors = nil
+	p.Cursor = *result.Next
+	result, err = FetchIssuesGraphQL(context.Background(), client, p, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 2 || cursors[0] != "c2" || cursors[1] != "c1" {
+		t.Errorf("expected pages after c2 got: %v\n", cursors)
+	}
+	if result.Next == nil || result.Next.Page != 5 {
+		t.Errorf("expected continuation at page 5 got: %+v\n", result.Next)
+	}
+}
+
+func TestFetchIssuesGraphQLErrors(t *testing.T) {
+	response := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, response)
+	}))
+	defer ts.Close()
+	client := NewClient([]string{"token"}, 1)
+	client.Root = ts.URL
+
+	response = `{"data": {"repository": null}, "errors": [{"type": "NOT_FOUND", "message": "not found"}]}`
+	_, err := FetchIssuesGraphQL(context.Background(), client, fake.Package, time.Time{})
+	if packagebug.ErrorClass(err) != "not_found" {
+		t.Errorf("expected not found got: %v\n", err)
+	}
+	response = `{"errors": [{"type": "RATE_LIMITED", "message": "exceeded"}]}`
+	_, err = FetchIssuesGraphQL(context.Background(), client, fake.Package, time.Time{})
+	if packagebug.ErrorClass(err) != "rate_limited" {
+		t.Errorf("expected rate limited got: %v\n", err)
+	}
+}
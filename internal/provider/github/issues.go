@@ -0,0 +1,527 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BugUrl returns the url where the bugs of the package is fetched from. Only
+// issues updated after p.Since are fetched if set.
+func BugUrl(p packagebug.Package, root string) string {
+	src := p.Source()
+	query := url.Values{}
+	query.Add("state", p.Query.IssueState())
+	labels := p.Query.BugLabels()[0]
+	if len(p.Labels) > 0 {
+		labels = strings.Join(p.Labels, ",")
+	}
+	query.Add("labels", labels)
+	if !p.Since.IsZero() {
+		query.Add("since", p.Since.UTC().Format(time.RFC3339))
+	}
+	if p.Query.Sort != "" {
+		query.Add("sort", p.Query.Sort)
+	}
+	if p.Query.Direction != "" {
+		query.Add("direction", p.Query.Direction)
+	}
+	return fmt.Sprintf("%s/repos/%s/%s/issues?%s", root,
+		src.Owner, src.Repo, query.Encode())
+}
+
+// LabelQueries returns the labels of every issue list query of the package.
+// The custom labels of the owner are a single query. Otherwise the bug
+// labels of the query and each of the aliases are queried separately, since
+// an issue must have all labels of a query.
+func LabelQueries(p packagebug.Package) [][]string {
+	if len(p.Labels) > 0 {
+		return [][]string{p.Labels}
+	}
+	var queries [][]string
+	seen := make(map[string]bool)
+	for _, l := range append(p.Query.BugLabels(), p.BugLabels...) {
+		if !seen[strings.ToLower(l)] {
+			seen[strings.ToLower(l)] = true
+			queries = append(queries, []string{l})
+		}
+	}
+	return queries
+}
+
+// IsBugLabel returns true if the label is one of the labels of the label
+// queries of the package, see LabelQueries.
+func IsBugLabel(p packagebug.Package, label string) bool {
+	for _, labels := range LabelQueries(p) {
+		for _, l := range labels {
+			if strings.EqualFold(l, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBug returns true if the issue has every label of any label query of the
+// package, see LabelQueries.
+func IsBug(p packagebug.Package, issue packagebug.Issue) bool {
+	for _, labels := range LabelQueries(p) {
+		match := true
+		for _, l := range labels {
+			match = match && hasLabel(issue, l)
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLabel returns true if the issue has the label, case insensitive like
+// the GitHub label filter.
+func hasLabel(issue packagebug.Issue, name string) bool {
+	for _, l := range issue.Labels {
+		if strings.EqualFold(l.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchIssues fetch bugs of the package updated after since from the GitHub
+// API. Every label query of the package is fetched and the issues are merged.
+// The requests are conditional if etag is not empty, it returns nil result if
+// the bugs is not modified since the etag. The fetch starts at the cursor of
+// the package and stops once the budget of the package is spent, Next of the
+// result is where the sync continues then. The lists are ordered by creation,
+// the issues created meanwhile only shift the remaining pages.
+func FetchIssues(ctx context.Context, api API, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	p.Since = since
+	// the etag of the result holds the etag of every label query
+	queries := LabelQueries(p)
+	etags := strings.Split(etag, "\n")
+	if len(etags) != len(queries) || !p.Cursor.IsZero() {
+		etags = make([]string, len(queries))
+	}
+	result := &packagebug.Result{Package: p}
+	seen := make(map[int]bool)
+	modified := false
+	pages, total := 0, 0
+	for i := p.Cursor.Query; i < len(queries); i++ {
+		start := 1
+		if i == p.Cursor.Query && p.Cursor.Page > 1 {
+			start = p.Cursor.Page
+		}
+		left := p.Budget.PagesLeft(pages, total, 0)
+		if left == 0 {
+			result.Next = &packagebug.Cursor{Query: i, Page: start}
+			break
+		}
+		q := p
+		q.Labels = queries[i]
+		q.Budget = p.Budget.Left(pages, total)
+		l, err := fetchLabels(ctx, api, q, etags[i], start)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil {
+			continue
+		}
+		modified = true
+		// the etag is of the first page only
+		etags[i] = ""
+		if start == 1 {
+			etags[i] = l.Etag
+		}
+		pages += l.Pages
+		total += len(l.Issues)
+		result.Responses = append(result.Responses, l.Responses...)
+		// the issues of the first list are deduplicated in place, the
+		// issues of the other lists are appended to them
+		if result.Issues == nil {
+			result.Issues = l.Issues[:0]
+		} else {
+			result.Issues = slices.Grow(result.Issues, len(l.Issues))
+		}
+		for _, issue := range l.Issues {
+			if !seen[issue.Number] {
+				seen[issue.Number] = true
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+		if l.Next > 0 {
+			result.Next = &packagebug.Cursor{Query: i, Page: l.Next}
+			break
+		}
+	}
+	if !modified {
+		return nil, nil
+	}
+	if result.Next == nil {
+		result.Etag = strings.Join(etags, "\n")
+	}
+
+	result.Project(p.Capabilities)
+	err := FetchDetails(ctx, api, result, p.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PageParallelism is the maximum concurrent page requests of an issue list.
+const PageParallelism = 4
+
+// listing is the part of an issue list fetched by fetchLabels.
+type listing struct {
+	Issues []packagebug.Issue
+	// Etag is the etag of the first page fetched
+	Etag  string
+	Pages int
+	// Next is the page the list continues from, zero if the list is
+	// complete
+	Next int
+	// Responses of the pages to cache, see responseCache
+	Responses []packagebug.CachedResponse
+}
+
+// add appends the issues and the response to cache of the page.
+func (l *listing) add(pg *page) {
+	l.Issues = append(l.Issues, pg.Issues...)
+	if pg.Response != nil {
+		l.Responses = append(l.Responses, *pg.Response)
+	}
+}
+
+// cacher is implemented by the API with the response cache, see
+// Client.Cache.
+type cacher interface {
+	responseCache() packagebug.ResponseCache
+}
+
+// responseCache returns the response cache of api used by the incremental
+// sync of the package, nil if none. The full sync needs every issue, so its
+// pages are never conditional on the cache.
+func responseCache(api API, p packagebug.Package) packagebug.ResponseCache {
+	c, ok := api.(cacher)
+	if !ok || p.Since.IsZero() {
+		return nil
+	}
+	return c.responseCache()
+}
+
+// fetchLabels fetches the pages of the issue list of the package labels from
+// the page start, at most the pages the budget of the package allows. It
+// returns nil listing if the list is not modified since etag. The first page
+// links the last page, so the rest of the pages are fetched concurrently;
+// the pages are followed one by one if the last page is unknown. The pages
+// not modified since their cached response have no issues.
+func fetchLabels(ctx context.Context, api API, p packagebug.Package, etag string, start int) (*listing, error) {
+	u := BugUrl(p, api.Endpoint())
+	if start > 1 {
+		u = PageUrl(u, start)
+	}
+	// only the first page is conditional, the etag of the first page
+	// changes if any issue is updated
+	first, err := fetchPageSpan(ctx, api, p, start, u, etag)
+	if err != nil {
+		return nil, err
+	}
+	// the moved repository is redirected to its new location
+	if first.Moved {
+		err = moved(ctx, api, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if first.NotModified && !first.Cached {
+		return nil, nil
+	}
+	l := &listing{Etag: first.Etag, Pages: 1}
+	l.add(first)
+	// the issue budget is spread over the pages of the size of the first
+	left := func() int {
+		return p.Budget.PagesLeft(l.Pages, len(l.Issues), len(first.Issues))
+	}
+
+	if urls := PageUrls(first.Last); len(urls) >= start {
+		// the urls start at the second page
+		urls = urls[start-1:]
+		if n := left(); len(urls) > n {
+			urls = urls[:n]
+			l.Next = start + 1 + n
+		}
+		pages, err := fetchPages(ctx, api, p, start+1, urls)
+		if err != nil {
+			return nil, err
+		}
+		// the pages are the size of the first one but the last
+		l.Issues = slices.Grow(l.Issues, len(first.Issues)*len(pages))
+		for _, pg := range pages {
+			l.add(pg)
+		}
+		l.Pages += len(pages)
+		return l, nil
+	}
+
+	next := first.Next
+	for n := start + 1; next != ""; n++ {
+		if left() == 0 {
+			l.Next = n
+			break
+		}
+		page, err := fetchPageSpan(ctx, api, p, n, next, "")
+		if err != nil {
+			return nil, err
+		}
+		l.add(page)
+		l.Pages++
+		next = page.Next
+	}
+	return l, nil
+}
+
+// fetchPages fetches the pages of urls with at most PageParallelism
+// concurrent requests and returns them in the order of urls, the first url is
+// the page number first. The first error cancels the remaining requests.
+func fetchPages(ctx context.Context, api API, p packagebug.Package, first int, urls []string) ([]*page, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pages := make([]*page, len(urls))
+	errs := make([]error, len(urls))
+	sem := make(chan struct{}, PageParallelism)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		sem <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			<-sem
+			errs[i] = err
+			break
+		}
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// the panic of the page fails the fetch, not the worker
+			defer func() {
+				if v := recover(); v != nil {
+					packagebug.Logger(ctx).Error("fetch page panicked", "panic", v,
+						"stack", string(debug.Stack()))
+					errs[i] = packagebug.PanicError(v)
+					cancel()
+				}
+			}()
+			pages[i], errs[i] = fetchPageSpan(ctx, api, p, first+i, u, "")
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, u)
+	}
+	wg.Wait()
+	// the cancellation errors of the other pages hide the first error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// fetchPageSpan fetches the page number n of the issue list in its span.
+func fetchPageSpan(ctx context.Context, api API, p packagebug.Package, n int, urls, etag string) (*page, error) {
+	pctx, span := packagebug.StartSpan(ctx, "github.fetch_page",
+		attribute.Int("page", n),
+		attribute.String("labels", strings.Join(p.Labels, ",")))
+	pg, err := fetchPage(pctx, api, p, urls, etag)
+	packagebug.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	if !pg.NotModified {
+		packagebug.Logger(ctx).Debug("page fetched", "page", n,
+			"issues", len(pg.Issues), "labels", p.Labels)
+	}
+	return pg, nil
+}
+
+// page represents a page of the issue list.
+type page struct {
+	Issues []packagebug.Issue
+	Etag   string
+	// Next is the url of the next page, empty if it is the last page
+	Next string
+	// Last is the url of the last page, empty if it is the last page
+	Last        string
+	NotModified bool
+	// Cached is true if the page is not modified since its cached response
+	Cached bool
+	// Moved is true if the request was redirected to other repository
+	Moved bool
+	// Response is the response of the page to cache, nil without cache
+	Response *packagebug.CachedResponse
+}
+
+// fetchPage fetches a page of the issue list, the request is conditional if
+// etag is not empty, otherwise on the cached response of the page if any.
+func fetchPage(ctx context.Context, api API, p packagebug.Package, urls, etag string) (*page, error) {
+	logger := packagebug.Logger(ctx)
+	// setup request
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	// setup request header
+	req.Header.Add("User-Agent", "pyk")
+	if p.Capabilities.Has(packagebug.CapReactions) {
+		req.Header.Add("Accept", "application/vnd.github.squirrel-girl-preview+json")
+	} else {
+		req.Header.Add("Accept", "application/vnd.github.v3+json")
+	}
+	// use conditional request if possible
+	cache := responseCache(api, p)
+	key := packagebug.CacheKey(urls)
+	var cached packagebug.CachedResponse
+	hit := false
+	if etag == "" && cache != nil {
+		cached, hit, err = cache.GetResponse(ctx, key)
+		if err != nil {
+			logger.Warn("get cached response failed", "error", err)
+		}
+		etag = cached.Etag
+		if cached.LastModified != "" {
+			req.Header.Add("If-Modified-Since", cached.LastModified)
+		}
+	}
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	// use private token of verified package owner
+	if p.Token != "" {
+		req.Header.Set("Authorization", "token "+p.Token)
+	}
+
+	// do the request
+	start := time.Now()
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	logger.Info("fetch", "status_code", resp.StatusCode,
+		"duration", time.Since(start))
+	packagebug.RecordPage(ctx, resp.StatusCode)
+	// the HTTP client follows the redirect of the moved repository
+	moved := resp.Request != nil && resp.Request.URL.Path != req.URL.Path
+	switch resp.StatusCode {
+	case http.StatusOK:
+		pg := &page{
+			Etag:  resp.Header.Get("ETag"),
+			Next:  NextPage(resp.Header),
+			Last:  LastPage(resp.Header),
+			Moved: moved,
+		}
+		err = json.NewDecoder(resp.Body).Decode(&pg.Issues)
+		if err != nil {
+			return nil, packagebug.DecodeError(err)
+		}
+		if cache != nil {
+			pg.Response = &packagebug.CachedResponse{
+				Url:          key,
+				Etag:         pg.Etag,
+				LastModified: resp.Header.Get("Last-Modified"),
+				Link:         resp.Header.Get("Link"),
+			}
+		}
+		return pg, nil
+	case http.StatusNotModified:
+		if hit {
+			h := http.Header{"Link": {cached.Link}}
+			return &page{NotModified: true, Cached: true, Moved: moved,
+				Etag: cached.Etag, Next: NextPage(h), Last: LastPage(h)}, nil
+		}
+		return &page{NotModified: true, Moved: moved}, nil
+	default:
+		return nil, packagebug.NewStatusError(resp)
+	}
+}
+
+// NextPage returns the url of the next page from the Link header, empty if
+// there is no next page.
+func NextPage(h http.Header) string {
+	return link(h, "next")
+}
+
+// LastPage returns the url of the last page from the Link header, empty if
+// there is no last page.
+func LastPage(h http.Header) string {
+	return link(h, "last")
+}
+
+// link returns the url of the relation rel of the Link header.
+func link(h http.Header, rel string) string {
+	for _, link := range strings.Split(h.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="`+rel+`"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// PageUrl returns the url of the page n of the issue list url.
+func PageUrl(u string, n int) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	query := parsed.Query()
+	query.Set("page", strconv.Itoa(n))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// PageUrls returns the urls of the pages from the second page to the last
+// page of the url of the last page. It returns nil if last has no page
+// number.
+func PageUrls(last string) []string {
+	u, err := url.Parse(last)
+	if err != nil {
+		return nil
+	}
+	query := u.Query()
+	n, err := strconv.Atoi(query.Get("page"))
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for i := 2; i <= n; i++ {
+		query.Set("page", strconv.Itoa(i))
+		u.RawQuery = query.Encode()
+		urls = append(urls, u.String())
+	}
+	return urls
+}
@@ -0,0 +1,360 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func issuesTest(n int) []packagebug.Issue {
+	var issues []packagebug.Issue
+	for i := 1; i <= n; i++ {
+		issues = append(issues, packagebug.Issue{
+			Number:    i,
+			Title:     "crash",
+			UpdatedAt: time.Date(2016, 1, i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return issues
+}
+
+// newGitHubServer starts the server of the fixture and returns the client
+// that sends the requests to it.
+func newGitHubServer(t *testing.T, f *fake.GitHub) *Client {
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	client := NewClient(nil, 1)
+	client.Root = ts.URL
+	return client
+}
+
+func TestFetchIssuesPagination(t *testing.T) {
+	f := &fake.GitHub{Issues: issuesTest(5), PerPage: 2, Etag: `"v1"`}
+	client := newGitHubServer(t, f)
+
+	result, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 5 {
+		t.Fatalf("expected 5 issues got: %d\n", len(result.Issues))
+	}
+	if len(f.Requests()) != 3 {
+		t.Errorf("expected 3 requests got: %d\n", len(f.Requests()))
+	}
+	if result.Etag != `"v1"` {
+		t.Errorf("expected etag of the first page got: %s\n", result.Etag)
+	}
+}
+
+func TestFetchIssuesEtag(t *testing.T) {
+	f := &fake.GitHub{Issues: issuesTest(1), PerPage: 10, Etag: `"v1"`}
+	client := &fake.Client{Handler: f, Root: "http://github.test"}
+
+	result, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not modified since the last fetch
+	result, err = FetchIssues(context.Background(), client, fake.Package,
+		result.Since(), result.Etag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil {
+		t.Errorf("expected nil result got: %+v\n", result)
+	}
+	requests := f.Requests()
+	last := requests[len(requests)-1]
+	if last.Header.Get("If-None-Match") != `"v1"` {
+		t.Errorf("expected conditional request got: %v\n", last.Header)
+	}
+	if last.URL.Query().Get("since") == "" {
+		t.Error("expected incremental request")
+	}
+}
+
+func TestFetchIssuesLabelAliases(t *testing.T) {
+	issues := issuesTest(3)
+	issues[0].Labels = []packagebug.Label{{Name: "bug"}}
+	issues[1].Labels = []packagebug.Label{{Name: "kind/bug"}}
+	issues[2].Labels = []packagebug.Label{{Name: "bug"}, {Name: "Kind/Bug"}}
+	f := &fake.GitHub{Issues: issues, PerPage: 10, Etag: `"v1"`}
+	client := &fake.Client{Handler: f, Root: "http://github.test"}
+	p := fake.Package
+	p.BugLabels = []string{"kind/bug"}
+
+	result, err := FetchIssues(context.Background(), client, p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 3 {
+		t.Fatalf("expected 3 merged issues got: %d\n", len(result.Issues))
+	}
+	if result.Etag != "\"v1\"\n\"v1\"" {
+		t.Errorf("expected etag of every label query got: %q\n", result.Etag)
+	}
+
+	// not modified since the last fetch
+	result, err = FetchIssues(context.Background(), client, p, result.Since(),
+		result.Etag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil {
+		t.Errorf("expected nil result got: %+v\n", result)
+	}
+	if n := len(f.Requests()); n != 4 {
+		t.Errorf("expected 4 requests got: %d\n", n)
+	}
+}
+
+func TestLabelQueries(t *testing.T) {
+	p := fake.Package
+	p.BugLabels = []string{"Bug", "type: bug"}
+	queries := LabelQueries(p)
+	if len(queries) != 2 || queries[1][0] != "type: bug" {
+		t.Errorf("unexpected queries: %v\n", queries)
+	}
+	p.Labels = []string{"crash", "confirmed"}
+	queries = LabelQueries(p)
+	if len(queries) != 1 || len(queries[0]) != 2 {
+		t.Errorf("expected custom labels only got: %v\n", queries)
+	}
+	p.Labels = nil
+	p.Query.Labels = []string{"defect", "Bug"}
+	queries = LabelQueries(p)
+	if len(queries) != 3 || queries[0][0] != "defect" || queries[2][0] != "type: bug" {
+		t.Errorf("expected the labels of the query got: %v\n", queries)
+	}
+}
+
+func TestFetchIssuesError(t *testing.T) {
+	client := &fake.Client{
+		Handler: http.NotFoundHandler(),
+		Root:    "http://github.test",
+	}
+	_, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if _, ok := err.(*packagebug.StatusError); !ok {
+		t.Errorf("expected status error got: %v\n", err)
+	}
+}
+
+func TestBugUrl(t *testing.T) {
+	expected := "root/repos/pyk/byten/issues?labels=bug&state=all"
+	urls := BugUrl(fake.Package, "root")
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestBugUrlLabels(t *testing.T) {
+	p := fake.Package
+	p.Labels = []string{"kind/bug", "crash"}
+	expected := "root/repos/pyk/byten/issues?labels=kind%2Fbug%2Ccrash&state=all"
+	urls := BugUrl(p, "root")
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestBugUrlSince(t *testing.T) {
+	p := fake.Package
+	p.Since = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	expected := "root/repos/pyk/byten/issues?labels=bug&since=2016-01-02T03%3A04%3A05Z&state=all"
+	urls := BugUrl(p, "root")
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestBugUrlQuery(t *testing.T) {
+	p := fake.Package
+	p.Query = packagebug.Query{State: "open", Labels: []string{"defect"}, Sort: "updated", Direction: "asc"}
+	expected := "root/repos/pyk/byten/issues?direction=asc&labels=defect&sort=updated&state=open"
+	urls := BugUrl(p, "root")
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestBugUrlUpstream(t *testing.T) {
+	p := fake.Package
+	p.Upstream = &packagebug.Package{Host: "github.com", Owner: "upstream", Repo: "byten"}
+	urls := BugUrl(p, "root")
+	if !strings.HasPrefix(urls, "root/repos/upstream/byten/issues?") {
+		t.Errorf("expected upstream url got: %s\n", urls)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	h := http.Header{}
+	h.Set("Link", `<https://api.github.com/repos/pyk/byten/issues?page=2>; rel="next", <https://api.github.com/repos/pyk/byten/issues?page=5>; rel="last"`)
+	expected := "https://api.github.com/repos/pyk/byten/issues?page=2"
+	if next := NextPage(h); next != expected {
+		t.Errorf("expected: %s got: %s\n", expected, next)
+	}
+	if last := LastPage(h); last != "https://api.github.com/repos/pyk/byten/issues?page=5" {
+		t.Errorf("unexpected last page: %s\n", last)
+	}
+	h.Set("Link", `<https://api.github.com/repos/pyk/byten/issues?page=1>; rel="prev"`)
+	if next := NextPage(h); next != "" {
+		t.Errorf("expected no next page got: %s\n", next)
+	}
+}
+
+func TestPageUrls(t *testing.T) {
+	urls := PageUrls("https://api.github.com/repos/pyk/byten/issues?labels=bug&page=4")
+	expected := []string{
+		"https://api.github.com/repos/pyk/byten/issues?labels=bug&page=2",
+		"https://api.github.com/repos/pyk/byten/issues?labels=bug&page=3",
+		"https://api.github.com/repos/pyk/byten/issues?labels=bug&page=4",
+	}
+	if strings.Join(urls, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected: %v got: %v\n", expected, urls)
+	}
+	if urls := PageUrls("https://api.github.com/repos/pyk/byten/issues"); urls != nil {
+		t.Errorf("expected no urls without page got: %v\n", urls)
+	}
+}
+
+func TestFetchIssuesBudget(t *testing.T) {
+	f := &fake.GitHub{Issues: issuesTest(25), PerPage: 2, Etag: `"v1"`}
+	client := newGitHubServer(t, f)
+
+	// the sync of 13 pages continues at the cursor until complete
+	p := fake.Package
+	p.Budget = packagebug.Budget{Pages: 5}
+	var numbers []int
+	for _, expected := range []*packagebug.Cursor{{Page: 6}, {Page: 11}, nil} {
+		result, err := FetchIssues(context.Background(), client, p, time.Time{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (expected == nil) != (result.Next == nil) || (expected != nil && *expected != *result.Next) {
+			t.Fatalf("expected next %v got: %v\n", expected, result.Next)
+		}
+		if result.Next != nil && result.Etag != "" {
+			t.Errorf("expected no etag of the partial result got: %q\n", result.Etag)
+		}
+		for _, issue := range result.Issues {
+			numbers = append(numbers, issue.Number)
+		}
+		if result.Next != nil {
+			p.Cursor = *result.Next
+		}
+	}
+	if len(numbers) != 25 || numbers[0] != 1 || numbers[24] != 25 {
+		t.Errorf("expected 25 issues in order got: %v\n", numbers)
+	}
+	if len(f.Requests()) != 13 {
+		t.Errorf("expected 13 requests got: %d\n", len(f.Requests()))
+	}
+
+	// the issue budget is spent in pages
+	p = fake.Package
+	p.Budget = packagebug.Budget{Issues: 5}
+	result, err := FetchIssues(context.Background(), client, p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 6 || result.Next == nil || result.Next.Page != 4 {
+		t.Errorf("expected 6 issues and next page 4 got: %d %v\n", len(result.Issues), result.Next)
+	}
+}
+
+func TestFetchIssuesResponseCache(t *testing.T) {
+	f := &fake.GitHub{Issues: issuesTest(5), PerPage: 2, Etag: `"v1"`}
+	client := newGitHubServer(t, f)
+	cache := fake.NewCache()
+	client.Cache = cache
+	since := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the full sync is never cached
+	result, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Responses) != 0 {
+		t.Errorf("expected no responses of the full sync got: %+v\n", result.Responses)
+	}
+
+	result, err = FetchIssues(context.Background(), client, fake.Package, since, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 5 || len(result.Responses) != 3 {
+		t.Fatalf("expected 5 issues and 3 responses got: %d %+v\n", len(result.Issues), result.Responses)
+	}
+	if strings.Contains(result.Responses[0].Url, "client_secret") {
+		t.Errorf("expected url without credentials got: %q\n", result.Responses[0].Url)
+	}
+	cache.SaveResponses(context.Background(), result.Responses)
+
+	// every page is conditional on its cached response
+	n := len(f.Requests())
+	result, err = FetchIssues(context.Background(), client, fake.Package, since, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || len(result.Issues) != 0 || result.Etag != `"v1"` {
+		t.Fatalf("expected no issues and the etag got: %+v\n", result)
+	}
+	requests := f.Requests()[n:]
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests got: %d\n", len(requests))
+	}
+	for _, r := range requests {
+		if r.Header.Get("If-None-Match") == "" {
+			t.Errorf("expected conditional request of %s\n", r.URL)
+		}
+	}
+}
+
+func TestFetchIssuesConcurrentPages(t *testing.T) {
+	f := &fake.GitHub{Issues: issuesTest(25), PerPage: 2, Etag: `"v1"`}
+	client := newGitHubServer(t, f)
+
+	result, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 25 {
+		t.Fatalf("expected 25 issues got: %d\n", len(result.Issues))
+	}
+	// the pages are merged in order
+	for i, issue := range result.Issues {
+		if issue.Number != i+1 {
+			t.Fatalf("expected issue #%d at %d got: #%d\n", i+1, i, issue.Number)
+		}
+	}
+	if len(f.Requests()) != 13 {
+		t.Errorf("expected 13 requests got: %d\n", len(f.Requests()))
+	}
+}
+
+func BenchmarkFetchIssues(b *testing.B) {
+	ts := httptest.NewServer(&fake.GitHub{Issues: fake.Issues(1000), PerPage: 100, Etag: `"v1"`})
+	defer ts.Close()
+	client := NewClient(nil, 1)
+	client.Root = ts.URL
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := FetchIssues(ctx, client, fake.Package, time.Time{}, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(result.Issues) != 1000 {
+			b.Fatalf("expected 1000 issues got: %d\n", len(result.Issues))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// moved returns MovedError with the new owner and name of the repository of
+// the package if it was renamed or transferred, otherwise nil. The
+// repository endpoint of the old name redirects to the new one.
+func moved(ctx context.Context, api API, p packagebug.Package) error {
+	root := api.Endpoint()
+	src := p.Source()
+	var repo struct {
+		FullName string `json:"full_name"`
+	}
+	urls := fmt.Sprintf("%s/repos/%s/%s", root, src.Owner, src.Repo)
+	err := getJSON(ctx, api, urls, p.Token, &repo)
+	if err != nil {
+		return fmt.Errorf("moved repository: %w", err)
+	}
+	return movedTo(src, repo.FullName)
+}
+
+// movedTo returns MovedError if the owner/name fullName is not the
+// repository of src. The names are case insensitive.
+func movedTo(src packagebug.Package, fullName string) error {
+	owner, repo, ok := strings.Cut(fullName, "/")
+	if !ok || strings.EqualFold(fullName, src.Owner+"/"+src.Repo) {
+		return nil
+	}
+	return &packagebug.MovedError{Owner: owner, Repo: repo}
+}
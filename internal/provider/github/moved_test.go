@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+// newMovedServer serves pyk/byten that was renamed from pyk/old.
+func newMovedServer(t *testing.T) *Client {
+	mux := http.NewServeMux()
+	mux.Handle("/repos/pyk/byten/issues", &fake.GitHub{Issues: issuesTest(1), PerPage: 10, Etag: `"v1"`})
+	mux.HandleFunc("/repos/pyk/byten", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"full_name": "pyk/byten"})
+	})
+	mux.Handle("/repos/pyk/old/issues", http.RedirectHandler("/repos/pyk/byten/issues", http.StatusMovedPermanently))
+	mux.Handle("/repos/pyk/old", http.RedirectHandler("/repos/pyk/byten", http.StatusMovedPermanently))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	client := NewClient(nil, 1)
+	client.Root = ts.URL
+	return client
+}
+
+func TestFetchIssuesMoved(t *testing.T) {
+	client := newMovedServer(t)
+	p := fake.Package
+	p.Repo = "old"
+	_, err := FetchIssues(context.Background(), client, p, time.Time{}, "")
+	var moved *packagebug.MovedError
+	if !errors.As(err, &moved) || moved.Owner != "pyk" || moved.Repo != "byten" {
+		t.Fatalf("expected moved to pyk/byten got: %v\n", err)
+	}
+
+	// the new location isn't moved
+	result, err := FetchIssues(context.Background(), client, fake.Package, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 1 {
+		t.Errorf("expected 1 issue got: %d\n", len(result.Issues))
+	}
+}
+
+func TestMovedTo(t *testing.T) {
+	src := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "byten"}
+	if err := movedTo(src, "PYK/Byten"); err != nil {
+		t.Errorf("expected the same repository got: %v\n", err)
+	}
+	if err := movedTo(src, ""); err != nil {
+		t.Errorf("expected no move without name got: %v\n", err)
+	}
+	if err := movedTo(src, "other/byten"); err == nil {
+		t.Error("expected moved repository")
+	}
+}
@@ -0,0 +1,42 @@
+package github
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Name implements provider.Provider.
+func (c *Client) Name() string {
+	return "github"
+}
+
+// Has implements provider.Provider, it returns true if host is github.com
+// or the GitHub Enterprise Server host of the client.
+func (c *Client) Has(host string) bool {
+	if c.Host == "" {
+		return host == "github.com"
+	}
+	return strings.EqualFold(host, c.Host)
+}
+
+// FetchRepo implements provider.RepoFetcher, see FetchRepo.
+func (c *Client) FetchRepo(ctx context.Context, p packagebug.Package, etag string) (*packagebug.Repo, error) {
+	return FetchRepo(ctx, c, p, etag)
+}
+
+// FetchReleases implements provider.ReleaseFetcher, see FetchReleases.
+func (c *Client) FetchReleases(ctx context.Context, p packagebug.Package, etag string) (*packagebug.Releases, error) {
+	return FetchReleases(ctx, c, p, etag)
+}
+
+// FetchIssues implements provider.Provider, see FetchIssues and
+// FetchIssuesGraphQL.
+func (c *Client) FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	if c.GraphQL {
+		return FetchIssuesGraphQL(ctx, c, p, since)
+	}
+	return FetchIssues(ctx, c, p, since, etag)
+}
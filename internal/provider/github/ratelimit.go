@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// RateUrl returns the URL where to check the current status of rate limit.
+func RateUrl(root string) string {
+	return root + "/rate_limit"
+}
+
+// RateLimit implements provider.Provider. It returns the remaining API
+// requests of all tokens and the reset time, the known state of the tokens
+// is used if possible. If error happen the rate limit will be -1.
+func (c *Client) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	// use the known state of the tokens if possible
+	if rateLimit, resetTime, ok := c.KnownRateLimit(); ok {
+		return rateLimit, resetTime, nil
+	}
+
+	urls := RateUrl(c.Root)
+	// send conditional request, the rate limit headers are also sent on 304
+	// response
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return -1, -1, err
+	}
+	req = req.WithContext(ctx)
+	c.mu.Lock()
+	if c.rateEtag != "" {
+		req.Header.Add("If-None-Match", c.rateEtag)
+	}
+	c.mu.Unlock()
+	resp, err := c.Do(req)
+	if err != nil {
+		return -1, -1, err
+	}
+	defer resp.Body.Close()
+	// the rate limiting of GitHub Enterprise Server is disabled by default
+	if c.Host != "" && resp.StatusCode == http.StatusNotFound {
+		return 1, 0, nil
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		c.mu.Lock()
+		c.rateEtag = etag
+		c.mu.Unlock()
+	}
+
+	// get remaining limit
+	limit := resp.Header.Get("X-RateLimit-Remaining")
+	rateLimit, err := strconv.Atoi(limit)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	// get time reset
+	reset := resp.Header.Get("X-RateLimit-Reset")
+	resetTime, err := strconv.ParseInt(reset, 10, 64)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return rateLimit, resetTime, nil
+}
+
+// TokenCheck is the rate limit of a token checked by CheckTokens.
+type TokenCheck struct {
+	// Id of the token, see Token.Id
+	Id        string
+	Remaining int
+	Reset     time.Time
+	// Disabled is true if the rate limiting of the GitHub Enterprise Server
+	// is disabled
+	Disabled bool
+	Err      error
+}
+
+// CheckTokens requests the rate limit of every token of the client, e.g.
+// to diagnose the credentials before the worker starts. The rejected token
+// has the status error of the response. The known state of the tokens is
+// updated.
+func (c *Client) CheckTokens(ctx context.Context) []TokenCheck {
+	checks := make([]TokenCheck, len(c.tokens))
+	for i, t := range c.tokens {
+		checks[i] = c.checkToken(ctx, t)
+	}
+	return checks
+}
+
+// checkToken requests the rate limit of the token.
+func (c *Client) checkToken(ctx context.Context, t *Token) TokenCheck {
+	check := TokenCheck{Id: t.Id()}
+	urls := RateUrl(c.Root)
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	if t.Value != "" {
+		req.Header.Set("Authorization", "token "+t.Value)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	defer resp.Body.Close()
+	if c.Host != "" && resp.StatusCode == http.StatusNotFound {
+		check.Disabled = true
+		return check
+	}
+	if resp.StatusCode != http.StatusOK {
+		check.Err = packagebug.NewStatusError(resp)
+		return check
+	}
+	t.update(resp.Header)
+	check.Remaining, err = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	check.Reset = time.Unix(reset, 0)
+	return check
+}
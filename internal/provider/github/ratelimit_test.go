@@ -0,0 +1,103 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestRateUrl(t *testing.T) {
+	expected := "root/rate_limit"
+	urls := RateUrl("root")
+	if urls != expected {
+		t.Fatalf("expected: %s got: %s\n", expected, urls)
+	}
+}
+
+func TestRateLimitConditional(t *testing.T) {
+	var conditional int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "1500000000")
+		w.Header().Set("ETag", `"rate"`)
+		if r.Header.Get("If-None-Match") == `"rate"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(nil, 1)
+	client.Root = ts.URL
+	for i := 0; i < 2; i++ {
+		rate, reset, err := client.RateLimit(context.Background(), "github.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rate != 42 || reset != 1500000000 {
+			t.Errorf("unexpected rate limit %d reset %d\n", rate, reset)
+		}
+		// forget the state to force the request
+		client.tokens[0].setState(packagebug.RateState{})
+	}
+	if conditional != 1 {
+		t.Errorf("expected 1 conditional request got: %d\n", conditional)
+	}
+}
+
+func TestRateLimitEnterpriseDisabled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	client := NewHostClient("ghe.corp.example.com", ts.URL, []string{"a"}, 1)
+	rate, _, err := client.RateLimit(context.Background(), "ghe.corp.example.com")
+	if err != nil || rate != 1 {
+		t.Errorf("expected unlimited got: %d %v\n", rate, err)
+	}
+	if !client.Has("GHE.corp.example.com") || client.Has("github.com") {
+		t.Errorf("expected the client of ghe.corp.example.com only\n")
+	}
+	if url := GraphQLUrl("https://ghe.corp.example.com/api/v3"); url != "https://ghe.corp.example.com/api/graphql" {
+		t.Errorf("unexpected graphql url: %s\n", url)
+	}
+	if NewClient([]string{"a"}, 1).TokenIds()[0] == client.TokenIds()[0] {
+		t.Errorf("expected the token ids of the hosts to differ\n")
+	}
+}
+
+func TestCheckTokens(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1500000000")
+	}))
+	defer ts.Close()
+
+	client := NewClient([]string{"good", "bad"}, 1)
+	client.Root = ts.URL
+	checks := client.CheckTokens(context.Background())
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks got: %d\n", len(checks))
+	}
+	if checks[0].Err != nil || checks[0].Remaining != 4999 || checks[0].Reset.Unix() != 1500000000 {
+		t.Errorf("unexpected check of the good token: %+v\n", checks[0])
+	}
+	if checks[0].Id != client.tokens[0].Id() {
+		t.Errorf("expected id %s got: %s\n", client.tokens[0].Id(), checks[0].Id)
+	}
+	if checks[1].Err == nil {
+		t.Errorf("expected error of the bad token got: %+v\n", checks[1])
+	}
+	if s := client.tokens[0].State(); !s.Known || s.Remaining != 4999 {
+		t.Errorf("expected known state of the good token got: %+v\n", s)
+	}
+}
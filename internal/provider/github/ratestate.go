@@ -0,0 +1,109 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Id returns the identifier of the token stored in the database. The token
+// itself is never stored.
+func (t *Token) Id() string {
+	// the same token of another host has its own rate limit
+	value := t.Value
+	if t.host != "" {
+		value = t.host + "/" + value
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// State returns the last known rate limit of the token.
+func (t *Token) State() packagebug.RateState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// setState replaces the rate limit state of the token.
+func (t *Token) setState(s packagebug.RateState) {
+	t.mu.Lock()
+	t.state = s
+	t.mu.Unlock()
+}
+
+// update sets the rate limit state from the GitHub response headers.
+func (t *Token) update(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	t.setState(packagebug.RateState{
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+		Known:     true,
+	})
+}
+
+// KnownRateLimit returns the total remaining requests of all tokens and the
+// earliest reset time. The token with passed reset time is considered
+// available. It returns false if the state of any token is unknown.
+func (c *Client) KnownRateLimit() (int, int64, bool) {
+	now := time.Now()
+	remaining := 0
+	var reset int64
+	for _, t := range c.tokens {
+		s := t.State()
+		if !s.Known {
+			return -1, -1, false
+		}
+		if now.After(s.Reset) {
+			remaining++
+			continue
+		}
+		remaining += s.Remaining
+		if reset == 0 || s.Reset.Unix() < reset {
+			reset = s.Reset.Unix()
+		}
+	}
+	return remaining, reset, true
+}
+
+// RateStates returns the known rate limit state of the tokens mapped by the
+// token id.
+func (c *Client) RateStates() map[string]packagebug.RateState {
+	states := make(map[string]packagebug.RateState)
+	for _, t := range c.tokens {
+		if s := t.State(); s.Known {
+			states[t.Id()] = s
+		}
+	}
+	return states
+}
+
+// SetRateStates sets the rate limit state of the tokens from states mapped
+// by the token id, e.g. the state persisted by the last run.
+func (c *Client) SetRateStates(states map[string]packagebug.RateState) {
+	for _, t := range c.tokens {
+		if s, ok := states[t.Id()]; ok {
+			t.setState(s)
+		}
+	}
+}
+
+// TokenIds returns the id of every token.
+func (c *Client) TokenIds() []string {
+	var ids []string
+	for _, t := range c.tokens {
+		ids = append(ids, t.Id())
+	}
+	return ids
+}
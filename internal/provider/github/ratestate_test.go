@@ -0,0 +1,36 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientKnownRateLimit(t *testing.T) {
+	c := NewClient([]string{"a", "b"}, 1)
+	_, _, ok := c.KnownRateLimit()
+	if ok {
+		t.Fatal("expected unknown rate limit")
+	}
+
+	reset := time.Now().Add(time.Hour).Unix()
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", "10")
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	c.tokens[0].update(h)
+	h.Set("X-RateLimit-Remaining", "5")
+	c.tokens[1].update(h)
+
+	remaining, r, ok := c.KnownRateLimit()
+	if !ok || remaining != 15 || r != reset {
+		t.Errorf("expected: 15 %d got: %d %d %v\n", reset, remaining, r, ok)
+	}
+}
+
+func TestTokenId(t *testing.T) {
+	a := &Token{Value: "secret"}
+	if a.Id() == "secret" || len(a.Id()) != 16 {
+		t.Errorf("got: %s\n", a.Id())
+	}
+}
@@ -0,0 +1,71 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// ReleasesUrl returns the url of the latest releases of the package.
+func ReleasesUrl(p packagebug.Package, root string) string {
+	return RepoUrl(p, root) + "/releases?per_page=100"
+}
+
+// FetchReleases fetches the latest 100 published releases of the package,
+// the drafts are skipped. The request is conditional if etag is not empty,
+// it returns nil if the releases are not modified since.
+func FetchReleases(ctx context.Context, api API, p packagebug.Package, etag string) (*packagebug.Releases, error) {
+	req, err := http.NewRequest("GET", ReleasesUrl(p, api.Endpoint()), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "token "+p.Token)
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, packagebug.NewStatusError(resp)
+	}
+
+	var releases []struct {
+		Tag         string     `json:"tag_name"`
+		Name        string     `json:"name"`
+		Draft       bool       `json:"draft"`
+		Prerelease  bool       `json:"prerelease"`
+		PublishedAt *time.Time `json:"published_at"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&releases)
+	if err != nil {
+		return nil, packagebug.DecodeError(err)
+	}
+	r := &packagebug.Releases{Etag: resp.Header.Get("ETag")}
+	for _, rel := range releases {
+		if rel.Draft || rel.PublishedAt == nil {
+			continue
+		}
+		r.Releases = append(r.Releases, packagebug.Release{
+			Tag:         rel.Tag,
+			Name:        rel.Name,
+			Prerelease:  rel.Prerelease,
+			PublishedAt: *rel.PublishedAt,
+		})
+	}
+	return r, nil
+}
@@ -0,0 +1,49 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestFetchReleases(t *testing.T) {
+	client := &fake.Client{Root: "http://github.test", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/pyk/byten/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"l1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"l1"`)
+		w.Write([]byte(`[{"tag_name": "v1.5.0-rc1", "prerelease": true, "published_at": "2016-03-01T00:00:00Z"},
+			{"tag_name": "v1.5.0", "draft": true, "published_at": null},
+			{"tag_name": "v1.4.0", "name": "v1.4", "published_at": "2016-02-01T00:00:00Z"}]`))
+	})}
+
+	releases, err := FetchReleases(context.Background(), client, fake.Package, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases.Releases) != 2 || releases.Etag != `"l1"` {
+		t.Fatalf("expected 2 published releases got: %+v\n", releases)
+	}
+	if r := releases.Releases[0]; r.Tag != "v1.5.0-rc1" || !r.Prerelease {
+		t.Errorf("unexpected prerelease: %+v\n", r)
+	}
+	if r := releases.Releases[1]; r.Tag != "v1.4.0" || r.Name != "v1.4" || r.PublishedAt.IsZero() {
+		t.Errorf("unexpected release: %+v\n", r)
+	}
+
+	// not modified since the last fetch
+	releases, err = FetchReleases(context.Background(), client, fake.Package, `"l1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if releases != nil {
+		t.Errorf("expected nil releases got: %+v\n", releases)
+	}
+}
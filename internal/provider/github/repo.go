@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// RepoUrl returns the url of the repository metadata of the package.
+func RepoUrl(p packagebug.Package, root string) string {
+	src := p.Source()
+	return fmt.Sprintf("%s/repos/%s/%s", root, src.Owner, src.Repo)
+}
+
+// FetchRepo fetches the repository metadata of the package. The request is
+// conditional if etag is not empty, it returns nil if the metadata is not
+// modified since.
+func FetchRepo(ctx context.Context, api API, p packagebug.Package, etag string) (*packagebug.Repo, error) {
+	req, err := http.NewRequest("GET", RepoUrl(p, api.Endpoint()), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "token "+p.Token)
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, packagebug.NewStatusError(resp)
+	}
+
+	var repo struct {
+		Stars         int       `json:"stargazers_count"`
+		Forks         int       `json:"forks_count"`
+		Archived      bool      `json:"archived"`
+		DefaultBranch string    `json:"default_branch"`
+		Description   string    `json:"description"`
+		PushedAt      time.Time `json:"pushed_at"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&repo)
+	if err != nil {
+		return nil, packagebug.DecodeError(err)
+	}
+	return &packagebug.Repo{
+		Stars:         repo.Stars,
+		Forks:         repo.Forks,
+		Archived:      repo.Archived,
+		DefaultBranch: repo.DefaultBranch,
+		Description:   repo.Description,
+		PushedAt:      repo.PushedAt,
+		Etag:          resp.Header.Get("ETag"),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestFetchRepo(t *testing.T) {
+	client := &fake.Client{Root: "http://github.test", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/pyk/byten" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"r1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"r1"`)
+		w.Write([]byte(`{"stargazers_count": 42, "forks_count": 3, "archived": true,
+			"default_branch": "main", "description": "byte units",
+			"pushed_at": "2016-01-02T00:00:00Z"}`))
+	})}
+
+	repo, err := FetchRepo(context.Background(), client, fake.Package, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Stars != 42 || repo.Forks != 3 || !repo.Archived || repo.DefaultBranch != "main" {
+		t.Errorf("unexpected repo: %+v\n", repo)
+	}
+	if repo.Etag != `"r1"` || repo.PushedAt.IsZero() {
+		t.Errorf("unexpected etag or push time: %+v\n", repo)
+	}
+
+	// not modified since the last fetch
+	repo, err = FetchRepo(context.Background(), client, fake.Package, `"r1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo != nil {
+		t.Errorf("expected nil repo got: %+v\n", repo)
+	}
+}
@@ -0,0 +1,67 @@
+package github
+
+import (
+	"context"
+	"time"
+)
+
+// delay reserves the next request slot of the token and returns how long the
+// request has to wait for it. The slots are spaced by the time until reset
+// divided by the remaining requests, so the token never runs out before its
+// reset. The requests of the token with unknown state are not throttled.
+// The paused token waits until the end of the pause first.
+func (t *Token) delay(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Before(t.paused) {
+		return t.paused.Sub(now)
+	}
+	s := t.state
+	if !s.Known || !now.Before(s.Reset) {
+		return 0
+	}
+	if s.Remaining <= 0 {
+		return s.Reset.Sub(now)
+	}
+	interval := s.Reset.Sub(now) / time.Duration(s.Remaining)
+	if t.next.Before(now) {
+		t.next = now
+	}
+	d := t.next.Sub(now)
+	t.next = t.next.Add(interval)
+	return d
+}
+
+// pause holds the requests of the token until the time, e.g. the host asked
+// to retry after it.
+func (t *Token) pause(until time.Time) {
+	t.mu.Lock()
+	if until.After(t.paused) {
+		t.paused = until
+	}
+	t.mu.Unlock()
+}
+
+// pausedUntil returns the end of the pause of the token.
+func (t *Token) pausedUntil() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused
+}
+
+// throttle blocks until the request slot of the token is available or ctx
+// is done.
+func (t *Token) throttle(ctx context.Context) error {
+	d := t.delay(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestTokenDelay(t *testing.T) {
+	now := time.Now()
+	tok := &Token{}
+	if d := tok.delay(now); d != 0 {
+		t.Errorf("expected no delay for unknown state got: %s\n", d)
+	}
+
+	// 10 requests left for 10s: one request per second
+	tok.setState(packagebug.RateState{Remaining: 10, Reset: now.Add(10 * time.Second), Known: true})
+	for i := 0; i < 3; i++ {
+		expected := time.Duration(i) * time.Second
+		if d := tok.delay(now); d != expected {
+			t.Errorf("expected delay %s got: %s\n", expected, d)
+		}
+	}
+
+	// exhausted: wait until reset
+	tok = &Token{}
+	tok.setState(packagebug.RateState{Remaining: 0, Reset: now.Add(time.Minute), Known: true})
+	if d := tok.delay(now); d != time.Minute {
+		t.Errorf("expected delay 1m got: %s\n", d)
+	}
+
+	// paused by the secondary rate limit: wait until the end of the pause
+	paused := &Token{}
+	paused.pause(now.Add(30 * time.Second))
+	if d := paused.delay(now); d != 30*time.Second {
+		t.Errorf("expected delay 30s of the pause got: %s\n", d)
+	}
+
+	// reset passed: not throttled
+	tok.setState(packagebug.RateState{Remaining: 0, Reset: now.Add(-time.Second), Known: true})
+	if d := tok.delay(now); d != 0 {
+		t.Errorf("expected no delay got: %s\n", d)
+	}
+}
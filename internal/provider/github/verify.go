@@ -0,0 +1,139 @@
+package github
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// WellKnownFile is the file in the root of the repository that contains the
+// verification token of the package owner.
+const WellKnownFile = ".packagebug"
+
+// MaxWellKnownSize bounds the read of WellKnownFile, the token is short.
+const MaxWellKnownSize = 4 << 10
+
+// Verifier verifies that the requester of the settings controls the
+// repository of the package.
+type Verifier interface {
+	Verify(ctx context.Context, client *Client, p packagebug.Package, s packagebug.Settings) (bool, error)
+}
+
+// WellKnownVerifier verifies the ownership by checking the content of
+// WellKnownFile in the repository equals to the verification token.
+type WellKnownVerifier struct {
+	// Root is the API root of github.com, the GitHub Enterprise Server
+	// packages are verified by the root of their client
+	Root string
+}
+
+// Verify implements Verifier.
+func (v WellKnownVerifier) Verify(ctx context.Context, client *Client, p packagebug.Package, s packagebug.Settings) (bool, error) {
+	if s.VerifyToken == "" {
+		return false, nil
+	}
+	urls := fmt.Sprintf("%s/repos/%s/%s/contents/%s", verifyRoot(v.Root, client), p.Owner,
+		p.Repo, WellKnownFile)
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Accept", "application/vnd.github.v3.raw")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, packagebug.NewStatusError(resp)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxWellKnownSize))
+	if err != nil {
+		return false, err
+	}
+	token := strings.TrimSpace(string(body))
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.VerifyToken)) == 1, nil
+}
+
+// PermissionVerifier verifies the ownership by checking the user of the
+// private token has push or admin permission on the repository.
+type PermissionVerifier struct {
+	// Root is the API root of github.com, see WellKnownVerifier
+	Root string
+}
+
+// Verify implements Verifier.
+func (v PermissionVerifier) Verify(ctx context.Context, client *Client, p packagebug.Package, s packagebug.Settings) (bool, error) {
+	if s.Token == "" {
+		return false, nil
+	}
+	urls := fmt.Sprintf("%s/repos/%s/%s", verifyRoot(v.Root, client), p.Owner, p.Repo)
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", "token "+s.Token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, packagebug.NewStatusError(resp)
+	}
+	var repo struct {
+		Permissions struct {
+			Admin bool `json:"admin"`
+			Push  bool `json:"push"`
+		} `json:"permissions"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&repo)
+	if err != nil {
+		return false, err
+	}
+	return repo.Permissions.Admin || repo.Permissions.Push, nil
+}
+
+// verifyRoot returns the API root of the verification requests sent by
+// client.
+func verifyRoot(root string, client *Client) string {
+	if client.Host != "" {
+		return client.Root
+	}
+	return root
+}
+
+// ParseVerifiers returns the verifiers from comma separated method names:
+// wellknown and permission.
+func ParseVerifiers(s, root string) ([]Verifier, error) {
+	var verifiers []Verifier
+	for _, m := range strings.Split(s, ",") {
+		switch strings.TrimSpace(m) {
+		case "":
+		case "wellknown":
+			verifiers = append(verifiers, WellKnownVerifier{Root: root})
+		case "permission":
+			verifiers = append(verifiers, PermissionVerifier{Root: root})
+		default:
+			return nil, fmt.Errorf("unknown verify method %q", m)
+		}
+	}
+	return verifiers, nil
+}
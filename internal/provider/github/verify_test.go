@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestParseVerifiers(t *testing.T) {
+	verifiers, err := ParseVerifiers("wellknown, permission", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifiers) != 2 {
+		t.Fatalf("expected 2 verifiers got: %d\n", len(verifiers))
+	}
+	_, err = ParseVerifiers("dns", "root")
+	if err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
+
+func TestWellKnownVerifier(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/pyk/byten/contents/.packagebug" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "secret-token")
+	}))
+	defer ts.Close()
+
+	v := WellKnownVerifier{Root: ts.URL}
+	client := NewClient(nil, 1)
+	ok, err := v.Verify(context.Background(), client, fake.Package, packagebug.Settings{VerifyToken: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected verified")
+	}
+	ok, err = v.Verify(context.Background(), client, fake.Package, packagebug.Settings{VerifyToken: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected not verified")
+	}
+}
+
+func TestPermissionVerifier(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token owner" {
+			fmt.Fprint(w, `{"permissions":{"admin":false,"push":false,"pull":true}}`)
+			return
+		}
+		fmt.Fprint(w, `{"permissions":{"admin":true,"push":true,"pull":true}}`)
+	}))
+	defer ts.Close()
+
+	v := PermissionVerifier{Root: ts.URL}
+	client := NewClient(nil, 1)
+	ok, err := v.Verify(context.Background(), client, fake.Package, packagebug.Settings{Token: "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("expected verified")
+	}
+	ok, err = v.Verify(context.Background(), client, fake.Package, packagebug.Settings{Token: "stranger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected not verified")
+	}
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// maxPolls is the number of the tracked poll intervals that triggers the
+// removal of the expired ones.
+const maxPolls = 1024
+
+// Pacer is the HTTP transport shared by the providers that paces the
+// requests by the hints of the hosts: the X-Poll-Interval of a resource
+// spaces the requests of the same URL and the Retry-After of a throttled
+// response (429, 503 or 403) holds every request to the host. The request
+// waits for at most MaxWait, the longer wait fails with the rate limited
+// StatusError whose RetryAfter is the remaining wait, so the message is
+// delayed instead of holding the process.
+type Pacer struct {
+	Transport http.RoundTripper
+	MaxWait   time.Duration
+
+	mu sync.Mutex
+	// paused is the end of the Retry-After by host
+	paused map[string]time.Time
+	// polls is the next poll by URL
+	polls map[string]time.Time
+}
+
+// NewPacer creates the pacer of the requests sent with transport, nil
+// transport is http.DefaultTransport.
+func NewPacer(transport http.RoundTripper, maxWait time.Duration) *Pacer {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Pacer{
+		Transport: transport,
+		MaxWait:   maxWait,
+		paused:    make(map[string]time.Time),
+		polls:     make(map[string]time.Time),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *Pacer) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := p.wait(req.Context(), req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	p.observe(req, resp, time.Now())
+	return resp, nil
+}
+
+// delay returns how long the request has to wait for its host and URL.
+func (p *Pacer) delay(req *http.Request, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until := p.paused[req.URL.Host]
+	if next := p.polls[req.URL.String()]; next.After(until) {
+		until = next
+	}
+	if !until.After(now) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// wait blocks until the request is allowed or ctx is done, see Pacer.
+func (p *Pacer) wait(ctx context.Context, req *http.Request, now time.Time) error {
+	d := p.delay(req, now)
+	if d <= 0 {
+		return nil
+	}
+	if d > p.MaxWait {
+		return &packagebug.StatusError{
+			StatusCode: http.StatusTooManyRequests,
+			Status:     fmt.Sprintf("%d paced by %s", http.StatusTooManyRequests, req.URL.Host),
+			RetryAfter: d,
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// observe records the X-Poll-Interval and the Retry-After of the response.
+// The exhausted rate limit without Retry-After, e.g. of a GitHub token, is
+// left to the client of the provider.
+func (p *Pacer) observe(req *http.Request, resp *http.Response, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, err := strconv.Atoi(resp.Header.Get("X-Poll-Interval")); err == nil && s > 0 {
+		if len(p.polls) >= maxPolls {
+			for u, next := range p.polls {
+				if !next.After(now) {
+					delete(p.polls, u)
+				}
+			}
+		}
+		p.polls[req.URL.String()] = now.Add(time.Duration(s) * time.Second)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusForbidden:
+	default:
+		return
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		return
+	}
+	d := packagebug.ParseRetryAfter(resp.Header, now)
+	if until := now.Add(d); d > 0 && until.After(p.paused[req.URL.Host]) {
+		p.paused[req.URL.Host] = until
+	}
+}
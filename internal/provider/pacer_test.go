@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestPacerDelay(t *testing.T) {
+	now := time.Now()
+	p := NewPacer(nil, time.Minute)
+	events, _ := http.NewRequest("GET", "https://api.github.com/repos/pyk/byten/events", nil)
+	issues, _ := http.NewRequest("GET", "https://api.github.com/repos/pyk/byten/issues", nil)
+	other, _ := http.NewRequest("GET", "https://codeberg.org/api/v1/repos/pyk/byten/issues", nil)
+
+	// the poll interval spaces the requests of the same URL only
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set("X-Poll-Interval", "60")
+	p.observe(events, resp, now)
+	if d := p.delay(events, now); d != time.Minute {
+		t.Errorf("expected delay 1m of the poll interval got: %s\n", d)
+	}
+	if d := p.delay(issues, now); d != 0 {
+		t.Errorf("expected no delay of the other URL got: %s\n", d)
+	}
+
+	// the Retry-After of the throttled response holds the host
+	resp = &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "30")
+	p.observe(issues, resp, now)
+	if d := p.delay(issues, now); d != 30*time.Second {
+		t.Errorf("expected delay 30s of the host got: %s\n", d)
+	}
+	if d := p.delay(events, now); d != time.Minute {
+		t.Errorf("expected delay 1m of the longer wait got: %s\n", d)
+	}
+	if d := p.delay(other, now); d != 0 {
+		t.Errorf("expected no delay of the other host got: %s\n", d)
+	}
+
+	// the exhausted rate limit without Retry-After is left to the client
+	resp = &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "0")
+	resp.Header.Set("X-RateLimit-Reset", "9999999999")
+	p.observe(other, resp, now)
+	if d := p.delay(other, now); d != 0 {
+		t.Errorf("expected no delay got: %s\n", d)
+	}
+}
+
+func TestPacerRoundTrip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Poll-Interval", "60")
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: NewPacer(nil, time.Second)}
+	resp, err := client.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// polled again before the interval: the wait is longer than MaxWait
+	_, err = client.Get(ts.URL + "/events")
+	if !errors.Is(err, packagebug.ErrRateLimited) {
+		t.Fatalf("expected rate limited error got: %v\n", err)
+	}
+	if d := packagebug.RetryAfter(err); d <= time.Second || d > time.Minute {
+		t.Errorf("expected retry after the poll interval got: %s\n", d)
+	}
+}
@@ -0,0 +1,56 @@
+// Package provider defines the code hosts the bugs of the packages are
+// fetched from. The providers live in the sub packages.
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Provider fetches the bugs of the packages of its hosts. Every provider has
+// its own rate limit semantics, e.g. GitHub exposes the remaining requests
+// while Bitbucket and Gitea only report when the limit is exceeded.
+type Provider interface {
+	// Name returns the name of the provider, e.g. github.
+	Name() string
+	// Has returns true if the provider serves the packages of host.
+	Has(host string) bool
+	// RateLimit returns the remaining requests to host and the reset time
+	// as unix time. Zero remaining means the requests must wait until the
+	// reset.
+	RateLimit(ctx context.Context, host string) (int, int64, error)
+	// FetchIssues fetches the bugs of the package updated after since, zero
+	// since fetches all. The request is conditional if etag is not empty,
+	// it returns nil result if the bugs is not modified.
+	FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error)
+}
+
+// RepoFetcher is implemented by the provider that exposes the repository
+// metadata of the packages.
+type RepoFetcher interface {
+	// FetchRepo fetches the repository metadata of the package. The request
+	// is conditional if etag is not empty, it returns nil if the metadata is
+	// not modified.
+	FetchRepo(ctx context.Context, p packagebug.Package, etag string) (*packagebug.Repo, error)
+}
+
+// ReleaseFetcher is implemented by the provider that exposes the releases
+// of the package repositories.
+type ReleaseFetcher interface {
+	// FetchReleases fetches the releases of the package. The request is
+	// conditional if etag is not empty, it returns nil if the releases are
+	// not modified.
+	FetchReleases(ctx context.Context, p packagebug.Package, etag string) (*packagebug.Releases, error)
+}
+
+// Find returns the provider of host, nil if no provider serves it.
+func Find(providers []Provider, host string) Provider {
+	for _, p := range providers {
+		if p.Has(host) {
+			return p
+		}
+	}
+	return nil
+}
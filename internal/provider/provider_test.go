@@ -0,0 +1,34 @@
+package provider_test
+
+import (
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/bitbucket"
+	"github.com/pyk/packagebug-worker/internal/provider/gitea"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/provider/sourcehut"
+)
+
+func TestFind(t *testing.T) {
+	providers := []provider.Provider{
+		github.NewClient(nil, 1),
+		bitbucket.New("", "", ""),
+		gitea.New([]string{"codeberg.org"}),
+		sourcehut.New("", "token"),
+	}
+	for host, name := range map[string]string{
+		"github.com":    "github",
+		"bitbucket.org": "bitbucket",
+		"codeberg.org":  "gitea",
+		"git.sr.ht":     "sourcehut",
+	} {
+		p := provider.Find(providers, host)
+		if p == nil || p.Name() != name {
+			t.Errorf("expected %s provider of %s got: %v\n", name, host, p)
+		}
+	}
+	if p := provider.Find(providers, "gitlab.com"); p != nil {
+		t.Errorf("expected no provider got: %s\n", p.Name())
+	}
+}
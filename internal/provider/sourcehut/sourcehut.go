@@ -0,0 +1,303 @@
+// Package sourcehut fetches the tickets of the packages hosted on SourceHut
+// from the todo.sr.ht GraphQL API.
+package sourcehut
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// ticketsQuery fetches a page of the tickets of the tracker of the user.
+const ticketsQuery = `
+query($owner: String!, $tracker: String!, $cursor: Cursor) {
+  user(username: $owner) {
+    tracker(name: $tracker) {
+      tickets(cursor: $cursor) {
+        cursor
+        results {
+          id subject body status created updated
+          submitter { canonicalName }
+          labels { name }
+          assignees { canonicalName }
+        }
+      }
+    }
+  }
+}`
+
+// Client fetches the tickets of the packages hosted on git.sr.ht. The
+// tracker of the package is the tracker of the repository owner with the
+// repository name. SourceHut doesn't expose the remaining rate limit, so the
+// client stops sending requests once it receives 429 until the limit window
+// is over.
+type Client struct {
+	// Root is the root of todo.sr.ht, e.g. https://todo.sr.ht
+	Root string
+	// Token is the personal access token, the API rejects the anonymous
+	// requests
+	Token string
+	HTTP  *http.Client
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+// New creates the SourceHut client with the personal access token.
+func New(root, token string) *Client {
+	if root == "" {
+		root = "https://todo.sr.ht"
+	}
+	return &Client{
+		Root:  strings.TrimSuffix(root, "/"),
+		Token: token,
+		HTTP:  &http.Client{},
+	}
+}
+
+// Name implements provider.Provider.
+func (c *Client) Name() string {
+	return "sourcehut"
+}
+
+// Has implements provider.Provider.
+func (c *Client) Has(host string) bool {
+	return host == "git.sr.ht"
+}
+
+// RateLimit implements provider.Provider. It returns 0 and the reset time if
+// the client is blocked by the rate limit, otherwise 1.
+func (c *Client) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.blockedUntil) {
+		return 0, c.blockedUntil.Unix(), nil
+	}
+	return 1, 0, nil
+}
+
+// block stops the requests until the rate limit window is over.
+func (c *Client) block(resp *http.Response) {
+	wait := time.Minute
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(s) * time.Second
+	}
+	c.mu.Lock()
+	c.blockedUntil = time.Now().Add(wait)
+	c.mu.Unlock()
+}
+
+// entity is the user of the ticket, its canonical name is ~username.
+type entity struct {
+	CanonicalName string `json:"canonicalName"`
+}
+
+// ticket is the ticket node of ticketsQuery.
+type ticket struct {
+	Id        int       `json:"id"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	Submitter *entity   `json:"submitter"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []entity `json:"assignees"`
+}
+
+// Issue maps the ticket onto the shared Issue model. SourceHut doesn't
+// record when the ticket is resolved, the last update time is used instead.
+func (t ticket) Issue(trackerUrl string) packagebug.Issue {
+	issue := packagebug.Issue{
+		Url:       trackerUrl + "/" + strconv.Itoa(t.Id),
+		Number:    t.Id,
+		Title:     t.Subject,
+		Body:      t.Body,
+		State:     "open",
+		CreatedAt: t.Created,
+		UpdatedAt: t.Updated,
+	}
+	if t.Status == "RESOLVED" {
+		issue.State = "closed"
+		closedAt := t.Updated
+		issue.ClosedAt = &closedAt
+	}
+	if t.Submitter != nil {
+		issue.User = packagebug.IssueCreator{
+			Username:   strings.TrimPrefix(t.Submitter.CanonicalName, "~"),
+			ProfileUrl: "https://sr.ht/" + t.Submitter.CanonicalName,
+		}
+	}
+	for _, a := range t.Assignees {
+		issue.Assignees = append(issue.Assignees, packagebug.IssueCreator{
+			Username: strings.TrimPrefix(a.CanonicalName, "~")})
+	}
+	for _, l := range t.Labels {
+		issue.Labels = append(issue.Labels, packagebug.Label{Name: l.Name})
+	}
+	return issue
+}
+
+// ticketsResponse is the response of ticketsQuery.
+type ticketsResponse struct {
+	Data struct {
+		User *struct {
+			Tracker *struct {
+				Tickets struct {
+					Cursor  *string  `json:"cursor"`
+					Results []ticket `json:"results"`
+				} `json:"tickets"`
+			} `json:"tracker"`
+		} `json:"user"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// IsBug returns true if the ticket has every custom label of the package, or
+// any bug label of the query or its aliases otherwise.
+func IsBug(p packagebug.Package, issue packagebug.Issue) bool {
+	has := func(name string) bool {
+		for _, l := range issue.Labels {
+			if strings.EqualFold(l.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p.Labels) > 0 {
+		for _, l := range p.Labels {
+			if !has(l) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, l := range append(p.Query.BugLabels(), p.BugLabels...) {
+		if has(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchIssues implements provider.Provider. The API has neither label nor
+// since filter and no etag, so every page of tickets is fetched and the bugs
+// updated after since are kept. It returns empty result if the owner has no
+// tracker of the repository. The fetch starts after the cursor of the
+// package cursor and stops once the budget of the package is spent, Next of
+// the result is where the sync continues then.
+func (c *Client) FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	logger := packagebug.Logger(ctx)
+	src := p.Source()
+	trackerUrl := fmt.Sprintf("%s/%s/%s", c.Root, src.Owner, src.Repo)
+	variables := map[string]interface{}{
+		"owner":   strings.TrimPrefix(src.Owner, "~"),
+		"tracker": src.Repo,
+	}
+
+	start := 1
+	if p.Cursor.After != "" {
+		variables["cursor"] = p.Cursor.After
+		start = p.Cursor.Page
+	}
+
+	result := &packagebug.Result{Package: p}
+	fetched := 0
+	for n := start; ; n++ {
+		if p.Budget.PagesLeft(n-start, fetched, 0) == 0 {
+			after, _ := variables["cursor"].(string)
+			result.Next = &packagebug.Cursor{Page: n, After: after}
+			break
+		}
+		resp, err := c.query(ctx, p, variables)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Data.User == nil || resp.Data.User.Tracker == nil {
+			logger.Debug("tracker not found")
+			return result, nil
+		}
+		tickets := resp.Data.User.Tracker.Tickets
+		fetched += len(tickets.Results)
+		for _, t := range tickets.Results {
+			issue := t.Issue(trackerUrl)
+			if issue.UpdatedAt.After(since) && IsBug(p, issue) {
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+		if tickets.Cursor == nil {
+			break
+		}
+		variables["cursor"] = *tickets.Cursor
+	}
+	result.Project(p.Capabilities)
+	return result, nil
+}
+
+// query sends ticketsQuery with variables. The private token of the package
+// is used if set.
+func (c *Client) query(ctx context.Context, p packagebug.Package, variables map[string]interface{}) (*ticketsResponse, error) {
+	logger := packagebug.Logger(ctx)
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     ticketsQuery,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.Root+"/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	req.Header.Add("Content-Type", "application/json")
+	token := c.Token
+	if p.Token != "" {
+		token = p.Token
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	start := time.Now()
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	logger.Info("fetch", "status_code", resp.StatusCode,
+		"duration", time.Since(start))
+	packagebug.RecordPage(ctx, resp.StatusCode)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		c.block(resp)
+		return nil, packagebug.ErrRateLimited
+	default:
+		return nil, packagebug.NewStatusError(resp)
+	}
+	var tr ticketsResponse
+	err = json.NewDecoder(resp.Body).Decode(&tr)
+	if err != nil {
+		return nil, packagebug.DecodeError(err)
+	}
+	// the missing user or tracker is a null field with an error
+	if len(tr.Errors) > 0 && tr.Data.User != nil && tr.Data.User.Tracker != nil {
+		return nil, fmt.Errorf("sourcehut: %s", tr.Errors[0].Message)
+	}
+	return &tr, nil
+}
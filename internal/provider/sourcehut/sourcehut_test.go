@@ -0,0 +1,203 @@
+package sourcehut
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+var sourcehutPkgTest = packagebug.Package{
+	Id:    "1",
+	Host:  "git.sr.ht",
+	Owner: "~pyk",
+	Repo:  "byten",
+}
+
+func TestSourcehutHas(t *testing.T) {
+	c := New("", "token")
+	if !c.Has("git.sr.ht") || c.Has("github.com") {
+		t.Error("unexpected hosts")
+	}
+}
+
+func TestSourcehutIsBug(t *testing.T) {
+	issue := packagebug.Issue{Labels: []packagebug.Label{{Name: "Bug"}}}
+	p := sourcehutPkgTest
+	if !IsBug(p, issue) {
+		t.Error("expected bug label matched")
+	}
+	p.Labels = []string{"bug", "confirmed"}
+	if IsBug(p, issue) {
+		t.Error("expected every custom label required")
+	}
+	p = sourcehutPkgTest
+	p.BugLabels = []string{"defect"}
+	if !IsBug(p, packagebug.Issue{Labels: []packagebug.Label{{Name: "defect"}}}) {
+		t.Error("expected bug alias matched")
+	}
+}
+
+func TestSourcehutFetchIssues(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/query" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Variables["owner"] != "pyk" || req.Variables["tracker"] != "byten" {
+			t.Errorf("unexpected variables: %v\n", req.Variables)
+		}
+		// the first page has the cursor of the second one
+		page := map[string]interface{}{
+			"cursor": "next",
+			"results": []map[string]interface{}{{
+				"id":        1,
+				"subject":   "crash",
+				"status":    "RESOLVED",
+				"created":   "2016-01-01T00:00:00Z",
+				"updated":   "2016-01-03T00:00:00Z",
+				"submitter": map[string]string{"canonicalName": "~alice"},
+				"labels":    []map[string]string{{"name": "bug"}},
+				"assignees": []map[string]string{{"canonicalName": "~pyk"}},
+			}, {
+				"id":      2,
+				"subject": "feature",
+				"status":  "REPORTED",
+				"created": "2016-01-01T00:00:00Z",
+				"updated": "2016-01-03T00:00:00Z",
+				"labels":  []map[string]string{{"name": "feature"}},
+			}},
+		}
+		if req.Variables["cursor"] == "next" {
+			page = map[string]interface{}{
+				"cursor": nil,
+				"results": []map[string]interface{}{{
+					"id":      3,
+					"subject": "old bug",
+					"status":  "CONFIRMED",
+					"created": "2015-01-01T00:00:00Z",
+					"updated": "2015-01-02T00:00:00Z",
+					"labels":  []map[string]string{{"name": "bug"}},
+				}},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"user": map[string]interface{}{
+					"tracker": map[string]interface{}{"tickets": page},
+				},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "token")
+	since := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := c.FetchIssues(context.Background(), sourcehutPkgTest, since, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 1 {
+		t.Fatalf("expected 1 bug updated after since got: %+v\n", result.Issues)
+	}
+	issue := result.Issues[0]
+	closedAt := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	if issue.State != "closed" || issue.ClosedAt == nil || !issue.ClosedAt.Equal(closedAt) {
+		t.Errorf("expected resolved ticket closed got: %+v\n", issue)
+	}
+	if issue.Url != ts.URL+"/~pyk/byten/1" || issue.User.Username != "alice" {
+		t.Errorf("unexpected ticket: %+v\n", issue)
+	}
+	if len(issue.Assignees) != 1 || issue.Assignees[0].Username != "pyk" {
+		t.Errorf("unexpected assignees: %+v\n", issue.Assignees)
+	}
+}
+
+func TestSourcehutFetchIssuesBudget(t *testing.T) {
+	var cursors []interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		cursors = append(cursors, req.Variables["cursor"])
+		page := map[string]interface{}{
+			"cursor": fmt.Sprintf("c%d", len(cursors)+1),
+			"results": []map[string]interface{}{{
+				"id":      len(cursors),
+				"status":  "REPORTED",
+				"updated": "2016-01-03T00:00:00Z",
+				"labels":  []map[string]string{{"name": "bug"}},
+			}},
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"user": map[string]interface{}{
+					"tracker": map[string]interface{}{"tickets": page},
+				},
+			},
+		})
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "")
+	p := sourcehutPkgTest
+	p.Budget = packagebug.Budget{Pages: 2}
+	p.Cursor = packagebug.Cursor{Page: 3, After: "c1"}
+	result, err := c.FetchIssues(context.Background(), p, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 2 || cursors[0] != "c1" || cursors[1] != "c2" {
+		t.Errorf("expected the pages after c1 and c2 got: %v\n", cursors)
+	}
+	if result.Next == nil || *result.Next != (packagebug.Cursor{Page: 5, After: "c3"}) {
+		t.Errorf("expected the sync continued after c3 got: %+v\n", result.Next)
+	}
+	if len(result.Issues) != 2 {
+		t.Errorf("expected 2 issues got: %+v\n", result.Issues)
+	}
+}
+
+func TestSourcehutTrackerNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"user":{"tracker":null}}}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "token")
+	result, err := c.FetchIssues(context.Background(), sourcehutPkgTest, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Issues) != 0 {
+		t.Errorf("expected no issues got: %d\n", len(result.Issues))
+	}
+}
+
+func TestSourcehutRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "token")
+	_, err := c.FetchIssues(context.Background(), sourcehutPkgTest, time.Time{}, "")
+	if err != packagebug.ErrRateLimited {
+		t.Fatalf("expected rate limit error got: %v\n", err)
+	}
+	rate, reset, _ := c.RateLimit(context.Background(), "git.sr.ht")
+	if rate != 0 || reset == 0 {
+		t.Errorf("expected blocked got: %d %d\n", rate, reset)
+	}
+}
@@ -0,0 +1,242 @@
+// Package memory is the in-process queue for the local development, so the
+// worker runs without SQS or Redis. The messages are loaded from a JSONL file
+// or enqueued over HTTP.
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Entry is a line of the JSONL file and the request body of the enqueue
+// endpoint, e.g. {"body": "1,github.com,pyk,byten", "priority": 5}.
+type Entry struct {
+	Body     string `json:"body"`
+	Priority int    `json:"priority"`
+}
+
+// message is a queued message, it's visible once visibleAt is passed.
+type message struct {
+	queue.Message
+	priority  int
+	visibleAt time.Time
+	// deliveries counts the receives, the handle of every delivery differs
+	deliveries int
+}
+
+// Queue is the in-memory queue. The messages of higher priority are received
+// first, the received messages are redelivered if not deleted before
+// VisibilityTimeout. The messages are lost when the process exits.
+type Queue struct {
+	// VisibilityTimeout is the default visibility timeout of the received
+	// messages.
+	VisibilityTimeout time.Duration
+
+	mu       sync.Mutex
+	seq      int
+	messages []*message
+	// wake is closed when a message is sent
+	wake chan struct{}
+}
+
+// New creates the empty queue.
+func New() *Queue {
+	return &Queue{
+		VisibilityTimeout: 30 * time.Second,
+		wake:              make(chan struct{}),
+	}
+}
+
+// Load enqueues every entry of the JSONL file. The empty lines are skipped.
+func (q *Queue) Load(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return n, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		q.Send(context.Background(), e.Body, e.Priority)
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// next returns the visible message of the highest priority, the oldest one
+// among the same priority. It returns nil if there is none, q.mu is held.
+func (q *Queue) next(now time.Time) *message {
+	var next *message
+	for _, m := range q.messages {
+		if m.visibleAt.After(now) {
+			continue
+		}
+		if next == nil || m.priority > next.priority {
+			next = m
+		}
+	}
+	return next
+}
+
+// Receive implements queue.Queue.
+func (q *Queue) Receive(ctx context.Context, max int, wait time.Duration) ([]*queue.Message, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	// the hidden messages become visible without a send, so the queue is
+	// polled every second too
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		var msgs []*queue.Message
+		for len(msgs) < max {
+			m := q.next(now)
+			if m == nil {
+				break
+			}
+			m.deliveries++
+			m.Handle = m.Id + ":" + strconv.Itoa(m.deliveries)
+			m.visibleAt = now.Add(q.VisibilityTimeout)
+			msg := m.Message
+			msgs = append(msgs, &msg)
+		}
+		wake := q.wake
+		q.mu.Unlock()
+		if len(msgs) > 0 {
+			return msgs, nil
+		}
+		select {
+		case <-wake:
+		case <-poll.C:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// find returns the index of the delivery of the handle, -1 if the message is
+// deleted or redelivered since. q.mu is held.
+func (q *Queue) find(handle string) int {
+	for i, m := range q.messages {
+		if m.Handle == handle {
+			return i
+		}
+	}
+	return -1
+}
+
+// Delete implements queue.Queue.
+func (q *Queue) Delete(ctx context.Context, m *queue.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.find(m.Handle); i >= 0 {
+		q.messages = append(q.messages[:i], q.messages[i+1:]...)
+	}
+	return nil
+}
+
+// DeleteBatch implements queue.Queue.
+func (q *Queue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	for _, m := range msgs {
+		q.Delete(ctx, m)
+	}
+	return nil
+}
+
+// ChangeVisibility implements queue.Queue.
+func (q *Queue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.find(m.Handle); i >= 0 {
+		q.messages[i].visibleAt = time.Now().Add(timeout)
+	}
+	if timeout == 0 {
+		q.signal()
+	}
+	return nil
+}
+
+// Send implements queue.Sender.
+func (q *Queue) Send(ctx context.Context, body string, priority int) error {
+	return q.SendDelayed(ctx, body, priority, 0)
+}
+
+// SendDelayed implements queue.DelaySender.
+func (q *Queue) SendDelayed(ctx context.Context, body string, priority int, delay time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	m := &message{
+		Message:  queue.Message{Id: strconv.Itoa(q.seq), Body: body},
+		priority: priority,
+	}
+	if delay > 0 {
+		m.visibleAt = time.Now().Add(delay)
+	}
+	q.messages = append(q.messages, m)
+	q.signal()
+	return nil
+}
+
+// Ping implements queue.Pinger.
+func (q *Queue) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Len returns the number of the queued messages, received or not.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// Wake implements queue.Waker.
+func (q *Queue) Wake() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.wake
+}
+
+// signal wakes up the receivers, q.mu is held.
+func (q *Queue) signal() {
+	close(q.wake)
+	q.wake = make(chan struct{})
+}
+
+// ServeHTTP enqueues the Entry of the POST request body:
+//
+//	curl -d '{"body": "1,github.com,pyk,byten"}' localhost:8082
+func (q *Queue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var e Entry
+	err := json.NewDecoder(r.Body).Decode(&e)
+	if err != nil || e.Body == "" {
+		http.Error(w, "expected {\"body\": ..., \"priority\": ...}", http.StatusBadRequest)
+		return
+	}
+	q.Send(r.Context(), e.Body, e.Priority)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueuePriority(t *testing.T) {
+	q := New()
+	ctx := context.Background()
+	q.Send(ctx, "low", 0)
+	q.Send(ctx, "high", 5)
+	q.Send(ctx, "low2", 0)
+	msgs, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for _, m := range msgs {
+		bodies = append(bodies, m.Body)
+	}
+	if strings.Join(bodies, ",") != "high,low,low2" {
+		t.Errorf("expected messages by priority got: %v\n", bodies)
+	}
+}
+
+func TestQueueVisibility(t *testing.T) {
+	q := New()
+	ctx := context.Background()
+	q.Send(ctx, "1,github.com,pyk,byten", 0)
+	msgs, _ := q.Receive(ctx, 1, 0)
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message got: %d\n", len(msgs))
+	}
+	if again, _ := q.Receive(ctx, 1, 0); len(again) != 0 {
+		t.Errorf("expected received message hidden got: %d\n", len(again))
+	}
+
+	// released right away and redelivered with a new handle
+	q.ChangeVisibility(ctx, msgs[0], 0)
+	again, _ := q.Receive(ctx, 1, 0)
+	if len(again) != 1 || again[0].Handle == msgs[0].Handle {
+		t.Fatalf("expected redelivery got: %+v\n", again)
+	}
+	// the handle of the former delivery is stale
+	q.Delete(ctx, msgs[0])
+	if q.Len() != 1 {
+		t.Errorf("expected stale handle ignored got: %d\n", q.Len())
+	}
+	q.Delete(ctx, again[0])
+	if q.Len() != 0 {
+		t.Errorf("expected message deleted got: %d\n", q.Len())
+	}
+}
+
+func TestQueueSendDelayed(t *testing.T) {
+	q := New()
+	ctx := context.Background()
+	q.SendDelayed(ctx, "1,github.com,pyk,byten", 0, time.Hour)
+	q.SendDelayed(ctx, "2,github.com,pyk,byten", 0, 0)
+	msgs, _ := q.Receive(ctx, 2, 0)
+	if len(msgs) != 1 || msgs[0].Body != "2,github.com,pyk,byten" {
+		t.Errorf("expected the delayed message hidden got: %+v\n", msgs)
+	}
+}
+
+func TestQueueReceiveWait(t *testing.T) {
+	q := New()
+	ctx := context.Background()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Send(ctx, "1,github.com,pyk,byten", 0)
+	}()
+	msgs, err := q.Receive(ctx, 1, time.Second)
+	if err != nil || len(msgs) != 1 {
+		t.Errorf("expected the sent message got: %v %v\n", msgs, err)
+	}
+}
+
+func TestQueueLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	data := `{"body": "1,github.com,pyk,byten"}
+
+{"body": "2,github.com,pyk,other", "priority": 3}
+`
+	err := os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := New()
+	n, err := q.Load(path)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 messages loaded got: %d %v\n", n, err)
+	}
+	msgs, _ := q.Receive(context.Background(), 1, 0)
+	if msgs[0].Body != "2,github.com,pyk,other" {
+		t.Errorf("expected the message of higher priority got: %s\n", msgs[0].Body)
+	}
+}
+
+func TestQueueServeHTTP(t *testing.T) {
+	q := New()
+	w := httptest.NewRecorder()
+	q.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"body": "1,github.com,pyk,byten"}`)))
+	if w.Code != http.StatusAccepted || q.Len() != 1 {
+		t.Errorf("expected message enqueued got: %d %d\n", w.Code, q.Len())
+	}
+	w = httptest.NewRecorder()
+	q.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 of empty body got: %d\n", w.Code)
+	}
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Lane is a named queue of Multi.
+type Lane struct {
+	Name  string
+	Queue Queue
+}
+
+// Multi consumes the messages from several queues, the lanes, ordered by
+// priority: e.g. a fast lane of the refreshes requested by the users before
+// a bulk lane of the scheduled crawls. The lane of higher priority is always
+// drained first.
+type Multi struct {
+	Lanes []Lane
+}
+
+// Receive implements Queue. The lanes are polled in the order of priority
+// without waiting, the first lane that has messages wins. If every lane is
+// empty it waits for the lane of the highest priority only.
+func (m *Multi) Receive(ctx context.Context, max int, wait time.Duration) ([]*Message, error) {
+	for _, l := range m.Lanes {
+		msgs, err := l.Queue.Receive(ctx, max, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", l.Name, err)
+		}
+		if len(msgs) > 0 {
+			return m.mark(l, msgs), nil
+		}
+	}
+	if len(m.Lanes) == 0 || wait == 0 {
+		return nil, nil
+	}
+	l := m.Lanes[0]
+	msgs, err := l.Queue.Receive(ctx, max, wait)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", l.Name, err)
+	}
+	return m.mark(l, msgs), nil
+}
+
+// mark sets the lane of the messages.
+func (m *Multi) mark(l Lane, msgs []*Message) []*Message {
+	for _, msg := range msgs {
+		msg.Lane = l.Name
+	}
+	return msgs
+}
+
+// lane returns the queue of the lane the message is received from.
+func (m *Multi) lane(msg *Message) (Queue, error) {
+	for _, l := range m.Lanes {
+		if l.Name == msg.Lane {
+			return l.Queue, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown lane %q of message %s", msg.Lane, msg.Id)
+}
+
+// Delete implements Queue.
+func (m *Multi) Delete(ctx context.Context, msg *Message) error {
+	q, err := m.lane(msg)
+	if err != nil {
+		return err
+	}
+	return q.Delete(ctx, msg)
+}
+
+// DeleteBatch implements Queue. The messages are deleted in a batch per
+// lane.
+func (m *Multi) DeleteBatch(ctx context.Context, msgs []*Message) error {
+	lanes := make(map[string][]*Message)
+	for _, msg := range msgs {
+		lanes[msg.Lane] = append(lanes[msg.Lane], msg)
+	}
+	for _, l := range m.Lanes {
+		if len(lanes[l.Name]) == 0 {
+			continue
+		}
+		err := l.Queue.DeleteBatch(ctx, lanes[l.Name])
+		if err != nil {
+			return fmt.Errorf("%s: %s", l.Name, err)
+		}
+		delete(lanes, l.Name)
+	}
+	for name := range lanes {
+		return fmt.Errorf("unknown lane %q", name)
+	}
+	return nil
+}
+
+// ChangeVisibility implements Queue.
+func (m *Multi) ChangeVisibility(ctx context.Context, msg *Message, timeout time.Duration) error {
+	q, err := m.lane(msg)
+	if err != nil {
+		return err
+	}
+	return q.ChangeVisibility(ctx, msg, timeout)
+}
+
+// Send implements Sender. The messages of positive priority are sent to the
+// lane of the highest priority, the rest to the lane of the lowest priority.
+func (m *Multi) Send(ctx context.Context, body string, priority int) error {
+	return m.SendDelayed(ctx, body, priority, 0)
+}
+
+// SendDelayed implements DelaySender, the message is sent to the lane of
+// Send and delayed if the queue of the lane supports it.
+func (m *Multi) SendDelayed(ctx context.Context, body string, priority int, delay time.Duration) error {
+	if len(m.Lanes) == 0 {
+		return fmt.Errorf("no lane")
+	}
+	l := m.Lanes[len(m.Lanes)-1]
+	if priority > 0 {
+		l = m.Lanes[0]
+	}
+	s, ok := l.Queue.(Sender)
+	if !ok {
+		return fmt.Errorf("%s: queue can't send messages", l.Name)
+	}
+	return SendDelayed(ctx, s, body, priority, delay)
+}
+
+// Ping implements Pinger, every lane must be reachable.
+func (m *Multi) Ping(ctx context.Context) error {
+	for _, l := range m.Lanes {
+		if p, ok := l.Queue.(Pinger); ok {
+			err := p.Ping(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %s", l.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package queue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+func TestMultiReceive(t *testing.T) {
+	high := fake.NewQueue("1,github.com,pyk,high")
+	low := fake.NewQueue("2,github.com,pyk,low", "3,github.com,pyk,low")
+	m := &queue.Multi{Lanes: []queue.Lane{{Name: "high", Queue: high}, {Name: "low", Queue: low}}}
+	ctx := context.Background()
+
+	// the high lane is drained first
+	msgs, err := m.Receive(ctx, queue.MaxBatch, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Lane != "high" {
+		t.Fatalf("expected message of high lane got: %+v\n", msgs)
+	}
+	msgs, err = m.Receive(ctx, queue.MaxBatch, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Lane != "low" {
+		t.Fatalf("expected messages of low lane got: %+v\n", msgs)
+	}
+
+	// the messages are deleted from their own lane
+	err = m.DeleteBatch(ctx, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low.Deleted()) != 2 || len(high.Deleted()) != 0 {
+		t.Errorf("expected 2 deleted of low lane got: %d %d\n",
+			len(low.Deleted()), len(high.Deleted()))
+	}
+	err = m.Delete(ctx, &queue.Message{Id: "4", Lane: "bulk"})
+	if err == nil {
+		t.Error("expected error of unknown lane")
+	}
+}
+
+func TestMultiSend(t *testing.T) {
+	high, low := fake.NewQueue(), fake.NewQueue()
+	m := &queue.Multi{Lanes: []queue.Lane{{Name: "high", Queue: high}, {Name: "low", Queue: low}}}
+	ctx := context.Background()
+	m.Send(ctx, "1,github.com,pyk,byten", 1)
+	m.Send(ctx, "2,github.com,pyk,byten", 0)
+	if len(high.Messages()) != 1 || len(low.Messages()) != 1 {
+		t.Errorf("expected a message per lane got: %v %v\n",
+			high.Messages(), low.Messages())
+	}
+}
+
+func TestSendDelayed(t *testing.T) {
+	// the queue without delay sends right away
+	q := fake.NewQueue()
+	err := queue.SendDelayed(context.Background(), q, "1,github.com,pyk,byten", 0, time.Minute)
+	if err != nil || len(q.Messages()) != 1 {
+		t.Errorf("expected message sent got: %v %v\n", q.Messages(), err)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if queue.Jitter(0) != 0 || queue.Jitter(-time.Second) != 0 {
+		t.Errorf("expected no jitter\n")
+	}
+	for i := 0; i < 100; i++ {
+		if d := queue.Jitter(time.Minute); d < 0 || d >= time.Minute {
+			t.Fatalf("expected jitter within a minute got: %s\n", d)
+		}
+	}
+}
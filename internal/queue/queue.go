@@ -0,0 +1,84 @@
+// Package queue defines the queue the worker consumes the messages from.
+package queue
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// MaxBatch is the maximum number of messages received or deleted at once.
+const MaxBatch = 10
+
+// Message represents a message received from the queue.
+type Message struct {
+	Id   string `json:"id"`
+	Body string `json:"body"`
+	// Handle identifies the delivery of the message, it's used to delete or
+	// change the visibility of the message.
+	Handle string `json:"handle"`
+	// Lane is the name of the queue of Multi the message is received from
+	Lane string `json:"lane,omitempty"`
+}
+
+// Queue is the backend where the worker consumes the messages from.
+type Queue interface {
+	// Receive waits at most wait until there is at least one message and
+	// returns at most max messages. It returns empty slice if there is no
+	// message.
+	Receive(ctx context.Context, max int, wait time.Duration) ([]*Message, error)
+
+	// Delete acknowledges the message, so it's not redelivered.
+	Delete(ctx context.Context, m *Message) error
+
+	// DeleteBatch acknowledges at most MaxBatch messages at once.
+	DeleteBatch(ctx context.Context, msgs []*Message) error
+
+	// ChangeVisibility hides the message from other consumers for timeout.
+	ChangeVisibility(ctx context.Context, m *Message, timeout time.Duration) error
+}
+
+// Sender is implemented by the queue that can enqueue the messages. The
+// messages of higher priority are received first if the queue supports it.
+type Sender interface {
+	Send(ctx context.Context, body string, priority int) error
+}
+
+// MaxDelay is the longest delay of a sent message, the limit of SQS.
+const MaxDelay = 15 * time.Minute
+
+// DelaySender is implemented by the queue that can delay the sent messages,
+// the delayed message is received after delay.
+type DelaySender interface {
+	SendDelayed(ctx context.Context, body string, priority int, delay time.Duration) error
+}
+
+// SendDelayed sends the message delayed by at most MaxDelay if s is a
+// DelaySender, otherwise right away.
+func SendDelayed(ctx context.Context, s Sender, body string, priority int, delay time.Duration) error {
+	ds, ok := s.(DelaySender)
+	if !ok || delay <= 0 {
+		return s.Send(ctx, body, priority)
+	}
+	return ds.SendDelayed(ctx, body, priority, min(delay, MaxDelay))
+}
+
+// Jitter returns a random delay in [0, max), zero if max is not positive.
+func Jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// Waker is implemented by the queue that signals the sent messages, so the
+// idle consumer wakes up right away.
+type Waker interface {
+	// Wake returns the channel closed when the next message is sent.
+	Wake() <-chan struct{}
+}
+
+// Pinger is implemented by the queue that can check its reachability.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
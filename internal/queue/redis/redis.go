@@ -0,0 +1,178 @@
+// Package redis consumes the messages from Redis list.
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Queue consumes the messages from Redis list. Producers push the
+// message body with LPUSH to Key. Received messages are moved to the
+// processing list and redelivered if not deleted before its visibility
+// timeout. Every delivery has its own handle, see envelope, so the copies of
+// the same body are deleted and redelivered on their own.
+type Queue struct {
+	Redis *goredis.Client
+	Key   string
+	// VisibilityTimeout is the default visibility timeout of received
+	// messages.
+	VisibilityTimeout time.Duration
+}
+
+// New creates Redis queue that consumes the list key.
+func New(rawurl, key string) (*Queue, error) {
+	opt, err := goredis.ParseURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		key = "packagebug"
+	}
+	return &Queue{
+		Redis:             goredis.NewClient(opt),
+		Key:               key,
+		VisibilityTimeout: 30 * time.Second,
+	}, nil
+}
+
+func (q *Queue) processingKey() string { return q.Key + ":processing" }
+func (q *Queue) inflightKey() string   { return q.Key + ":inflight" }
+func (q *Queue) seqKey() string        { return q.Key + ":seq" }
+
+// envelope returns the handle of the delivery id of body, <id>:<body>. The
+// handle is the member of the processing list and the inflight set.
+func envelope(id int64, body string) string {
+	return strconv.FormatInt(id, 10) + ":" + body
+}
+
+// unwrap returns the body of the handle. The handle without id, e.g. of the
+// message received before the envelopes, is the body itself.
+func unwrap(handle string) string {
+	id, body, ok := strings.Cut(handle, ":")
+	if !ok || id == "" || strings.Trim(id, "0123456789") != "" {
+		return handle
+	}
+	return body
+}
+
+// receiveScript moves the next message of KEYS[1] to the processing list
+// KEYS[2] and the inflight set KEYS[3] until ARGV[1] in the envelope of the
+// next id of KEYS[4]. It returns the handle, nil if the list is empty.
+var receiveScript = goredis.NewScript(`
+local body = redis.call('RPOP', KEYS[1])
+if not body then
+	return false
+end
+local handle = redis.call('INCR', KEYS[4]) .. ':' .. body
+redis.call('LPUSH', KEYS[2], handle)
+redis.call('ZADD', KEYS[3], ARGV[1], handle)
+return handle
+`)
+
+// requeueScript moves the body of the deliveries of the inflight set
+// KEYS[3] expired by ARGV[1] from the processing list KEYS[2] back to the
+// consuming end of KEYS[1]. It returns the number of expired deliveries.
+var requeueScript = goredis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1])
+for _, handle in ipairs(expired) do
+	if redis.call('LREM', KEYS[2], 1, handle) > 0 then
+		local body = string.match(handle, '^%d+:(.*)$') or handle
+		redis.call('RPUSH', KEYS[1], body)
+	end
+	redis.call('ZREM', KEYS[3], handle)
+end
+return #expired
+`)
+
+// requeue moves back the messages with expired visibility timeout. The
+// consumers requeue at once, the script runs atomically.
+func (q *Queue) requeue(ctx context.Context) error {
+	keys := []string{q.Key, q.processingKey(), q.inflightKey()}
+	return requeueScript.Run(ctx, q.Redis, keys, time.Now().Unix()).Err()
+}
+
+// Receive implements queue.Queue.
+func (q *Queue) Receive(ctx context.Context, max int, wait time.Duration) ([]*queue.Message, error) {
+	err := q.requeue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []*queue.Message
+	keys := []string{q.Key, q.processingKey(), q.inflightKey(), q.seqKey()}
+	waited := false
+	for len(msgs) < max {
+		deadline := time.Now().Add(q.VisibilityTimeout).Unix()
+		handle, err := receiveScript.Run(ctx, q.Redis, keys, deadline).Text()
+		// only block for the first message: the list is rotated in place
+		// once a message arrives, the script takes it
+		if errors.Is(err, goredis.Nil) && len(msgs) == 0 && wait > 0 && !waited {
+			waited = true
+			err = q.Redis.BLMove(ctx, q.Key, q.Key, "RIGHT", "RIGHT", wait).Err()
+			if err == nil {
+				continue
+			}
+		}
+		if errors.Is(err, goredis.Nil) {
+			break
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, &queue.Message{
+			Id:     packagebug.NewCorrelationId(),
+			Body:   unwrap(handle),
+			Handle: handle,
+		})
+	}
+	return msgs, nil
+}
+
+// Delete implements queue.Queue.
+func (q *Queue) Delete(ctx context.Context, m *queue.Message) error {
+	return q.DeleteBatch(ctx, []*queue.Message{m})
+}
+
+// DeleteBatch implements queue.Queue. The deliveries are removed from the
+// processing list and the inflight set in a single transaction.
+func (q *Queue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	_, err := q.Redis.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		for _, m := range msgs {
+			p.LRem(ctx, q.processingKey(), 1, m.Handle)
+			p.ZRem(ctx, q.inflightKey(), m.Handle)
+		}
+		return nil
+	})
+	return err
+}
+
+// ChangeVisibility implements queue.Queue. The deleted or requeued delivery
+// is left alone.
+func (q *Queue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout).Unix()
+	return q.Redis.ZAddXX(ctx, q.inflightKey(), goredis.Z{
+		Score:  float64(deadline),
+		Member: m.Handle,
+	}).Err()
+}
+
+// Send implements queue.Sender. The message with positive priority is pushed to
+// the consuming end of the list, so it's received before the others.
+func (q *Queue) Send(ctx context.Context, body string, priority int) error {
+	if priority > 0 {
+		return q.Redis.RPush(ctx, q.Key, body).Err()
+	}
+	return q.Redis.LPush(ctx, q.Key, body).Err()
+}
+
+// Ping implements queue.Pinger.
+func (q *Queue) Ping(ctx context.Context) error {
+	return q.Redis.Ping(ctx).Err()
+}
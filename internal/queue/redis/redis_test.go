@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// testQueue returns the queue of a fresh key of the Redis of
+// PACKAGEBUG_REDIS_TEST.
+func testQueue(t *testing.T) *Queue {
+	rawurl := os.Getenv("PACKAGEBUG_REDIS_TEST")
+	if rawurl == "" {
+		t.Skip("PACKAGEBUG_REDIS_TEST not set")
+	}
+	q, err := New(rawurl, "packagebug-test-"+packagebug.NewCorrelationId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		q.Redis.Del(context.Background(), q.Key, q.processingKey(),
+			q.inflightKey(), q.seqKey())
+		q.Redis.Close()
+	})
+	return q
+}
+
+func TestEnvelope(t *testing.T) {
+	body := "1,github.com,pyk,byten,0"
+	handle := envelope(42, body)
+	if handle != "42:"+body {
+		t.Errorf("unexpected handle got: %s\n", handle)
+	}
+	if b := unwrap(handle); b != body {
+		t.Errorf("expected body %s got: %s\n", body, b)
+	}
+	// the handles received before the envelopes are the body
+	for _, h := range []string{body, "x:" + body, ":" + body} {
+		if b := unwrap(h); b != h {
+			t.Errorf("expected body %s got: %s\n", h, b)
+		}
+	}
+}
+
+func TestQueueReceiveDelete(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	err := q.Send(ctx, "1,github.com,pyk,byten", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.Send(ctx, "2,github.com,pyk,other", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the priority message is received first
+	if len(msgs) != 2 || msgs[0].Body != "2,github.com,pyk,other" || msgs[1].Body != "1,github.com,pyk,byten" {
+		t.Fatalf("unexpected messages: %+v\n", msgs)
+	}
+	err = q.DeleteBatch(ctx, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := q.Redis.LLen(ctx, q.processingKey()).Result()
+	inflight, _ := q.Redis.ZCard(ctx, q.inflightKey()).Result()
+	if n != 0 || inflight != 0 {
+		t.Errorf("expected no message in flight got: %d %d\n", n, inflight)
+	}
+
+	// the empty queue waits for a message
+	start := time.Now()
+	msgs, err = q.Receive(ctx, 10, time.Second)
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("expected no message got: %+v %v\n", msgs, err)
+	}
+	if time.Since(start) < 500*time.Millisecond {
+		t.Errorf("expected the receive to wait got: %s\n", time.Since(start))
+	}
+}
+
+func TestQueueDuplicateBody(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	body := "1,github.com,pyk,byten"
+	for i := 0; i < 2; i++ {
+		err := q.Send(ctx, body, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Handle == msgs[1].Handle {
+		t.Fatalf("expected 2 deliveries with their own handle got: %+v\n", msgs)
+	}
+
+	// the delete of the first copy keeps the second in flight
+	err = q.Delete(ctx, msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, err := q.Redis.ZScore(ctx, q.inflightKey(), msgs[1].Handle).Result()
+	if err != nil || score == 0 {
+		t.Fatalf("expected the second copy in flight got: %v %v\n", score, err)
+	}
+
+	// the second copy is redelivered once its visibility expires
+	err = q.ChangeVisibility(ctx, msgs[1], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redelivered, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redelivered) != 1 || redelivered[0].Body != body || redelivered[0].Handle == msgs[1].Handle {
+		t.Fatalf("expected the second copy redelivered got: %+v\n", redelivered)
+	}
+	n, _ := q.Redis.LLen(ctx, q.processingKey()).Result()
+	if n != 1 {
+		t.Errorf("expected 1 message processing got: %d\n", n)
+	}
+
+	// the change of the deleted delivery is ignored
+	err = q.Delete(ctx, redelivered[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.ChangeVisibility(ctx, redelivered[0], time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflight, _ := q.Redis.ZCard(ctx, q.inflightKey()).Result()
+	if inflight != 0 {
+		t.Errorf("expected no message in flight got: %d\n", inflight)
+	}
+}
+
+func TestQueueRequeueLegacy(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	// the message received before the envelopes is in flight by its body
+	body := "1,github.com,pyk,byten"
+	q.Redis.LPush(ctx, q.processingKey(), body)
+	q.Redis.ZAdd(ctx, q.inflightKey(), goredis.Z{Score: 0, Member: body})
+
+	msgs, err := q.Receive(ctx, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Body != body {
+		t.Fatalf("expected the legacy message redelivered got: %+v\n", msgs)
+	}
+}
@@ -0,0 +1,198 @@
+// Package sqs consumes the messages from Amazon SQS.
+package sqs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Queue consumes the messages from Amazon SQS.
+type Queue struct {
+	SQS      *awssqs.Client
+	QueueUrl string
+	Cred     aws.CredentialsProvider
+}
+
+// New creates SQS queue of the queue url or name using the credentials of
+// the default chain: the environment, the shared config files, the web
+// identity token of the IAM roles for service accounts and the ECS or EC2
+// instance roles. The name is resolved to the url of the queue. The
+// endpoint of the service is optional, e.g. the url of LocalStack.
+func New(endpoint, region, queue string) (*Queue, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	client := awssqs.NewFromConfig(cfg, func(o *awssqs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	q := &Queue{
+		SQS:      client,
+		QueueUrl: queue,
+		Cred:     cfg.Credentials,
+	}
+	if !IsQueueUrl(queue) {
+		resp, err := client.GetQueueUrl(context.Background(), &awssqs.GetQueueUrlInput{
+			QueueName: aws.String(queue),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("resolve queue %q: %w", queue, err)
+		}
+		q.QueueUrl = aws.ToString(resp.QueueUrl)
+	}
+	return q, nil
+}
+
+// IsQueueUrl returns true if queue is the url of the queue rather than its
+// name.
+func IsQueueUrl(queue string) bool {
+	return strings.HasPrefix(queue, "http://") || strings.HasPrefix(queue, "https://")
+}
+
+// IsFifo returns true if queue is the url of a FIFO queue.
+func IsFifo(queue string) bool {
+	return strings.HasSuffix(queue, ".fifo")
+}
+
+// fifoIds returns the message group and the deduplication id of the body
+// sent to the FIFO queue. The messages of a package share the group, so the
+// packages are still received concurrently, and SQS drops the same message
+// of the package sent again within its 5 minutes deduplication interval,
+// see packagebug.Message.DedupeKey. The other bodies share a single group.
+func fifoIds(body string) (group, dedupe string) {
+	group, key := "packagebug", body
+	m, err := packagebug.ParseMessage(body)
+	if err == nil {
+		group, key = hash(m.Path()), m.DedupeKey()
+	}
+	return group, hash(key)
+}
+
+// hash returns the hex sha256 of s, the ids of SQS are at most 128
+// characters.
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Receive implements queue.Queue.
+func (q *Queue) Receive(ctx context.Context, max int, wait time.Duration) ([]*queue.Message, error) {
+	resp, err := q.SQS.ReceiveMessage(ctx, &awssqs.ReceiveMessageInput{
+		MaxNumberOfMessages: int32(max),
+		QueueUrl:            aws.String(q.QueueUrl),
+		WaitTimeSeconds:     int32(wait.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*queue.Message, 0, len(resp.Messages))
+	for _, m := range resp.Messages {
+		msgs = append(msgs, &queue.Message{
+			Id:     aws.ToString(m.MessageId),
+			Body:   aws.ToString(m.Body),
+			Handle: aws.ToString(m.ReceiptHandle),
+		})
+	}
+	return msgs, nil
+}
+
+// Delete implements queue.Queue.
+func (q *Queue) Delete(ctx context.Context, m *queue.Message) error {
+	_, err := q.SQS.DeleteMessage(ctx, &awssqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.QueueUrl),
+		ReceiptHandle: aws.String(m.Handle),
+	})
+	return err
+}
+
+// DeleteBatch implements queue.Queue.
+func (q *Queue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	input := &awssqs.DeleteMessageBatchInput{QueueUrl: aws.String(q.QueueUrl)}
+	for i, m := range msgs {
+		input.Entries = append(input.Entries, types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: aws.String(m.Handle),
+		})
+	}
+	resp, err := q.SQS.DeleteMessageBatch(ctx, input)
+	if err != nil {
+		return err
+	}
+	if len(resp.Failed) > 0 {
+		f := resp.Failed[0]
+		return fmt.Errorf("delete %d of %d messages failed: %s: %s",
+			len(resp.Failed), len(msgs), aws.ToString(f.Code),
+			aws.ToString(f.Message))
+	}
+	return nil
+}
+
+// ChangeVisibility implements queue.Queue.
+func (q *Queue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	_, err := q.SQS.ChangeMessageVisibility(ctx, &awssqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.QueueUrl),
+		ReceiptHandle:     aws.String(m.Handle),
+		VisibilityTimeout: int32(timeout.Seconds()),
+	})
+	return err
+}
+
+// Send implements queue.Sender. SQS has no priority, the priority is only sent as
+// the message attribute. The messages sent to the FIFO queue are deduplicated,
+// see fifoIds.
+func (q *Queue) Send(ctx context.Context, body string, priority int) error {
+	return q.SendDelayed(ctx, body, priority, 0)
+}
+
+// SendDelayed implements queue.DelaySender, the delay is rounded down to
+// seconds. The messages of the FIFO queues are sent right away, their delay
+// is the one of the queue.
+func (q *Queue) SendDelayed(ctx context.Context, body string, priority int, delay time.Duration) error {
+	input := &awssqs.SendMessageInput{
+		QueueUrl:    aws.String(q.QueueUrl),
+		MessageBody: aws.String(body),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"priority": {
+				DataType:    aws.String("Number"),
+				StringValue: aws.String(strconv.Itoa(priority)),
+			},
+		},
+	}
+	if IsFifo(q.QueueUrl) {
+		group, dedupe := fifoIds(body)
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = aws.String(dedupe)
+	} else if delay > 0 {
+		input.DelaySeconds = int32(min(delay, queue.MaxDelay) / time.Second)
+	}
+	_, err := q.SQS.SendMessage(ctx, input)
+	return err
+}
+
+// Ping checks the credentials are valid and the queue is reachable.
+func (q *Queue) Ping(ctx context.Context) error {
+	_, err := q.Cred.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = q.SQS.GetQueueAttributes(ctx, &awssqs.GetQueueAttributesInput{
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		QueueUrl:       aws.String(q.QueueUrl),
+	})
+	return err
+}
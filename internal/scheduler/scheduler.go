@@ -0,0 +1,237 @@
+// Package scheduler enqueues the packages that are due to be fetched.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+const (
+	// MaxPriority is the priority of the most active packages.
+	MaxPriority = 9
+	// MinFetchInterval & MaxFetchInterval bound the fetch interval of the
+	// most and the least active packages.
+	MinFetchInterval = time.Hour
+	MaxFetchInterval = 24 * time.Hour
+	// ActivityWindow is the period where the issue updates are counted as
+	// the package activity.
+	ActivityWindow = 30 * 24 * time.Hour
+	// EmptyWindow & MaxEmptyInterval back off the packages without bugs:
+	// their interval doubles for every EmptyWindow they stay empty, up to
+	// MaxEmptyInterval.
+	EmptyWindow      = 7 * 24 * time.Hour
+	MaxEmptyInterval = 7 * 24 * time.Hour
+)
+
+// Schedule returns the fetch interval and the priority of the package from
+// its activity, the number of issues updated within ActivityWindow. The
+// interval is halved for every doubling of the activity.
+func Schedule(activity int) (time.Duration, int) {
+	priority := 0
+	for n := activity; n > 0 && priority < MaxPriority; n /= 2 {
+		priority++
+	}
+	interval := MaxFetchInterval >> uint(priority)
+	if interval < MinFetchInterval {
+		interval = MinFetchInterval
+	}
+	return interval, priority
+}
+
+// EmptyInterval returns the fetch interval of the package without bugs for
+// empty, e.g. most packages never have a bug label. The interval doubles
+// for every EmptyWindow the package stays empty up to MaxEmptyInterval, so
+// the quota concentrates on the active packages. The package whose bugs
+// show up is not empty anymore and decays back to its interval.
+func EmptyInterval(interval, empty time.Duration) time.Duration {
+	for n := empty / EmptyWindow; n > 0 && interval < MaxEmptyInterval; n-- {
+		interval = min(2*interval, MaxEmptyInterval)
+	}
+	return interval
+}
+
+// Scheduler enqueues the packages that are due to be fetched. Active
+// packages are fetched more often and with higher priority, see Schedule.
+type Scheduler struct {
+	DB     *sql.DB
+	Sender queue.Sender
+	// Batch is the maximum number of packages enqueued per scan
+	Batch int
+	// Grace is how long the issues of the gone packages are kept, the
+	// issues are never pruned if zero
+	Grace time.Duration
+	// Jitter spreads the fetches of the packages due at once, e.g. at the
+	// top of the hour: every package is offset by a random Jitter, its
+	// message is delayed by the offset up to queue.MaxDelay and its next
+	// fetch by the whole offset. zero disables.
+	Jitter time.Duration
+}
+
+// Scan enqueues the due packages and sets their next fetch time. It returns
+// the number of enqueued packages. The subpackages linked to the package of
+// their repository are fetched with it, they are not enqueued. The packages
+// without bugs are fetched less often the longer they stay empty, see
+// EmptyInterval. The fetches are spread by Jitter.
+func (s *Scheduler) Scan(ctx context.Context) (int, error) {
+	query := `
+	SELECT p.package_id, p.package_host, p.package_owner, p.package_repo,
+		coalesce(p.package_subpath, ''), count(i.issue_number),
+		p.package_empty_since, p.package_tenant
+	FROM packages p
+	LEFT JOIN issues i ON i.package_id=p.package_id
+		AND i.issue_updated_at > now() - $1::interval
+	WHERE (p.package_next_fetch_at IS NULL OR p.package_next_fetch_at <= now())
+		AND p.package_root_id IS NULL
+	GROUP BY p.package_id
+	ORDER BY p.package_next_fetch_at NULLS FIRST
+	LIMIT $2`
+	window := fmt.Sprintf("%d seconds", int(ActivityWindow.Seconds()))
+	rows, err := s.DB.QueryContext(ctx, query, window, s.Batch)
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		p          packagebug.Package
+		activity   int
+		emptySince sql.NullTime
+	}
+	var packages []due
+	for rows.Next() {
+		var d due
+		err = rows.Scan(&d.p.Id, &d.p.Host, &d.p.Owner, &d.p.Repo, &d.p.Subpath,
+			&d.activity, &d.emptySince, &d.p.Tenant)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		packages = append(packages, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	query = `
+	UPDATE packages
+	SET package_next_fetch_at=now() + $1::interval, package_priority=$2
+	WHERE package_id=$3`
+	for i, d := range packages {
+		interval, priority := Schedule(d.activity)
+		if d.emptySince.Valid {
+			interval = EmptyInterval(interval, time.Since(d.emptySince.Time))
+		}
+		offset := queue.Jitter(s.Jitter)
+		err = queue.SendDelayed(ctx, s.Sender, packagebug.FormatMessage(d.p, priority),
+			priority, offset)
+		if err != nil {
+			return i, err
+		}
+		next := fmt.Sprintf("%d seconds", int((interval + offset).Seconds()))
+		_, err = s.DB.ExecContext(ctx, query, next, priority, d.p.Id)
+		if err != nil {
+			return i + 1, err
+		}
+	}
+	return len(packages), nil
+}
+
+// Prune deletes the issues and resets the sync state of the packages gone
+// for longer than Grace, so the package that comes back is fetched in full.
+// The packages are kept. It returns the number of deleted issues.
+func (s *Scheduler) Prune(ctx context.Context) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	grace := fmt.Sprintf("%d seconds", int(s.Grace.Seconds()))
+	query := `
+	DELETE FROM issues
+	WHERE package_id IN (
+		SELECT package_id FROM packages
+		WHERE package_gone_at < now() - $1::interval)`
+	res, err := tx.ExecContext(ctx, query, grace)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	query = `
+	UPDATE packages
+	SET package_etag=NULL, package_since=NULL, package_repo_etag=NULL
+	WHERE package_gone_at < now() - $1::interval
+	AND (package_etag IS NOT NULL OR package_since IS NOT NULL)`
+	_, err = tx.ExecContext(ctx, query, grace)
+	if err != nil {
+		return 0, err
+	}
+	query = `
+	UPDATE package_stats
+	SET open_bugs=0, closed_bugs=0, median_time_to_close=NULL, updated_at=now()
+	WHERE package_id IN (
+		SELECT package_id FROM packages
+		WHERE package_gone_at < now() - $1::interval)
+	AND (open_bugs > 0 OR closed_bugs > 0)`
+	_, err = tx.ExecContext(ctx, query, grace)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// PruneCache deletes the cached responses older than packagebug.CacheTTL.
+// It returns the number of deleted responses.
+func (s *Scheduler) PruneCache(ctx context.Context) (int64, error) {
+	query := `
+	DELETE FROM response_cache
+	WHERE cache_updated_at < now() - $1::interval`
+	ttl := fmt.Sprintf("%d seconds", int(packagebug.CacheTTL.Seconds()))
+	res, err := s.DB.ExecContext(ctx, query, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Run scans the due packages every interval until ctx is done. The issues of
+// the gone packages are pruned after every scan if Grace is set, the expired
+// cached responses are always pruned.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Scan(ctx)
+		if err != nil {
+			slog.Error("schedule failed", "error", err, "enqueued", n)
+		} else {
+			slog.Info("scheduled", "enqueued", n)
+		}
+		if s.Grace > 0 {
+			pruned, err := s.Prune(ctx)
+			if err != nil {
+				slog.Error("prune gone packages failed", "error", err)
+			} else if pruned > 0 {
+				slog.Info("gone packages pruned", "issues", pruned)
+			}
+		}
+		pruned, err := s.PruneCache(ctx)
+		if err != nil {
+			slog.Error("prune response cache failed", "error", err)
+		} else if pruned > 0 {
+			slog.Info("response cache pruned", "responses", pruned)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	cases := []struct {
+		activity int
+		interval time.Duration
+		priority int
+	}{
+		{0, 24 * time.Hour, 0},
+		{1, 12 * time.Hour, 1},
+		{3, 6 * time.Hour, 2},
+		{8, 90 * time.Minute, 4},
+		{1000, time.Hour, MaxPriority},
+	}
+	for _, c := range cases {
+		interval, priority := Schedule(c.activity)
+		if interval != c.interval || priority != c.priority {
+			t.Errorf("activity %d: expected %s/%d got: %s/%d\n", c.activity,
+				c.interval, c.priority, interval, priority)
+		}
+	}
+}
+
+func TestEmptyInterval(t *testing.T) {
+	day := 24 * time.Hour
+	cases := []struct {
+		empty    time.Duration
+		interval time.Duration
+	}{
+		{0, day},
+		{6 * day, day},
+		{7 * day, 2 * day},
+		{15 * day, 4 * day},
+		{22 * day, MaxEmptyInterval},
+		{365 * day, MaxEmptyInterval},
+	}
+	for _, c := range cases {
+		interval := EmptyInterval(day, c.empty)
+		if interval != c.interval {
+			t.Errorf("empty %s: expected %s got: %s\n", c.empty, c.interval, interval)
+		}
+	}
+}
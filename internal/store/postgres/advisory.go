@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+)
+
+// SaveAdvisories replaces the stored advisories of the package.
+func (s *Store) SaveAdvisories(p packagebug.Package, advisories []packagebug.Advisory) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveAdvisories(tx, p, advisories)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+func saveAdvisories(tx *sql.Tx, p packagebug.Package, advisories []packagebug.Advisory) error {
+	query := `
+	DELETE FROM advisories
+	WHERE package_id=(SELECT package_id FROM packages WHERE package_path=$1 AND package_tenant=$2)`
+	_, err := tx.Exec(query, p.Path(), p.Tenant)
+	if err != nil {
+		return err
+	}
+	query = `
+	INSERT INTO advisories(package_id, advisory_id, advisory_aliases,
+		advisory_summary, advisory_severity, advisory_url,
+		advisory_published_at, advisory_modified_at, advisory_withdrawn_at)
+	SELECT package_id, $2, $3, $4, $5, $6, $7, $8, $9
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$10
+	ON CONFLICT (package_id, advisory_id) DO NOTHING`
+	for _, a := range advisories {
+		aliases := a.Aliases
+		if aliases == nil {
+			aliases = []string{}
+		}
+		_, err = tx.Exec(query, p.Path(), a.Id, pq.Array(aliases), a.Summary,
+			a.Severity, a.Url, nullTime(a.PublishedAt), nullTime(a.ModifiedAt),
+			a.WithdrawnAt, p.Tenant)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+)
+
+// batch writes the issues of a result within a single transaction. Every
+// statement is prepared once and reused for all issues of the result.
+type batch struct {
+	tx          *sql.Tx
+	packageId   string
+	packagePath string
+	// outbox writes the changes of the issues to the outbox
+	outbox bool
+	// users and milestones already stored by the batch, most issues share
+	// a few of them
+	users      map[int64]bool
+	milestones map[int]bool
+	// labels is the buffer of the label names shared by the issues
+	labels []string
+
+	issue           *sql.Stmt
+	user            *sql.Stmt
+	milestone       *sql.Stmt
+	deleteLabels    *sql.Stmt
+	label           *sql.Stmt
+	deleteAssignees *sql.Stmt
+	assignee        *sql.Stmt
+	comment         *sql.Stmt
+	event           *sql.Stmt
+	state           *sql.Stmt
+	outboxEvent     *sql.Stmt
+	link            *sql.Stmt
+	release         *sql.Stmt
+}
+
+// newBatch prepares the statements within tx. The statements are closed
+// when tx is committed or rolled back.
+func newBatch(tx *sql.Tx, p packagebug.Package, outbox bool) (*batch, error) {
+	b := &batch{tx: tx, packageId: p.Id, packagePath: p.Path(),
+		outbox: outbox, users: make(map[int64]bool),
+		milestones: make(map[int]bool)}
+	stmts := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&b.issue, `
+	INSERT INTO issues(issue_github_id, package_id, issue_number,
+		issue_title, issue_url, issue_api_url, issue_api_labels_url,
+		issue_api_comments_url, issue_api_events_url, issue_body,
+		issue_reactions_plus_one, issue_updated_at, issue_language,
+		issue_state, issue_created_at, issue_closed_at,
+		issue_user_github_id, issue_is_pull_request, issue_assignees,
+		issue_milestone, issue_milestone_number, issue_bug_labeled_at,
+		issue_bug_unlabeled_at, issue_severity, issue_search, issue_tenant)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+		$15, $16, $17, $18, $19, $20, $21, $22, $23, $24, ` + searchVector + `,
+		(SELECT package_tenant FROM packages WHERE package_id=$2))
+	ON CONFLICT (package_id, issue_number) DO UPDATE
+	SET issue_github_id=$1, issue_title=$4, issue_url=$5, issue_body=$10,
+		issue_reactions_plus_one=$11, issue_updated_at=$12,
+		issue_language=$13, issue_state=$14, issue_created_at=$15,
+		issue_closed_at=$16, issue_user_github_id=$17,
+		issue_is_pull_request=$18, issue_assignees=$19, issue_milestone=$20,
+		issue_milestone_number=$21, issue_severity=$24,
+		issue_search=excluded.issue_search,
+		issue_tenant=excluded.issue_tenant, issue_removed_at=NULL,
+		issue_bug_labeled_at=coalesce($22, issues.issue_bug_labeled_at),
+		issue_bug_unlabeled_at=CASE WHEN $22 IS NULL
+			THEN issues.issue_bug_unlabeled_at ELSE $23 END
+	WHERE issues.issue_updated_at IS NULL OR excluded.issue_updated_at IS NULL
+		OR issues.issue_updated_at <= excluded.issue_updated_at`},
+		{&b.user, `
+	INSERT INTO users(user_github_id, user_username, user_avatar_url,
+		user_profile_url)
+	VALUES($1, $2, $3, $4)
+	ON CONFLICT (user_github_id) DO UPDATE
+	SET user_username=$2, user_avatar_url=$3, user_profile_url=$4`},
+		{&b.milestone, `
+	INSERT INTO milestones(package_id, milestone_number, milestone_title,
+		milestone_state, milestone_due_on)
+	VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (package_id, milestone_number) DO UPDATE
+	SET milestone_title=$3, milestone_state=$4, milestone_due_on=$5`},
+		{&b.deleteLabels, `
+	DELETE FROM issue_labels
+	WHERE package_id=$1 AND issue_number=$2`},
+		{&b.label, `
+	INSERT INTO issue_labels(package_id, issue_number, label_name)
+	SELECT $1::bigint, $2::integer, unnest($3::text[])
+	ON CONFLICT DO NOTHING`},
+		{&b.deleteAssignees, `
+	DELETE FROM issue_assignees
+	WHERE package_id=$1 AND issue_number=$2`},
+		{&b.assignee, `
+	INSERT INTO issue_assignees(package_id, issue_number, assignee_username,
+		assignee_github_id)
+	VALUES($1, $2, $3, $4)
+	ON CONFLICT DO NOTHING`},
+		{&b.comment, `
+	INSERT INTO issue_comments(comment_github_id, package_id, issue_number,
+		comment_body, comment_username, comment_created_at)
+	VALUES($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (comment_github_id) DO UPDATE
+	SET comment_body=$4`},
+		{&b.event, `
+	INSERT INTO issue_events(event_github_id, package_id, issue_number,
+		event, event_label, event_created_at)
+	VALUES($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (event_github_id) DO NOTHING`},
+		{&b.link, `
+	INSERT INTO issue_links(package_id, issue_number, link_kind, link_path,
+		link_number, link_created_at)
+	VALUES($1, $2, $3, $4, $5, $6)
+	ON CONFLICT DO NOTHING`},
+		{&b.release, releaseWindows + ` AND issues.issue_number=$2`},
+		{&b.state, `
+	SELECT issue_state
+	FROM issues
+	WHERE package_id=$1 AND issue_number=$2`},
+		{&b.outboxEvent, `
+	INSERT INTO outbox(outbox_event, package_id, package_path, issue_number,
+		issue_title, issue_url)
+	VALUES($1, $2, $3, $4, $5, $6)`},
+	}
+	for _, s := range stmts {
+		stmt, err := tx.Prepare(s.query)
+		if err != nil {
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+	return b, nil
+}
+
+// saveIssue stores the issue with its user, labels, comments and events.
+// The upserts are idempotent, so the redelivered or concurrent fetches of the
+// package store the same rows: the issue is keyed on its number and never
+// replaced by an older update of it, the users, comments and events are keyed
+// on their GitHub id.
+func (b *batch) saveIssue(issue packagebug.Issue) error {
+	err := b.saveUser(issue.User)
+	if err != nil {
+		return err
+	}
+	err = b.saveMilestone(issue.Milestone)
+	if err != nil {
+		return err
+	}
+	// only issues from github have github id
+	githubId := sql.NullInt64{Int64: issue.GithubId, Valid: issue.GithubId != 0}
+	userId := sql.NullInt64{Int64: issue.User.GithubId, Valid: issue.User.GithubId != 0}
+	var milestone sql.NullString
+	var milestoneNumber sql.NullInt64
+	if issue.Milestone != nil {
+		milestone = sql.NullString{String: issue.Milestone.Title, Valid: true}
+		milestoneNumber = sql.NullInt64{Int64: int64(issue.Milestone.Number), Valid: true}
+	}
+	// the events of the outbox are the changes from the stored state
+	var stored string
+	if b.outbox {
+		err = b.state.QueryRow(b.packageId, issue.Number).Scan(&stored)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	res, err := b.issue.Exec(githubId, b.packageId, issue.Number,
+		issue.Title, issue.Url, issue.ApiUrl, issue.ApiLabelsUrl,
+		issue.ApiCommentsUrl, issue.ApiEventsUrl, issue.Body,
+		issue.Reactions.PlusOne, nullTime(issue.UpdatedAt), issue.Language,
+		issue.State, nullTime(issue.CreatedAt), issue.ClosedAt, userId,
+		issue.IsPullRequest(), pq.Array(issue.AssigneeNames()), milestone,
+		milestoneNumber, issue.BugLabeledAt, issue.BugUnlabeledAt,
+		issue.Severity)
+	if err != nil {
+		return err
+	}
+	// the stored issue is newer, keep its labels and assignees too
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		err = b.saveLabels(issue)
+		if err != nil {
+			return err
+		}
+		err = b.saveAssignees(issue)
+		if err != nil {
+			return err
+		}
+		err = b.saveOutbox(stored, issue)
+		if err != nil {
+			return err
+		}
+		_, err = b.release.Exec(b.packageId, issue.Number)
+		if err != nil {
+			return err
+		}
+	}
+	return b.saveDetails(issue)
+}
+
+// saveOutbox writes the events of the issue saved over its stored state to
+// the outbox, see packagebug.OutboxEvents.
+func (b *batch) saveOutbox(stored string, issue packagebug.Issue) error {
+	if !b.outbox {
+		return nil
+	}
+	for _, event := range packagebug.OutboxEvents(stored, issue) {
+		_, err := b.outboxEvent.Exec(event, b.packageId, b.packagePath,
+			issue.Number, issue.Title, issue.Url)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveUser stores the user who opened the issue once per batch. Users
+// without github id are ignored.
+func (b *batch) saveUser(u packagebug.IssueCreator) error {
+	if u.GithubId == 0 || b.users[u.GithubId] {
+		return nil
+	}
+	_, err := b.user.Exec(u.GithubId, u.Username, u.AvatarUrl, u.ProfileUrl)
+	if err != nil {
+		return err
+	}
+	b.users[u.GithubId] = true
+	return nil
+}
+
+// saveMilestone stores the milestone of the issue once per batch. Issues
+// without milestone are ignored.
+func (b *batch) saveMilestone(m *packagebug.Milestone) error {
+	if m == nil || b.milestones[m.Number] {
+		return nil
+	}
+	_, err := b.milestone.Exec(b.packageId, m.Number, m.Title,
+		sql.NullString{String: m.State, Valid: m.State != ""}, m.DueOn)
+	if err != nil {
+		return err
+	}
+	b.milestones[m.Number] = true
+	return nil
+}
+
+// saveAssignees replaces the assignees of the issue, the assignees with
+// github id are stored as users too.
+func (b *batch) saveAssignees(issue packagebug.Issue) error {
+	_, err := b.deleteAssignees.Exec(b.packageId, issue.Number)
+	if err != nil {
+		return err
+	}
+	for _, a := range issue.Assignees {
+		err = b.saveUser(a)
+		if err != nil {
+			return err
+		}
+		_, err = b.assignee.Exec(b.packageId, issue.Number, a.Username,
+			sql.NullInt64{Int64: a.GithubId, Valid: a.GithubId != 0})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveLabels replaces the labels of the issue, the labels are inserted by a
+// single statement.
+func (b *batch) saveLabels(issue packagebug.Issue) error {
+	_, err := b.deleteLabels.Exec(b.packageId, issue.Number)
+	if err != nil || len(issue.Labels) == 0 {
+		return err
+	}
+	b.labels = b.labels[:0]
+	for _, l := range issue.Labels {
+		b.labels = append(b.labels, l.Name)
+	}
+	_, err = b.label.Exec(b.packageId, issue.Number, pq.Array(b.labels))
+	return err
+}
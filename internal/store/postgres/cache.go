@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// ttl is packagebug.CacheTTL as interval.
+var ttl = fmt.Sprintf("%d seconds", int(packagebug.CacheTTL.Seconds()))
+
+// GetResponse returns the cached response of the url, the responses older
+// than packagebug.CacheTTL are ignored.
+func (s *Store) GetResponse(ctx context.Context, url string) (packagebug.CachedResponse, bool, error) {
+	query := `
+	SELECT coalesce(cache_etag, ''), coalesce(cache_last_modified, ''),
+		coalesce(cache_link, '')
+	FROM response_cache
+	WHERE cache_url=$1 AND cache_updated_at > now() - $2::interval`
+	r := packagebug.CachedResponse{Url: url}
+	err := s.DB.QueryRowContext(ctx, query, url, ttl).Scan(&r.Etag,
+		&r.LastModified, &r.Link)
+	if err == sql.ErrNoRows {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, packagebug.DBError(err)
+	}
+	return r, true, nil
+}
+
+// SaveResponses stores the cached responses, see packagebug.ResponseCache.
+func (s *Store) SaveResponses(ctx context.Context, responses []packagebug.CachedResponse) error {
+	query := `
+	INSERT INTO response_cache(cache_url, cache_etag, cache_last_modified,
+		cache_link, cache_updated_at)
+	VALUES($1, $2, $3, $4, now())
+	ON CONFLICT (cache_url) DO UPDATE
+	SET cache_etag=$2, cache_last_modified=$3, cache_link=$4,
+		cache_updated_at=now()`
+	for _, r := range responses {
+		_, err := s.DB.ExecContext(ctx, query, r.Url, r.Etag, r.LastModified,
+			r.Link)
+		if err != nil {
+			return packagebug.DBError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// DeleteIssues deletes the issues of the package and resets its sync state
+// and stats in a single transaction. The package itself is kept. The errors
+// are ErrDB.
+func (s *Store) DeleteIssues(p packagebug.Package) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = deleteIssues(tx, p)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// deleteIssues deletes the issues of the package within tx.
+func deleteIssues(tx *sql.Tx, p packagebug.Package) error {
+	query := `
+	DELETE FROM issues
+	WHERE package_id=$1`
+	_, err := tx.Exec(query, p.Id)
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_etag=NULL, package_since=NULL, package_repo_etag=NULL,
+		package_releases_etag=NULL
+	WHERE package_path=$1 AND package_tenant=$2`
+	_, err = tx.Exec(query, p.Path(), p.Tenant)
+	if err != nil {
+		return err
+	}
+	return updateStats(tx, p.Id)
+}
+
+// ResetSync clears the etag and the since of the package, so the next fetch
+// is unconditional and full. The etags of the repository metadata and the
+// releases are cleared too.
+func (s *Store) ResetSync(p packagebug.Package) error {
+	query := `
+	UPDATE packages
+	SET package_etag=NULL, package_since=NULL, package_repo_etag=NULL,
+		package_releases_etag=NULL
+	WHERE package_path=$1 AND package_tenant=$2`
+	_, err := s.DB.Exec(query, p.Path(), p.Tenant)
+	return packagebug.DBError(err)
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"github.com/pyk/packagebug-worker"
+)
+
+// saveDetails stores the comments, events and links of the issue.
+func (b *batch) saveDetails(issue packagebug.Issue) error {
+	for _, c := range issue.Comments {
+		_, err := b.comment.Exec(c.GithubId, b.packageId, issue.Number, c.Body,
+			c.User.Username, c.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range issue.Events {
+		_, err := b.event.Exec(e.GithubId, b.packageId, issue.Number, e.Event,
+			e.Label.Name, e.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range issue.Links {
+		_, err := b.link.Exec(b.packageId, issue.Number, l.Kind, l.Path,
+			l.Number, nullTime(l.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReadOnlyDSN returns the Postgres connection string whose transactions are
+// read-only by default, so any write in dry run fails instead of modifying
+// the database. Both URL and key=value connection strings are supported.
+func ReadOnlyDSN(dsn string) string {
+	return withParam(dsn, "default_transaction_read_only=on")
+}
+
+// StatementTimeoutDSN returns the Postgres connection string whose
+// statements are canceled by the server after timeout, see ReadOnlyDSN.
+// The zero timeout returns dsn as is.
+func StatementTimeoutDSN(dsn string, timeout time.Duration) string {
+	if timeout <= 0 {
+		return dsn
+	}
+	return withParam(dsn, fmt.Sprintf("statement_timeout=%d", timeout.Milliseconds()))
+}
+
+// withParam returns dsn with the run-time parameter of the session added.
+func withParam(dsn, param string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&" + param
+		}
+		return dsn + "?" + param
+	}
+	if dsn == "" {
+		return param
+	}
+	return dsn + " " + param
+}
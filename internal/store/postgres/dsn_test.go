@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadOnlyDSN(t *testing.T) {
+	cases := map[string]string{
+		"postgres://localhost/db":              "postgres://localhost/db?default_transaction_read_only=on",
+		"postgres://localhost/db?sslmode=none": "postgres://localhost/db?sslmode=none&default_transaction_read_only=on",
+		"host=localhost dbname=db":             "host=localhost dbname=db default_transaction_read_only=on",
+	}
+	for dsn, expected := range cases {
+		if got := ReadOnlyDSN(dsn); got != expected {
+			t.Errorf("expected: %s got: %s\n", expected, got)
+		}
+	}
+}
+
+func TestStatementTimeoutDSN(t *testing.T) {
+	dsn := StatementTimeoutDSN("postgres://localhost/db", 30*time.Second)
+	if dsn != "postgres://localhost/db?statement_timeout=30000" {
+		t.Errorf("unexpected dsn: %s\n", dsn)
+	}
+	dsn = StatementTimeoutDSN(ReadOnlyDSN("host=localhost"), time.Second)
+	if dsn != "host=localhost default_transaction_read_only=on statement_timeout=1000" {
+		t.Errorf("unexpected dsn: %s\n", dsn)
+	}
+	if dsn = StatementTimeoutDSN("postgres://localhost/db", 0); dsn != "postgres://localhost/db" {
+		t.Errorf("expected dsn without timeout got: %s\n", dsn)
+	}
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// SaveFetchLog stores the fetch log to the database and sets the last fetch
+// time and error of the package in a single transaction. The package is
+// marked gone since the first fetch that found it gone, and unmarked by the
+// next successful fetch.
+func (s *Store) SaveFetchLog(l packagebug.FetchLog) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveFetchLog(tx, l)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// saveFetchLog stores the fetch log within tx.
+func saveFetchLog(tx *sql.Tx, l packagebug.FetchLog) error {
+	lastError := sql.NullString{String: l.Error, Valid: l.Error != ""}
+	query := `
+	INSERT INTO fetch_log(package_id, started_at, duration_ms,
+		issues_upserted, error, status_code, pages, skip_reason)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.Exec(query, l.PackageId, l.StartedAt,
+		l.Duration.Nanoseconds()/int64(time.Millisecond), l.Issues,
+		lastError, sql.NullInt64{Int64: int64(l.StatusCode), Valid: l.StatusCode != 0},
+		l.Pages, sql.NullString{String: l.Skipped, Valid: l.Skipped != ""})
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_last_fetched_at=$1, package_last_error=$2,
+		package_gone_at=CASE
+			WHEN $4 THEN coalesce(package_gone_at, $1)
+			WHEN $2::text IS NULL THEN NULL
+			ELSE package_gone_at
+		END
+	WHERE package_id=$3`
+	_, err = tx.Exec(query, l.StartedAt, lastError, l.PackageId, l.Gone)
+	return err
+}
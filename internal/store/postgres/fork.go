@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetFork get the stored fork relationship of the package.
+func (s *Store) GetFork(p packagebug.Package) (packagebug.Fork, error) {
+	var f packagebug.Fork
+	var parent, policy sql.NullString
+	var checkedAt *time.Time
+
+	query := `
+	SELECT package_fork_parent, package_fork_policy, package_fork_checked_at
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&parent, &policy, &checkedAt)
+	if err != nil {
+		return f, err
+	}
+
+	f.Parent = parent.String
+	f.Policy = packagebug.ForkPolicy(policy.String)
+	if checkedAt != nil {
+		f.CheckedAt = *checkedAt
+	}
+	return f, nil
+}
+
+// SetForkParent stores the parent repository of the package, empty parent
+// means the package is not a fork.
+func (s *Store) SetForkParent(p packagebug.Package, parent string) error {
+	query := `
+	UPDATE packages
+	SET package_fork_parent=$1, package_fork_checked_at=now()
+	WHERE package_path=$2 AND package_tenant=$3`
+	_, err := s.DB.Exec(query, sql.NullString{String: parent, Valid: parent != ""},
+		p.Path(), p.Tenant)
+	return err
+}
+
+// SetPackageRoot links the subpackage to the package of its repository.
+func (s *Store) SetPackageRoot(p, root packagebug.Package) error {
+	query := `
+	UPDATE packages
+	SET package_root_id=$1
+	WHERE package_path=$2 AND package_tenant=$3
+	AND package_root_id IS DISTINCT FROM $1`
+	_, err := s.DB.Exec(query, root.Id, p.Path(), p.Tenant)
+	return err
+}
+
+// FindPackage returns the package of the path crawled for the tenant, empty
+// for the deployment itself. It returns false if the path is not a package
+// of the tenant. The package that moved is found by its former path too,
+// the package at its current path is returned then.
+func (s *Store) FindPackage(tenant, path string) (packagebug.Package, bool, error) {
+	p, err := packagebug.ParsePath(path)
+	if err != nil {
+		return p, false, err
+	}
+	p.Tenant = tenant
+	query := `
+	SELECT p.package_id, p.package_host, p.package_owner, p.package_repo,
+		coalesce(p.package_subpath, ''), p.package_tenant
+	FROM packages p
+	LEFT JOIN package_aliases a ON a.package_id=p.package_id
+		AND a.alias_path=$1
+	WHERE p.package_tenant=$2 AND (p.package_path=$1 OR a.alias_path=$1)
+	ORDER BY p.package_path=$1 DESC
+	LIMIT 1`
+	err = s.DB.QueryRow(query, path, tenant).Scan(&p.Id, &p.Host, &p.Owner, &p.Repo,
+		&p.Subpath, &p.Tenant)
+	if err == sql.ErrNoRows {
+		return p, false, nil
+	}
+	if err != nil {
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+// ListPackages returns at most limit packages with the id greater than
+// after ordered by id, e.g. to enqueue every package in batches. The
+// subpackages linked to the package of their repository are left out.
+func (s *Store) ListPackages(after string, limit int) ([]packagebug.Package, error) {
+	query := `
+	SELECT package_id, package_host, package_owner, package_repo,
+		coalesce(package_subpath, ''), package_tenant
+	FROM packages
+	WHERE package_id > $1 AND package_root_id IS NULL
+	ORDER BY package_id
+	LIMIT $2`
+	rows, err := s.reader().Query(query, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var packages []packagebug.Package
+	for rows.Next() {
+		var p packagebug.Package
+		err = rows.Scan(&p.Id, &p.Host, &p.Owner, &p.Repo, &p.Subpath,
+			&p.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+	return packages, rows.Err()
+}
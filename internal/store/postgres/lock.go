@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Lock takes the session advisory lock keyed on the package path within its
+// tenant, see packagebug.Package.Key. It holds a connection of the pool until
+// unlock is called. It returns false if other session holds the lock.
+func (s *Store) Lock(ctx context.Context, p packagebug.Package) (func(), bool, error) {
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var ok bool
+	query := `SELECT pg_try_advisory_lock(hashtext($1))`
+	err = conn.QueryRowContext(ctx, query, p.Key()).Scan(&ok)
+	if err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+
+	unlock := func() {
+		query := `SELECT pg_advisory_unlock(hashtext($1))`
+		_, err := conn.ExecContext(context.Background(), query, p.Key())
+		if err != nil {
+			// discard the connection, the lock is released with the session
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationLock is the key of the advisory lock held while migrating, so
+// only one worker migrates the schema at a time.
+const migrationLock = 7290515
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a schema change embedded in the binary. The file name of the
+// migration is <version>_<name>.sql.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	names, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, f := range names {
+		version, name, ok := strings.Cut(strings.TrimSuffix(f.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name %q", f.Name())
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q", f.Name())
+		}
+		if other, ok := seen[v]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s, %s", v, other, f.Name())
+		}
+		seen[v] = f.Name()
+		b, err := migrationFiles.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: v, Name: name, SQL: string(b)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies the pending migrations, each in its own transaction, and
+// returns the number of applied migrations. The applied versions are
+// recorded in schema_migrations table.
+func Migrate(ctx context.Context, dbconn *sql.DB) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	// the advisory lock belongs to the session, use a single connection
+	conn, err := dbconn.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// the lock wait and the migrations may outlast the statement timeout of
+	// the connection string
+	_, err = conn.ExecContext(ctx, `SET statement_timeout=0`)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.ExecContext(context.Background(), `RESET statement_timeout`)
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	var current int
+	query = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+	err = conn.QueryRowContext(ctx, query).Scan(&current)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		err = applyMigration(ctx, conn, m)
+		if err != nil {
+			return n, fmt.Errorf("migration %d %s: %s", m.Version, m.Name, err)
+		}
+		slog.Info("migration applied", "version", m.Version, "name", m.Name)
+		n++
+	}
+	return n, nil
+}
+
+// AppliedVersion returns the version of the latest applied migration, 0 if
+// the database was never migrated. It doesn't take the migration lock.
+func AppliedVersion(ctx context.Context, dbconn *sql.DB) (int, error) {
+	var exists bool
+	query := `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	err := dbconn.QueryRowContext(ctx, query).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	query = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+	err = dbconn.QueryRowContext(ctx, query).Scan(&version)
+	return version, err
+}
+
+// applyMigration applies the migration and records its version.
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, m.SQL)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	query := `
+	INSERT INTO schema_migrations(version, name)
+	VALUES($1, $2)`
+	_, err = tx.ExecContext(ctx, query, m.Version, m.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d got: %d (%s)\n", i+1, m.Version, m.Name)
+		}
+		if m.SQL == "" {
+			t.Errorf("expected SQL of migration %d\n", m.Version)
+		}
+	}
+}
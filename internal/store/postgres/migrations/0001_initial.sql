@@ -0,0 +1,23 @@
+-- packages & issues, created if the database predates the migrations
+CREATE TABLE IF NOT EXISTS packages (
+	package_id bigserial PRIMARY KEY,
+	package_path text NOT NULL UNIQUE,
+	package_host text NOT NULL,
+	package_owner text NOT NULL,
+	package_repo text NOT NULL,
+	package_etag text
+);
+
+CREATE TABLE IF NOT EXISTS issues (
+	issue_id bigserial PRIMARY KEY,
+	issue_github_id bigint,
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	issue_number integer NOT NULL,
+	issue_title text NOT NULL,
+	issue_url text,
+	issue_api_url text,
+	issue_api_labels_url text,
+	issue_api_comments_url text,
+	issue_api_events_url text,
+	UNIQUE (package_id, issue_number)
+);
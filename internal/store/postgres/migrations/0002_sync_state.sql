@@ -0,0 +1,18 @@
+-- incremental sync, fetch history & rate limit state
+ALTER TABLE packages ADD COLUMN IF NOT EXISTS package_since timestamptz;
+
+CREATE TABLE IF NOT EXISTS fetch_log (
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	started_at timestamptz NOT NULL,
+	duration_ms bigint NOT NULL,
+	issues_upserted integer NOT NULL,
+	error text
+);
+CREATE INDEX IF NOT EXISTS fetch_log_started_at_idx ON fetch_log (started_at);
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+	token_id text PRIMARY KEY,
+	remaining integer NOT NULL,
+	reset_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL
+);
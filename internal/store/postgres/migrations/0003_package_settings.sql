@@ -0,0 +1,11 @@
+-- custom settings of the package owner, capabilities & forks
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_labels text,
+	ADD COLUMN IF NOT EXISTS package_token text,
+	ADD COLUMN IF NOT EXISTS package_verify_token text,
+	ADD COLUMN IF NOT EXISTS package_verified boolean,
+	ADD COLUMN IF NOT EXISTS package_verified_at timestamptz,
+	ADD COLUMN IF NOT EXISTS package_capabilities integer,
+	ADD COLUMN IF NOT EXISTS package_fork_parent text,
+	ADD COLUMN IF NOT EXISTS package_fork_policy text,
+	ADD COLUMN IF NOT EXISTS package_fork_checked_at timestamptz;
@@ -0,0 +1,39 @@
+-- optional issue data, state & language
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_body text,
+	ADD COLUMN IF NOT EXISTS issue_reactions_plus_one integer,
+	ADD COLUMN IF NOT EXISTS issue_updated_at timestamptz,
+	ADD COLUMN IF NOT EXISTS issue_language text,
+	ADD COLUMN IF NOT EXISTS issue_state text,
+	ADD COLUMN IF NOT EXISTS issue_created_at timestamptz,
+	ADD COLUMN IF NOT EXISTS issue_closed_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS issue_comments (
+	comment_github_id bigint PRIMARY KEY,
+	package_id bigint NOT NULL,
+	issue_number integer NOT NULL,
+	comment_body text,
+	comment_username text,
+	comment_created_at timestamptz,
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS issue_events (
+	event_github_id bigint PRIMARY KEY,
+	package_id bigint NOT NULL,
+	issue_number integer NOT NULL,
+	event text NOT NULL,
+	event_label text,
+	event_created_at timestamptz,
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS package_stats (
+	package_id bigint PRIMARY KEY REFERENCES packages ON DELETE CASCADE,
+	open_bugs integer NOT NULL,
+	closed_bugs integer NOT NULL,
+	median_time_to_close interval,
+	updated_at timestamptz NOT NULL
+);
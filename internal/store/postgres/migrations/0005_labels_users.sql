@@ -0,0 +1,19 @@
+-- issue labels & the users who opened the issues
+CREATE TABLE IF NOT EXISTS users (
+	user_github_id bigint PRIMARY KEY,
+	user_username text NOT NULL,
+	user_avatar_url text,
+	user_profile_url text
+);
+
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_user_github_id bigint REFERENCES users;
+
+CREATE TABLE IF NOT EXISTS issue_labels (
+	package_id bigint NOT NULL,
+	issue_number integer NOT NULL,
+	label_name text NOT NULL,
+	PRIMARY KEY (package_id, issue_number, label_name),
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- fetch schedule of the scheduler mode
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_next_fetch_at timestamptz,
+	ADD COLUMN IF NOT EXISTS package_priority integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS packages_next_fetch_at_idx
+	ON packages (package_next_fetch_at);
@@ -0,0 +1,3 @@
+-- comma separated aliases of the bug label, e.g. kind/bug,type: bug
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_bug_labels text;
@@ -0,0 +1,3 @@
+-- the GitHub issues endpoint also returns the pull requests
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_is_pull_request boolean NOT NULL DEFAULT false;
@@ -0,0 +1,10 @@
+-- repository metadata used to rank the packages
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_stars integer,
+	ADD COLUMN IF NOT EXISTS package_forks integer,
+	ADD COLUMN IF NOT EXISTS package_archived boolean,
+	ADD COLUMN IF NOT EXISTS package_default_branch text,
+	ADD COLUMN IF NOT EXISTS package_description text,
+	ADD COLUMN IF NOT EXISTS package_pushed_at timestamptz,
+	ADD COLUMN IF NOT EXISTS package_repo_etag text,
+	ADD COLUMN IF NOT EXISTS package_repo_fetched_at timestamptz;
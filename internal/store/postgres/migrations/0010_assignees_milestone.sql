@@ -0,0 +1,4 @@
+-- usernames of the assignees and the milestone title of the issues
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_assignees text[],
+	ADD COLUMN IF NOT EXISTS issue_milestone text;
@@ -0,0 +1,10 @@
+-- full-text search over the issue title and body, the title weighs more
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_search tsvector;
+
+UPDATE issues
+SET issue_search=setweight(to_tsvector('simple', coalesce(issue_title, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(issue_body, '')), 'B')
+WHERE issue_search IS NULL;
+
+CREATE INDEX IF NOT EXISTS issues_search_idx ON issues USING gin(issue_search);
@@ -0,0 +1,8 @@
+-- status & pages of every fetch, and the last fetch of the packages
+ALTER TABLE fetch_log
+	ADD COLUMN IF NOT EXISTS status_code integer,
+	ADD COLUMN IF NOT EXISTS pages integer;
+
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_last_fetched_at timestamptz,
+	ADD COLUMN IF NOT EXISTS package_last_error text;
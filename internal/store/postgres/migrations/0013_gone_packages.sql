@@ -0,0 +1,4 @@
+-- the time the repository of the package was first found gone, its issues
+-- are pruned after the grace period
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_gone_at timestamptz;
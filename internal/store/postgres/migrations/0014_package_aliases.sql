@@ -0,0 +1,6 @@
+-- the former paths of the packages whose repository moved
+CREATE TABLE IF NOT EXISTS package_aliases (
+	alias_path text PRIMARY KEY,
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,29 @@
+-- the milestones of the packages and the assignees of the issues, kept
+-- alongside issue_milestone and issue_assignees
+CREATE TABLE IF NOT EXISTS milestones (
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	milestone_number integer NOT NULL,
+	milestone_title text NOT NULL,
+	milestone_state text,
+	milestone_due_on timestamptz,
+	PRIMARY KEY (package_id, milestone_number)
+);
+
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_milestone_number integer;
+
+CREATE TABLE IF NOT EXISTS issue_assignees (
+	package_id bigint NOT NULL,
+	issue_number integer NOT NULL,
+	assignee_username text NOT NULL,
+	assignee_github_id bigint,
+	PRIMARY KEY (package_id, issue_number, assignee_username),
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+INSERT INTO issue_assignees(package_id, issue_number, assignee_username)
+SELECT package_id, issue_number, unnest(issue_assignees)
+FROM issues
+WHERE issue_assignees IS NOT NULL
+ON CONFLICT DO NOTHING;
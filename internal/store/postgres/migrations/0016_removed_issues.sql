@@ -0,0 +1,4 @@
+-- the issues deleted or transferred on the host, they are missing from the
+-- full syncs of the package and kept for the history
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_removed_at timestamptz;
@@ -0,0 +1,12 @@
+-- the validators of the fetched pages by url, the pages of the incremental
+-- syncs are conditional on them
+CREATE TABLE IF NOT EXISTS response_cache (
+	cache_url text PRIMARY KEY,
+	cache_etag text,
+	cache_last_modified text,
+	cache_link text,
+	cache_updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS response_cache_updated_at_idx
+	ON response_cache (cache_updated_at);
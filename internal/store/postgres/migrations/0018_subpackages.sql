@@ -0,0 +1,12 @@
+-- the subpackages of the repositories, e.g. github.com/aws/aws-sdk-go/service/sqs
+-- of github.com/aws/aws-sdk-go. the subpackage whose repository is a package
+-- too is linked to it, the issues of the repository are fetched once.
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_subpath text,
+	ADD COLUMN IF NOT EXISTS package_root_id bigint
+		REFERENCES packages ON DELETE SET NULL;
+
+UPDATE packages
+SET package_subpath=substring(package_path from '^[^/]+/[^/]+/[^/]+/(.+)$'),
+	package_repo=split_part(package_repo, '/', 1)
+WHERE package_path ~ '^[^/]+/[^/]+/[^/]+/.+$' AND package_subpath IS NULL;
@@ -0,0 +1,7 @@
+-- the issue list query of the package overriding the query of the
+-- deployment: the state filter, the bug labels and the sort order.
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_issue_state text,
+	ADD COLUMN IF NOT EXISTS package_issue_labels text,
+	ADD COLUMN IF NOT EXISTS package_issue_sort text,
+	ADD COLUMN IF NOT EXISTS package_issue_direction text;
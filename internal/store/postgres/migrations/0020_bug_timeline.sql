@@ -0,0 +1,5 @@
+-- the bug timeline of the issue events: when the bug label was first applied
+-- and when the last bug label was removed.
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_bug_labeled_at timestamptz,
+	ADD COLUMN IF NOT EXISTS issue_bug_unlabeled_at timestamptz;
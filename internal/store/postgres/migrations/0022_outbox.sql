@@ -0,0 +1,21 @@
+-- the changes of the bugs written in the transaction of their save, the
+-- publisher forwards the unpublished ones, see packagebug.OutboxEvent.
+CREATE TABLE IF NOT EXISTS outbox (
+	outbox_id bigserial PRIMARY KEY,
+	outbox_event text NOT NULL,
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	package_path text NOT NULL,
+	issue_number integer NOT NULL,
+	issue_title text NOT NULL,
+	issue_url text NOT NULL,
+	outbox_created_at timestamptz NOT NULL DEFAULT now(),
+	outbox_published_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (outbox_id)
+	WHERE outbox_published_at IS NULL;
+
+-- the events are written from the second sync of the package on, the issues
+-- of its first sync are not new.
+ALTER TABLE packages ADD COLUMN IF NOT EXISTS package_synced_at timestamptz;
+UPDATE packages SET package_synced_at=package_last_fetched_at
+WHERE package_synced_at IS NULL;
@@ -0,0 +1,14 @@
+-- the security advisories of the packages, refreshed by every sync, see
+-- packagebug.Advisory.
+CREATE TABLE IF NOT EXISTS advisories (
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	advisory_id text NOT NULL,
+	advisory_aliases text[] NOT NULL DEFAULT '{}',
+	advisory_summary text,
+	advisory_severity text,
+	advisory_url text,
+	advisory_published_at timestamptz,
+	advisory_modified_at timestamptz,
+	advisory_withdrawn_at timestamptz,
+	PRIMARY KEY (package_id, advisory_id)
+);
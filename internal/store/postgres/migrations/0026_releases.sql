@@ -0,0 +1,20 @@
+-- the published releases of the package repositories and the release window
+-- of the issues: the latest release the issue was opened against and the
+-- first release published after it was closed.
+CREATE TABLE IF NOT EXISTS releases (
+	package_id bigint NOT NULL REFERENCES packages ON DELETE CASCADE,
+	release_tag text NOT NULL,
+	release_name text,
+	release_prerelease boolean NOT NULL DEFAULT false,
+	release_published_at timestamptz NOT NULL,
+	PRIMARY KEY (package_id, release_tag)
+);
+CREATE INDEX IF NOT EXISTS releases_published_at_idx
+	ON releases (package_id, release_published_at);
+
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_releases_etag text;
+
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_opened_release text,
+	ADD COLUMN IF NOT EXISTS issue_fixed_release text;
@@ -0,0 +1,3 @@
+-- the reason the package wasn't fetched, e.g. archived, NULL if fetched.
+ALTER TABLE fetch_log
+	ADD COLUMN IF NOT EXISTS skip_reason text;
@@ -0,0 +1,4 @@
+-- the time since the package has no bugs stored, NULL if it has any. the
+-- scheduler fetches the packages empty for long less often.
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_empty_since timestamptz;
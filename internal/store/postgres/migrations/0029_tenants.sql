@@ -0,0 +1,11 @@
+-- the tenant the package is crawled for, empty for the deployment itself.
+-- the issues carry the tenant of their package, so the queries of a tenant
+-- never join the packages of the others.
+ALTER TABLE packages
+	ADD COLUMN IF NOT EXISTS package_tenant text NOT NULL DEFAULT '';
+ALTER TABLE issues
+	ADD COLUMN IF NOT EXISTS issue_tenant text NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS packages_tenant_idx
+	ON packages (package_tenant, package_id);
+CREATE INDEX IF NOT EXISTS issues_tenant_idx
+	ON issues (issue_tenant, package_id);
@@ -0,0 +1,6 @@
+-- the same path may be crawled for several tenants, the package is unique
+-- by its tenant and path. the lookups of the worker filter both.
+ALTER TABLE packages
+	DROP CONSTRAINT IF EXISTS packages_package_path_key;
+CREATE UNIQUE INDEX IF NOT EXISTS packages_tenant_path_key
+	ON packages (package_tenant, package_path);
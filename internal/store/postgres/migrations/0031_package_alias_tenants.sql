@@ -0,0 +1,8 @@
+-- the former path of a package is its alias, the same path may be the alias
+-- of the packages of several tenants. FindPackage filters the tenant.
+ALTER TABLE package_aliases
+	DROP CONSTRAINT IF EXISTS package_aliases_pkey;
+ALTER TABLE package_aliases
+	ADD PRIMARY KEY (package_id, alias_path);
+CREATE INDEX IF NOT EXISTS package_aliases_path_idx
+	ON package_aliases (alias_path);
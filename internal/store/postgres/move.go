@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// MovePackage moves the package to the path of to and keeps its former path
+// as alias, so FindPackage still finds it by the former path. The package is
+// identified by its id, moving it again is a no-op. It returns
+// packagebug.ErrPackageExists if another package of the tenant has the path of
+// to, the other errors are ErrDB.
+func (s *Store) MovePackage(p, to packagebug.Package) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = movePackage(tx, p, to)
+	if errors.Is(err, packagebug.ErrPackageExists) {
+		tx.Rollback()
+		return err
+	}
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// movePackage moves the package within tx.
+func movePackage(tx *sql.Tx, p, to packagebug.Package) error {
+	var exists bool
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM packages
+		WHERE package_path=$1 AND package_tenant=$2 AND package_id<>$3
+	)`
+	err := tx.QueryRow(query, to.Path(), p.Tenant, p.Id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return packagebug.ErrPackageExists
+	}
+	query = `
+	INSERT INTO package_aliases(alias_path, package_id)
+	VALUES($1, $2)
+	ON CONFLICT (package_id, alias_path) DO NOTHING`
+	_, err = tx.Exec(query, p.Path(), p.Id)
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_path=$1, package_host=$2, package_owner=$3, package_repo=$4
+	WHERE package_id=$5`
+	_, err = tx.Exec(query, to.Path(), to.Host, to.Owner, to.Repo, p.Id)
+	return err
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+)
+
+// PublishOutbox passes at most limit unpublished events of the outbox to
+// publish in order and marks the published ones. The events are locked
+// until they are marked, the concurrent publishers skip them. It returns the
+// number of published events, the events after the one that failed are
+// left for the next call.
+func (s *Store) PublishOutbox(ctx context.Context, limit int, publish func(packagebug.OutboxEvent) error) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, packagebug.DBError(err)
+	}
+	defer tx.Rollback()
+	query := `
+	SELECT outbox_id, outbox_event, package_id, package_path, issue_number,
+		issue_title, issue_url, outbox_created_at
+	FROM outbox
+	WHERE outbox_published_at IS NULL
+	ORDER BY outbox_id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, packagebug.DBError(err)
+	}
+	var events []packagebug.OutboxEvent
+	for rows.Next() {
+		var e packagebug.OutboxEvent
+		err = rows.Scan(&e.Id, &e.Event, &e.PackageId, &e.PackagePath,
+			&e.IssueNumber, &e.IssueTitle, &e.IssueUrl, &e.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, packagebug.DBError(err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, packagebug.DBError(err)
+	}
+
+	var published []int64
+	var perr error
+	for _, e := range events {
+		perr = publish(e)
+		if perr != nil {
+			break
+		}
+		published = append(published, e.Id)
+	}
+	if len(published) > 0 {
+		query = `
+		UPDATE outbox
+		SET outbox_published_at=now()
+		WHERE outbox_id = ANY($1)`
+		_, err = tx.ExecContext(ctx, query, pq.Array(published))
+		if err != nil {
+			return 0, packagebug.DBError(err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, packagebug.DBError(err)
+	}
+	return len(published), perr
+}
+
+// PruneOutbox deletes the events published before t. It returns the number
+// of deleted events.
+func (s *Store) PruneOutbox(ctx context.Context, t time.Time) (int64, error) {
+	query := `
+	DELETE FROM outbox
+	WHERE outbox_published_at < $1`
+	res, err := s.DB.ExecContext(ctx, query, t)
+	if err != nil {
+		return 0, packagebug.DBError(err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// LoadRateStates loads the persisted rate limit state of the tokens ids, so
+// the worker knows its budget right after boot. Expired states are ignored.
+func (s *Store) LoadRateStates(ids []string) (map[string]packagebug.RateState, error) {
+	query := `
+	SELECT remaining, reset_at
+	FROM rate_limits
+	WHERE token_id=$1 AND reset_at > now()`
+	states := make(map[string]packagebug.RateState)
+	for _, id := range ids {
+		var state packagebug.RateState
+		err := s.DB.QueryRow(query, id).Scan(&state.Remaining, &state.Reset)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return states, err
+		}
+		state.Known = true
+		states[id] = state
+	}
+	return states, nil
+}
+
+// SaveRateStates persists the rate limit state of the tokens mapped by the
+// token id. The token itself is never stored.
+func (s *Store) SaveRateStates(states map[string]packagebug.RateState) error {
+	query := `
+	INSERT INTO rate_limits(token_id, remaining, reset_at, updated_at)
+	VALUES($1, $2, $3, now())
+	ON CONFLICT (token_id) DO UPDATE
+	SET remaining=$2, reset_at=$3, updated_at=now()`
+	for id, state := range states {
+		_, err := s.DB.Exec(query, id, state.Remaining, state.Reset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
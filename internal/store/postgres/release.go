@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// releaseWindows sets the release window of the issues of the package:
+// the latest release published before the issue was opened and the first
+// release published after it was closed. The prereleases are skipped.
+const releaseWindows = `
+	UPDATE issues
+	SET issue_opened_release=(
+			SELECT release_tag
+			FROM releases r
+			WHERE r.package_id=issues.package_id AND NOT r.release_prerelease
+			AND r.release_published_at <= issues.issue_created_at
+			ORDER BY r.release_published_at DESC
+			LIMIT 1),
+		issue_fixed_release=(
+			SELECT release_tag
+			FROM releases r
+			WHERE r.package_id=issues.package_id AND NOT r.release_prerelease
+			AND r.release_published_at >= issues.issue_closed_at
+			ORDER BY r.release_published_at
+			LIMIT 1)
+	WHERE issues.package_id=$1`
+
+// GetReleasesEtag returns the etag of the last releases fetch, empty if
+// never fetched.
+func (s *Store) GetReleasesEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+	query := `
+	SELECT package_releases_etag
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+	return etag.String, nil
+}
+
+// SaveReleases replaces the stored releases of the package and updates the
+// release windows of its issues.
+func (s *Store) SaveReleases(p packagebug.Package, r packagebug.Releases) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveReleases(tx, p, r)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+func saveReleases(tx *sql.Tx, p packagebug.Package, r packagebug.Releases) error {
+	query := `
+	UPDATE packages
+	SET package_releases_etag=$1
+	WHERE package_path=$2 AND package_tenant=$3
+	RETURNING package_id`
+	var id string
+	err := tx.QueryRow(query, r.Etag, p.Path(), p.Tenant).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM releases WHERE package_id=$1`, id)
+	if err != nil {
+		return err
+	}
+	query = `
+	INSERT INTO releases(package_id, release_tag, release_name,
+		release_prerelease, release_published_at)
+	VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (package_id, release_tag) DO NOTHING`
+	for _, rel := range r.Releases {
+		_, err = tx.Exec(query, id, rel.Tag, rel.Name, rel.Prerelease,
+			rel.PublishedAt)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(releaseWindows, id)
+	return err
+}
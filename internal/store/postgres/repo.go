@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetRepoEtag returns the etag of the last repository metadata fetch, empty
+// if never fetched.
+func (s *Store) GetRepoEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+	query := `
+	SELECT package_repo_etag
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+	return etag.String, nil
+}
+
+// SaveRepo stores the repository metadata of the package.
+func (s *Store) SaveRepo(p packagebug.Package, r packagebug.Repo) error {
+	query := `
+	UPDATE packages
+	SET package_stars=$1, package_forks=$2, package_archived=$3,
+		package_default_branch=$4, package_description=$5,
+		package_pushed_at=$6, package_repo_etag=$7,
+		package_repo_fetched_at=now()
+	WHERE package_path=$8 AND package_tenant=$9`
+	_, err := s.DB.Exec(query, r.Stars, r.Forks, r.Archived, r.DefaultBranch,
+		r.Description, nullTime(r.PushedAt), r.Etag, p.Path(), p.Tenant)
+	return packagebug.DBError(err)
+}
+
+// GetRepo returns the stored repository metadata of the package, zero
+// FetchedAt if never fetched.
+func (s *Store) GetRepo(p packagebug.Package) (packagebug.Repo, error) {
+	var r packagebug.Repo
+	var branch, description, etag sql.NullString
+	var pushedAt, fetchedAt sql.NullTime
+	query := `
+	SELECT coalesce(package_stars, 0), coalesce(package_forks, 0),
+		coalesce(package_archived, false), package_default_branch,
+		package_description, package_pushed_at, package_repo_etag,
+		package_repo_fetched_at
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&r.Stars, &r.Forks, &r.Archived,
+		&branch, &description, &pushedAt, &etag, &fetchedAt)
+	if err != nil {
+		return r, packagebug.DBError(err)
+	}
+	r.DefaultBranch = branch.String
+	r.Description = description.String
+	r.PushedAt = pushedAt.Time
+	r.Etag = etag.String
+	r.FetchedAt = fetchedAt.Time
+	return r, nil
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"github.com/pyk/packagebug-worker"
+)
+
+// searchVector is the tsvector of the issue title ($4) and body ($10) stored
+// on every issue upsert. The simple configuration is used because the issues
+// are written in many languages.
+const searchVector = `setweight(to_tsvector('simple', coalesce($4, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce($10, '')), 'B')`
+
+// SearchLimit is the maximum number of issues returned by Search.
+const SearchLimit = 100
+
+// Search returns the issues of the package whose title or body match the
+// web search style query, e.g. `panic -windows "nil map"`, ordered by rank.
+// The removed issues are excluded.
+func (s *Store) Search(p packagebug.Package, q string) ([]packagebug.Issue, error) {
+	query := `
+	SELECT issue_number, issue_title, coalesce(issue_url, ''),
+		coalesce(issue_state, '')
+	FROM issues
+	WHERE package_id=(SELECT package_id FROM packages WHERE package_path=$1 AND package_tenant=$4)
+		AND issue_removed_at IS NULL
+		AND issue_search @@ websearch_to_tsquery('simple', $2)
+	ORDER BY ts_rank(issue_search, websearch_to_tsquery('simple', $2)) DESC,
+		issue_number DESC
+	LIMIT $3`
+	rows, err := s.reader().Query(query, p.Path(), q, SearchLimit, p.Tenant)
+	if err != nil {
+		return nil, packagebug.DBError(err)
+	}
+	defer rows.Close()
+	var issues []packagebug.Issue
+	for rows.Next() {
+		var issue packagebug.Issue
+		err = rows.Scan(&issue.Number, &issue.Title, &issue.Url, &issue.State)
+		if err != nil {
+			return nil, packagebug.DBError(err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, packagebug.DBError(rows.Err())
+}
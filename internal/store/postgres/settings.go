@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetSettings get the custom settings of the package.
+func (s *Store) GetSettings(p packagebug.Package) (packagebug.Settings, error) {
+	var settings packagebug.Settings
+	var labels, bugLabels, token, verifyToken sql.NullString
+	var state, issueLabels, sort, direction sql.NullString
+	var verified sql.NullBool
+	var verifiedAt *time.Time
+
+	query := `
+	SELECT package_labels, package_bug_labels, package_token,
+		package_verify_token, package_verified, package_verified_at,
+		package_issue_state, package_issue_labels, package_issue_sort,
+		package_issue_direction
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&labels, &bugLabels, &token,
+		&verifyToken, &verified, &verifiedAt, &state, &issueLabels, &sort,
+		&direction)
+	if err != nil {
+		return settings, err
+	}
+	settings.Query, err = packagebug.ParseQuery(state.String,
+		issueLabels.String, sort.String, direction.String)
+	if err != nil {
+		return settings, err
+	}
+
+	if labels.Valid {
+		settings.Labels = packagebug.ParseTokens(labels.String)
+	}
+	if bugLabels.Valid {
+		settings.BugLabels = packagebug.ParseTokens(bugLabels.String)
+	}
+	settings.Token = token.String
+	settings.VerifyToken = verifyToken.String
+	settings.Verified = verified.Bool
+	if verifiedAt != nil {
+		settings.VerifiedAt = *verifiedAt
+	}
+	return settings, nil
+}
+
+// SetVerified stores the ownership verification state of the package.
+func (s *Store) SetVerified(p packagebug.Package, verified bool) error {
+	query := `
+	UPDATE packages
+	SET package_verified=$1, package_verified_at=now()
+	WHERE package_path=$2 AND package_tenant=$3`
+	_, err := s.DB.Exec(query, verified, p.Path(), p.Tenant)
+	return err
+}
+
+// GetCapabilities get the capabilities of the package. The package
+// capabilities are limited by the deployment capabilities max, it returns max
+// if the package has no capabilities set.
+func (s *Store) GetCapabilities(p packagebug.Package, max packagebug.Capability) (packagebug.Capability, error) {
+	var caps sql.NullInt64
+	query := `
+	SELECT package_capabilities
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&caps)
+	if err != nil {
+		return max, err
+	}
+	if !caps.Valid {
+		return max, nil
+	}
+	return packagebug.Capability(caps.Int64) & max, nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// updateStats recomputes the bug-resolution aggregates of the package from
+// its stored issues within tx: the number of open and closed bugs and the
+// median time-to-close, and its score, see updateScore. The removed issues
+// are not counted.
+func updateStats(tx *sql.Tx, packageId string) error {
+	query := `
+	INSERT INTO package_stats(package_id, open_bugs, closed_bugs,
+		median_time_to_close, updated_at)
+	SELECT $1,
+		count(*) FILTER (WHERE issue_state='open'),
+		count(*) FILTER (WHERE issue_state='closed'),
+		percentile_cont(0.5) WITHIN GROUP (
+			ORDER BY issue_closed_at - issue_created_at)
+			FILTER (WHERE issue_state='closed'
+				AND issue_closed_at IS NOT NULL),
+		now()
+	FROM issues
+	WHERE package_id=$1 AND issue_removed_at IS NULL
+	ON CONFLICT (package_id) DO UPDATE
+	SET open_bugs=excluded.open_bugs, closed_bugs=excluded.closed_bugs,
+		median_time_to_close=excluded.median_time_to_close,
+		updated_at=excluded.updated_at`
+	_, err := tx.Exec(query, packageId)
+	if err != nil {
+		return err
+	}
+	// the package without bugs is empty since its first empty save
+	query = `
+	UPDATE packages p
+	SET package_empty_since=CASE WHEN s.open_bugs + s.closed_bugs = 0
+		THEN coalesce(p.package_empty_since, now()) END
+	FROM package_stats s
+	WHERE p.package_id=$1 AND s.package_id=p.package_id`
+	_, err = tx.Exec(query, packageId)
+	if err != nil {
+		return err
+	}
+	return updateScore(tx, packageId)
+}
+
+// updateScore recomputes the bug-health score of the package from its
+// stored issues within tx, see packagebug.ComputeScore. The response of the
+// issue is its first comment by someone other than its author.
+func updateScore(tx *sql.Tx, packageId string) error {
+	query := `
+	SELECT i.issue_state, i.issue_created_at, i.issue_closed_at,
+		(SELECT min(c.comment_created_at)
+		FROM issue_comments c
+		WHERE c.package_id=i.package_id AND c.issue_number=i.issue_number
+			AND c.comment_username IS DISTINCT FROM u.user_username)
+	FROM issues i
+	LEFT JOIN users u ON u.user_github_id=i.issue_user_github_id
+	WHERE i.package_id=$1 AND i.issue_removed_at IS NULL`
+	rows, err := tx.Query(query, packageId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var issues []packagebug.ScoredIssue
+	for rows.Next() {
+		var i packagebug.ScoredIssue
+		var state sql.NullString
+		var createdAt *time.Time
+		err = rows.Scan(&state, &createdAt, &i.ClosedAt, &i.RespondedAt)
+		if err != nil {
+			return err
+		}
+		i.State = state.String
+		if createdAt != nil {
+			i.CreatedAt = *createdAt
+		}
+		issues = append(issues, i)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	s := packagebug.ComputeScore(issues, time.Now())
+	var response sql.NullString
+	if s.MedianResponse > 0 {
+		response = sql.NullString{String: fmt.Sprintf("%d seconds",
+			int64(s.MedianResponse/time.Second)), Valid: true}
+	}
+	query = `
+	INSERT INTO package_scores(package_id, score, weighted_open_bugs,
+		close_rate, median_time_to_response, updated_at)
+	VALUES($1, $2, $3, $4, $5::interval, now())
+	ON CONFLICT (package_id) DO UPDATE
+	SET score=excluded.score, weighted_open_bugs=excluded.weighted_open_bugs,
+		close_rate=excluded.close_rate,
+		median_time_to_response=excluded.median_time_to_response,
+		updated_at=excluded.updated_at`
+	_, err = tx.Exec(query, packageId, s.Value, s.WeightedOpenBugs,
+		s.CloseRate, response)
+	return err
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetStatus returns the sync state of the package: the etag, the since, the
+// last fetch, the gone time and the bug counts of the package stats.
+func (s *Store) GetStatus(p packagebug.Package) (packagebug.Status, error) {
+	st := packagebug.Status{Path: p.Path()}
+	query := `
+	SELECT p.package_etag, p.package_since, p.package_last_fetched_at,
+		p.package_last_error, p.package_gone_at, coalesce(s.open_bugs, 0),
+		coalesce(s.closed_bugs, 0), p.package_empty_since
+	FROM packages p
+	LEFT JOIN package_stats s ON s.package_id=p.package_id
+	WHERE p.package_path=$1 AND p.package_tenant=$2`
+	var etag, lastError sql.NullString
+	var since, lastFetchAt sql.NullTime
+	err := s.reader().QueryRow(query, p.Path(), p.Tenant).Scan(&etag, &since, &lastFetchAt,
+		&lastError, &st.GoneAt, &st.OpenBugs, &st.ClosedBugs, &st.EmptySince)
+	if err != nil {
+		return st, packagebug.DBError(err)
+	}
+	st.Etag = etag.String
+	st.Since = since.Time
+	st.LastFetchAt = lastFetchAt.Time
+	st.LastError = lastError.String
+	return st, nil
+}
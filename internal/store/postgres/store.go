@@ -0,0 +1,232 @@
+// Package postgres stores the packages and their bugs in Postgres.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+)
+
+// Store is the store backed by Postgres.
+type Store struct {
+	DB *sql.DB
+	// Replica is optional, the read-only queries that tolerate the
+	// replication lag run on the read replica instead of DB: the status,
+	// the search and the listing of the packages. The sync state, e.g. the
+	// etags, drives the next request of the package and is read from DB:
+	// the stale etag of a reset sync would be answered with 304.
+	Replica *sql.DB
+}
+
+// reader returns the database of the read-only queries, the replica if
+// configured.
+func (s *Store) reader() *sql.DB {
+	if s.Replica != nil {
+		return s.Replica
+	}
+	return s.DB
+}
+
+// GetEtag implements packagebug.Store. It returns the complete etag of the
+// last fetch if exists, otherwise empty string.
+func (s *Store) GetEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+
+	query := `
+	SELECT package_etag
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+
+	if etag.Valid {
+		return etag.String, nil
+	}
+
+	return "", nil
+}
+
+// GetSince implements packagebug.Store. It returns zero time if the package
+// never synced.
+func (s *Store) GetSince(p packagebug.Package) (time.Time, error) {
+	var since *time.Time
+
+	query := `
+	SELECT package_since
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	err := s.DB.QueryRow(query, p.Path(), p.Tenant).Scan(&since)
+	if err != nil {
+		return time.Time{}, packagebug.DBError(err)
+	}
+
+	if since != nil {
+		return *since, nil
+	}
+	return time.Time{}, nil
+}
+
+// Save implements packagebug.Store. The etag and the issues of the result
+// are stored in a single transaction, any failure rolls back the whole
+// result. The package row is locked first, so the concurrent saves of the
+// same package are serialized. The errors are ErrDB.
+func (s *Store) Save(r *packagebug.Result) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = lockPackage(tx, r.Package)
+	if err == nil {
+		err = save(tx, r)
+	}
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// lockPackage locks the row of the package until tx ends, so the saves of
+// the same package by several workers don't interleave their stats.
+func lockPackage(tx *sql.Tx, p packagebug.Package) error {
+	query := `
+	SELECT package_id
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2
+	FOR UPDATE`
+	var id string
+	err := tx.QueryRow(query, p.Path(), p.Tenant).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// save stores the result within tx.
+func save(tx *sql.Tx, r *packagebug.Result) error {
+	outbox, err := synced(tx, r.Package)
+	if err != nil {
+		return err
+	}
+	query := `
+	UPDATE packages
+	SET package_etag=$1, package_synced_at=now()
+	WHERE package_path=$2 AND package_tenant=$3`
+	_, err = tx.Exec(query, r.Etag, r.Package.Path(), r.Package.Tenant)
+	if err != nil {
+		return err
+	}
+
+	// the next sync only fetch issues updated after the newest one
+	if since := r.Since(); !since.IsZero() {
+		query = `
+		UPDATE packages
+		SET package_since=$1
+		WHERE package_path=$2 AND package_tenant=$3
+		AND (package_since IS NULL OR package_since < $1)`
+		_, err = tx.Exec(query, since, r.Package.Path(), r.Package.Tenant)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Full {
+		err = markRemoved(tx, r)
+		if err != nil {
+			return err
+		}
+	}
+	return saveIssues(tx, r, outbox)
+}
+
+// synced returns true if the package was saved before within tx, only the
+// changes of its issues after the first sync are written to the outbox.
+func synced(tx *sql.Tx, p packagebug.Package) (bool, error) {
+	query := `
+	SELECT package_synced_at IS NOT NULL
+	FROM packages
+	WHERE package_path=$1 AND package_tenant=$2`
+	var ok bool
+	err := tx.QueryRow(query, p.Path(), p.Tenant).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return ok, err
+}
+
+// markRemoved marks the stored open issues of the package missing from the
+// full result as removed within tx. The issues updated after the newest
+// issue of the result, e.g. received from the webhooks meanwhile, are kept.
+// The removed issue that shows up again is restored by its upsert.
+func markRemoved(tx *sql.Tx, r *packagebug.Result) error {
+	query := `
+	UPDATE issues
+	SET issue_removed_at=now()
+	WHERE package_id=$1 AND issue_state='open' AND issue_removed_at IS NULL
+		AND NOT (issue_number = ANY($2))
+		AND ($3::timestamptz IS NULL OR issue_updated_at IS NULL
+			OR issue_updated_at <= $3)`
+	_, err := tx.Exec(query, r.Package.Id, pq.Array(r.Numbers()),
+		nullTime(r.Since()))
+	return err
+}
+
+// SaveIssues stores the issues of the result in a single transaction
+// without changing the sync state of the package, e.g. the issues received
+// from the webhooks. The package row is locked first, see Save.
+func (s *Store) SaveIssues(r *packagebug.Result) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = lockPackage(tx, r.Package)
+	var outbox bool
+	if err == nil {
+		outbox, err = synced(tx, r.Package)
+	}
+	if err == nil {
+		err = saveIssues(tx, r, outbox)
+	}
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// saveIssues stores the issues of the result and updates the package stats
+// within tx. The changes of the issues are written to the outbox if outbox
+// is true.
+func saveIssues(tx *sql.Tx, r *packagebug.Result, outbox bool) error {
+	if len(r.Issues) > 0 {
+		b, err := newBatch(tx, r.Package, outbox)
+		if err != nil {
+			return err
+		}
+		for _, issue := range r.Issues {
+			err = b.saveIssue(issue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return updateStats(tx, r.Package.Id)
+}
+
+// nullTime returns NULL for zero time.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Ping checks the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+var dbconn *sql.DB
+
+func init() {
+	var err error
+	dbconn, err = sql.Open("postgres", os.Getenv("PACKAGEBUG_DB_TEST"))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+var insertTestDataSQL = `
+INSERT INTO packages(package_path,
+	package_host, package_owner,
+	package_repo, package_etag)
+VALUES('test_host/test_owner/test_repo',
+	'test_host', 'test_owner', 'test_repo',
+	'test_etag');`
+
+var deleteTestDataSQL = `
+DELETE FROM packages
+WHERE package_host='test_host' AND package_owner='test_owner';`
+
+func TestGetEtag(t *testing.T) {
+	// create test data
+	_, err := dbconn.Exec(insertTestDataSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Store{DB: dbconn}
+	p := packagebug.Package{
+		Host:  "test_host",
+		Owner: "test_owner",
+		Repo:  "test_repo",
+	}
+	etag, err := s.GetEtag(p)
+	if err != nil {
+		t.Error(err)
+	}
+	if etag != "test_etag" {
+		t.Errorf("got: %s\n", etag)
+	}
+
+	// delete test data
+	_, err = dbconn.Exec(deleteTestDataSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	if os.Getenv("PACKAGEBUG_DB_TEST") == "" {
+		b.Skip("PACKAGEBUG_DB_TEST not set")
+	}
+	_, err := dbconn.Exec(insertTestDataSQL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dbconn.Exec(deleteTestDataSQL)
+	s := &Store{DB: dbconn}
+	p, ok, err := s.FindPackage("", "test_host/test_owner/test_repo")
+	if err != nil || !ok {
+		b.Fatalf("expected package got: %v %v\n", ok, err)
+	}
+	r := &packagebug.Result{Package: p, Issues: fake.Issues(1000)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = s.Save(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStoreReader(t *testing.T) {
+	s := &Store{DB: dbconn}
+	if s.reader() != dbconn {
+		t.Errorf("expected primary reader got: %v\n", s.reader())
+	}
+	replica := &sql.DB{}
+	s.Replica = replica
+	if s.reader() != replica {
+		t.Errorf("expected replica reader got: %v\n", s.reader())
+	}
+}
+
+func TestGetEtagPrimary(t *testing.T) {
+	_, err := dbconn.Exec(insertTestDataSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbconn.Exec(deleteTestDataSQL)
+	// the replica lags behind, the sync state is read from the primary
+	replica, err := sql.Open("postgres", "host=/nonexistent sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	s := &Store{DB: dbconn, Replica: replica}
+	p := packagebug.Package{Host: "test_host", Owner: "test_owner", Repo: "test_repo"}
+	etag, err := s.GetEtag(p)
+	if err != nil || etag != "test_etag" {
+		t.Errorf("expected the etag of the primary got: %s %v\n", etag, err)
+	}
+	_, err = s.GetSince(p)
+	if err != nil {
+		t.Errorf("expected the since of the primary got: %v\n", err)
+	}
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// SaveAdvisories replaces the stored advisories of the package.
+func (s *Store) SaveAdvisories(p packagebug.Package, advisories []packagebug.Advisory) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveAdvisories(tx, p, advisories)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+func saveAdvisories(tx *sql.Tx, p packagebug.Package, advisories []packagebug.Advisory) error {
+	query := `
+	DELETE FROM advisories
+	WHERE package_id=(SELECT package_id FROM packages WHERE package_tenant=? AND package_path=?)`
+	_, err := tx.Exec(query, p.Tenant, p.Path())
+	if err != nil {
+		return err
+	}
+	query = `
+	INSERT INTO advisories(package_id, advisory_id, advisory_aliases,
+		advisory_summary, advisory_severity, advisory_url,
+		advisory_published_at, advisory_modified_at, advisory_withdrawn_at)
+	SELECT package_id, ?, ?, ?, ?, ?, ?, ?, ?
+	FROM packages
+	WHERE package_tenant=? AND package_path=?
+	ON CONFLICT (package_id, advisory_id) DO NOTHING`
+	for _, a := range advisories {
+		aliases := a.Aliases
+		if aliases == nil {
+			aliases = []string{}
+		}
+		data, err := json.Marshal(aliases)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(query, a.Id, string(data), a.Summary, a.Severity,
+			a.Url, nullTime(a.PublishedAt), nullTime(a.ModifiedAt),
+			nullTimePtr(a.WithdrawnAt), p.Tenant, p.Path())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// batch writes the issues of a result within a single transaction. Every
+// statement is prepared once and reused for all issues of the result.
+type batch struct {
+	tx          *sql.Tx
+	packageId   string
+	packagePath string
+	// outbox writes the changes of the issues to the outbox
+	outbox bool
+	// users and milestones already stored by the batch, most issues share
+	// a few of them
+	users      map[int64]bool
+	milestones map[int]bool
+
+	issue           *sql.Stmt
+	user            *sql.Stmt
+	milestone       *sql.Stmt
+	deleteLabels    *sql.Stmt
+	label           *sql.Stmt
+	deleteAssignees *sql.Stmt
+	assignee        *sql.Stmt
+	comment         *sql.Stmt
+	event           *sql.Stmt
+	state           *sql.Stmt
+	outboxEvent     *sql.Stmt
+	link            *sql.Stmt
+	release         *sql.Stmt
+}
+
+// newBatch prepares the statements within tx. The statements are closed
+// when tx is committed or rolled back.
+func newBatch(tx *sql.Tx, p packagebug.Package, outbox bool) (*batch, error) {
+	b := &batch{tx: tx, packageId: p.Id, packagePath: p.Path(),
+		outbox: outbox, users: make(map[int64]bool),
+		milestones: make(map[int]bool)}
+	stmts := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&b.issue, `
+	INSERT INTO issues(issue_github_id, package_id, issue_number,
+		issue_title, issue_url, issue_api_url, issue_api_labels_url,
+		issue_api_comments_url, issue_api_events_url, issue_body,
+		issue_reactions_plus_one, issue_updated_at, issue_language,
+		issue_state, issue_created_at, issue_closed_at,
+		issue_user_github_id, issue_is_pull_request, issue_assignees,
+		issue_milestone, issue_milestone_number, issue_bug_labeled_at,
+		issue_bug_unlabeled_at, issue_severity, issue_tenant)
+	VALUES(?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15,
+		?16, ?17, ?18, ?19, ?20, ?21, ?22, ?23, ?24,
+		(SELECT package_tenant FROM packages WHERE package_id=?2))
+	ON CONFLICT (package_id, issue_number) DO UPDATE
+	SET issue_github_id=excluded.issue_github_id,
+		issue_title=excluded.issue_title, issue_url=excluded.issue_url,
+		issue_body=excluded.issue_body,
+		issue_reactions_plus_one=excluded.issue_reactions_plus_one,
+		issue_updated_at=excluded.issue_updated_at,
+		issue_language=excluded.issue_language,
+		issue_state=excluded.issue_state,
+		issue_created_at=excluded.issue_created_at,
+		issue_closed_at=excluded.issue_closed_at,
+		issue_user_github_id=excluded.issue_user_github_id,
+		issue_is_pull_request=excluded.issue_is_pull_request,
+		issue_assignees=excluded.issue_assignees,
+		issue_milestone=excluded.issue_milestone,
+		issue_milestone_number=excluded.issue_milestone_number,
+		issue_severity=excluded.issue_severity,
+		issue_tenant=excluded.issue_tenant,
+		issue_removed_at=NULL,
+		issue_bug_labeled_at=coalesce(excluded.issue_bug_labeled_at,
+			issues.issue_bug_labeled_at),
+		issue_bug_unlabeled_at=CASE WHEN excluded.issue_bug_labeled_at IS NULL
+			THEN issues.issue_bug_unlabeled_at
+			ELSE excluded.issue_bug_unlabeled_at END
+	WHERE issues.issue_updated_at IS NULL OR excluded.issue_updated_at IS NULL
+		OR issues.issue_updated_at <= excluded.issue_updated_at`},
+		{&b.user, `
+	INSERT INTO users(user_github_id, user_username, user_avatar_url,
+		user_profile_url)
+	VALUES(?, ?, ?, ?)
+	ON CONFLICT (user_github_id) DO UPDATE
+	SET user_username=excluded.user_username,
+		user_avatar_url=excluded.user_avatar_url,
+		user_profile_url=excluded.user_profile_url`},
+		{&b.milestone, `
+	INSERT INTO milestones(package_id, milestone_number, milestone_title,
+		milestone_state, milestone_due_on)
+	VALUES(?, ?, ?, ?, ?)
+	ON CONFLICT (package_id, milestone_number) DO UPDATE
+	SET milestone_title=excluded.milestone_title,
+		milestone_state=excluded.milestone_state,
+		milestone_due_on=excluded.milestone_due_on`},
+		{&b.deleteLabels, `
+	DELETE FROM issue_labels
+	WHERE package_id=? AND issue_number=?`},
+		{&b.label, `
+	INSERT INTO issue_labels(package_id, issue_number, label_name)
+	VALUES(?, ?, ?)
+	ON CONFLICT DO NOTHING`},
+		{&b.deleteAssignees, `
+	DELETE FROM issue_assignees
+	WHERE package_id=? AND issue_number=?`},
+		{&b.assignee, `
+	INSERT INTO issue_assignees(package_id, issue_number, assignee_username,
+		assignee_github_id)
+	VALUES(?, ?, ?, ?)
+	ON CONFLICT DO NOTHING`},
+		{&b.comment, `
+	INSERT INTO issue_comments(comment_github_id, package_id, issue_number,
+		comment_body, comment_username, comment_created_at)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT (comment_github_id) DO UPDATE
+	SET comment_body=excluded.comment_body`},
+		{&b.event, `
+	INSERT INTO issue_events(event_github_id, package_id, issue_number,
+		event, event_label, event_created_at)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT (event_github_id) DO NOTHING`},
+		{&b.link, `
+	INSERT INTO issue_links(package_id, issue_number, link_kind, link_path,
+		link_number, link_created_at)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING`},
+		{&b.release, releaseWindows + ` AND issues.issue_number=?`},
+		{&b.state, `
+	SELECT issue_state
+	FROM issues
+	WHERE package_id=? AND issue_number=?`},
+		{&b.outboxEvent, `
+	INSERT INTO outbox(outbox_event, package_id, package_path, issue_number,
+		issue_title, issue_url, outbox_created_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?)`},
+	}
+	for _, s := range stmts {
+		stmt, err := tx.Prepare(s.query)
+		if err != nil {
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+	return b, nil
+}
+
+// saveIssue stores the issue with its user, labels, comments and events.
+// The issue is never replaced by an older update of it, see the Postgres
+// store.
+func (b *batch) saveIssue(issue packagebug.Issue) error {
+	err := b.saveUser(issue.User)
+	if err != nil {
+		return err
+	}
+	err = b.saveMilestone(issue.Milestone)
+	if err != nil {
+		return err
+	}
+	// only issues from github have github id
+	githubId := sql.NullInt64{Int64: issue.GithubId, Valid: issue.GithubId != 0}
+	userId := sql.NullInt64{Int64: issue.User.GithubId, Valid: issue.User.GithubId != 0}
+	var milestone sql.NullString
+	var milestoneNumber sql.NullInt64
+	if issue.Milestone != nil {
+		milestone = sql.NullString{String: issue.Milestone.Title, Valid: true}
+		milestoneNumber = sql.NullInt64{Int64: int64(issue.Milestone.Number), Valid: true}
+	}
+	// the assignees are a JSON array, SQLite has no arrays
+	var assignees sql.NullString
+	if names := issue.AssigneeNames(); len(names) > 0 {
+		data, err := json.Marshal(names)
+		if err != nil {
+			return err
+		}
+		assignees = sql.NullString{String: string(data), Valid: true}
+	}
+	// the events of the outbox are the changes from the stored state
+	var stored string
+	if b.outbox {
+		err = b.state.QueryRow(b.packageId, issue.Number).Scan(&stored)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	res, err := b.issue.Exec(githubId, b.packageId, issue.Number,
+		issue.Title, issue.Url, issue.ApiUrl, issue.ApiLabelsUrl,
+		issue.ApiCommentsUrl, issue.ApiEventsUrl, issue.Body,
+		issue.Reactions.PlusOne, nullTime(issue.UpdatedAt), issue.Language,
+		issue.State, nullTime(issue.CreatedAt), nullTimePtr(issue.ClosedAt),
+		userId, issue.IsPullRequest(), assignees, milestone, milestoneNumber,
+		nullTimePtr(issue.BugLabeledAt), nullTimePtr(issue.BugUnlabeledAt),
+		issue.Severity)
+	if err != nil {
+		return err
+	}
+	// the stored issue is newer, keep its labels and assignees too
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		err = b.saveLabels(issue)
+		if err != nil {
+			return err
+		}
+		err = b.saveAssignees(issue)
+		if err != nil {
+			return err
+		}
+		err = b.saveOutbox(stored, issue)
+		if err != nil {
+			return err
+		}
+		_, err = b.release.Exec(b.packageId, issue.Number)
+		if err != nil {
+			return err
+		}
+	}
+	return b.saveDetails(issue)
+}
+
+// saveOutbox writes the events of the issue saved over its stored state to
+// the outbox, see packagebug.OutboxEvents.
+func (b *batch) saveOutbox(stored string, issue packagebug.Issue) error {
+	if !b.outbox {
+		return nil
+	}
+	for _, event := range packagebug.OutboxEvents(stored, issue) {
+		_, err := b.outboxEvent.Exec(event, b.packageId, b.packagePath,
+			issue.Number, issue.Title, issue.Url, now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveUser stores the user who opened the issue once per batch. Users
+// without github id are ignored.
+func (b *batch) saveUser(u packagebug.IssueCreator) error {
+	if u.GithubId == 0 || b.users[u.GithubId] {
+		return nil
+	}
+	_, err := b.user.Exec(u.GithubId, u.Username, u.AvatarUrl, u.ProfileUrl)
+	if err != nil {
+		return err
+	}
+	b.users[u.GithubId] = true
+	return nil
+}
+
+// saveMilestone stores the milestone of the issue once per batch. Issues
+// without milestone are ignored.
+func (b *batch) saveMilestone(m *packagebug.Milestone) error {
+	if m == nil || b.milestones[m.Number] {
+		return nil
+	}
+	var dueOn *time.Time
+	if m.DueOn != nil {
+		dueOn = nullTime(*m.DueOn)
+	}
+	_, err := b.milestone.Exec(b.packageId, m.Number, m.Title,
+		sql.NullString{String: m.State, Valid: m.State != ""}, dueOn)
+	if err != nil {
+		return err
+	}
+	b.milestones[m.Number] = true
+	return nil
+}
+
+// saveAssignees replaces the assignees of the issue, the assignees with
+// github id are stored as users too.
+func (b *batch) saveAssignees(issue packagebug.Issue) error {
+	_, err := b.deleteAssignees.Exec(b.packageId, issue.Number)
+	if err != nil {
+		return err
+	}
+	for _, a := range issue.Assignees {
+		err = b.saveUser(a)
+		if err != nil {
+			return err
+		}
+		_, err = b.assignee.Exec(b.packageId, issue.Number, a.Username,
+			sql.NullInt64{Int64: a.GithubId, Valid: a.GithubId != 0})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveLabels replaces the labels of the issue.
+func (b *batch) saveLabels(issue packagebug.Issue) error {
+	_, err := b.deleteLabels.Exec(b.packageId, issue.Number)
+	if err != nil {
+		return err
+	}
+	for _, l := range issue.Labels {
+		_, err = b.label.Exec(b.packageId, issue.Number, l.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveDetails stores the comments, events and links of the issue.
+func (b *batch) saveDetails(issue packagebug.Issue) error {
+	for _, c := range issue.Comments {
+		_, err := b.comment.Exec(c.GithubId, b.packageId, issue.Number, c.Body,
+			c.User.Username, nullTime(c.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range issue.Events {
+		_, err := b.event.Exec(e.GithubId, b.packageId, issue.Number, e.Event,
+			e.Label.Name, nullTime(e.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range issue.Links {
+		_, err := b.link.Exec(b.packageId, issue.Number, l.Kind, l.Path,
+			l.Number, nullTime(l.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetResponse returns the cached response of the url, the responses older
+// than packagebug.CacheTTL are ignored.
+func (s *Store) GetResponse(ctx context.Context, url string) (packagebug.CachedResponse, bool, error) {
+	query := `
+	SELECT coalesce(cache_etag, ''), coalesce(cache_last_modified, ''),
+		coalesce(cache_link, '')
+	FROM response_cache
+	WHERE cache_url=? AND cache_updated_at > ?`
+	r := packagebug.CachedResponse{Url: url}
+	err := s.DB.QueryRowContext(ctx, query, url,
+		now().Add(-packagebug.CacheTTL)).Scan(&r.Etag, &r.LastModified, &r.Link)
+	if err == sql.ErrNoRows {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, packagebug.DBError(err)
+	}
+	return r, true, nil
+}
+
+// SaveResponses stores the cached responses, see packagebug.ResponseCache.
+// There is no scheduler on SQLite, the expired responses are pruned here.
+func (s *Store) SaveResponses(ctx context.Context, responses []packagebug.CachedResponse) error {
+	query := `
+	INSERT INTO response_cache(cache_url, cache_etag, cache_last_modified,
+		cache_link, cache_updated_at)
+	VALUES(?, ?, ?, ?, ?)
+	ON CONFLICT (cache_url) DO UPDATE
+	SET cache_etag=excluded.cache_etag,
+		cache_last_modified=excluded.cache_last_modified,
+		cache_link=excluded.cache_link,
+		cache_updated_at=excluded.cache_updated_at`
+	for _, r := range responses {
+		_, err := s.DB.ExecContext(ctx, query, r.Url, r.Etag, r.LastModified,
+			r.Link, now())
+		if err != nil {
+			return packagebug.DBError(err)
+		}
+	}
+	query = `
+	DELETE FROM response_cache
+	WHERE cache_updated_at < ?`
+	_, err := s.DB.ExecContext(ctx, query, now().Add(-packagebug.CacheTTL))
+	return packagebug.DBError(err)
+}
@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+)
+
+// DSN returns the connection string of the database file at path. The
+// foreign keys are enforced, the journal is written ahead so the readers
+// don't block the writer, and the writers wait for the busy database instead
+// of failing. The read-only database fails any write, see dry run.
+func DSN(path string, readOnly bool) string {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	if readOnly {
+		q.Set("mode", "ro")
+	} else {
+		q.Add("_pragma", "journal_mode(WAL)")
+	}
+	return "file:" + path + "?" + q.Encode()
+}
+
+// Open opens the database file at path. SQLite has a single writer, the
+// connections are limited to one so the concurrent saves of the workers wait
+// for each other instead of failing as busy.
+func Open(path string, readOnly bool) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", DSN(path, readOnly))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	cases := []struct {
+		readOnly bool
+		expected string
+	}{
+		{false, "file:/var/lib/packagebug.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29"},
+		{true, "file:/var/lib/packagebug.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&mode=ro"},
+	}
+	for _, c := range cases {
+		dsn := DSN("/var/lib/packagebug.db", c.readOnly)
+		if dsn != c.expected {
+			t.Errorf("expected %s got: %s\n", c.expected, dsn)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Lock takes the lock keyed on the package path within its tenant, see
+// packagebug.Package.Key. The SQLite database belongs
+// to a single process, the lock is held in memory until unlock is called. It
+// returns false if other worker of the process holds the lock.
+func (s *Store) Lock(ctx context.Context, p packagebug.Package) (func(), bool, error) {
+	key := p.Key()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked[key] {
+		return nil, false, nil
+	}
+	if s.locked == nil {
+		s.locked = make(map[string]bool)
+	}
+	s.locked[key] = true
+
+	unlock := func() {
+		s.mu.Lock()
+		delete(s.locked, key)
+		s.mu.Unlock()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestLock(t *testing.T) {
+	s := &Store{}
+	p := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "byten"}
+	unlock, ok, err := s.Lock(context.Background(), p)
+	if err != nil || !ok {
+		t.Fatalf("expected lock got: %v %v\n", ok, err)
+	}
+	_, ok, _ = s.Lock(context.Background(), p)
+	if ok {
+		t.Errorf("expected the locked package not to be locked again\n")
+	}
+	other := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "other"}
+	_, ok, _ = s.Lock(context.Background(), other)
+	if !ok {
+		t.Errorf("expected other package to be locked\n")
+	}
+	tenant := p
+	tenant.Tenant = "acme"
+	_, ok, _ = s.Lock(context.Background(), tenant)
+	if !ok {
+		t.Errorf("expected the package of acme to be locked\n")
+	}
+	unlock()
+	_, ok, _ = s.Lock(context.Background(), p)
+	if !ok {
+		t.Errorf("expected the unlocked package to be locked\n")
+	}
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a schema change embedded in the binary. The file name of the
+// migration is <version>_<name>.sql.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the embedded migrations ordered by version. The
+// versions are independent of the Postgres migrations, the first one creates
+// the schema the Postgres migrations had when the SQLite store was added.
+func Migrations() ([]Migration, error) {
+	names, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, f := range names {
+		version, name, ok := strings.Cut(strings.TrimSuffix(f.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name %q", f.Name())
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q", f.Name())
+		}
+		if other, ok := seen[v]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s, %s", v, other, f.Name())
+		}
+		seen[v] = f.Name()
+		b, err := migrationFiles.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: v, Name: name, SQL: string(b)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate applies the pending migrations, each in its own transaction, and
+// returns the number of applied migrations. The applied versions are
+// recorded in schema_migrations table. The database file has a single
+// writer, no other lock is taken.
+func Migrate(ctx context.Context, dbconn *sql.DB) (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL
+	)`
+	_, err = dbconn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	var current int
+	query = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+	err = dbconn.QueryRowContext(ctx, query).Scan(&current)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		err = applyMigration(ctx, dbconn, m)
+		if err != nil {
+			return n, fmt.Errorf("migration %d %s: %s", m.Version, m.Name, err)
+		}
+		slog.Info("migration applied", "version", m.Version, "name", m.Name)
+		n++
+	}
+	return n, nil
+}
+
+// AppliedVersion returns the version of the latest applied migration, 0 if
+// the database was never migrated.
+func AppliedVersion(ctx context.Context, dbconn *sql.DB) (int, error) {
+	var exists bool
+	query := `
+	SELECT count(*) > 0 FROM sqlite_master
+	WHERE type='table' AND name='schema_migrations'`
+	err := dbconn.QueryRowContext(ctx, query).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	query = `SELECT coalesce(max(version), 0) FROM schema_migrations`
+	err = dbconn.QueryRowContext(ctx, query).Scan(&version)
+	return version, err
+}
+
+// applyMigration applies the migration and records its version. The
+// foreign keys are off while it runs, otherwise the tables rebuilt by the
+// migration would cascade their drop to the rows referencing them, e.g. the
+// issues of the packages. The foreign keys are checked before the commit.
+func applyMigration(ctx context.Context, dbconn *sql.DB, m Migration) error {
+	// the pragma is set per connection and is a no-op in a transaction
+	conn, err := dbconn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF`)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `PRAGMA foreign_keys=ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, m.SQL)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	violation := rows.Next()
+	rows.Close()
+	if violation {
+		tx.Rollback()
+		return fmt.Errorf("foreign key violation")
+	}
+	query := `
+	INSERT INTO schema_migrations(version, name, applied_at)
+	VALUES(?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, m.Version, m.Name, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d got: %d (%s)\n", i+1, m.Version, m.Name)
+		}
+		if m.SQL == "" {
+			t.Errorf("expected SQL of migration %d\n", m.Version)
+		}
+	}
+}
+
+func TestAppliedVersion(t *testing.T) {
+	s, _ := testStore(t)
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := AppliedVersion(context.Background(), s.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := migrations[len(migrations)-1].Version; version != latest {
+		t.Errorf("expected version %d got: %d\n", latest, version)
+	}
+
+	db, err := Open(filepath.Join(t.TempDir(), "empty.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	version, err = AppliedVersion(context.Background(), db)
+	if err != nil || version != 0 {
+		t.Errorf("expected version 0 of the empty database got: %d %v\n", version, err)
+	}
+}
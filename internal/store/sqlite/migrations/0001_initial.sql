@@ -0,0 +1,132 @@
+-- the schema of the Postgres migrations up to 0014 in the SQLite dialect:
+-- the arrays are JSON, the booleans are integers and the intervals are
+-- seconds
+CREATE TABLE IF NOT EXISTS packages (
+	package_id integer PRIMARY KEY AUTOINCREMENT,
+	package_path text NOT NULL UNIQUE,
+	package_host text NOT NULL,
+	package_owner text NOT NULL,
+	package_repo text NOT NULL,
+	package_etag text,
+	package_since timestamp,
+	package_labels text,
+	package_bug_labels text,
+	package_token text,
+	package_verify_token text,
+	package_verified integer,
+	package_verified_at timestamp,
+	package_capabilities integer,
+	package_fork_parent text,
+	package_fork_policy text,
+	package_fork_checked_at timestamp,
+	package_next_fetch_at timestamp,
+	package_priority integer NOT NULL DEFAULT 0,
+	package_stars integer,
+	package_forks integer,
+	package_archived integer,
+	package_default_branch text,
+	package_description text,
+	package_pushed_at timestamp,
+	package_repo_etag text,
+	package_repo_fetched_at timestamp,
+	package_last_fetched_at timestamp,
+	package_last_error text,
+	package_gone_at timestamp
+);
+CREATE INDEX IF NOT EXISTS packages_next_fetch_at_idx
+	ON packages (package_next_fetch_at);
+
+CREATE TABLE IF NOT EXISTS package_aliases (
+	alias_path text PRIMARY KEY,
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	created_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	user_github_id integer PRIMARY KEY,
+	user_username text NOT NULL,
+	user_avatar_url text,
+	user_profile_url text
+);
+
+CREATE TABLE IF NOT EXISTS issues (
+	issue_id integer PRIMARY KEY AUTOINCREMENT,
+	issue_github_id integer,
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	issue_number integer NOT NULL,
+	issue_title text NOT NULL,
+	issue_url text,
+	issue_api_url text,
+	issue_api_labels_url text,
+	issue_api_comments_url text,
+	issue_api_events_url text,
+	issue_body text,
+	issue_reactions_plus_one integer,
+	issue_updated_at timestamp,
+	issue_language text,
+	issue_state text,
+	issue_created_at timestamp,
+	issue_closed_at timestamp,
+	issue_user_github_id integer REFERENCES users,
+	issue_is_pull_request integer NOT NULL DEFAULT 0,
+	issue_assignees text,
+	issue_milestone text,
+	UNIQUE (package_id, issue_number)
+);
+
+CREATE TABLE IF NOT EXISTS issue_labels (
+	package_id integer NOT NULL,
+	issue_number integer NOT NULL,
+	label_name text NOT NULL,
+	PRIMARY KEY (package_id, issue_number, label_name),
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS issue_comments (
+	comment_github_id integer PRIMARY KEY,
+	package_id integer NOT NULL,
+	issue_number integer NOT NULL,
+	comment_body text,
+	comment_username text,
+	comment_created_at timestamp,
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS issue_events (
+	event_github_id integer PRIMARY KEY,
+	package_id integer NOT NULL,
+	issue_number integer NOT NULL,
+	event text NOT NULL,
+	event_label text,
+	event_created_at timestamp,
+	FOREIGN KEY (package_id, issue_number)
+		REFERENCES issues (package_id, issue_number) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS package_stats (
+	package_id integer PRIMARY KEY REFERENCES packages ON DELETE CASCADE,
+	open_bugs integer NOT NULL,
+	closed_bugs integer NOT NULL,
+	median_time_to_close integer,
+	updated_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS fetch_log (
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	started_at timestamp NOT NULL,
+	duration_ms integer NOT NULL,
+	issues_upserted integer NOT NULL,
+	error text,
+	status_code integer,
+	pages integer
+);
+CREATE INDEX IF NOT EXISTS fetch_log_started_at_idx ON fetch_log (started_at);
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+	token_id text PRIMARY KEY,
+	remaining integer NOT NULL,
+	reset_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL
+);
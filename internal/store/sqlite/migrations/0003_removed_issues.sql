@@ -0,0 +1,3 @@
+-- the issues deleted or transferred on the host, they are missing from the
+-- full syncs of the package and kept for the history
+ALTER TABLE issues ADD COLUMN issue_removed_at timestamp;
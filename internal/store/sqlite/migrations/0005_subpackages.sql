@@ -0,0 +1,6 @@
+-- the subpackages of the repositories, e.g. github.com/aws/aws-sdk-go/service/sqs
+-- of github.com/aws/aws-sdk-go. the subpackage whose repository is a package
+-- too is linked to it, the issues of the repository are fetched once.
+ALTER TABLE packages ADD COLUMN package_subpath text;
+ALTER TABLE packages ADD COLUMN package_root_id integer
+	REFERENCES packages ON DELETE SET NULL;
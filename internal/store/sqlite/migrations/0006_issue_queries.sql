@@ -0,0 +1,6 @@
+-- the issue list query of the package overriding the query of the
+-- deployment: the state filter, the bug labels and the sort order.
+ALTER TABLE packages ADD COLUMN package_issue_state text;
+ALTER TABLE packages ADD COLUMN package_issue_labels text;
+ALTER TABLE packages ADD COLUMN package_issue_sort text;
+ALTER TABLE packages ADD COLUMN package_issue_direction text;
@@ -0,0 +1,4 @@
+-- the bug timeline of the issue events: when the bug label was first applied
+-- and when the last bug label was removed.
+ALTER TABLE issues ADD COLUMN issue_bug_labeled_at timestamp;
+ALTER TABLE issues ADD COLUMN issue_bug_unlabeled_at timestamp;
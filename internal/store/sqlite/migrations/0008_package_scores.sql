@@ -0,0 +1,10 @@
+-- the bug-health score of the package computed at sync time, see
+-- packagebug.ComputeScore. median_time_to_response is in seconds.
+CREATE TABLE IF NOT EXISTS package_scores (
+	package_id integer PRIMARY KEY REFERENCES packages ON DELETE CASCADE,
+	score real NOT NULL,
+	weighted_open_bugs real NOT NULL,
+	close_rate real NOT NULL,
+	median_time_to_response integer,
+	updated_at timestamp NOT NULL
+);
@@ -0,0 +1,13 @@
+-- the links of the issues to the canonical issue they duplicate and to the
+-- issues and pull requests that referenced them, see packagebug.IssueLink.
+CREATE TABLE IF NOT EXISTS issue_links (
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	issue_number integer NOT NULL,
+	link_kind text NOT NULL,
+	link_path text NOT NULL,
+	link_number integer NOT NULL,
+	link_created_at timestamp,
+	PRIMARY KEY (package_id, issue_number, link_kind, link_path, link_number)
+);
+CREATE INDEX IF NOT EXISTS issue_links_target_idx
+	ON issue_links (link_path, link_number);
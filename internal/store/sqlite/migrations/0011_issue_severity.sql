@@ -0,0 +1,6 @@
+-- the severity of the issue inferred from its labels and title, see
+-- packagebug.Severity: 0 unknown, 1 low up to 4 critical.
+ALTER TABLE issues ADD COLUMN issue_severity integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS issues_package_severity_idx
+	ON issues (package_id, issue_severity DESC);
@@ -0,0 +1,14 @@
+-- the security advisories of the packages, refreshed by every sync, see
+-- packagebug.Advisory. the aliases are the JSON array of the ids.
+CREATE TABLE IF NOT EXISTS advisories (
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	advisory_id text NOT NULL,
+	advisory_aliases text NOT NULL DEFAULT '[]',
+	advisory_summary text,
+	advisory_severity text,
+	advisory_url text,
+	advisory_published_at timestamp,
+	advisory_modified_at timestamp,
+	advisory_withdrawn_at timestamp,
+	PRIMARY KEY (package_id, advisory_id)
+);
@@ -0,0 +1,17 @@
+-- the published releases of the package repositories and the release window
+-- of the issues: the latest release the issue was opened against and the
+-- first release published after it was closed.
+CREATE TABLE IF NOT EXISTS releases (
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	release_tag text NOT NULL,
+	release_name text,
+	release_prerelease boolean NOT NULL DEFAULT false,
+	release_published_at timestamp NOT NULL,
+	PRIMARY KEY (package_id, release_tag)
+);
+CREATE INDEX IF NOT EXISTS releases_published_at_idx
+	ON releases (package_id, release_published_at);
+
+ALTER TABLE packages ADD COLUMN package_releases_etag text;
+ALTER TABLE issues ADD COLUMN issue_opened_release text;
+ALTER TABLE issues ADD COLUMN issue_fixed_release text;
@@ -0,0 +1,2 @@
+-- the reason the package wasn't fetched, e.g. archived, NULL if fetched.
+ALTER TABLE fetch_log ADD COLUMN skip_reason text;
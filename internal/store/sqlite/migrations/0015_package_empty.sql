@@ -0,0 +1,2 @@
+-- the time since the package has no bugs stored, NULL if it has any.
+ALTER TABLE packages ADD COLUMN package_empty_since timestamp;
@@ -0,0 +1,8 @@
+-- the tenant the package is crawled for, empty for the deployment itself.
+-- the issues carry the tenant of their package.
+ALTER TABLE packages ADD COLUMN package_tenant text NOT NULL DEFAULT '';
+ALTER TABLE issues ADD COLUMN issue_tenant text NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS packages_tenant_idx
+	ON packages (package_tenant, package_id);
+CREATE INDEX IF NOT EXISTS issues_tenant_idx
+	ON issues (issue_tenant, package_id);
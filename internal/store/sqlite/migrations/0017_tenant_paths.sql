@@ -0,0 +1,54 @@
+-- the same path may be crawled for several tenants, the package is unique
+-- by its tenant and path. SQLite can't drop the UNIQUE of package_path, the
+-- table is rebuilt with the foreign keys off, see applyMigration.
+CREATE TABLE packages_new (
+	package_id integer PRIMARY KEY AUTOINCREMENT,
+	package_path text NOT NULL,
+	package_host text NOT NULL,
+	package_owner text NOT NULL,
+	package_repo text NOT NULL,
+	package_etag text,
+	package_since timestamp,
+	package_labels text,
+	package_bug_labels text,
+	package_token text,
+	package_verify_token text,
+	package_verified integer,
+	package_verified_at timestamp,
+	package_capabilities integer,
+	package_fork_parent text,
+	package_fork_policy text,
+	package_fork_checked_at timestamp,
+	package_next_fetch_at timestamp,
+	package_priority integer NOT NULL DEFAULT 0,
+	package_stars integer,
+	package_forks integer,
+	package_archived integer,
+	package_default_branch text,
+	package_description text,
+	package_pushed_at timestamp,
+	package_repo_etag text,
+	package_repo_fetched_at timestamp,
+	package_last_fetched_at timestamp,
+	package_last_error text,
+	package_gone_at timestamp,
+	package_subpath text,
+	package_root_id integer
+		REFERENCES packages ON DELETE SET NULL,
+	package_issue_state text,
+	package_issue_labels text,
+	package_issue_sort text,
+	package_issue_direction text,
+	package_synced_at timestamp,
+	package_releases_etag text,
+	package_empty_since timestamp,
+	package_tenant text NOT NULL DEFAULT '',
+	UNIQUE (package_tenant, package_path)
+);
+INSERT INTO packages_new SELECT * FROM packages;
+DROP TABLE packages;
+ALTER TABLE packages_new RENAME TO packages;
+CREATE INDEX IF NOT EXISTS packages_next_fetch_at_idx
+	ON packages (package_next_fetch_at);
+CREATE INDEX IF NOT EXISTS packages_tenant_idx
+	ON packages (package_tenant, package_id);
@@ -0,0 +1,14 @@
+-- the former path of a package is its alias, the same path may be the alias
+-- of the packages of several tenants. FindPackage filters the tenant.
+CREATE TABLE package_aliases_new (
+	alias_path text NOT NULL,
+	package_id integer NOT NULL REFERENCES packages ON DELETE CASCADE,
+	created_at timestamp NOT NULL,
+	PRIMARY KEY (package_id, alias_path)
+);
+INSERT INTO package_aliases_new SELECT alias_path, package_id, created_at
+	FROM package_aliases;
+DROP TABLE package_aliases;
+ALTER TABLE package_aliases_new RENAME TO package_aliases;
+CREATE INDEX IF NOT EXISTS package_aliases_path_idx
+	ON package_aliases (alias_path);
@@ -0,0 +1,457 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// GetSettings get the custom settings of the package.
+func (s *Store) GetSettings(p packagebug.Package) (packagebug.Settings, error) {
+	var settings packagebug.Settings
+	var labels, bugLabels, token, verifyToken sql.NullString
+	var state, issueLabels, sort, direction sql.NullString
+	var verified sql.NullBool
+	var verifiedAt sql.NullTime
+
+	query := `
+	SELECT package_labels, package_bug_labels, package_token,
+		package_verify_token, package_verified, package_verified_at,
+		package_issue_state, package_issue_labels, package_issue_sort,
+		package_issue_direction
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&labels, &bugLabels, &token,
+		&verifyToken, &verified, &verifiedAt, &state, &issueLabels, &sort,
+		&direction)
+	if err != nil {
+		return settings, err
+	}
+	settings.Query, err = packagebug.ParseQuery(state.String,
+		issueLabels.String, sort.String, direction.String)
+	if err != nil {
+		return settings, err
+	}
+
+	if labels.Valid {
+		settings.Labels = packagebug.ParseTokens(labels.String)
+	}
+	if bugLabels.Valid {
+		settings.BugLabels = packagebug.ParseTokens(bugLabels.String)
+	}
+	settings.Token = token.String
+	settings.VerifyToken = verifyToken.String
+	settings.Verified = verified.Bool
+	settings.VerifiedAt = verifiedAt.Time
+	return settings, nil
+}
+
+// SetVerified stores the ownership verification state of the package.
+func (s *Store) SetVerified(p packagebug.Package, verified bool) error {
+	query := `
+	UPDATE packages
+	SET package_verified=?, package_verified_at=?
+	WHERE package_tenant=? AND package_path=?`
+	_, err := s.DB.Exec(query, verified, now(), p.Tenant, p.Path())
+	return err
+}
+
+// GetCapabilities get the capabilities of the package. The package
+// capabilities are limited by the deployment capabilities max, it returns max
+// if the package has no capabilities set.
+func (s *Store) GetCapabilities(p packagebug.Package, max packagebug.Capability) (packagebug.Capability, error) {
+	var caps sql.NullInt64
+	query := `
+	SELECT package_capabilities
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&caps)
+	if err != nil {
+		return max, err
+	}
+	if !caps.Valid {
+		return max, nil
+	}
+	return packagebug.Capability(caps.Int64) & max, nil
+}
+
+// GetFork get the stored fork relationship of the package.
+func (s *Store) GetFork(p packagebug.Package) (packagebug.Fork, error) {
+	var f packagebug.Fork
+	var parent, policy sql.NullString
+	var checkedAt sql.NullTime
+
+	query := `
+	SELECT package_fork_parent, package_fork_policy, package_fork_checked_at
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&parent, &policy, &checkedAt)
+	if err != nil {
+		return f, err
+	}
+
+	f.Parent = parent.String
+	f.Policy = packagebug.ForkPolicy(policy.String)
+	f.CheckedAt = checkedAt.Time
+	return f, nil
+}
+
+// SetForkParent stores the parent repository of the package, empty parent
+// means the package is not a fork.
+func (s *Store) SetForkParent(p packagebug.Package, parent string) error {
+	query := `
+	UPDATE packages
+	SET package_fork_parent=?, package_fork_checked_at=?
+	WHERE package_tenant=? AND package_path=?`
+	_, err := s.DB.Exec(query, sql.NullString{String: parent, Valid: parent != ""},
+		now(), p.Tenant, p.Path())
+	return err
+}
+
+// SetPackageRoot links the subpackage to the package of its repository.
+func (s *Store) SetPackageRoot(p, root packagebug.Package) error {
+	query := `
+	UPDATE packages
+	SET package_root_id=?
+	WHERE package_tenant=? AND package_path=?
+	AND package_root_id IS NOT ?`
+	_, err := s.DB.Exec(query, root.Id, p.Tenant, p.Path(), root.Id)
+	return err
+}
+
+// FindPackage returns the package of the path crawled for the tenant, empty
+// for the deployment itself. It returns false if the path is not a package
+// of the tenant. The package that moved is found by its former path too,
+// the package at its current path is returned then.
+func (s *Store) FindPackage(tenant, path string) (packagebug.Package, bool, error) {
+	p, err := packagebug.ParsePath(path)
+	if err != nil {
+		return p, false, err
+	}
+	p.Tenant = tenant
+	query := `
+	SELECT p.package_id, p.package_host, p.package_owner, p.package_repo,
+		coalesce(p.package_subpath, ''), p.package_tenant
+	FROM packages p
+	LEFT JOIN package_aliases a ON a.package_id=p.package_id
+		AND a.alias_path=?
+	WHERE p.package_tenant=? AND (p.package_path=? OR a.alias_path=?)
+	ORDER BY p.package_path=? DESC
+	LIMIT 1`
+	err = s.DB.QueryRow(query, path, tenant, path, path, path).Scan(&p.Id, &p.Host,
+		&p.Owner, &p.Repo, &p.Subpath, &p.Tenant)
+	if err == sql.ErrNoRows {
+		return p, false, nil
+	}
+	if err != nil {
+		return p, false, err
+	}
+	return p, true, nil
+}
+
+// ListPackages returns at most limit packages with the id greater than
+// after ordered by id, e.g. to enqueue every package in batches. The
+// subpackages linked to the package of their repository are left out.
+func (s *Store) ListPackages(after string, limit int) ([]packagebug.Package, error) {
+	query := `
+	SELECT package_id, package_host, package_owner, package_repo,
+		coalesce(package_subpath, ''), package_tenant
+	FROM packages
+	WHERE package_id > CAST(? AS integer) AND package_root_id IS NULL
+	ORDER BY package_id
+	LIMIT ?`
+	rows, err := s.DB.Query(query, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var packages []packagebug.Package
+	for rows.Next() {
+		var p packagebug.Package
+		err = rows.Scan(&p.Id, &p.Host, &p.Owner, &p.Repo, &p.Subpath,
+			&p.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+	return packages, rows.Err()
+}
+
+// MovePackage moves the package to the path of to and keeps its former path
+// as alias, so FindPackage still finds it by the former path. The package is
+// identified by its id, moving it again is a no-op. It returns
+// packagebug.ErrPackageExists if another package of the tenant has the path of
+// to, the other errors are ErrDB.
+func (s *Store) MovePackage(p, to packagebug.Package) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = movePackage(tx, p, to)
+	if errors.Is(err, packagebug.ErrPackageExists) {
+		tx.Rollback()
+		return err
+	}
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// movePackage moves the package within tx.
+func movePackage(tx *sql.Tx, p, to packagebug.Package) error {
+	var exists bool
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM packages
+		WHERE package_tenant=? AND package_path=? AND package_id<>?
+	)`
+	err := tx.QueryRow(query, p.Tenant, to.Path(), p.Id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return packagebug.ErrPackageExists
+	}
+	query = `
+	INSERT INTO package_aliases(alias_path, package_id, created_at)
+	VALUES(?, ?, ?)
+	ON CONFLICT (package_id, alias_path) DO NOTHING`
+	_, err = tx.Exec(query, p.Path(), p.Id, now())
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_path=?, package_host=?, package_owner=?, package_repo=?
+	WHERE package_id=?`
+	_, err = tx.Exec(query, to.Path(), to.Host, to.Owner, to.Repo, p.Id)
+	return err
+}
+
+// DeleteIssues deletes the issues of the package and resets its sync state
+// and stats in a single transaction. The package itself is kept. The errors
+// are ErrDB.
+func (s *Store) DeleteIssues(p packagebug.Package) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = deleteIssues(tx, p)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// deleteIssues deletes the issues of the package within tx.
+func deleteIssues(tx *sql.Tx, p packagebug.Package) error {
+	query := `
+	DELETE FROM issues
+	WHERE package_id=?`
+	_, err := tx.Exec(query, p.Id)
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_etag=NULL, package_since=NULL, package_repo_etag=NULL,
+		package_releases_etag=NULL
+	WHERE package_tenant=? AND package_path=?`
+	_, err = tx.Exec(query, p.Tenant, p.Path())
+	if err != nil {
+		return err
+	}
+	return updateStats(tx, p.Id)
+}
+
+// ResetSync clears the etag and the since of the package, so the next fetch
+// is unconditional and full. The etags of the repository metadata and the
+// releases are cleared too.
+func (s *Store) ResetSync(p packagebug.Package) error {
+	query := `
+	UPDATE packages
+	SET package_etag=NULL, package_since=NULL, package_repo_etag=NULL,
+		package_releases_etag=NULL
+	WHERE package_tenant=? AND package_path=?`
+	_, err := s.DB.Exec(query, p.Tenant, p.Path())
+	return packagebug.DBError(err)
+}
+
+// GetRepoEtag returns the etag of the last repository metadata fetch, empty
+// if never fetched.
+func (s *Store) GetRepoEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+	query := `
+	SELECT package_repo_etag
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+	return etag.String, nil
+}
+
+// SaveRepo stores the repository metadata of the package.
+func (s *Store) SaveRepo(p packagebug.Package, r packagebug.Repo) error {
+	query := `
+	UPDATE packages
+	SET package_stars=?, package_forks=?, package_archived=?,
+		package_default_branch=?, package_description=?,
+		package_pushed_at=?, package_repo_etag=?,
+		package_repo_fetched_at=?
+	WHERE package_tenant=? AND package_path=?`
+	_, err := s.DB.Exec(query, r.Stars, r.Forks, r.Archived, r.DefaultBranch,
+		r.Description, nullTime(r.PushedAt), r.Etag, now(), p.Tenant, p.Path())
+	return packagebug.DBError(err)
+}
+
+// GetRepo returns the stored repository metadata of the package, zero
+// FetchedAt if never fetched.
+func (s *Store) GetRepo(p packagebug.Package) (packagebug.Repo, error) {
+	var r packagebug.Repo
+	var branch, description, etag sql.NullString
+	var pushedAt, fetchedAt sql.NullTime
+	query := `
+	SELECT coalesce(package_stars, 0), coalesce(package_forks, 0),
+		coalesce(package_archived, false), package_default_branch,
+		package_description, package_pushed_at, package_repo_etag,
+		package_repo_fetched_at
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&r.Stars, &r.Forks, &r.Archived,
+		&branch, &description, &pushedAt, &etag, &fetchedAt)
+	if err != nil {
+		return r, packagebug.DBError(err)
+	}
+	r.DefaultBranch = branch.String
+	r.Description = description.String
+	r.PushedAt = pushedAt.Time
+	r.Etag = etag.String
+	r.FetchedAt = fetchedAt.Time
+	return r, nil
+}
+
+// GetStatus returns the sync state of the package: the etag, the since, the
+// last fetch, the gone time and the bug counts of the package stats.
+func (s *Store) GetStatus(p packagebug.Package) (packagebug.Status, error) {
+	st := packagebug.Status{Path: p.Path()}
+	query := `
+	SELECT p.package_etag, p.package_since, p.package_last_fetched_at,
+		p.package_last_error, p.package_gone_at, coalesce(s.open_bugs, 0),
+		coalesce(s.closed_bugs, 0), p.package_empty_since
+	FROM packages p
+	LEFT JOIN package_stats s ON s.package_id=p.package_id
+	WHERE p.package_tenant=? AND p.package_path=?`
+	var etag, lastError sql.NullString
+	var since, lastFetchAt, goneAt, emptySince sql.NullTime
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&etag, &since, &lastFetchAt,
+		&lastError, &goneAt, &st.OpenBugs, &st.ClosedBugs, &emptySince)
+	if err != nil {
+		return st, packagebug.DBError(err)
+	}
+	st.Etag = etag.String
+	st.Since = since.Time
+	st.LastFetchAt = lastFetchAt.Time
+	st.LastError = lastError.String
+	if goneAt.Valid {
+		st.GoneAt = &goneAt.Time
+	}
+	if emptySince.Valid {
+		st.EmptySince = &emptySince.Time
+	}
+	return st, nil
+}
+
+// SaveFetchLog stores the fetch log to the database and sets the last fetch
+// time and error of the package in a single transaction. The package is
+// marked gone since the first fetch that found it gone, and unmarked by the
+// next successful fetch.
+func (s *Store) SaveFetchLog(l packagebug.FetchLog) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveFetchLog(tx, l)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// saveFetchLog stores the fetch log within tx.
+func saveFetchLog(tx *sql.Tx, l packagebug.FetchLog) error {
+	lastError := sql.NullString{String: l.Error, Valid: l.Error != ""}
+	startedAt := l.StartedAt.UTC()
+	query := `
+	INSERT INTO fetch_log(package_id, started_at, duration_ms,
+		issues_upserted, error, status_code, pages, skip_reason)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, l.PackageId, startedAt,
+		l.Duration.Nanoseconds()/int64(time.Millisecond), l.Issues,
+		lastError, sql.NullInt64{Int64: int64(l.StatusCode), Valid: l.StatusCode != 0},
+		l.Pages, sql.NullString{String: l.Skipped, Valid: l.Skipped != ""})
+	if err != nil {
+		return err
+	}
+	query = `
+	UPDATE packages
+	SET package_last_fetched_at=?, package_last_error=?,
+		package_gone_at=CASE
+			WHEN ? THEN coalesce(package_gone_at, ?)
+			WHEN ? IS NULL THEN NULL
+			ELSE package_gone_at
+		END
+	WHERE package_id=?`
+	_, err = tx.Exec(query, startedAt, lastError, l.Gone, startedAt,
+		lastError, l.PackageId)
+	return err
+}
+
+// LoadRateStates loads the persisted rate limit state of the tokens ids, so
+// the worker knows its budget right after boot. Expired states are ignored.
+func (s *Store) LoadRateStates(ids []string) (map[string]packagebug.RateState, error) {
+	query := `
+	SELECT remaining, reset_at
+	FROM rate_limits
+	WHERE token_id=? AND reset_at > ?`
+	states := make(map[string]packagebug.RateState)
+	for _, id := range ids {
+		var state packagebug.RateState
+		err := s.DB.QueryRow(query, id, now()).Scan(&state.Remaining, &state.Reset)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return states, err
+		}
+		state.Known = true
+		states[id] = state
+	}
+	return states, nil
+}
+
+// SaveRateStates persists the rate limit state of the tokens mapped by the
+// token id. The token itself is never stored.
+func (s *Store) SaveRateStates(states map[string]packagebug.RateState) error {
+	query := `
+	INSERT INTO rate_limits(token_id, remaining, reset_at, updated_at)
+	VALUES(?, ?, ?, ?)
+	ON CONFLICT (token_id) DO UPDATE
+	SET remaining=excluded.remaining, reset_at=excluded.reset_at,
+		updated_at=excluded.updated_at`
+	for id, state := range states {
+		_, err := s.DB.Exec(query, id, state.Remaining, state.Reset.UTC(), now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
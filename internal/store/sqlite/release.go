@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// releaseWindows sets the release window of the issues of the package:
+// the latest release published before the issue was opened and the first
+// release published after it was closed. The prereleases are skipped.
+const releaseWindows = `
+	UPDATE issues
+	SET issue_opened_release=(
+			SELECT release_tag
+			FROM releases r
+			WHERE r.package_id=issues.package_id AND NOT r.release_prerelease
+			AND r.release_published_at <= issues.issue_created_at
+			ORDER BY r.release_published_at DESC
+			LIMIT 1),
+		issue_fixed_release=(
+			SELECT release_tag
+			FROM releases r
+			WHERE r.package_id=issues.package_id AND NOT r.release_prerelease
+			AND r.release_published_at >= issues.issue_closed_at
+			ORDER BY r.release_published_at
+			LIMIT 1)
+	WHERE issues.package_id=?`
+
+// GetReleasesEtag returns the etag of the last releases fetch, empty if
+// never fetched.
+func (s *Store) GetReleasesEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+	query := `
+	SELECT package_releases_etag
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+	return etag.String, nil
+}
+
+// SaveReleases replaces the stored releases of the package and updates the
+// release windows of its issues.
+func (s *Store) SaveReleases(p packagebug.Package, r packagebug.Releases) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = saveReleases(tx, p, r)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+func saveReleases(tx *sql.Tx, p packagebug.Package, r packagebug.Releases) error {
+	query := `
+	UPDATE packages
+	SET package_releases_etag=?
+	WHERE package_tenant=? AND package_path=?
+	RETURNING package_id`
+	var id string
+	err := tx.QueryRow(query, r.Etag, p.Tenant, p.Path()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM releases WHERE package_id=?`, id)
+	if err != nil {
+		return err
+	}
+	query = `
+	INSERT INTO releases(package_id, release_tag, release_name,
+		release_prerelease, release_published_at)
+	VALUES(?, ?, ?, ?, ?)
+	ON CONFLICT (package_id, release_tag) DO NOTHING`
+	for _, rel := range r.Releases {
+		_, err = tx.Exec(query, id, rel.Tag, rel.Name, rel.Prerelease,
+			nullTime(rel.PublishedAt))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(releaseWindows, id)
+	return err
+}
@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// updateStats recomputes the bug-resolution aggregates of the package from
+// its stored issues within tx: the number of open and closed bugs and the
+// median time-to-close in seconds, and its score, see updateScore. The
+// removed issues are not counted. SQLite has no percentile, the median is
+// computed from the closed issues.
+func updateStats(tx *sql.Tx, packageId string) error {
+	query := `
+	SELECT issue_state, issue_created_at, issue_closed_at
+	FROM issues
+	WHERE package_id=? AND issue_removed_at IS NULL`
+	rows, err := tx.Query(query, packageId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var open, closed int
+	var durations []time.Duration
+	for rows.Next() {
+		var state sql.NullString
+		var createdAt, closedAt sql.NullTime
+		err = rows.Scan(&state, &createdAt, &closedAt)
+		if err != nil {
+			return err
+		}
+		switch state.String {
+		case "open":
+			open++
+		case "closed":
+			closed++
+			if createdAt.Valid && closedAt.Valid {
+				durations = append(durations, closedAt.Time.Sub(createdAt.Time))
+			}
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO package_stats(package_id, open_bugs, closed_bugs,
+		median_time_to_close, updated_at)
+	VALUES(?, ?, ?, ?, ?)
+	ON CONFLICT (package_id) DO UPDATE
+	SET open_bugs=excluded.open_bugs, closed_bugs=excluded.closed_bugs,
+		median_time_to_close=excluded.median_time_to_close,
+		updated_at=excluded.updated_at`
+	_, err = tx.Exec(query, packageId, open, closed, median(durations), now())
+	if err != nil {
+		return err
+	}
+	// the package without bugs is empty since its first empty save
+	query = `
+	UPDATE packages
+	SET package_empty_since=CASE WHEN ? THEN coalesce(package_empty_since, ?) END
+	WHERE package_id=?`
+	_, err = tx.Exec(query, open+closed == 0, now(), packageId)
+	if err != nil {
+		return err
+	}
+	return updateScore(tx, packageId)
+}
+
+// updateScore recomputes the bug-health score of the package from its
+// stored issues within tx, see packagebug.ComputeScore. The response of the
+// issue is its first comment by someone other than its author.
+func updateScore(tx *sql.Tx, packageId string) error {
+	query := `
+	SELECT i.issue_state, i.issue_created_at, i.issue_closed_at,
+		(SELECT min(c.comment_created_at)
+		FROM issue_comments c
+		WHERE c.package_id=i.package_id AND c.issue_number=i.issue_number
+			AND c.comment_username IS NOT u.user_username)
+	FROM issues i
+	LEFT JOIN users u ON u.user_github_id=i.issue_user_github_id
+	WHERE i.package_id=? AND i.issue_removed_at IS NULL`
+	rows, err := tx.Query(query, packageId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var issues []packagebug.ScoredIssue
+	for rows.Next() {
+		var i packagebug.ScoredIssue
+		var state sql.NullString
+		var createdAt, closedAt, respondedAt sql.NullTime
+		err = rows.Scan(&state, &createdAt, &closedAt, &respondedAt)
+		if err != nil {
+			return err
+		}
+		i.State = state.String
+		i.CreatedAt = createdAt.Time
+		if closedAt.Valid {
+			i.ClosedAt = &closedAt.Time
+		}
+		if respondedAt.Valid {
+			i.RespondedAt = &respondedAt.Time
+		}
+		issues = append(issues, i)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	s := packagebug.ComputeScore(issues, now())
+	var response sql.NullInt64
+	if s.MedianResponse > 0 {
+		response = sql.NullInt64{Int64: int64(s.MedianResponse / time.Second), Valid: true}
+	}
+	query = `
+	INSERT INTO package_scores(package_id, score, weighted_open_bugs,
+		close_rate, median_time_to_response, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?)
+	ON CONFLICT (package_id) DO UPDATE
+	SET score=excluded.score, weighted_open_bugs=excluded.weighted_open_bugs,
+		close_rate=excluded.close_rate,
+		median_time_to_response=excluded.median_time_to_response,
+		updated_at=excluded.updated_at`
+	_, err = tx.Exec(query, packageId, s.Value, s.WeightedOpenBugs,
+		s.CloseRate, response, now())
+	return err
+}
+
+// median returns the median of the durations in seconds, the mean of the
+// two middle durations if their number is even, NULL without durations.
+func median(durations []time.Duration) sql.NullInt64 {
+	if len(durations) == 0 {
+		return sql.NullInt64{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	m := durations[len(durations)/2]
+	if len(durations)%2 == 0 {
+		m = (durations[len(durations)/2-1] + m) / 2
+	}
+	return sql.NullInt64{Int64: int64(m / time.Second), Valid: true}
+}
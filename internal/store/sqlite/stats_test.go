@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		durations []time.Duration
+		expected  int64
+		valid     bool
+	}{
+		{nil, 0, false},
+		{[]time.Duration{time.Hour}, 3600, true},
+		{[]time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour}, 7200, true},
+		{[]time.Duration{4 * time.Second, time.Second, 2 * time.Second, 3 * time.Second}, 2, true},
+	}
+	for _, c := range cases {
+		m := median(c.durations)
+		if m.Valid != c.valid || m.Int64 != c.expected {
+			t.Errorf("expected median %d of %v got: %+v\n", c.expected, c.durations, m)
+		}
+	}
+}
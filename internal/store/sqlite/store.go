@@ -0,0 +1,211 @@
+// Package sqlite stores the packages and their bugs in an embedded SQLite
+// database, for the single-node deployments without Postgres. The schema and
+// the upserts are the ones of the Postgres store.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// Store is the store backed by SQLite, see Open.
+type Store struct {
+	DB *sql.DB
+
+	mu sync.Mutex
+	// locked are the paths of the packages locked by Lock
+	locked map[string]bool
+}
+
+// GetEtag implements packagebug.Store. It returns the complete etag of the
+// last fetch if exists, otherwise empty string.
+func (s *Store) GetEtag(p packagebug.Package) (string, error) {
+	var etag sql.NullString
+
+	query := `
+	SELECT package_etag
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&etag)
+	if err != nil {
+		return "", packagebug.DBError(err)
+	}
+	return etag.String, nil
+}
+
+// GetSince implements packagebug.Store. It returns zero time if the package
+// never synced.
+func (s *Store) GetSince(p packagebug.Package) (time.Time, error) {
+	var since sql.NullTime
+
+	query := `
+	SELECT package_since
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	err := s.DB.QueryRow(query, p.Tenant, p.Path()).Scan(&since)
+	if err != nil {
+		return time.Time{}, packagebug.DBError(err)
+	}
+	return since.Time, nil
+}
+
+// Save implements packagebug.Store. The etag and the issues of the result
+// are stored in a single transaction, any failure rolls back the whole
+// result. The errors are ErrDB.
+func (s *Store) Save(r *packagebug.Result) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	err = save(tx, r)
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// save stores the result within tx.
+func save(tx *sql.Tx, r *packagebug.Result) error {
+	outbox, err := synced(tx, r.Package)
+	if err != nil {
+		return err
+	}
+	query := `
+	UPDATE packages
+	SET package_etag=?, package_synced_at=?
+	WHERE package_tenant=? AND package_path=?`
+	_, err = tx.Exec(query, r.Etag, now(), r.Package.Tenant, r.Package.Path())
+	if err != nil {
+		return err
+	}
+
+	// the next sync only fetch issues updated after the newest one
+	if since := r.Since(); !since.IsZero() {
+		query = `
+		UPDATE packages
+		SET package_since=?
+		WHERE package_tenant=? AND package_path=?
+		AND (package_since IS NULL OR package_since < ?)`
+		_, err = tx.Exec(query, since.UTC(), r.Package.Tenant, r.Package.Path(), since.UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Full {
+		err = markRemoved(tx, r)
+		if err != nil {
+			return err
+		}
+	}
+	return saveIssues(tx, r, outbox)
+}
+
+// synced returns true if the package was saved before within tx, see the
+// Postgres store.
+func synced(tx *sql.Tx, p packagebug.Package) (bool, error) {
+	query := `
+	SELECT package_synced_at IS NOT NULL
+	FROM packages
+	WHERE package_tenant=? AND package_path=?`
+	var ok bool
+	err := tx.QueryRow(query, p.Tenant, p.Path()).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return ok, err
+}
+
+// markRemoved marks the stored open issues of the package missing from the
+// full result as removed within tx, see the postgres store. SQLite has no
+// arrays, the numbers are passed as JSON.
+func markRemoved(tx *sql.Tx, r *packagebug.Result) error {
+	numbers, err := json.Marshal(r.Numbers())
+	if err != nil {
+		return err
+	}
+	since := nullTime(r.Since())
+	query := `
+	UPDATE issues
+	SET issue_removed_at=?
+	WHERE package_id=? AND issue_state='open' AND issue_removed_at IS NULL
+		AND issue_number NOT IN (SELECT value FROM json_each(?))
+		AND (? IS NULL OR issue_updated_at IS NULL
+			OR issue_updated_at <= ?)`
+	_, err = tx.Exec(query, now(), r.Package.Id, string(numbers), since, since)
+	return err
+}
+
+// SaveIssues stores the issues of the result in a single transaction
+// without changing the sync state of the package, e.g. the issues received
+// from the webhooks.
+func (s *Store) SaveIssues(r *packagebug.Result) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return packagebug.DBError(err)
+	}
+	outbox, err := synced(tx, r.Package)
+	if err == nil {
+		err = saveIssues(tx, r, outbox)
+	}
+	if err != nil {
+		tx.Rollback()
+		return packagebug.DBError(err)
+	}
+	return packagebug.DBError(tx.Commit())
+}
+
+// saveIssues stores the issues of the result and updates the package stats
+// within tx. The changes of the issues are written to the outbox if outbox
+// is true.
+func saveIssues(tx *sql.Tx, r *packagebug.Result, outbox bool) error {
+	if len(r.Issues) > 0 {
+		b, err := newBatch(tx, r.Package, outbox)
+		if err != nil {
+			return err
+		}
+		for _, issue := range r.Issues {
+			err = b.saveIssue(issue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return updateStats(tx, r.Package.Id)
+}
+
+// nullTime returns NULL for zero time. The times are stored as text in UTC,
+// so they compare in order.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// nullTimePtr is nullTime of the optional time.
+func nullTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return nullTime(*t)
+}
+
+// now returns the current time as stored, SQLite has no now().
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// Ping checks the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
@@ -0,0 +1,464 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+// testStore returns the migrated store of a temporary database file with the
+// package github.com/pyk/byten.
+func testStore(t testing.TB) (*Store, packagebug.Package) {
+	registered := false
+	for _, d := range sql.Drivers() {
+		registered = registered || d == "sqlite"
+	}
+	if !registered {
+		t.Skip("sqlite driver not registered")
+	}
+	db, err := Open(filepath.Join(t.TempDir(), "packagebug.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	_, err = Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := `
+	INSERT INTO packages(package_path, package_host, package_owner,
+		package_repo)
+	VALUES('github.com/pyk/byten', 'github.com', 'pyk', 'byten')`
+	_, err = db.Exec(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Store{DB: db}
+	p, ok, err := s.FindPackage("", "github.com/pyk/byten")
+	if err != nil || !ok {
+		t.Fatalf("expected package got: %v %v\n", ok, err)
+	}
+	return s, p
+}
+
+func TestStoreSave(t *testing.T) {
+	s, p := testStore(t)
+	created := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	closed := created.Add(2 * time.Hour)
+	r := &packagebug.Result{Package: p, Etag: "etag", Issues: []packagebug.Issue{
+		{Number: 1, Title: "open", State: "open", CreatedAt: created, UpdatedAt: created,
+			Labels:    []packagebug.Label{{Name: "bug"}},
+			Assignees: []packagebug.IssueCreator{{Username: "pyk", GithubId: 1}},
+			Milestone: &packagebug.Milestone{Number: 1, Title: "v1.0", State: "open"}},
+		{Number: 2, Title: "closed", State: "closed", CreatedAt: created, UpdatedAt: closed,
+			ClosedAt: &closed, User: packagebug.IssueCreator{GithubId: 1, Username: "pyk"}},
+	}}
+	// saving twice upserts the issues
+	for i := 0; i < 2; i++ {
+		err := s.Save(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	etag, err := s.GetEtag(p)
+	if err != nil || etag != "etag" {
+		t.Errorf("expected etag got: %q %v\n", etag, err)
+	}
+	since, err := s.GetSince(p)
+	if err != nil || !since.Equal(closed) {
+		t.Errorf("expected since %v got: %v %v\n", closed, since, err)
+	}
+	st, err := s.GetStatus(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.OpenBugs != 1 || st.ClosedBugs != 1 {
+		t.Errorf("expected 1 open and 1 closed bug got: %+v\n", st)
+	}
+	var median int64
+	err = s.DB.QueryRow(`SELECT median_time_to_close FROM package_stats`).Scan(&median)
+	if err != nil || median != 7200 {
+		t.Errorf("expected median 7200 got: %d %v\n", median, err)
+	}
+	var score, weighted float64
+	err = s.DB.QueryRow(`SELECT score, weighted_open_bugs FROM package_scores`).Scan(&score, &weighted)
+	if err != nil || score <= 0 || weighted < 1 {
+		t.Errorf("expected the score of the package got: %v %v %v\n", score, weighted, err)
+	}
+	var assignee, milestone string
+	query := `
+	SELECT a.assignee_username, m.milestone_title
+	FROM issues i
+	JOIN issue_assignees a ON a.package_id=i.package_id
+		AND a.issue_number=i.issue_number
+	JOIN milestones m ON m.package_id=i.package_id
+		AND m.milestone_number=i.issue_milestone_number`
+	err = s.DB.QueryRow(query).Scan(&assignee, &milestone)
+	if err != nil || assignee != "pyk" || milestone != "v1.0" {
+		t.Errorf("expected assignee and milestone got: %q %q %v\n", assignee, milestone, err)
+	}
+}
+
+func TestStoreSaveFull(t *testing.T) {
+	s, p := testStore(t)
+	updated := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &packagebug.Result{Package: p, Issues: []packagebug.Issue{
+		{Number: 1, Title: "deleted", State: "open", UpdatedAt: updated},
+		{Number: 2, Title: "kept", State: "open", UpdatedAt: updated},
+	}}
+	err := s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the issue 1 is missing from the full sync
+	r = &packagebug.Result{Package: p, Full: true, Issues: r.Issues[1:]}
+	err = s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := s.GetStatus(p)
+	if err != nil || st.OpenBugs != 1 {
+		t.Errorf("expected 1 open bug got: %+v %v\n", st, err)
+	}
+	var removed int
+	query := `SELECT count(*) FROM issues WHERE issue_removed_at IS NOT NULL`
+	err = s.DB.QueryRow(query).Scan(&removed)
+	if err != nil || removed != 1 {
+		t.Errorf("expected 1 removed issue got: %d %v\n", removed, err)
+	}
+
+	// the issue shows up again
+	r.Issues = []packagebug.Issue{{Number: 1, Title: "reopened", State: "open",
+		UpdatedAt: updated.Add(time.Hour)}}
+	r.Full = false
+	err = s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.DB.QueryRow(query).Scan(&removed)
+	if err != nil || removed != 0 {
+		t.Errorf("expected no removed issue got: %d %v\n", removed, err)
+	}
+}
+
+func TestStoreFetchLogGone(t *testing.T) {
+	s, p := testStore(t)
+	started := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := []packagebug.FetchLog{
+		{PackageId: p.Id, StartedAt: started, Error: "not found", Gone: true},
+		{PackageId: p.Id, StartedAt: started.Add(time.Hour), Error: "not found", Gone: true},
+	}
+	for _, l := range logs {
+		err := s.SaveFetchLog(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := s.GetStatus(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.GoneAt == nil || !st.GoneAt.Equal(started) || st.LastError != "not found" {
+		t.Errorf("expected gone since the first fetch got: %+v\n", st)
+	}
+
+	err = s.SaveFetchLog(packagebug.FetchLog{PackageId: p.Id, StartedAt: started.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err = s.GetStatus(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.GoneAt != nil || st.LastError != "" {
+		t.Errorf("expected the successful fetch to unmark gone got: %+v\n", st)
+	}
+}
+
+func TestStoreMovePackage(t *testing.T) {
+	s, p := testStore(t)
+	to := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "bytes"}
+	err := s.MovePackage(p, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"github.com/pyk/byten", "github.com/pyk/bytes"} {
+		found, ok, err := s.FindPackage("", path)
+		if err != nil || !ok {
+			t.Fatalf("expected package of %s got: %v %v\n", path, ok, err)
+		}
+		if found.Id != p.Id || found.Path() != to.Path() {
+			t.Errorf("expected moved package of %s got: %+v\n", path, found)
+		}
+	}
+
+	// the package can't move to the path of another package
+	query := `
+	INSERT INTO packages(package_path, package_host, package_owner,
+		package_repo)
+	VALUES('github.com/pyk/other', 'github.com', 'pyk', 'other')`
+	_, err = s.DB.Exec(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, _, _ := s.FindPackage("", to.Path())
+	other := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "other"}
+	err = s.MovePackage(moved, other)
+	if !errors.Is(err, packagebug.ErrPackageExists) {
+		t.Errorf("expected package exists got: %v\n", err)
+	}
+}
+
+func TestStoreGetSettingsQuery(t *testing.T) {
+	s, p := testStore(t)
+	query := `
+	UPDATE packages
+	SET package_issue_state='open', package_issue_labels='bug, defect'
+	WHERE package_path=?`
+	_, err := s.DB.Exec(query, p.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := s.GetSettings(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Query.State != "open" || len(settings.Query.Labels) != 2 {
+		t.Errorf("expected the query of the package got: %+v\n", settings.Query)
+	}
+	if settings.Query.Sort != "" {
+		t.Errorf("expected the default sort got: %q\n", settings.Query.Sort)
+	}
+}
+
+func TestStoreListPackages(t *testing.T) {
+	s, p := testStore(t)
+	query := `
+	INSERT INTO packages(package_path, package_host, package_owner,
+		package_repo, package_subpath, package_root_id)
+	VALUES('github.com/pyk/byten/sub', 'github.com', 'pyk', 'byten', 'sub', ?)`
+	_, err := s.DB.Exec(query, p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packages, err := s.ListPackages("0", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 1 || packages[0].Id != p.Id {
+		t.Errorf("expected the package without its subpackage got: %+v\n", packages)
+	}
+	packages, err = s.ListPackages(p.Id, 10)
+	if err != nil || len(packages) != 0 {
+		t.Errorf("expected no package after the last got: %+v %v\n", packages, err)
+	}
+}
+
+func TestStoreOutbox(t *testing.T) {
+	s, p := testStore(t)
+	created := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	closed := created.Add(time.Hour)
+	// the issues of the first sync are not new
+	r := &packagebug.Result{Package: p, Issues: []packagebug.Issue{
+		{Number: 1, Title: "open", State: "open", CreatedAt: created, UpdatedAt: created},
+	}}
+	err := s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Issues = []packagebug.Issue{
+		{Number: 1, Title: "open", State: "closed", CreatedAt: created, UpdatedAt: closed,
+			ClosedAt: &closed},
+		{Number: 2, Title: "new", State: "open", CreatedAt: closed, UpdatedAt: closed},
+	}
+	err = s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	n, err := s.PublishOutbox(context.Background(), 10, func(e packagebug.OutboxEvent) error {
+		events = append(events, fmt.Sprintf("%s:%d", e.Event, e.IssueNumber))
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 events published got: %d %v\n", n, err)
+	}
+	if events[0] != "issue_closed:1" || events[1] != "issue_created:2" {
+		t.Errorf("unexpected events: %v\n", events)
+	}
+	n, err = s.PublishOutbox(context.Background(), 10, func(e packagebug.OutboxEvent) error {
+		return nil
+	})
+	if err != nil || n != 0 {
+		t.Errorf("expected the events published once got: %d %v\n", n, err)
+	}
+}
+
+func TestStoreSaveReleases(t *testing.T) {
+	s, p := testStore(t)
+	day := func(d int) time.Time { return time.Date(2016, 1, d, 0, 0, 0, 0, time.UTC) }
+	closed := day(15)
+	r := &packagebug.Result{Package: p, Issues: []packagebug.Issue{
+		{Number: 1, Title: "fixed", State: "closed", CreatedAt: day(5), UpdatedAt: closed, ClosedAt: &closed},
+		{Number: 2, Title: "open", State: "open", CreatedAt: day(12), UpdatedAt: day(12)},
+	}}
+	err := s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SaveReleases(p, packagebug.Releases{Etag: `"l1"`, Releases: []packagebug.Release{
+		{Tag: "v1.0.0", PublishedAt: day(1)},
+		{Tag: "v1.1.0", PublishedAt: day(10)},
+		{Tag: "v1.2.0-rc1", Prerelease: true, PublishedAt: day(16)},
+		{Tag: "v1.2.0", PublishedAt: day(20)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := s.GetReleasesEtag(p)
+	if err != nil || etag != `"l1"` {
+		t.Errorf("expected the releases etag got: %q %v\n", etag, err)
+	}
+	windows := map[int][2]sql.NullString{}
+	rows, err := s.DB.Query(`SELECT issue_number, issue_opened_release, issue_fixed_release FROM issues`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int
+		var w [2]sql.NullString
+		err = rows.Scan(&n, &w[0], &w[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		windows[n] = w
+	}
+	if w := windows[1]; w[0].String != "v1.0.0" || w[1].String != "v1.2.0" {
+		t.Errorf("expected the fixed issue opened against v1.0.0 and fixed in v1.2.0 got: %v\n", w)
+	}
+	if w := windows[2]; w[0].String != "v1.1.0" || w[1].Valid {
+		t.Errorf("expected the open issue opened against v1.1.0 got: %v\n", w)
+	}
+}
+
+func BenchmarkStoreSave(b *testing.B) {
+	s, p := testStore(b)
+	r := &packagebug.Result{Package: p, Issues: fake.Issues(1000)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := s.Save(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStoreTenant(t *testing.T) {
+	s, p := testStore(t)
+	// the path of the deployment is crawled for acme too
+	query := `
+	INSERT INTO packages(package_path, package_host, package_owner,
+		package_repo, package_tenant)
+	VALUES('github.com/pyk/byten', 'github.com', 'pyk', 'byten', 'acme')`
+	_, err := s.DB.Exec(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, ok, err := s.FindPackage("acme", p.Path())
+	if err != nil || !ok || found.Tenant != "acme" || found.Id == p.Id {
+		t.Fatalf("expected package of acme got: %+v %v\n", found, err)
+	}
+	_, ok, err = s.FindPackage("other", p.Path())
+	if err != nil || ok {
+		t.Errorf("expected no package of other got: %v %v\n", ok, err)
+	}
+
+	// the issues carry the tenant of their package
+	r := &packagebug.Result{Package: found, Issues: []packagebug.Issue{
+		{Number: 1, Title: "crash", State: "open"},
+	}}
+	err = s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tenant string
+	err = s.DB.QueryRow(`SELECT issue_tenant FROM issues`).Scan(&tenant)
+	if err != nil || tenant != "acme" {
+		t.Errorf("expected issue of acme got: %q %v\n", tenant, err)
+	}
+}
+
+func TestStoreEmptySince(t *testing.T) {
+	s, p := testStore(t)
+	// the empty saves keep the time of the first one
+	var first time.Time
+	for i := 0; i < 2; i++ {
+		err := s.Save(&packagebug.Result{Package: p, Etag: "empty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := s.GetStatus(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.EmptySince == nil || (i > 0 && !st.EmptySince.Equal(first)) {
+			t.Fatalf("expected empty since the first save got: %v\n", st.EmptySince)
+		}
+		first = *st.EmptySince
+	}
+
+	r := &packagebug.Result{Package: p, Issues: []packagebug.Issue{
+		{Number: 1, Title: "crash", State: "open"},
+	}}
+	err := s.Save(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := s.GetStatus(p)
+	if err != nil || st.EmptySince != nil {
+		t.Errorf("expected the package with a bug not empty got: %v %v\n", st.EmptySince, err)
+	}
+}
+
+func TestStoreTenantAliases(t *testing.T) {
+	s, p := testStore(t)
+	query := `
+	INSERT INTO packages(package_path, package_host, package_owner,
+		package_repo, package_tenant)
+	VALUES('github.com/pyk/byten', 'github.com', 'pyk', 'byten', 'acme')`
+	_, err := s.DB.Exec(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, _, err := s.FindPackage("acme", p.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the former path is the alias of the package of both tenants
+	to := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "bytes"}
+	for _, moved := range []packagebug.Package{p, acme} {
+		err = s.MovePackage(moved, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []packagebug.Package{p, acme} {
+		found, ok, err := s.FindPackage(expected.Tenant, "github.com/pyk/byten")
+		if err != nil || !ok || found.Id != expected.Id {
+			t.Errorf("expected moved package of %q got: %+v %v\n", expected.Tenant, found, err)
+		}
+	}
+}
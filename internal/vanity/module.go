@@ -0,0 +1,149 @@
+package vanity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// DefaultProxy is the Go module proxy of the resolver.
+const DefaultProxy = "https://proxy.golang.org"
+
+// origin is the origin of the module version reported by the proxy.
+type origin struct {
+	VCS    string
+	URL    string
+	Subdir string
+}
+
+// ResolveModule returns the repositories of the module path, e.g.
+// golang.org/x/net or gopkg.in/yaml.v3, with the directory of the module in
+// the repository as subpath: the origin of the latest version reported by
+// Proxy, otherwise the repositories of the meta tags of the module path, see
+// Resolve. The major version suffix, e.g. /v2, is not part of the subpath.
+// It returns empty slice if the module can't be resolved.
+func (r *Resolver) ResolveModule(ctx context.Context, module string) ([]packagebug.Package, error) {
+	module = strings.Trim(module, "/")
+	if host, _, _ := strings.Cut(module, "/"); !strings.Contains(host, ".") {
+		return nil, fmt.Errorf("invalid module path %q", module)
+	}
+	// the failure of the proxy is only returned if the meta tags fail too
+	var proxyErr error
+	if r.Proxy != "" {
+		repo, ok, err := r.proxyOrigin(ctx, module)
+		if ok {
+			return []packagebug.Package{repo}, nil
+		}
+		proxyErr = err
+	}
+	repos, err := r.resolvePath(ctx, module)
+	if err != nil {
+		return nil, errors.Join(proxyErr, err)
+	}
+	var packages []packagebug.Package
+	for _, repo := range repos {
+		repo.Subpath = TrimMajor(repo.Subpath)
+		packages = append(packages, repo)
+	}
+	return packages, nil
+}
+
+// proxyOrigin returns the repository of the latest version of the module
+// from its origin reported by the proxy. It returns false if the proxy
+// doesn't know the module or its origin, or the origin has no owner/repo
+// path, e.g. go.googlesource.com/net.
+func (r *Resolver) proxyOrigin(ctx context.Context, module string) (packagebug.Package, bool, error) {
+	key := "proxy:" + module
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Since(e.resolvedAt) < TTL {
+		return firstPackage(e.packages)
+	}
+
+	urls := fmt.Sprintf("%s/%s/@latest", strings.TrimSuffix(r.Proxy, "/"), EscapePath(module))
+	req, err := http.NewRequestWithContext(ctx, "GET", urls, nil)
+	if err != nil {
+		return packagebug.Package{}, false, err
+	}
+	req.Header.Add("User-Agent", "pyk")
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return packagebug.Package{}, false, err
+	}
+	defer resp.Body.Close()
+	var packages []packagebug.Package
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var info struct {
+			Origin *origin
+		}
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		if err != nil {
+			return packagebug.Package{}, false, err
+		}
+		if info.Origin != nil && info.Origin.VCS == "git" {
+			repo, err := RepoPackage(info.Origin.URL)
+			if err == nil {
+				repo.Subpath = TrimMajor(strings.Trim(info.Origin.Subdir, "/"))
+				packages = append(packages, repo)
+			}
+		}
+	// the proxy answers the unknown modules with 404 or 410
+	case http.StatusNotFound, http.StatusGone:
+	default:
+		return packagebug.Package{}, false, packagebug.NewStatusError(resp)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = entry{packages: packages, resolvedAt: time.Now()}
+	r.mu.Unlock()
+	return firstPackage(packages)
+}
+
+// firstPackage returns the first package if any.
+func firstPackage(packages []packagebug.Package) (packagebug.Package, bool, error) {
+	if len(packages) == 0 {
+		return packagebug.Package{}, false, nil
+	}
+	return packages[0], true, nil
+}
+
+// EscapePath escapes the module path for the proxy: the upper-case letters
+// are the exclamation mark followed by the lower-case letter, e.g.
+// github.com/Azure/azure-sdk-for-go is github.com/!azure/azure-sdk-for-go.
+func EscapePath(module string) string {
+	var b strings.Builder
+	for _, c := range module {
+		if unicode.IsUpper(c) {
+			b.WriteByte('!')
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// TrimMajor trims the major version suffix of the module path, e.g.
+// github.com/pyk/byten/v2 is github.com/pyk/byten. The suffix is in the
+// same repository as the module.
+func TrimMajor(module string) string {
+	dir, last := path.Split(module)
+	if len(last) < 2 || last[0] != 'v' || last == "v0" || last == "v1" {
+		return module
+	}
+	for _, c := range last[1:] {
+		if c < '0' || c > '9' {
+			return module
+		}
+	}
+	return strings.TrimSuffix(dir, "/")
+}
@@ -0,0 +1,80 @@
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResolveModule(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Path)
+		switch r.URL.Path {
+		case "/github.com/!azure/azure-sdk-for-go/sdk/storage/azblob/v2/@latest":
+			fmt.Fprint(w, `{"Version":"v2.0.0","Origin":{"VCS":"git",
+				"URL":"https://github.com/Azure/azure-sdk-for-go",
+				"Subdir":"sdk/storage/azblob"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer proxy.Close()
+	meta := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, pageTest, r.Host)
+	}))
+	defer meta.Close()
+
+	r := New()
+	r.Scheme = "http"
+	r.Proxy = proxy.URL
+	ctx := context.Background()
+
+	// the origin of the proxy
+	packages, err := r.ResolveModule(ctx, "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 1 || packages[0].Path() != "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob" {
+		t.Fatalf("unexpected packages: %+v\n", packages)
+	}
+
+	// the meta tags of the module unknown to the proxy
+	u, _ := url.Parse(meta.URL)
+	packages, err = r.ResolveModule(ctx, u.Host+"/x/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packages) != 1 || packages[0].Path() != "github.com/golang/net" {
+		t.Fatalf("unexpected packages: %+v\n", packages)
+	}
+	if len(proxied) != 2 || !strings.HasSuffix(proxied[1], "/x/net/@latest") {
+		t.Errorf("unexpected proxy requests: %v\n", proxied)
+	}
+
+	_, err = r.ResolveModule(ctx, "net")
+	if err == nil {
+		t.Errorf("expected error of the module path without host\n")
+	}
+}
+
+func TestTrimMajor(t *testing.T) {
+	cases := map[string]string{
+		"github.com/pyk/byten/v2":  "github.com/pyk/byten",
+		"github.com/pyk/byten/v1":  "github.com/pyk/byten/v1",
+		"github.com/pyk/byten/vx":  "github.com/pyk/byten/vx",
+		"gopkg.in/yaml.v3":         "gopkg.in/yaml.v3",
+		"v3":                       "",
+		"service/sqs":              "service/sqs",
+		"github.com/pyk/byten/v10": "github.com/pyk/byten",
+	}
+	for module, expected := range cases {
+		if got := TrimMajor(module); got != expected {
+			t.Errorf("%s: expected %q got: %q\n", module, expected, got)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package vanity resolves the vanity import paths, e.g. golang.org/x/net, to
+// the repository they are hosted in via the go-import and go-source meta tags.
+package vanity
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// TTL is how long the resolved repositories are reused.
+const TTL = 24 * time.Hour
+
+// MetaImport represents the go-import or go-source meta tag.
+type MetaImport struct {
+	Prefix string
+	// VCS is the version control system of go-import, empty for go-source
+	VCS string
+	// Url is the repository root of go-import or the home of go-source
+	Url string
+}
+
+// Resolver resolves the import path by fetching the page of the path with
+// ?go-get=1 like the go command does.
+type Resolver struct {
+	HTTP *http.Client
+	// Scheme of the vanity hosts, https if empty
+	Scheme string
+	// Proxy is the Go module proxy asked for the origin of the modules
+	// first, see ResolveModule. The proxy is skipped if empty.
+	Proxy string
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+type entry struct {
+	packages   []packagebug.Package
+	resolvedAt time.Time
+}
+
+// New creates a resolver.
+func New() *Resolver {
+	return &Resolver{
+		HTTP:   &http.Client{Timeout: 10 * time.Second},
+		Scheme: "https",
+		Proxy:  DefaultProxy,
+		cache:  make(map[string]entry),
+	}
+}
+
+// Resolve returns the repositories of the package from its go-import meta
+// tag followed by its go-source meta tag, e.g. golang.org/x/net resolves to
+// github.com/golang/net of its go-source. The repository without owner/repo
+// path, e.g. go.googlesource.com/net, is skipped. It returns empty slice if the page has
+// no matching meta tags.
+func (r *Resolver) Resolve(ctx context.Context, p packagebug.Package) ([]packagebug.Package, error) {
+	repos, err := r.resolvePath(ctx, strings.TrimSuffix(p.Path(), "/"))
+	if err != nil {
+		return nil, err
+	}
+	packages := make([]packagebug.Package, len(repos))
+	for i, repo := range repos {
+		repo.Subpath = ""
+		packages[i] = repo
+	}
+	return packages, nil
+}
+
+// resolvePath resolves the import path by its meta tags. The subpath of
+// the repositories is the rest of path after the import prefix.
+func (r *Resolver) resolvePath(ctx context.Context, path string) ([]packagebug.Package, error) {
+	r.mu.Lock()
+	e, ok := r.cache[path]
+	r.mu.Unlock()
+	if ok && time.Since(e.resolvedAt) < TTL {
+		return e.packages, nil
+	}
+
+	urls := fmt.Sprintf("%s://%s?go-get=1", r.Scheme, path)
+	req, err := http.NewRequest("GET", urls, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("User-Agent", "pyk")
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, packagebug.NewStatusError(resp)
+	}
+	metas, err := ParseMeta(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []packagebug.Package
+	for _, m := range metas {
+		if !matchPrefix(path, m.Prefix) || (m.VCS != "" && m.VCS != "git") {
+			continue
+		}
+		repo, err := RepoPackage(m.Url)
+		if err != nil {
+			continue
+		}
+		repo.Subpath = strings.Trim(strings.TrimPrefix(path, m.Prefix), "/")
+		packages = append(packages, repo)
+	}
+
+	r.mu.Lock()
+	r.cache[path] = entry{packages: packages, resolvedAt: time.Now()}
+	r.mu.Unlock()
+	return packages, nil
+}
+
+// matchPrefix returns true if the import prefix contains path.
+func matchPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// RepoPackage returns the package of the repository url, e.g.
+// https://github.com/golang/net.git is github.com/golang/net.
+func RepoPackage(rawurl string) (packagebug.Package, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return packagebug.Package{}, err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 2 {
+		return packagebug.Package{}, fmt.Errorf("invalid repository url %q", rawurl)
+	}
+	return packagebug.Package{
+		Host:  u.Host,
+		Owner: parts[0],
+		Repo:  strings.TrimSuffix(parts[1], ".git"),
+	}, nil
+}
+
+// ParseMeta parses the go-import and go-source meta tags of the html page in
+// the order they appear. It stops at the end of the head.
+func ParseMeta(r io.Reader) ([]MetaImport, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var metas []MetaImport
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return metas, nil
+		}
+		if err != nil {
+			// the rest of the page is not html we understand
+			return metas, nil
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return metas, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return metas, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+		name, content := attr(e, "name"), strings.Fields(attr(e, "content"))
+		switch {
+		case name == "go-import" && len(content) == 3:
+			metas = append(metas, MetaImport{Prefix: content[0], VCS: content[1], Url: content[2]})
+		case name == "go-source" && len(content) >= 2:
+			metas = append(metas, MetaImport{Prefix: content[0], Url: content[1]})
+		}
+	}
+}
+
+// attr returns the value of the attribute name of the element.
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+const pageTest = `<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="go-import" content="%[1]s/x/net git https://go.googlesource.com/net">
+<meta name="go-source" content="%[1]s/x/net https://github.com/golang/net/ https://github.com/golang/net/tree/master{/dir} https://github.com/golang/net/blob/master{/dir}/{file}#L{line}">
+</head>
+<body>
+<meta name="go-import" content="%[1]s/x/net git https://example.com/ignored">
+</body>
+</html>`
+
+func TestParseMeta(t *testing.T) {
+	metas, err := ParseMeta(strings.NewReader(fmt.Sprintf(pageTest, "golang.org")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metas) != 2 {
+		t.Fatalf("expected 2 meta tags got: %d\n", len(metas))
+	}
+	if metas[0].VCS != "git" || metas[0].Url != "https://go.googlesource.com/net" {
+		t.Errorf("unexpected go-import: %+v\n", metas[0])
+	}
+	if metas[1].VCS != "" || metas[1].Url != "https://github.com/golang/net/" {
+		t.Errorf("unexpected go-source: %+v\n", metas[1])
+	}
+}
+
+func TestRepoPackage(t *testing.T) {
+	p, err := RepoPackage("https://github.com/go-yaml/yaml.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Path() != "github.com/go-yaml/yaml" {
+		t.Errorf("expected github.com/go-yaml/yaml got: %s\n", p.Path())
+	}
+	_, err = RepoPackage("https://example.com/")
+	if err == nil {
+		t.Error("expected error for url without repository")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("go-get") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, pageTest, r.Host)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	r := New()
+	r.Scheme = "http"
+	p := packagebug.Package{Host: u.Host, Owner: "x", Repo: "net"}
+	for i := 0; i < 2; i++ {
+		packages, err := r.Resolve(context.Background(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// go.googlesource.com/net has no owner, only go-source is used
+		if len(packages) != 1 || packages[0].Path() != "github.com/golang/net" {
+			t.Fatalf("unexpected packages: %+v\n", packages)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected cached resolution got: %d requests\n", requests)
+	}
+}
@@ -0,0 +1,161 @@
+// Package webhook receives the GitHub issue webhooks and stores the issues
+// right away, without waiting for the next poll of the package.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+// MaxPayload is the maximum size of the webhook payload, GitHub caps the
+// payloads at 25MB.
+const MaxPayload = 25 << 20
+
+// Store persists the issues received from the webhooks.
+type Store interface {
+	// FindPackage returns the package of the path crawled for the tenant,
+	// false if not a package.
+	FindPackage(tenant, path string) (packagebug.Package, bool, error)
+	// GetSettings get the custom settings of the package.
+	GetSettings(p packagebug.Package) (packagebug.Settings, error)
+	// GetCapabilities get the capabilities of the package limited by max.
+	GetCapabilities(p packagebug.Package, max packagebug.Capability) (packagebug.Capability, error)
+	// SaveIssues stores the issues without changing the sync state, so the
+	// next poll still fetches the issues missed by the webhooks.
+	SaveIssues(r *packagebug.Result) error
+}
+
+// Handler handles the GitHub webhooks signed with Secret.
+type Handler struct {
+	Secret []byte
+	Store  Store
+	// Capabilities is the maximum capabilities of every package
+	Capabilities packagebug.Capability
+	// Query is the issue list query of packages without their own, its
+	// labels decide which issues are bugs
+	Query packagebug.Query
+	// Severity classifies the issues, packagebug.DefaultSeverityRules if
+	// nil
+	Severity packagebug.SeverityRules
+}
+
+// event is the payload of the issues webhook.
+type event struct {
+	Action     string           `json:"action"`
+	Issue      packagebug.Issue `json:"issue"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// Verify returns true if signature, the X-Hub-Signature-256 header, is the
+// HMAC-SHA256 of the payload with secret.
+func Verify(secret, payload []byte, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, MaxPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !Verify(h.Secret, payload, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	logger := slog.With("delivery_id", r.Header.Get("X-GitHub-Delivery"))
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		fmt.Fprintln(w, "pong")
+		return
+	case "issues":
+	default:
+		// only the issues events are subscribed, ignore the rest
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var e event
+	err = json.Unmarshal(payload, &e)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger = logger.With("package_path", "github.com/"+e.Repository.FullName,
+		"action", e.Action, "issue_number", e.Issue.Number)
+	err = h.handleIssue(e)
+	if err != nil {
+		logger.Error("webhook failed", "error", err)
+		http.Error(w, "store failed", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("webhook processed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIssue stores the issue of the event if it is a bug of a package.
+func (h *Handler) handleIssue(e event) error {
+	// the deleted and transferred issues are pruned by the poll
+	if e.Action == "deleted" || e.Action == "transferred" {
+		return nil
+	}
+	// the webhooks are those of the deployment itself
+	p, ok, err := h.Store.FindPackage("", "github.com/"+e.Repository.FullName)
+	if err != nil || !ok {
+		return err
+	}
+	s, err := h.Store.GetSettings(p)
+	if err != nil {
+		return err
+	}
+	// the custom labels are only honored if the ownership was verified by
+	// the worker
+	p.BugLabels = s.BugLabels
+	p.Query = h.Query.Override(s.Query)
+	if s.Verified {
+		p.Labels = s.Labels
+	}
+	if !github.IsBug(p, e.Issue) {
+		return nil
+	}
+	p.Capabilities, err = h.Store.GetCapabilities(p, h.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	r := &packagebug.Result{Package: p, Issues: []packagebug.Issue{e.Issue}}
+	r.Project(p.Capabilities)
+	r.DetectLanguage()
+	rules := h.Severity
+	if rules == nil {
+		rules = packagebug.DefaultSeverityRules
+	}
+	r.ClassifySeverity(rules)
+	return h.Store.SaveIssues(r)
+}
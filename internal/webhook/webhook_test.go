@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+const payloadTest = `{
+	"action": "opened",
+	"issue": {
+		"number": 7,
+		"title": "panic on empty input",
+		"state": "open",
+		"body": "the program crashes",
+		"labels": [{"name": "Bug"}]
+	},
+	"repository": {"full_name": "pyk/byten"}
+}`
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func request(payload, signature, event string) *http.Request {
+	req := httptest.NewRequest("POST", "/webhook", strings.NewReader(payload))
+	req.Header.Set("X-Hub-Signature-256", signature)
+	req.Header.Set("X-GitHub-Event", event)
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	if !Verify([]byte("secret"), []byte("payload"), sign("secret", "payload")) {
+		t.Error("expected valid signature")
+	}
+	if Verify([]byte("secret"), []byte("payload"), sign("other", "payload")) {
+		t.Error("expected invalid signature of other secret")
+	}
+	if Verify([]byte("secret"), []byte("payload"), "sha1=abc") {
+		t.Error("expected invalid signature without sha256 prefix")
+	}
+}
+
+func TestHandlerIssue(t *testing.T) {
+	store := fake.NewStore()
+	p := fake.Package
+	p.Id = "1"
+	store.AddPackage(p)
+	h := &Handler{Secret: []byte("secret"), Store: store,
+		Capabilities: packagebug.CapBodies}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(payloadTest, sign("secret", payloadTest), "issues"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 got: %d %s\n", w.Code, w.Body)
+	}
+	saved := store.Saved()
+	if len(saved) != 1 || len(saved[0].Issues) != 1 {
+		t.Fatalf("expected the issue saved got: %+v\n", saved)
+	}
+	if saved[0].Package.Id != "1" || saved[0].Issues[0].Number != 7 {
+		t.Errorf("unexpected result: %+v\n", saved[0])
+	}
+	if saved[0].Issues[0].Language != "en" {
+		t.Errorf("expected language detected got: %s\n", saved[0].Issues[0].Language)
+	}
+	// the sync state is left to the poll
+	if etag, _ := store.GetEtag(p); etag != "" {
+		t.Errorf("expected etag unchanged got: %s\n", etag)
+	}
+}
+
+func TestHandlerInvalidSignature(t *testing.T) {
+	store := fake.NewStore()
+	h := &Handler{Secret: []byte("secret"), Store: store}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(payloadTest, sign("other", payloadTest), "issues"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 got: %d\n", w.Code)
+	}
+	if len(store.Saved()) != 0 {
+		t.Error("expected nothing saved")
+	}
+}
+
+func TestHandlerNotBug(t *testing.T) {
+	store := fake.NewStore()
+	store.AddPackage(fake.Package)
+	h := &Handler{Secret: []byte("secret"), Store: store}
+	payload := strings.Replace(payloadTest, `"Bug"`, `"question"`, 1)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request(payload, sign("secret", payload), "issues"))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204 got: %d\n", w.Code)
+	}
+	if len(store.Saved()) != 0 {
+		t.Error("expected issue without bug label ignored")
+	}
+}
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// AckFlushTimeout bounds the delete of the last batch once the acker is
+// done.
+const AckFlushTimeout = 10 * time.Second
+
+// Acker collects the acknowledged messages and deletes them from the queue
+// in batches of queue.MaxBatch, or after interval if the batch is not full.
+type Acker struct {
+	Queue    queue.Queue
+	Interval time.Duration
+
+	acks chan *queue.Message
+}
+
+// NewAcker creates an acker of the queue.
+func NewAcker(q queue.Queue, interval time.Duration) *Acker {
+	return &Acker{
+		Queue:    q,
+		Interval: interval,
+		acks:     make(chan *queue.Message, queue.MaxBatch),
+	}
+}
+
+// Ack schedules the message to be deleted.
+func (a *Acker) Ack(m *queue.Message) {
+	a.acks <- m
+}
+
+// Run deletes the acknowledged messages until ctx is done. The messages
+// acknowledged by then are deleted within AckFlushTimeout after, so the
+// messages must not be acknowledged once Run returns: the worker keeps it
+// running until its processes are done, see Worker.Run.
+func (a *Acker) Run(ctx context.Context) {
+	batch := make([]*queue.Message, 0, queue.MaxBatch)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		err := a.Queue.DeleteBatch(ctx, batch)
+		if err != nil && ctx.Err() != nil {
+			// the tick raced the shutdown, the batch is deleted after
+			return
+		}
+		if err != nil {
+			slog.Error("delete message batch failed", "error", err,
+				"messages", len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-a.acks:
+			batch = append(batch, m)
+			if len(batch) == queue.MaxBatch {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// the deletes of ctx would fail right away
+			fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), AckFlushTimeout)
+			defer cancel()
+			for len(a.acks) > 0 {
+				batch = append(batch, <-a.acks)
+				if len(batch) == queue.MaxBatch {
+					flush(fctx)
+				}
+			}
+			flush(fctx)
+			return
+		}
+	}
+}
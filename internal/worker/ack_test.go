@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// batchQueue records the batches of deleted messages.
+type batchQueue struct {
+	queue.Queue
+	mu      sync.Mutex
+	batches [][]*queue.Message
+}
+
+func (q *batchQueue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.batches = append(q.batches, append([]*queue.Message(nil), msgs...))
+	return nil
+}
+
+func TestAckerBatch(t *testing.T) {
+	q := &batchQueue{}
+	a := NewAcker(q, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < queue.MaxBatch+2; i++ {
+		a.Ack(&queue.Message{Id: "id"})
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.batches) != 2 {
+		t.Fatalf("expected 2 batches got: %d\n", len(q.batches))
+	}
+	if len(q.batches[0]) != queue.MaxBatch || len(q.batches[1]) != 2 {
+		t.Errorf("expected batch size %d and 2 got: %d and %d\n", queue.MaxBatch,
+			len(q.batches[0]), len(q.batches[1]))
+	}
+}
+
+func TestAckerDeletesOnDone(t *testing.T) {
+	q := fake.NewQueue("1,github.com,pyk,byten", "2,github.com,pyk,other")
+	msgs, err := q.Receive(context.Background(), queue.MaxBatch, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAcker(q, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	for _, m := range msgs {
+		a.Ack(m)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	if len(q.Deleted()) != 2 || q.Inflight() != 0 {
+		t.Errorf("expected 2 deleted messages got: %d\n", len(q.Deleted()))
+	}
+}
+
+// cancelQueue cancels the acker on the first batch, like the tick that races
+// the shutdown.
+type cancelQueue struct {
+	*fake.Queue
+	cancel context.CancelFunc
+}
+
+func (q *cancelQueue) DeleteBatch(ctx context.Context, msgs []*queue.Message) error {
+	if q.cancel != nil {
+		q.cancel()
+		q.cancel = nil
+		return ctx.Err()
+	}
+	return q.Queue.DeleteBatch(ctx, msgs)
+}
+
+func TestAckerDeletesOnCanceledTick(t *testing.T) {
+	q := fake.NewQueue("1,github.com,pyk,byten")
+	msgs, err := q.Receive(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := NewAcker(&cancelQueue{Queue: q, cancel: cancel}, time.Millisecond)
+	a.Ack(msgs[0])
+	a.Run(ctx)
+
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected the message deleted after the shutdown got: %d\n", len(q.Deleted()))
+	}
+}
+
+// slowStore blocks the saves until release is closed.
+type slowStore struct {
+	*fake.Store
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) Save(r *packagebug.Result) error {
+	close(s.saving)
+	<-s.release
+	return s.Store.Save(r)
+}
+
+func TestWorkerRunAcksAfterDone(t *testing.T) {
+	w, base, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}, "1,github.com,pyk,byten")
+	store := &slowStore{Store: base, saving: make(chan struct{}),
+		release: make(chan struct{})}
+	w.Store = store
+	w.Acker = NewAcker(q, time.Hour)
+
+	// the package saved while the worker shuts down is acknowledged
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	select {
+	case <-store.saving:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the save of the package")
+	}
+	cancel()
+	close(store.release)
+	<-done
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected 1 deleted message got: %d\n", len(q.Deleted()))
+	}
+}
+
+func TestWorkerRunDeletesPendingAcks(t *testing.T) {
+	w, store, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}, "1,github.com,pyk,byten")
+	w.Acker = NewAcker(q, time.Hour)
+
+	// the ack of the processed package waits for its batch until the
+	// worker is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.FetchLogs()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(q.Deleted()) != 0 {
+		t.Fatalf("expected the ack pending got: %d\n", len(q.Deleted()))
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected canceled got: %v\n", err)
+	}
+	if len(q.Deleted()) != 1 || q.Inflight() != 0 {
+		t.Errorf("expected the pending ack deleted got: %d\n", len(q.Deleted()))
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// AdvisoryFetcher fetches the security advisories of the package, e.g.
+// osv.Client.
+type AdvisoryFetcher interface {
+	FetchAdvisories(ctx context.Context, p packagebug.Package) ([]packagebug.Advisory, error)
+}
+
+// syncAdvisories fetches and replaces the stored advisories of the package
+// if w.Advisories is set. The advisories are best effort like the
+// repository metadata, the failure is only logged.
+func (w *Worker) syncAdvisories(ctx context.Context, p packagebug.Package) {
+	if w.Advisories == nil {
+		return
+	}
+	logger := packagebug.Logger(ctx)
+	advisories, err := w.Advisories.FetchAdvisories(ctx, p)
+	if err != nil {
+		logger.Warn("fetch advisories failed", "error", err)
+		return
+	}
+	if w.DryRun {
+		logger.Info("dry run: advisories", "advisories", len(advisories))
+		return
+	}
+	err = w.Store.SaveAdvisories(p, advisories)
+	if err != nil {
+		logger.Warn("save advisories failed", "error", err)
+	}
+}
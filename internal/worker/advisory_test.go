@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+type advisoryFetcher struct {
+	advisories []packagebug.Advisory
+	err        error
+}
+
+func (f advisoryFetcher) FetchAdvisories(ctx context.Context, p packagebug.Package) ([]packagebug.Advisory, error) {
+	return f.advisories, f.err
+}
+
+func TestWorkerSyncAdvisories(t *testing.T) {
+	store := fake.NewStore()
+	w := &Worker{Store: store}
+	ctx := context.Background()
+	// disabled
+	w.syncAdvisories(ctx, fake.Package)
+
+	w.Advisories = advisoryFetcher{advisories: []packagebug.Advisory{{Id: "GO-2023-1571"}}}
+	w.syncAdvisories(ctx, fake.Package)
+	if a := store.Advisories(fake.Package); len(a) != 1 || a[0].Id != "GO-2023-1571" {
+		t.Errorf("expected the advisory saved got: %+v\n", a)
+	}
+
+	// the failed fetch keeps the stored advisories
+	w.Advisories = advisoryFetcher{err: errors.New("unavailable")}
+	w.syncAdvisories(ctx, fake.Package)
+	if a := store.Advisories(fake.Package); len(a) != 1 {
+		t.Errorf("expected the advisories kept got: %+v\n", a)
+	}
+}
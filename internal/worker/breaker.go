@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// breakerStates publishes the state of the circuit breakers by name.
+var breakerStates = expvar.NewMap("packagebug_breakers")
+
+// The states of the circuit breaker.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerDB is the name of the breaker of the database, the breakers of the
+// hosts are named by the provider.
+const BreakerDB = "postgres"
+
+// Breaker is the circuit breaker of a dependency. It opens after Threshold
+// consecutive failures and rejects the calls for Cooldown. Then it's half
+// open: a single probe is allowed, its success closes the breaker and its
+// failure opens it again.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	// openedAt is the time the breaker opened, probeAt is the time the
+	// probe of the half-open breaker was allowed
+	openedAt time.Time
+	probeAt  time.Time
+	// value is the state published in breakerStates
+	value *expvar.String
+}
+
+// NewBreaker creates the closed breaker of the dependency name.
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	b := &Breaker{
+		Name:      name,
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		value:     new(expvar.String),
+	}
+	b.setState(BreakerClosed)
+	breakerStates.Set(name, b.value)
+	return b
+}
+
+// setState sets the state of the breaker, b.mu is held or b is not shared
+// yet.
+func (b *Breaker) setState(state string) {
+	b.state = state
+	b.value.Set(state)
+}
+
+// State returns the state of the breaker: closed, open or half_open.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow returns true if the call to the dependency is allowed, otherwise how
+// long until the breaker allows a probe. The probe of the half-open breaker
+// that never reports its outcome is replaced after Cooldown.
+func (b *Breaker) Allow(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.openedAt.Add(b.Cooldown).Sub(now); wait > 0 {
+			return false, wait
+		}
+		b.setState(BreakerHalfOpen)
+		b.probeAt = now
+		return true, 0
+	case BreakerHalfOpen:
+		if wait := b.probeAt.Add(b.Cooldown).Sub(now); wait > 0 {
+			return false, wait
+		}
+		b.probeAt = now
+		return true, 0
+	}
+	return true, 0
+}
+
+// Success closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state != BreakerClosed {
+		slog.Info("circuit breaker closed", "breaker", b.Name)
+		b.setState(BreakerClosed)
+	}
+}
+
+// Failure counts the consecutive failure and opens the breaker at the
+// threshold. The failed probe opens the half-open breaker again.
+func (b *Breaker) Failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.Threshold) {
+		slog.Warn("circuit breaker opened", "breaker", b.Name,
+			"failures", b.failures, "cooldown", b.Cooldown)
+		b.setState(BreakerOpen)
+		b.openedAt = now
+	}
+}
+
+// Breakers are the circuit breakers of the worker dependencies by name,
+// created on first use.
+type Breakers struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewBreakers creates the breakers that open after threshold consecutive
+// failures for cooldown.
+func NewBreakers(threshold int, cooldown time.Duration) *Breakers {
+	return &Breakers{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		breakers:  make(map[string]*Breaker),
+	}
+}
+
+// Get returns the breaker of the dependency name.
+func (bs *Breakers) Get(name string) *Breaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.breakers[name]
+	if !ok {
+		b = NewBreaker(name, bs.Threshold, bs.Cooldown)
+		bs.breakers[name] = b
+	}
+	return b
+}
+
+// ObserveHost reports the outcome of a fetch to the breaker of the
+// provider. The 5xx and network errors are failures, the other errors, e.g.
+// not found or rate limited, mean the host is up.
+func (bs *Breakers) ObserveHost(provider string, err error) {
+	var serr *packagebug.StatusError
+	var nerr net.Error
+	switch {
+	case errors.As(err, &serr) && serr.StatusCode >= 500, errors.As(err, &nerr):
+		bs.Get(provider).Failure(time.Now())
+	default:
+		bs.Get(provider).Success()
+	}
+}
+
+// ObserveDB reports the outcome of a database write to the breaker of the
+// database.
+func (bs *Breakers) ObserveDB(err error) {
+	if err != nil {
+		bs.Get(BreakerDB).Failure(time.Now())
+		return
+	}
+	bs.Get(BreakerDB).Success()
+}
+
+// allow returns true if the breaker of the dependency name allows the call,
+// otherwise how long until it allows a probe. Every call is allowed without
+// breakers.
+func (w *Worker) allow(name string) (bool, time.Duration) {
+	if w.Breakers == nil {
+		return true, 0
+	}
+	return w.Breakers.Get(name).Allow(time.Now())
+}
+
+// pause waits for d or until ctx is done.
+func pause(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker("test", 2, time.Minute)
+	b.Failure(now)
+	if ok, _ := b.Allow(now); !ok || b.State() != BreakerClosed {
+		t.Errorf("expected closed below threshold got: %s\n", b.State())
+	}
+	b.Failure(now)
+	ok, wait := b.Allow(now.Add(time.Second))
+	if ok || b.State() != BreakerOpen || wait != 59*time.Second {
+		t.Errorf("expected open for the rest of cooldown got: %s %s\n", b.State(), wait)
+	}
+
+	// a single probe once the cooldown is over
+	now = now.Add(time.Minute)
+	if ok, _ := b.Allow(now); !ok || b.State() != BreakerHalfOpen {
+		t.Errorf("expected probe of half open got: %s\n", b.State())
+	}
+	if ok, _ := b.Allow(now); ok {
+		t.Error("expected a single probe")
+	}
+	b.Failure(now)
+	if ok, _ := b.Allow(now); ok || b.State() != BreakerOpen {
+		t.Errorf("expected failed probe to open got: %s\n", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow(now)
+	b.Success()
+	if ok, _ := b.Allow(now); !ok || b.State() != BreakerClosed {
+		t.Errorf("expected successful probe to close got: %s\n", b.State())
+	}
+}
+
+func TestBreakersObserve(t *testing.T) {
+	bs := NewBreakers(1, time.Minute)
+	bs.ObserveHost("github", &packagebug.StatusError{StatusCode: 404})
+	bs.ObserveHost("github", packagebug.ErrRateLimited)
+	if bs.Get("github").State() != BreakerClosed {
+		t.Errorf("expected host up on 404 and rate limit\n")
+	}
+	bs.ObserveHost("github", fmt.Errorf("fetch: %w", &packagebug.StatusError{StatusCode: 502}))
+	if bs.Get("github").State() != BreakerOpen {
+		t.Errorf("expected host breaker open on 5xx\n")
+	}
+	bs.ObserveDB(packagebug.DBError(errors.New("connection refused")))
+	if bs.Get(BreakerDB).State() != BreakerOpen {
+		t.Errorf("expected database breaker open\n")
+	}
+	if v := breakerStates.Get("github").String(); v != `"open"` {
+		t.Errorf("expected state published got: %s\n", v)
+	}
+}
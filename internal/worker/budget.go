@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// continueSync sends the message that continues the sync of the package at
+// the cursor, with the priority of msg.
+func (w *Worker) continueSync(ctx context.Context, p packagebug.Package, msg *queue.Message, c packagebug.Cursor) error {
+	sender, ok := w.Queue.(queue.Sender)
+	if !ok {
+		return errors.New("queue can't send messages")
+	}
+	// the message was parsed before, the priority is known
+	m, _ := packagebug.ParseMessage(msg.Body)
+	packagebug.Logger(ctx).Info("budget spent. sync continued", "cursor", c.String())
+	return sender.Send(ctx, packagebug.FormatContinuation(p, m.Priority, c), m.Priority)
+}
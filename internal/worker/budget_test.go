@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestWorkerProcessBudget(t *testing.T) {
+	var issues []packagebug.Issue
+	for i := 1; i <= 5; i++ {
+		issues = append(issues, packagebug.Issue{Number: i, Title: "crash", State: "open",
+			UpdatedAt: time.Date(2016, 1, i, 0, 0, 0, 0, time.UTC)})
+	}
+	p := fake.Package
+	p.Id = "1"
+	w, store, q := newTestWorker(t, &fake.GitHub{Issues: issues, PerPage: 2, Etag: `"v1"`},
+		packagebug.FormatMessage(p, 3))
+	w.Budget = packagebug.Budget{Pages: 2}
+	store.AddPackage(p)
+	process(t, w, p, 1)
+
+	saved := store.Saved()
+	if len(saved) != 1 || len(saved[0].Issues) != 4 {
+		t.Fatalf("expected the issues of 2 pages saved got: %+v\n", saved)
+	}
+	// the partial result keeps the sync state
+	etag, _ := store.GetEtag(p)
+	since, _ := store.GetSince(p)
+	if etag != "" || !since.IsZero() {
+		t.Errorf("expected no sync state got: %q %v\n", etag, since)
+	}
+	expected := packagebug.FormatContinuation(p, 3, packagebug.Cursor{Page: 3})
+	if sent := q.Messages(); len(sent) != 1 || sent[0] != expected {
+		t.Errorf("expected continuation %q got: %v\n", expected, sent)
+	}
+}
@@ -0,0 +1,225 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// ErrBufferFull returned when the buffer reach its maximum number of entries.
+var ErrBufferFull = errors.New("buffer is full")
+
+// BufferEntry represents a fetch result that is not stored yet to the database
+// together with its message.
+type BufferEntry struct {
+	Result  *packagebug.Result `json:"result"`
+	Message *queue.Message     `json:"message"`
+}
+
+// Buffer keeps the fetch results on the local disk while the database is
+// unavailable. The number of entries is bounded by Max.
+type Buffer struct {
+	Dir string
+	Max int
+
+	mu  sync.Mutex
+	seq int64
+}
+
+// NewBuffer creates a buffer that store its entries in dir. It creates the
+// directory if not exists.
+func NewBuffer(dir string, max int) (*Buffer, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{Dir: dir, Max: max}, nil
+}
+
+// files returns the path of buffered entries sorted from the oldest.
+func (b *Buffer) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(b.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// load reads the buffered entry of the file.
+func load(file string) (BufferEntry, error) {
+	var e BufferEntry
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(data, &e)
+	if err != nil {
+		return e, fmt.Errorf("%s: %s", file, err)
+	}
+	return e, nil
+}
+
+// Files returns the path of the buffered entries sorted from the oldest.
+func (b *Buffer) Files() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.files()
+}
+
+// Len returns the number of buffered entries.
+func (b *Buffer) Len() int {
+	files, err := b.Files()
+	if err != nil {
+		return 0
+	}
+	return len(files)
+}
+
+// Add writes the entry to the disk. It returns ErrBufferFull if the buffer
+// already has Max entries.
+func (b *Buffer) Add(e BufferEntry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files, err := b.files()
+	if err != nil {
+		return err
+	}
+	if len(files) >= b.Max {
+		return ErrBufferFull
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file first so partially written entry never loaded
+	b.seq++
+	name := fmt.Sprintf("%020d-%06d", time.Now().UnixNano(), b.seq)
+	tmp := filepath.Join(b.Dir, name+".tmp")
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(b.Dir, name+".json"))
+}
+
+// Entries returns all buffered entries mapped by its file path.
+func (b *Buffer) Entries() (map[string]BufferEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files, err := b.files()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]BufferEntry, len(files))
+	for _, f := range files {
+		e, err := load(f)
+		if err != nil {
+			return nil, err
+		}
+		entries[f] = e
+	}
+	return entries, nil
+}
+
+// Remove deletes the buffered entry from the disk.
+func (b *Buffer) Remove(file string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !strings.HasPrefix(file, b.Dir) {
+		return fmt.Errorf("%s is not buffer entry", file)
+	}
+	return os.Remove(file)
+}
+
+// Flush stores all buffered entries to the store from the oldest and
+// deletes its messages from the queue, so the older result of a package
+// never overwrites the newer one. It stops on the first store error.
+func (b *Buffer) Flush(ctx context.Context, store Store, q queue.Queue) error {
+	files, err := b.Files()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		e, err := load(file)
+		if err != nil {
+			return err
+		}
+		err = store.Save(e.Result)
+		if err != nil {
+			return err
+		}
+		err = q.Delete(ctx, e.Message)
+		if err != nil {
+			slog.Error("buffer: delete message failed", "error", err)
+		}
+		err = b.Remove(file)
+		if err != nil {
+			return err
+		}
+		slog.Info("buffer: flushed", "package_path", e.Result.Package.Path())
+	}
+	return nil
+}
+
+// Extend extends the visibility timeout of all buffered messages, so it's
+// not redelivered while the result is waiting in the buffer.
+func (b *Buffer) Extend(ctx context.Context, q queue.Queue, timeout time.Duration) error {
+	entries, err := b.Entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err = q.ChangeVisibility(ctx, e.Message, timeout)
+		if err != nil {
+			slog.Error("buffer: extend visibility failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// Run checks the store every interval until ctx is done. If the store is
+// available the buffer is flushed, otherwise the visibility of buffered
+// messages extended.
+func (b *Buffer) Run(ctx context.Context, store Store, q queue.Queue, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if b.Len() == 0 {
+			continue
+		}
+
+		err := store.Ping(ctx)
+		if err != nil {
+			slog.Warn("buffer: database unavailable", "error", err,
+				"buffered", b.Len())
+			b.Extend(ctx, q, 2*interval)
+			continue
+		}
+
+		err = b.Flush(ctx, store, q)
+		if err != nil {
+			slog.Error("buffer: flush failed", "error", err)
+		}
+	}
+}
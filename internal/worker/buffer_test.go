@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+func TestBufferAdd(t *testing.T) {
+	buf, err := NewBuffer(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := BufferEntry{
+		Result: &packagebug.Result{
+			Package: fake.Package,
+			Etag:    "etag",
+			Issues:  []packagebug.Issue{{GithubId: 1, Number: 1, Title: "bug"}},
+		},
+		Message: &queue.Message{Id: "id", Body: "1,github.com,pyk,byten", Handle: "handle"},
+	}
+	for i := 0; i < 2; i++ {
+		err = buf.Add(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = buf.Add(e)
+	if err != ErrBufferFull {
+		t.Fatalf("expected: %s got: %v\n", ErrBufferFull, err)
+	}
+
+	entries, err := buf.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries got: %d\n", len(entries))
+	}
+	for file, got := range entries {
+		if got.Message.Handle != "handle" || got.Result.Issues[0].Title != "bug" {
+			t.Errorf("got: %+v\n", got)
+		}
+		err = buf.Remove(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected empty buffer got: %d\n", buf.Len())
+	}
+}
+
+func TestBufferFlush(t *testing.T) {
+	buf, err := NewBuffer(t.TempDir(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		err = buf.Add(BufferEntry{
+			Result:  &packagebug.Result{Package: fake.Package, Etag: fmt.Sprint(i)},
+			Message: &queue.Message{Id: fmt.Sprint(i), Handle: fmt.Sprint(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := fake.NewStore()
+	q := fake.NewQueue()
+	err = buf.Flush(context.Background(), store, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the older results are saved first, so the newest etag is kept
+	saved := store.Saved()
+	if len(saved) != 20 {
+		t.Fatalf("expected 20 saved results got: %d\n", len(saved))
+	}
+	for i, r := range saved {
+		if r.Etag != fmt.Sprint(i) {
+			t.Errorf("expected etag %d got: %s\n", i, r.Etag)
+		}
+	}
+	if etag, _ := store.GetEtag(fake.Package); etag != "19" {
+		t.Errorf("expected etag of the newest result got: %s\n", etag)
+	}
+	if len(q.Deleted()) != 20 || buf.Len() != 0 {
+		t.Errorf("expected flushed buffer got: %d deleted %d buffered\n", len(q.Deleted()), buf.Len())
+	}
+}
+
+// extendQueue records the visibility timeout of the messages.
+type extendQueue struct {
+	*fake.Queue
+	timeouts map[string]time.Duration
+}
+
+func (q *extendQueue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	q.timeouts[m.Handle] = timeout
+	return q.Queue.ChangeVisibility(ctx, m, timeout)
+}
+
+func TestBufferExtend(t *testing.T) {
+	buf, err := NewBuffer(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = buf.Add(BufferEntry{
+			Result:  &packagebug.Result{Package: fake.Package},
+			Message: &queue.Message{Id: fmt.Sprint(i), Handle: fmt.Sprint(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := &extendQueue{Queue: fake.NewQueue(), timeouts: make(map[string]time.Duration)}
+	err = buf.Extend(context.Background(), q, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.timeouts) != 2 || q.timeouts["0"] != time.Minute || q.timeouts["1"] != time.Minute {
+		t.Errorf("expected extended visibility of the buffered messages got: %v\n", q.timeouts)
+	}
+	// the results stay in the buffer until flushed
+	if buf.Len() != 2 || len(q.Messages()) != 0 {
+		t.Errorf("expected 2 buffered entries got: %d\n", buf.Len())
+	}
+}
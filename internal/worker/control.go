@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// control handles the action of the job and returns true if the package is
+// fetched afterwards. The delete message is acknowledged once the issues of
+// the package are deleted, otherwise it's redelivered. The rescan resets the
+// sync state before the fetch; if the reset fails, the fetch is conditional
+// as usual.
+func (w *Worker) control(ctx context.Context, job Job) bool {
+	logger := packagebug.Logger(ctx)
+	p := job.Package
+	switch job.Action {
+	case packagebug.ActionDelete:
+		if w.DryRun {
+			logger.Info("dry run: package issues not deleted")
+			return false
+		}
+		err := packagebug.Retry.Do(ctx, func() error {
+			return w.Store.DeleteIssues(p)
+		})
+		if err != nil {
+			logger.Error("delete package issues failed", "error", err)
+			return false
+		}
+		logger.Info("package issues deleted")
+		w.ack(job.Message)
+		return false
+	case packagebug.ActionRescan:
+		if w.DryRun {
+			return true
+		}
+		err := w.Store.ResetSync(p)
+		if err != nil {
+			logger.Warn("reset sync state failed", "error", err)
+			return true
+		}
+		logger.Info("sync state reset")
+	}
+	return true
+}
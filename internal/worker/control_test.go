@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestWorkerControl(t *testing.T) {
+	store := fake.NewStore()
+	p := fake.Package
+	p.Id = "1"
+	store.Save(&packagebug.Result{Package: p, Etag: "etag",
+		Issues: []packagebug.Issue{{Number: 1, UpdatedAt: time.Now()}}})
+	q := fake.NewQueue(packagebug.FormatAction(p, 0, packagebug.ActionRescan),
+		packagebug.FormatAction(p, 0, packagebug.ActionDelete))
+	w := &Worker{Store: store, Queue: q, Acker: NewAcker(q, time.Millisecond)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Acker.Run(ctx)
+	msgs, _ := q.Receive(ctx, 2, 0)
+
+	// rescan resets the sync state and fetches the package
+	if !w.control(ctx, Job{Package: p, Action: packagebug.ActionRescan, Message: msgs[0]}) {
+		t.Error("expected the rescanned package fetched")
+	}
+	if etag, _ := store.GetEtag(p); etag != "" {
+		t.Errorf("expected etag reset got: %s\n", etag)
+	}
+	if since, _ := store.GetSince(p); !since.IsZero() {
+		t.Errorf("expected since reset got: %s\n", since)
+	}
+	if len(store.Saved()) != 1 {
+		t.Errorf("expected issues kept on rescan\n")
+	}
+
+	// delete removes the issues and acknowledges the message
+	if w.control(ctx, Job{Package: p, Action: packagebug.ActionDelete, Message: msgs[1]}) {
+		t.Error("expected the deleted package not fetched")
+	}
+	if len(store.Saved()) != 0 {
+		t.Errorf("expected issues deleted got: %d\n", len(store.Saved()))
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(q.Deleted()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	deleted := q.Deleted()
+	if len(deleted) != 1 || deleted[0].Id != msgs[1].Id {
+		t.Errorf("expected the delete message acknowledged got: %+v\n", deleted)
+	}
+}
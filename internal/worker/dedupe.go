@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// DuplicateDelay is how long the message of a package that is already
+// fetched by another process is delayed. The message isn't acknowledged, the
+// running fetch may have started before the package was updated.
+const DuplicateDelay = 30 * time.Second
+
+// duplicates counts the duplicate deliveries that were skipped by reason:
+// inflight if the package is already fetched by this worker, locked if by
+// another worker and recent if it was fetched within Worker.DedupeWindow.
+var duplicates = expvar.NewMap("packagebug_duplicates")
+
+// claim marks the package in flight in this worker. It returns false if the
+// package is already in flight. The packages of the same path crawled for
+// other tenants are claimed on their own, see packagebug.Package.Key.
+func (w *Worker) claim(p packagebug.Package) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.inflight == nil {
+		w.inflight = make(map[string]bool)
+	}
+	if w.inflight[p.Key()] {
+		return false
+	}
+	w.inflight[p.Key()] = true
+	return true
+}
+
+// release removes the package from the in-flight packages.
+func (w *Worker) release(p packagebug.Package) {
+	w.mu.Lock()
+	delete(w.inflight, p.Key())
+	w.mu.Unlock()
+}
+
+// lock takes the lock of the package shared by all workers. It returns false
+// if other worker holds the lock. The lock is best effort, the package is
+// fetched without it if the store fails to take it.
+func (w *Worker) lock(ctx context.Context, p packagebug.Package) (unlock func(), ok bool) {
+	unlock, ok, err := w.Store.Lock(ctx, p)
+	if err != nil {
+		packagebug.Logger(ctx).Warn("lock package failed", "error", err)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+	return unlock, true
+}
+
+// recent acknowledges the fetch message of the package fetched successfully
+// within DedupeWindow and returns true. The continuations of the sync and the
+// other actions are never skipped. If the status of the package can't be
+// read, the package is fetched.
+func (w *Worker) recent(ctx context.Context, job Job) bool {
+	p := job.Package
+	if w.DedupeWindow == 0 || job.Action != packagebug.ActionFetch || !p.Cursor.IsZero() {
+		return false
+	}
+	logger := packagebug.Logger(ctx)
+	st, err := w.Store.GetStatus(p)
+	if err != nil {
+		logger.Warn("get package status failed", "error", err)
+		return false
+	}
+	if st.LastError != "" || st.LastFetchAt.IsZero() || time.Since(st.LastFetchAt) >= w.DedupeWindow {
+		return false
+	}
+	logger.Info("package fetched recently. skipped", "last_fetch_at", st.LastFetchAt)
+	duplicates.Add("recent", 1)
+	w.ack(job.Message)
+	return true
+}
+
+// delay hides the message of the duplicate package for DuplicateDelay and
+// counts the duplicate by reason.
+func (w *Worker) delay(ctx context.Context, m *queue.Message, reason string) {
+	logger := packagebug.Logger(ctx)
+	logger.Info("package already in flight. delayed", "wait", DuplicateDelay,
+		"reason", reason)
+	duplicates.Add(reason, 1)
+	err := w.Queue.ChangeVisibility(ctx, m, DuplicateDelay)
+	if err != nil {
+		logger.Warn("delay message failed", "error", err)
+	}
+}
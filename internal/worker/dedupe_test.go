@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+// counter returns the value of the counter key of m, zero if not counted.
+func counter(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestWorkerClaim(t *testing.T) {
+	w := &Worker{}
+	if !w.claim(fake.Package) {
+		t.Fatal("expected package claimed")
+	}
+	if w.claim(fake.Package) {
+		t.Error("expected duplicate package rejected")
+	}
+	w.release(fake.Package)
+	if !w.claim(fake.Package) {
+		t.Error("expected released package claimed again")
+	}
+	// the same path of another tenant is claimed on its own
+	p := fake.Package
+	p.Tenant = "acme"
+	if !w.claim(p) {
+		t.Error("expected package of acme claimed")
+	}
+}
+
+func TestWorkerProcessLocked(t *testing.T) {
+	store := fake.NewStore()
+	q := fake.NewQueue("1,github.com,pyk,byten")
+	w := &Worker{Store: store, Queue: q}
+	ctx := context.Background()
+	msgs, _ := q.Receive(ctx, 1, 0)
+
+	// other worker is fetching the package
+	unlock, ok, _ := store.Lock(ctx, fake.Package)
+	if !ok {
+		t.Fatal("expected lock")
+	}
+	defer unlock()
+	before := counter(duplicates, "locked")
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	w.claim(fake.Package)
+	w.Process(ctx, wg, fake.Package, msgs[0])
+
+	if len(store.Saved()) != 0 || len(store.FetchLogs()) != 0 {
+		t.Error("expected locked package skipped")
+	}
+	if q.Inflight() != 1 || len(q.Deleted()) != 0 {
+		t.Error("expected message delayed")
+	}
+	if n := counter(duplicates, "locked") - before; n != 1 {
+		t.Errorf("expected 1 locked duplicate got: %d\n", n)
+	}
+	if !w.claim(fake.Package) {
+		t.Error("expected package released")
+	}
+}
+
+func TestWorkerRecent(t *testing.T) {
+	store := fake.NewStore()
+	q := fake.NewQueue("1,github.com,pyk,byten")
+	w := &Worker{Store: store, Queue: q, Acker: NewAcker(q, time.Millisecond)}
+	ctx := context.Background()
+	msgs, _ := q.Receive(ctx, 1, 0)
+	job := Job{Package: fake.Package, Action: packagebug.ActionFetch, Message: msgs[0]}
+	if w.recent(ctx, job) {
+		t.Error("expected disabled window")
+	}
+
+	w.DedupeWindow = time.Minute
+	if w.recent(ctx, job) {
+		t.Error("expected the package never fetched kept")
+	}
+	store.SaveFetchLog(packagebug.FetchLog{PackageId: fake.Package.Id, StartedAt: time.Now()})
+	before := counter(duplicates, "recent")
+	if !w.recent(ctx, job) {
+		t.Error("expected the package fetched recently skipped")
+	}
+	if n := counter(duplicates, "recent") - before; n != 1 {
+		t.Errorf("expected 1 recent duplicate got: %d\n", n)
+	}
+
+	cont := job
+	cont.Package.Cursor = packagebug.Cursor{Query: 1, Page: 2}
+	rescan := job
+	rescan.Action = packagebug.ActionRescan
+	if w.recent(ctx, cont) || w.recent(ctx, rescan) {
+		t.Error("expected the continuation and the rescan kept")
+	}
+	store.SaveFetchLog(packagebug.FetchLog{PackageId: fake.Package.Id, StartedAt: time.Now(), Error: "failed"})
+	if w.recent(ctx, job) {
+		t.Error("expected the package whose fetch failed kept")
+	}
+}
@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"log/slog"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// LogUpserts logs the upserts that Store.Save would perform with the result
+// instead of writing to the database.
+func LogUpserts(logger *slog.Logger, r *packagebug.Result) {
+	logger.Info("dry run: would update package", "etag", r.Etag,
+		"since", r.Since(), "issues", len(r.Issues))
+	for _, issue := range r.Issues {
+		logger.Info("dry run: would upsert issue", "issue_number", issue.Number,
+			"issue_title", issue.Title, "issue_state", issue.State,
+			"issue_language", issue.Language, "comments", len(issue.Comments),
+			"events", len(issue.Events))
+	}
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+// ForkTTL is how long the fork relationship is reused before it is checked
+// again.
+const ForkTTL = 7 * 24 * time.Hour
+
+// resolveFork returns the fork relationship of the package. The stored
+// relationship is reused until ForkTTL passed, the dry run doesn't store
+// it.
+func (w *Worker) resolveFork(ctx context.Context, p packagebug.Package) (packagebug.Fork, error) {
+	f, err := w.Store.GetFork(p)
+	if err != nil {
+		return f, err
+	}
+	if time.Since(f.CheckedAt) < ForkTTL {
+		return f, nil
+	}
+	f.Parent, err = github.FetchForkParent(ctx, w.githubOf(p), p)
+	if err != nil {
+		return f, err
+	}
+	f.CheckedAt = time.Now()
+	if w.DryRun {
+		return f, nil
+	}
+	return f, w.Store.SetForkParent(p, f.Parent)
+}
+
+// applyFork returns the package to fetch according to the fork policy and
+// the parent package to fetch alongside it, if any. It returns false if the
+// package should not be fetched.
+func (w *Worker) applyFork(ctx context.Context, p packagebug.Package) (packagebug.Package, *packagebug.Package, bool) {
+	logger := packagebug.Logger(ctx)
+	// only GitHub exposes the fork relationship
+	if w.githubOf(p) == nil {
+		return p, nil, true
+	}
+	f, err := w.resolveFork(ctx, p)
+	if err != nil {
+		logger.Warn("resolve fork failed", "error", err)
+	}
+	if f.Parent == "" {
+		return p, nil, true
+	}
+
+	policy := f.Policy
+	if policy == "" {
+		policy = w.ForkPolicy
+	}
+	logger = logger.With("fork_parent", f.Parent, "fork_policy", policy)
+	switch policy {
+	case packagebug.ForkSkip:
+		logger.Info("fork skipped")
+		return p, nil, false
+	case packagebug.ForkParent:
+		upstream, err := packagebug.ParsePath(f.Parent)
+		if err != nil {
+			logger.Warn("invalid fork parent", "error", err)
+			return p, nil, true
+		}
+		p.Upstream = &upstream
+		return p, nil, true
+	}
+
+	parent, ok, err := w.Store.FindPackage(p.Tenant, f.Parent)
+	if err != nil {
+		logger.Warn("find fork parent failed", "error", err)
+	}
+	if !ok {
+		return p, nil, true
+	}
+	return p, &parent, true
+}
+
+// syncParent fetches and stores the issues of the parent package of a fork.
+// The parent has its own messages, so the failure is only logged.
+func (w *Worker) syncParent(ctx context.Context, parent packagebug.Package) {
+	logger := packagebug.Logger(ctx).With("parent_path", parent.Path())
+	var err error
+	parent.Capabilities, err = w.Store.GetCapabilities(parent, w.Capabilities)
+	if err != nil {
+		logger.Warn("get parent capabilities failed", "error", err)
+	}
+	result, err := w.fetch(ctx, parent)
+	if err != nil {
+		logger.Warn("fetch fork parent failed", "error", err)
+		return
+	}
+	if result == nil {
+		return
+	}
+	w.prepare(result)
+	if w.DryRun {
+		LogUpserts(logger, result)
+		return
+	}
+	err = packagebug.Retry.Do(ctx, func() error {
+		return w.Store.Save(result)
+	})
+	if err != nil {
+		logger.Warn("save fork parent failed", "error", err)
+		return
+	}
+	w.cacheResponses(ctx, result)
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"slices"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+// githubOf returns the GitHub client of the host of the package, the client
+// of github.com or of a GitHub Enterprise Server among the providers, with
+// the tokens of its tenant if it has its own. It returns nil if the host is
+// not served by GitHub.
+func (w *Worker) githubOf(p packagebug.Package) *github.Client {
+	if t, _ := w.tenantOf(p); t != nil && t.GitHub != nil && t.GitHub.Has(p.Host) {
+		return t.GitHub
+	}
+	if w.GitHub.Has(p.Host) {
+		return w.GitHub
+	}
+	c, _ := provider.Find(w.providersOf(p), p.Host).(*github.Client)
+	return c
+}
+
+// githubClients returns the client of github.com and the clients of the
+// GitHub Enterprise Server hosts among the providers, the ones of the
+// tenants included.
+func (w *Worker) githubClients() []*github.Client {
+	clients := []*github.Client{w.GitHub}
+	add := func(c *github.Client) {
+		if c != nil && !slices.Contains(clients, c) {
+			clients = append(clients, c)
+		}
+	}
+	for _, p := range w.Providers {
+		c, _ := p.(*github.Client)
+		add(c)
+	}
+	for _, t := range w.Tenants {
+		add(t.GitHub)
+		for _, p := range t.Providers {
+			c, _ := p.(*github.Client)
+			add(c)
+		}
+	}
+	return clients
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Health serves the liveness and readiness probes of the worker.
+type Health struct {
+	DB *sql.DB
+	// Replica is the optional read replica of DB
+	Replica *sql.DB
+	Queue   queue.Queue
+}
+
+// Check returns the first error of the worker dependencies: the database,
+// its replica and the queue (including its credentials).
+func (h *Health) Check(ctx context.Context) error {
+	err := h.DB.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("database: %s", err)
+	}
+	if h.Replica != nil {
+		err = h.Replica.PingContext(ctx)
+		if err != nil {
+			return fmt.Errorf("replica: %s", err)
+		}
+	}
+
+	if p, ok := h.Queue.(queue.Pinger); ok {
+		err = p.Ping(ctx)
+		if err != nil {
+			return fmt.Errorf("queue: %s", err)
+		}
+	}
+	return nil
+}
+
+// Healthz reports that the process is alive.
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz reports whether the worker is ready to process the messages.
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	err := h.Check(r.Context())
+	if err != nil {
+		slog.Warn("readyz: not ready", "error", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// ListenAndServe serves the health endpoints and the expvar metrics of
+// /debug/vars on addr.
+func (h *Health) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", h.Healthz)
+	mux.HandleFunc("/readyz", h.Readyz)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return http.ListenAndServe(addr, mux)
+}
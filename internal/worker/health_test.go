@@ -0,0 +1,16 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	h := &Health{}
+	w := httptest.NewRecorder()
+	h.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected: %d got: %d\n", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// DefaultVisibilityTimeout is the visibility timeout of the messages unless
+// set.
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// Heartbeat extends the visibility timeout of the in-flight message to
+// timeout every timeout/3 so the message isn't redelivered while it's still
+// processed. The heartbeat runs until the returned stop function is called,
+// it does nothing if the timeout is too short to be extended.
+func Heartbeat(ctx context.Context, q queue.Queue, m *queue.Message, timeout time.Duration) (stop func()) {
+	if timeout/3 <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(timeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			err := q.ChangeVisibility(ctx, m, timeout)
+			if err != nil && ctx.Err() == nil {
+				packagebug.Logger(ctx).Warn("extend visibility timeout failed", "error", err)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// visibilityQueue counts the visibility timeout changes.
+type visibilityQueue struct {
+	queue.Queue
+	changes int32
+}
+
+func (q *visibilityQueue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	atomic.AddInt32(&q.changes, 1)
+	return nil
+}
+
+func TestHeartbeat(t *testing.T) {
+	q := &visibilityQueue{}
+	stop := Heartbeat(context.Background(), q, &queue.Message{Id: "id"}, 30*time.Millisecond)
+	time.Sleep(55 * time.Millisecond)
+	stop()
+	n := atomic.LoadInt32(&q.changes)
+	if n < 2 {
+		t.Errorf("expected at least 2 extensions got: %d\n", n)
+	}
+
+	// no extension after stop
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&q.changes) != n {
+		t.Error("expected no extension after stop")
+	}
+}
+
+func TestHeartbeatShortTimeout(t *testing.T) {
+	q := &visibilityQueue{}
+	for _, timeout := range []time.Duration{0, 2} {
+		stop := Heartbeat(context.Background(), q, &queue.Message{Id: "id"}, timeout)
+		stop()
+	}
+	if n := atomic.LoadInt32(&q.changes); n != 0 {
+		t.Errorf("expected no extension got: %d\n", n)
+	}
+}
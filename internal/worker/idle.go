@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// DefaultReceiveWait is the long poll of the receives unless set.
+const DefaultReceiveWait = 10 * time.Second
+
+// IdleDelay returns the sleep after the n-th consecutive empty receive: it
+// doubles from a second up to max, zero max disables the idle backoff.
+func IdleDelay(n int, max time.Duration) time.Duration {
+	if n < 1 || max <= 0 {
+		return 0
+	}
+	if n > 31 {
+		n = 31
+	}
+	d := time.Second << (n - 1)
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// idle sleeps the idle backoff of the n-th consecutive empty receive. The
+// sleep ends as soon as wake is closed, e.g. a message is sent to the
+// queue, see queue.Waker.
+func (w *Worker) idle(ctx context.Context, n int, wake <-chan struct{}) {
+	d := IdleDelay(n, w.IdleBackoff)
+	if d == 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-wake:
+	case <-ctx.Done():
+	}
+}
+
+// waker returns the channel closed when a message is sent to the queue, nil
+// if the queue doesn't signal its messages.
+func (w *Worker) waker() <-chan struct{} {
+	if q, ok := w.Queue.(queue.Waker); ok {
+		return q.Wake()
+	}
+	return nil
+}
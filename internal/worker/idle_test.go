@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker/internal/queue/memory"
+)
+
+func TestIdleDelay(t *testing.T) {
+	cases := []struct {
+		n        int
+		max      time.Duration
+		expected time.Duration
+	}{
+		{0, time.Minute, 0},
+		{1, time.Minute, time.Second},
+		{3, time.Minute, 4 * time.Second},
+		{7, time.Minute, time.Minute},
+		{100, time.Minute, time.Minute},
+		{3, 0, 0},
+	}
+	for _, c := range cases {
+		d := IdleDelay(c.n, c.max)
+		if d != c.expected {
+			t.Errorf("expected %s after %d empty receives got: %s\n", c.expected, c.n, d)
+		}
+	}
+}
+
+func TestWorkerIdleWake(t *testing.T) {
+	q := memory.New()
+	w := &Worker{Queue: q, IdleBackoff: time.Minute}
+	wake := w.waker()
+	go q.Send(context.Background(), "1,github.com,pyk,byten", 0)
+
+	done := make(chan struct{})
+	go func() {
+		w.idle(context.Background(), 10, wake)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the sent message to end the idle sleep\n")
+	}
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"sort"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// Job is a package to process and its message.
+type Job struct {
+	Package  packagebug.Package
+	Priority int
+	Action   packagebug.Action
+	Message  *queue.Message
+	// Module is the module path of the message of packagebug.FormatModule, the
+	// package has only its id and tenant until the module is resolved
+	Module string
+}
+
+// Path returns the path of the package of the job, the module path of the
+// unresolved module.
+func (j Job) Path() string {
+	if j.Module != "" {
+		return j.Module
+	}
+	return j.Package.Path()
+}
+
+// SortJobs sorts the jobs by priority, the highest first. Jobs of the same
+// priority keep their order.
+func SortJobs(jobs []Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Priority > jobs[j].Priority
+	})
+}
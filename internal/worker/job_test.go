@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+func TestSortJobs(t *testing.T) {
+	jobs := []Job{
+		{Priority: 0, Message: &queue.Message{Id: "a"}},
+		{Priority: 5, Message: &queue.Message{Id: "b"}},
+		{Priority: 0, Message: &queue.Message{Id: "c"}},
+		{Priority: 9, Message: &queue.Message{Id: "d"}},
+	}
+	SortJobs(jobs)
+	var order string
+	for _, j := range jobs {
+		order += j.Message.Id
+	}
+	if order != "dbac" {
+		t.Errorf("expected dbac got: %s\n", order)
+	}
+}
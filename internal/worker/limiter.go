@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// concurrency is the current limit of the concurrent processes.
+var concurrency = expvar.NewInt("packagebug_concurrency")
+
+// Limiter bounds the concurrent worker processes with an adaptive limit
+// between 1 and Max. The limit is halved when the hosts throttle the worker
+// or the database is slow or failing, and it grows back by one after every
+// limit healthy processes.
+type Limiter struct {
+	Max int
+	// SlowSave is the database write latency above which the limit shrinks
+	SlowSave time.Duration
+
+	mu      sync.Mutex
+	limit   int
+	active  int
+	healthy int
+	// wake is closed when a slot may be free
+	wake chan struct{}
+}
+
+// NewLimiter creates the limiter that starts at max concurrent processes.
+func NewLimiter(max int, slowSave time.Duration) *Limiter {
+	concurrency.Set(int64(max))
+	return &Limiter{
+		Max:      max,
+		SlowSave: slowSave,
+		limit:    max,
+		wake:     make(chan struct{}),
+	}
+}
+
+// Acquire waits until the number of processes is below the limit and takes a
+// slot. It returns the error of ctx if ctx is done first.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees the slot taken by Acquire.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	l.active--
+	l.signal()
+	l.mu.Unlock()
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// SetMax changes the maximum of the limit at runtime, the limit starts
+// over at max. The processes above the lowered limit finish their packages,
+// the new ones wait for the free slots.
+func (l *Limiter) SetMax(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Max = max
+	l.limit = max
+	l.healthy = 0
+	concurrency.Set(int64(max))
+	l.signal()
+}
+
+// Stats returns the current limit, its maximum and the number of the
+// running processes.
+func (l *Limiter) Stats() (limit, max, active int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.Max, l.active
+}
+
+// Observe adjusts the limit by the outcome of a process: the limit shrinks
+// if err is a throttling or database error, see Throttled.
+func (l *Limiter) Observe(err error) {
+	if Throttled(err) {
+		l.shrink()
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.healthy++
+	if l.healthy >= l.limit && l.limit < l.Max {
+		l.limit++
+		l.healthy = 0
+		concurrency.Set(int64(l.limit))
+		l.signal()
+	}
+}
+
+// ObserveSave shrinks the limit if the database write took longer than
+// SlowSave.
+func (l *Limiter) ObserveSave(latency time.Duration) {
+	if l.SlowSave > 0 && latency > l.SlowSave {
+		l.shrink()
+	}
+}
+
+// shrink halves the limit.
+func (l *Limiter) shrink() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.healthy = 0
+	if l.limit > 1 {
+		l.limit /= 2
+		concurrency.Set(int64(l.limit))
+	}
+}
+
+// signal wakes up the processes waiting in Acquire.
+func (l *Limiter) signal() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// Throttled returns true if err means the worker should slow down: the host
+// rate limited the request (429 or 403) or the database failed.
+func Throttled(err error) bool {
+	var serr *packagebug.StatusError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, packagebug.ErrRateLimited), errors.Is(err, packagebug.ErrDB):
+		return true
+	case errors.As(err, &serr):
+		return serr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
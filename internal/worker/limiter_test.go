@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	l := NewLimiter(2, 0)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		err := l.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the third process waits until a slot is released
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.Acquire(ctx)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected acquire to wait at the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.Release()
+	err := <-acquired
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = l.Acquire(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded got: %v\n", err)
+	}
+}
+
+func TestLimiterObserve(t *testing.T) {
+	l := NewLimiter(8, time.Second)
+	l.Observe(packagebug.ErrRateLimited)
+	if l.Limit() != 4 {
+		t.Errorf("expected limit halved on rate limit got: %d\n", l.Limit())
+	}
+	l.ObserveSave(2 * time.Second)
+	if l.Limit() != 2 {
+		t.Errorf("expected limit halved on slow save got: %d\n", l.Limit())
+	}
+	l.ObserveSave(time.Millisecond)
+	if l.Limit() != 2 {
+		t.Errorf("expected limit kept on fast save got: %d\n", l.Limit())
+	}
+
+	// grows by one after limit healthy processes
+	l.Observe(nil)
+	l.Observe(nil)
+	if l.Limit() != 3 {
+		t.Errorf("expected limit grown got: %d\n", l.Limit())
+	}
+	for i := 0; i < 100; i++ {
+		l.Observe(nil)
+	}
+	if l.Limit() != 8 {
+		t.Errorf("expected limit capped at max got: %d\n", l.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		l.Observe(packagebug.DBError(fmt.Errorf("connection refused")))
+	}
+	if l.Limit() != 1 {
+		t.Errorf("expected limit at least 1 got: %d\n", l.Limit())
+	}
+}
+
+func TestLimiterSetMax(t *testing.T) {
+	l := NewLimiter(2, 0)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		err := l.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the raised limit frees a slot for the waiting process
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.Acquire(ctx)
+	}()
+	l.SetMax(3)
+	err := <-acquired
+	if err != nil {
+		t.Error(err)
+	}
+	limit, max, active := l.Stats()
+	if limit != 3 || max != 3 || active != 3 {
+		t.Errorf("expected 3 of 3 active got: %d of %d, %d active\n", limit, max, active)
+	}
+
+	// the running processes above the lowered limit finish
+	l.SetMax(1)
+	l.Release()
+	if limit, _, active := l.Stats(); limit != 1 || active != 2 {
+		t.Errorf("expected limit 1 with 2 active got: %d, %d active\n", limit, active)
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{packagebug.ErrRateLimited, true},
+		{&packagebug.StatusError{StatusCode: 429}, true},
+		{fmt.Errorf("fetch: %w", &packagebug.StatusError{StatusCode: 403}), true},
+		{&packagebug.StatusError{StatusCode: 500}, false},
+		{packagebug.DBError(fmt.Errorf("timeout")), true},
+		{fmt.Errorf("other"), false},
+	}
+	for _, test := range tests {
+		if got := Throttled(test.err); got != test.want {
+			t.Errorf("expected %v of %v got: %v\n", test.want, test.err, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// RateLimitDelay is how long the message of the rate limited package is
+// delayed, so the other packages are processed meanwhile.
+const RateLimitDelay = time.Minute
+
+// The metrics are published by expvar, see Health.
+var (
+	// processed counts the processed packages by provider
+	processed = expvar.NewMap("packagebug_processed")
+	// failures counts the failed processes by provider and failure class,
+	// see packagebug.ErrorClass
+	failures = expvar.NewMap("packagebug_errors")
+)
+
+// providerName returns the name of the provider of the package for the
+// metrics, none if no provider serves it.
+func (w *Worker) providerName(p packagebug.Package) string {
+	if prov := provider.Find(w.Providers, p.Source().Host); prov != nil {
+		return prov.Name()
+	}
+	return "none"
+}
+
+// record counts the process of the package by provider and the failure
+// class of err.
+func (w *Worker) record(p packagebug.Package, err error) {
+	name := w.providerName(p)
+	processed.Add(name, 1)
+	if err == nil {
+		return
+	}
+	m, ok := failures.Get(name).(*expvar.Map)
+	if !ok {
+		m = new(expvar.Map)
+		failures.Set(name, m)
+	}
+	m.Add(packagebug.ErrorClass(err), 1)
+}
+
+// retry decides whether the message of the failed process is redelivered by
+// the failure class of err. The message of the package that is gone is
+// acknowledged, the rate limited one is delayed for the Retry-After of the
+// host or RateLimitDelay and the rest is redelivered once its visibility
+// timeout is over.
+func (w *Worker) retry(ctx context.Context, msg *queue.Message, err error) {
+	logger := packagebug.Logger(ctx)
+	switch packagebug.ErrorClass(err) {
+	case "not_found":
+		logger.Warn("package not found. message dropped")
+		w.ack(msg)
+	case "rate_limited":
+		wait := RateLimitDelay
+		if d := packagebug.RetryAfter(err); d > 0 {
+			wait = d
+		}
+		logger.Warn("rate limited. message delayed", "wait", wait)
+		err = w.Queue.ChangeVisibility(ctx, msg, wait)
+		if err != nil {
+			logger.Warn("delay message failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/bitbucket"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+func TestWorkerRecord(t *testing.T) {
+	w := &Worker{Providers: []provider.Provider{bitbucket.New("", "", "")}}
+	p := packagebug.Package{Host: "bitbucket.org", Owner: "pyk", Repo: "byten"}
+	w.record(p, nil)
+	w.record(p, packagebug.ErrRateLimited)
+
+	m, ok := failures.Get("bitbucket").(*expvar.Map)
+	if !ok {
+		t.Fatal("expected failures of bitbucket")
+	}
+	if v := m.Get("rate_limited"); v == nil || v.String() != "1" {
+		t.Errorf("expected 1 rate limited failure got: %v\n", v)
+	}
+	if v := processed.Get("bitbucket"); v == nil || v.String() != "2" {
+		t.Errorf("expected 2 processed got: %v\n", v)
+	}
+}
+
+func TestWorkerRetry(t *testing.T) {
+	q := fake.NewQueue("1,github.com,pyk,byten", "2,github.com,pyk,gone")
+	w := &Worker{Queue: q, Acker: NewAcker(q, time.Millisecond)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Acker.Run(ctx)
+	msgs, _ := q.Receive(ctx, 2, 0)
+
+	w.retry(ctx, msgs[0], packagebug.ErrRateLimited)
+	w.retry(ctx, msgs[1], &packagebug.StatusError{StatusCode: http.StatusNotFound})
+	deadline := time.Now().Add(time.Second)
+	for len(q.Deleted()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	deleted := q.Deleted()
+	if len(deleted) != 1 || deleted[0].Id != msgs[1].Id {
+		t.Errorf("expected the message of the gone package deleted got: %+v\n", deleted)
+	}
+	if q.Inflight() != 1 {
+		t.Errorf("expected rate limited message delayed got: %d\n", q.Inflight())
+	}
+}
+
+// timeoutQueue records the last visibility timeout.
+type timeoutQueue struct {
+	queue.Queue
+	timeout time.Duration
+}
+
+func (q *timeoutQueue) ChangeVisibility(ctx context.Context, m *queue.Message, timeout time.Duration) error {
+	q.timeout = timeout
+	return nil
+}
+
+func TestWorkerRetryAfter(t *testing.T) {
+	q := &timeoutQueue{}
+	w := &Worker{Queue: q}
+	err := &packagebug.StatusError{StatusCode: http.StatusForbidden, RetryAfter: 2 * time.Minute}
+	w.retry(context.Background(), &queue.Message{Id: "1"}, err)
+	if q.timeout != 2*time.Minute {
+		t.Errorf("expected message delayed for the Retry-After got: %s\n", q.timeout)
+	}
+
+	w.retry(context.Background(), &queue.Message{Id: "2"}, packagebug.ErrRateLimited)
+	if q.timeout != RateLimitDelay {
+		t.Errorf("expected message delayed for RateLimitDelay got: %s\n", q.timeout)
+	}
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// move returns the package at the new location of its moved repository. The
+// package is moved in the store, so the next messages use the new location.
+// The upstream that moved is only replaced for this process.
+func (w *Worker) move(ctx context.Context, p packagebug.Package, moved *packagebug.MovedError) (packagebug.Package, error) {
+	logger := packagebug.Logger(ctx)
+	if p.Upstream != nil {
+		up := *p.Upstream
+		up.Owner, up.Repo = moved.Owner, moved.Repo
+		logger.Info("upstream repository moved", "upstream_path", up.Path())
+		p.Upstream = &up
+		return p, nil
+	}
+	to := p
+	to.Owner, to.Repo = moved.Owner, moved.Repo
+	logger.Info("repository moved", "to", to.Path())
+	if w.DryRun {
+		return to, nil
+	}
+	err := w.Store.MovePackage(p, to)
+	if err != nil {
+		return p, fmt.Errorf("move to %s: %w", to.Path(), err)
+	}
+	return to, nil
+}
@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// notifyFailures counts the sync events that failed to publish.
+var notifyFailures = expvar.NewInt("packagebug_notify_failures")
+
+// Notifier publishes the completed syncs, e.g. notify.SNS.
+type Notifier interface {
+	NotifySync(ctx context.Context, e packagebug.SyncEvent) error
+}
+
+// notifySync publishes the event of the stored result if w.Notifier is set.
+// The event is best effort, the failure is only logged and counted: the
+// message is acknowledged anyway, the next sync publishes again.
+func (w *Worker) notifySync(ctx context.Context, r *packagebug.Result, d time.Duration) {
+	if w.Notifier == nil {
+		return
+	}
+	err := w.Notifier.NotifySync(ctx, packagebug.NewSyncEvent(r, d))
+	if err != nil {
+		notifyFailures.Add(1)
+		packagebug.Logger(ctx).Warn("notify sync failed", "error", err)
+	}
+}
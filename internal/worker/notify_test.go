@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+// recordingNotifier records the sync events.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []packagebug.SyncEvent
+}
+
+func (n *recordingNotifier) NotifySync(ctx context.Context, e packagebug.SyncEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+	return nil
+}
+
+func TestWorkerProcessNotify(t *testing.T) {
+	gh := &fake.GitHub{
+		Issues: []packagebug.Issue{
+			{Number: 1, Title: "crash", State: "open"},
+			{Number: 2, Title: "leak", State: "closed"},
+		},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}
+	p := fake.Package
+	p.Id = "1"
+	w, store, _ := newTestWorker(t, gh, packagebug.FormatMessage(p, 0), packagebug.FormatMessage(p, 0))
+	notifier := &recordingNotifier{}
+	w.Notifier = notifier
+	store.AddPackage(p)
+	// the second sync of the not modified package stores nothing
+	process(t, w, p, 2)
+
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected 1 event got: %+v\n", notifier.events)
+	}
+	e := notifier.events[0]
+	if e.Event != packagebug.SyncCompleted || e.PackagePath != p.Path() {
+		t.Errorf("unexpected event: %+v\n", e)
+	}
+	if e.Issues != 2 || e.Open != 1 || e.Closed != 1 {
+		t.Errorf("expected delta of 1 open and 1 closed issue got: %+v\n", e)
+	}
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// State is the runtime state of the worker reported to the operators.
+type State struct {
+	Paused bool `json:"paused"`
+	// Concurrency is the current adaptive limit of the processes, at most
+	// MaxConcurrency, see Limiter
+	Concurrency    int `json:"concurrency"`
+	MaxConcurrency int `json:"max_concurrency"`
+	Active         int `json:"active"`
+	// InFlight are the paths of the packages being fetched
+	InFlight   []string   `json:"in_flight"`
+	RateLimits []HostRate `json:"rate_limits"`
+}
+
+// Pause stops receiving the messages, e.g. to drain the worker before a
+// deploy. The processes in flight finish their packages, the worker is
+// drained once State reports none in flight.
+func (w *Worker) Pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.resume == nil {
+		w.resume = make(chan struct{})
+	}
+}
+
+// Resume receives the messages again after Pause.
+func (w *Worker) Resume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.resume != nil {
+		close(w.resume)
+		w.resume = nil
+	}
+}
+
+// paused returns the channel closed by Resume, nil if not paused.
+func (w *Worker) paused() chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resume
+}
+
+// PoolReserve is the number of the connections of the database pool left to
+// the store calls of the processes, which hold a connection each for the
+// lock of their package, and to the background jobs.
+const PoolReserve = 5
+
+// SetConcurrency changes the maximum of the concurrent processes at
+// runtime, see Limiter.SetMax. It is bounded by ConcurrencyCeiling, the
+// processes past the pool would wait for a connection forever.
+func (w *Worker) SetConcurrency(max int) error {
+	if max < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	if w.ConcurrencyCeiling > 0 && max > w.ConcurrencyCeiling {
+		return fmt.Errorf("concurrency must not exceed %d of the database pool", w.ConcurrencyCeiling)
+	}
+	if w.Limiter == nil {
+		return errors.New("worker is not running")
+	}
+	w.Limiter.SetMax(max)
+	return nil
+}
+
+// State returns the runtime state of the worker.
+func (w *Worker) State() State {
+	w.mu.Lock()
+	st := State{Paused: w.resume != nil, InFlight: make([]string, 0, len(w.inflight))}
+	for path := range w.inflight {
+		st.InFlight = append(st.InFlight, path)
+	}
+	w.mu.Unlock()
+	slices.Sort(st.InFlight)
+	if w.Limiter != nil {
+		st.Concurrency, st.MaxConcurrency, st.Active = w.Limiter.Stats()
+	}
+	st.RateLimits = []HostRate{}
+	if w.RateLimits != nil {
+		st.RateLimits = w.RateLimits.Hosts()
+	}
+	return st
+}
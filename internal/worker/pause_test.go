@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestWorkerPause(t *testing.T) {
+	w, _, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+	}, "1,github.com,pyk,byten")
+	w.Limiter = NewLimiter(4, 0)
+	w.Pause()
+	if st := w.State(); !st.Paused || st.MaxConcurrency != 4 {
+		t.Errorf("expected paused worker of 4 processes got: %+v\n", st)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// the paused worker receives nothing
+	time.Sleep(50 * time.Millisecond)
+	if len(q.Messages()) != 1 {
+		t.Errorf("expected the message kept while paused got: %d\n", len(q.Messages()))
+	}
+
+	w.Resume()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.Deleted()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected canceled got: %v\n", err)
+	}
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected the message processed once resumed got: %d\n", len(q.Deleted()))
+	}
+	st := w.State()
+	if st.Paused || len(st.InFlight) != 0 {
+		t.Errorf("expected drained worker got: %+v\n", st)
+	}
+	if len(st.RateLimits) != 1 || st.RateLimits[0].Host != "github.com" {
+		t.Errorf("expected rate limit of github.com got: %+v\n", st.RateLimits)
+	}
+
+	err := w.SetConcurrency(0)
+	if err == nil {
+		t.Errorf("expected error of zero concurrency\n")
+	}
+	w.ConcurrencyCeiling = 3
+	err = w.SetConcurrency(4)
+	if err == nil {
+		t.Errorf("expected error of concurrency past the pool\n")
+	}
+	err = w.SetConcurrency(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := w.State(); st.Concurrency != 2 || st.MaxConcurrency != 2 {
+		t.Errorf("expected concurrency 2 got: %+v\n", st)
+	}
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RateLimits is the rate limit state of the hosts shared by the worker
+// processes. The rate limits are per credential of the provider, not per
+// package, so the limit of a host is checked once per poll cycle and
+// consulted before every fetch of the cycle is dispatched, see Reset.
+type RateLimits struct {
+	Providers []provider.Provider
+
+	mu    sync.Mutex
+	hosts map[string]rateLimit
+}
+
+// rateLimit is the checked rate limit of a host.
+type rateLimit struct {
+	remaining int
+	reset     int64
+	err       error
+}
+
+// NewRateLimits creates the rate limits of the hosts of providers.
+func NewRateLimits(providers []provider.Provider) *RateLimits {
+	return &RateLimits{
+		Providers: providers,
+		hosts:     make(map[string]rateLimit),
+	}
+}
+
+// Reset starts the poll cycle, the next Check of every host asks its
+// provider again.
+func (r *RateLimits) Reset() {
+	r.mu.Lock()
+	r.hosts = make(map[string]rateLimit)
+	r.mu.Unlock()
+}
+
+// HostRate is the rate limit of a host checked in the poll cycle.
+type HostRate struct {
+	Host      string `json:"host"`
+	Remaining int    `json:"remaining"`
+	// Reset is the reset time as unix time
+	Reset int64  `json:"reset"`
+	Error string `json:"error,omitempty"`
+}
+
+// Hosts returns the rate limits of the hosts checked in the current poll
+// cycle ordered by host.
+func (r *RateLimits) Hosts() []HostRate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make([]HostRate, 0, len(r.hosts))
+	for host, l := range r.hosts {
+		h := HostRate{Host: host, Remaining: l.remaining, Reset: l.reset}
+		if l.err != nil {
+			h.Error = l.err.Error()
+		}
+		hosts = append(hosts, h)
+	}
+	slices.SortFunc(hosts, func(a, b HostRate) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+	return hosts
+}
+
+// Check returns the remaining requests to host and the reset time as unix
+// time, checked once per poll cycle. The failed check is not repeated
+// within the cycle either.
+func (r *RateLimits) Check(ctx context.Context, host string) (int, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.hosts[host]; ok {
+		return l.remaining, l.reset, l.err
+	}
+	var l rateLimit
+	prov := provider.Find(r.Providers, host)
+	if prov == nil {
+		l = rateLimit{remaining: -1, reset: -1, err: errors.New("host not supported")}
+	} else {
+		ctx, span := packagebug.StartSpan(ctx, "rate_limit",
+			attribute.String("host", host))
+		l.remaining, l.reset, l.err = prov.RateLimit(ctx, host)
+		span.SetAttributes(attribute.Int("remaining", l.remaining))
+		packagebug.EndSpan(span, l.err)
+	}
+	r.hosts[host] = l
+	return l.remaining, l.reset, l.err
+}
@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+)
+
+// countingProvider counts the rate limit checks.
+type countingProvider struct {
+	checks int
+}
+
+func (p *countingProvider) Name() string         { return "counting" }
+func (p *countingProvider) Has(host string) bool { return host == "github.com" }
+
+func (p *countingProvider) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	p.checks++
+	return 42, 1500000000, nil
+}
+
+func (p *countingProvider) FetchIssues(ctx context.Context, pkg packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	return nil, nil
+}
+
+func TestRateLimitsCheck(t *testing.T) {
+	prov := &countingProvider{}
+	r := NewRateLimits([]provider.Provider{prov})
+	ctx := context.Background()
+
+	// the host is checked once per poll cycle
+	for i := 0; i < 3; i++ {
+		rate, reset, err := r.Check(ctx, "github.com")
+		if err != nil || rate != 42 || reset != 1500000000 {
+			t.Fatalf("unexpected rate limit %d reset %d: %v\n", rate, reset, err)
+		}
+	}
+	if prov.checks != 1 {
+		t.Errorf("expected 1 check got: %d\n", prov.checks)
+	}
+	r.Reset()
+	r.Check(ctx, "github.com")
+	if prov.checks != 2 {
+		t.Errorf("expected 2 checks after reset got: %d\n", prov.checks)
+	}
+
+	_, _, err := r.Check(ctx, "example.com")
+	if err == nil {
+		t.Errorf("expected error of the unsupported host\n")
+	}
+}
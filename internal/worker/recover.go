@@ -0,0 +1,24 @@
+package worker
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+)
+
+// safeProcess calls process and recovers its panic as the error of the
+// panic class, so the malformed response of one package doesn't crash the
+// worker. The stack is logged with the package path and the message is
+// redelivered like any other failure, see retry.
+func (w *Worker) safeProcess(ctx context.Context, p packagebug.Package, msg *queue.Message) (n int, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			packagebug.Logger(ctx).Error("process panicked", "panic", v,
+				"stack", string(debug.Stack()))
+			n, err = 0, packagebug.PanicError(v)
+		}
+	}()
+	return w.process(ctx, p, msg)
+}
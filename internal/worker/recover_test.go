@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+)
+
+// panicProvider panics on every fetch.
+type panicProvider struct{}
+
+func (panicProvider) Name() string         { return "panicking" }
+func (panicProvider) Has(host string) bool { return host == "example.org" }
+
+func (panicProvider) RateLimit(ctx context.Context, host string) (int, int64, error) {
+	return 1, 0, nil
+}
+
+func (panicProvider) FetchIssues(ctx context.Context, p packagebug.Package, since time.Time, etag string) (*packagebug.Result, error) {
+	var issues map[int]packagebug.Issue
+	issues[1] = packagebug.Issue{}
+	return nil, nil
+}
+
+func TestWorkerProcessPanic(t *testing.T) {
+	w, store, q := newTestWorker(t, nil, "1,example.org,pyk,byten")
+	w.Providers = []provider.Provider{panicProvider{}}
+	p := packagebug.Package{Id: "1", Host: "example.org", Owner: "pyk", Repo: "byten"}
+	process(t, w, p, 1)
+
+	logs := store.FetchLogs()
+	if len(logs) != 1 || !strings.Contains(logs[0].Error, "panic") {
+		t.Fatalf("expected the fetch log of the panic got: %+v\n", logs)
+	}
+	m, ok := failures.Get("panicking").(*expvar.Map)
+	if !ok || counter(m, "panic") != 1 {
+		t.Errorf("expected 1 panic counted got: %v\n", m)
+	}
+	// the message is redelivered once its visibility timeout is over
+	if deleted := q.Deleted(); len(deleted) != 0 {
+		t.Errorf("expected message not deleted got: %v\n", deleted)
+	}
+}
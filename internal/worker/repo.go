@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+)
+
+// syncRepo fetches and stores the repository metadata of the package if its
+// provider exposes it. The metadata is best effort, the failure is only
+// logged.
+func (w *Worker) syncRepo(ctx context.Context, p packagebug.Package) {
+	logger := packagebug.Logger(ctx)
+	rf, ok := provider.Find(w.providersOf(p), p.Source().Host).(provider.RepoFetcher)
+	if !ok {
+		return
+	}
+	etag, err := w.Store.GetRepoEtag(p)
+	if err != nil {
+		logger.Warn("get repository etag failed", "error", err)
+	}
+	repo, err := rf.FetchRepo(ctx, p, etag)
+	if err != nil {
+		logger.Warn("fetch repository failed", "error", err)
+		return
+	}
+	if repo == nil {
+		return
+	}
+	if w.DryRun {
+		logger.Info("dry run: repository", "stars", repo.Stars,
+			"forks", repo.Forks, "archived", repo.Archived)
+		return
+	}
+	err = w.Store.SaveRepo(p, *repo)
+	if err != nil {
+		logger.Warn("save repository failed", "error", err)
+	}
+}
+
+// syncReleases fetches and stores the releases of the package if its
+// provider exposes them, the store ties the issues to the release windows.
+// The releases are best effort like the repository metadata.
+func (w *Worker) syncReleases(ctx context.Context, p packagebug.Package) {
+	logger := packagebug.Logger(ctx)
+	rf, ok := provider.Find(w.providersOf(p), p.Source().Host).(provider.ReleaseFetcher)
+	if !ok {
+		return
+	}
+	etag, err := w.Store.GetReleasesEtag(p)
+	if err != nil {
+		logger.Warn("get releases etag failed", "error", err)
+	}
+	releases, err := rf.FetchReleases(ctx, p, etag)
+	if err != nil {
+		logger.Warn("fetch releases failed", "error", err)
+		return
+	}
+	if releases == nil {
+		return
+	}
+	if w.DryRun {
+		logger.Info("dry run: releases", "releases", len(releases.Releases))
+		return
+	}
+	err = w.Store.SaveReleases(p, *releases)
+	if err != nil {
+		logger.Warn("save releases failed", "error", err)
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// VerifyTTL is how long the ownership verification is valid.
+const VerifyTTL = 24 * time.Hour
+
+// applySettings returns the package with its custom settings applied. The
+// settings are ignored unless one of the verifiers confirms the ownership.
+// The verification state is stored and reused until VerifyTTL passed, the
+// dry run verifies every time instead.
+func (w *Worker) applySettings(ctx context.Context, p packagebug.Package) (packagebug.Package, error) {
+	s, err := w.Store.GetSettings(p)
+	if err != nil {
+		return p, err
+	}
+	p.BugLabels = s.BugLabels
+	p.Query = w.Query.Override(s.Query)
+	if s.Empty() {
+		return p, nil
+	}
+
+	verified := s.Verified && time.Since(s.VerifiedAt) < VerifyTTL
+	if !verified {
+		verified, err = w.verify(ctx, p, s)
+		if err != nil {
+			return p, err
+		}
+		if !w.DryRun {
+			err = w.Store.SetVerified(p, verified)
+			if err != nil {
+				return p, err
+			}
+		}
+	}
+	if !verified {
+		packagebug.Logger(ctx).Warn("package settings ignored: ownership not verified")
+		return p, nil
+	}
+
+	p.Labels = s.Labels
+	p.Token = s.Token
+	return p, nil
+}
+
+// verify returns true if any of the verifiers confirms the ownership.
+func (w *Worker) verify(ctx context.Context, p packagebug.Package, s packagebug.Settings) (bool, error) {
+	client := w.githubOf(p)
+	if client == nil {
+		return false, nil
+	}
+	var errs []string
+	for _, v := range w.Verifiers {
+		ok, err := v.Verify(ctx, client, p, s)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	if len(errs) > 0 {
+		return false, errors.New(strings.Join(errs, "; "))
+	}
+	return false, nil
+}
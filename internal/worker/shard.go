@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard is the partition of the packages processed by a worker group. The
+// package belongs to the shard where its id hashes into, so every group
+// processes a deterministic subset of the packages without coordination.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses the shard in the form of index/count, e.g. 0/4 is the
+// first of four shards. Empty string is the single shard that owns all the
+// packages.
+func ParseShard(s string) (Shard, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Shard{Index: 0, Count: 1}, nil
+	}
+	index, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected index/count", s)
+	}
+	var sh Shard
+	var err error
+	sh.Index, err = strconv.Atoi(index)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard index %q", index)
+	}
+	sh.Count, err = strconv.Atoi(count)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard count %q", count)
+	}
+	if sh.Count < 1 || sh.Index < 0 || sh.Index >= sh.Count {
+		return Shard{}, fmt.Errorf("invalid shard %q, index must be in [0, count)", s)
+	}
+	return sh, nil
+}
+
+// Owns returns true if the package id hashes into the shard.
+func (sh Shard) Owns(id string) bool {
+	if sh.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32()%uint32(sh.Count)) == sh.Index
+}
+
+func (sh Shard) String() string {
+	return fmt.Sprintf("%d/%d", sh.Index, sh.Count)
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	sh, err := ParseShard("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh.Count != 1 || !sh.Owns("42") {
+		t.Errorf("expected single shard got: %s\n", sh)
+	}
+	sh, err = ParseShard("2/4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh.Index != 2 || sh.Count != 4 {
+		t.Errorf("expected 2/4 got: %s\n", sh)
+	}
+	for _, s := range []string{"4/4", "-1/4", "1", "a/b", "0/0"} {
+		_, err = ParseShard(s)
+		if err == nil {
+			t.Errorf("expected error for %q\n", s)
+		}
+	}
+}
+
+func TestShardOwns(t *testing.T) {
+	shards := []Shard{{0, 3}, {1, 3}, {2, 3}}
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		owners := 0
+		for _, sh := range shards {
+			if sh.Owns(id) {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Errorf("expected exactly 1 owner of %s got: %d\n", id, owners)
+		}
+		// deterministic
+		if shards[0].Owns(id) != shards[0].Owns(id) {
+			t.Errorf("expected deterministic owner of %s\n", id)
+		}
+	}
+}
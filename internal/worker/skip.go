@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// the reasons of the skipped fetches recorded in the fetch log
+const (
+	SkipArchived   = "archived"
+	SkipFork       = "fork"
+	SkipForkPolicy = "fork_policy"
+	// SkipMoved is the package whose repository moved to the path of
+	// another package of its tenant, that package fetches the bugs
+	SkipMoved = "moved"
+)
+
+// SkipRules skip the packages whose fetch wastes the quota. The rules only
+// apply once the repository metadata of the package is fetched.
+type SkipRules struct {
+	// Archived skips the archived repositories
+	Archived bool
+	// ForkMinStars skips the forks with fewer stars, zero disables
+	ForkMinStars int
+}
+
+// SkipError is returned by process if the package is skipped, the message
+// is acknowledged and the reason recorded in the fetch log.
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return "skipped: " + e.Reason
+}
+
+// skipReason returns why the package is skipped by w.Skip, empty if it is
+// fetched. The skip is checked against the refreshed metadata, so the
+// unarchived repository or the fork that gained stars is fetched again.
+func (w *Worker) skipReason(ctx context.Context, p packagebug.Package) string {
+	reason := w.Skip.reason(ctx, w.Store, p)
+	if reason == "" {
+		return ""
+	}
+	w.syncRepo(ctx, p)
+	return w.Skip.reason(ctx, w.Store, p)
+}
+
+func (s SkipRules) reason(ctx context.Context, store Store, p packagebug.Package) string {
+	if !s.Archived && s.ForkMinStars == 0 {
+		return ""
+	}
+	logger := packagebug.Logger(ctx)
+	repo, err := store.GetRepo(p)
+	if err != nil {
+		logger.Warn("get repository failed", "error", err)
+		return ""
+	}
+	if repo.FetchedAt.IsZero() {
+		return ""
+	}
+	if s.Archived && repo.Archived {
+		return SkipArchived
+	}
+	if repo.Stars >= s.ForkMinStars {
+		return ""
+	}
+	f, err := store.GetFork(p)
+	if err != nil {
+		logger.Warn("get fork failed", "error", err)
+		return ""
+	}
+	if f.Parent != "" {
+		return SkipFork
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestWorkerProcessSkipArchived(t *testing.T) {
+	w, store, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+	}, "1,github.com,pyk,byten")
+	w.Skip = SkipRules{Archived: true}
+	store.SaveRepo(fake.Package, packagebug.Repo{Archived: true})
+	process(t, w, fake.Package, 1)
+	if len(store.Saved()) != 0 {
+		t.Error("expected the archived package not fetched")
+	}
+	logs := store.FetchLogs()
+	if len(logs) != 1 || logs[0].Skipped != SkipArchived || logs[0].Error != "" {
+		t.Errorf("expected the skip recorded got: %+v\n", logs)
+	}
+	if len(q.Deleted()) != 1 {
+		t.Error("expected the message of the skipped package deleted")
+	}
+}
+
+func TestSkipRulesReason(t *testing.T) {
+	ctx := context.Background()
+	store := fake.NewStore()
+	rules := SkipRules{Archived: true, ForkMinStars: 10}
+	if r := rules.reason(ctx, store, fake.Package); r != "" {
+		t.Errorf("expected the package without metadata fetched got: %s\n", r)
+	}
+	store.SaveRepo(fake.Package, packagebug.Repo{Stars: 3})
+	if r := rules.reason(ctx, store, fake.Package); r != "" {
+		t.Errorf("expected the package that isn't a fork fetched got: %s\n", r)
+	}
+	store.SetForkParent(fake.Package, "github.com/other/byten")
+	if r := rules.reason(ctx, store, fake.Package); r != SkipFork {
+		t.Errorf("expected the trivial fork skipped got: %s\n", r)
+	}
+	store.SaveRepo(fake.Package, packagebug.Repo{Stars: 10})
+	if r := rules.reason(ctx, store, fake.Package); r != "" {
+		t.Errorf("expected the starred fork fetched got: %s\n", r)
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/pyk/packagebug-worker"
+)
+
+// resolveRoot returns the package to fetch for the subpackage of a
+// repository, e.g. github.com/aws/aws-sdk-go/service/sqs: the package of the
+// repository if it is a package too, so the issues of the repository are
+// fetched once for all of its subpackages. The subpackage is linked to it
+// unless in dry run. Otherwise the subpackage is fetched from its repository itself.
+func (w *Worker) resolveRoot(ctx context.Context, p packagebug.Package) packagebug.Package {
+	if p.Subpath == "" {
+		return p
+	}
+	logger := packagebug.Logger(ctx)
+	root, ok, err := w.Store.FindPackage(p.Tenant, p.Repository().Path())
+	if err != nil {
+		logger.Warn("find subpackage root failed", "error", err)
+		return p
+	}
+	if !ok {
+		return p
+	}
+	if !w.DryRun {
+		err = w.Store.SetPackageRoot(p, root)
+		if err != nil {
+			logger.Warn("link subpackage root failed", "error", err)
+		}
+	}
+	logger.Debug("subpackage fetched from its root", "root_path", root.Path())
+	return root
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestWorkerResolveRoot(t *testing.T) {
+	store := fake.NewStore()
+	w := &Worker{Store: store}
+	sub := packagebug.Package{Id: "2", Host: "github.com", Owner: "aws",
+		Repo: "aws-sdk-go", Subpath: "service/sqs"}
+
+	// the repository is not a package: the subpackage is fetched itself
+	p := w.resolveRoot(context.Background(), sub)
+	if p.Id != "2" {
+		t.Errorf("expected the subpackage got: %+v\n", p)
+	}
+
+	root := packagebug.Package{Id: "1", Host: "github.com", Owner: "aws",
+		Repo: "aws-sdk-go"}
+	store.AddPackage(root)
+	p = w.resolveRoot(context.Background(), sub)
+	if p.Id != "1" || p.Subpath != "" {
+		t.Errorf("expected the root package got: %+v\n", p)
+	}
+	if id := store.Root(sub); id != "1" {
+		t.Errorf("expected subpackage linked to 1 got: %q\n", id)
+	}
+}
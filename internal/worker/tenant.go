@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+// Tenant is a source or team whose packages are crawled by the deployment
+// with its own credentials and page budget, see packagebug.Package.Tenant.
+type Tenant struct {
+	Name string
+	// GitHub is the client of github.com with the tokens of the tenant, the
+	// client of the worker if nil
+	GitHub *github.Client
+	// Providers fetch the bugs of the packages of the tenant, the providers
+	// of the worker if nil
+	Providers []provider.Provider
+	// PagesPerHour is the maximum pages fetched for the tenant per hour by
+	// this worker, zero is unlimited. The pages are counted in memory, the
+	// deployment of n workers fetches up to n times the cap
+	PagesPerHour int
+
+	rateLimits *RateLimits
+
+	mu     sync.Mutex
+	window time.Time
+	pages  int
+}
+
+// allow reports whether the tenant has pages left in the hour of now,
+// otherwise how long until the next hour.
+func (t *Tenant) allow(now time.Time) (bool, time.Duration) {
+	if t.PagesPerHour <= 0 {
+		return true, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.window) >= time.Hour {
+		return true, 0
+	}
+	if t.pages < t.PagesPerHour {
+		return true, 0
+	}
+	return false, t.window.Add(time.Hour).Sub(now)
+}
+
+// spend counts the pages fetched for the tenant at now.
+func (t *Tenant) spend(now time.Time, pages int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.window) >= time.Hour {
+		t.window = now
+		t.pages = 0
+	}
+	t.pages += pages
+}
+
+// tenantOf returns the tenant of the package, nil for the packages of the
+// deployment itself. The ok is false if the tenant is unknown.
+func (w *Worker) tenantOf(p packagebug.Package) (*Tenant, bool) {
+	if p.Tenant == "" {
+		return nil, true
+	}
+	t, ok := w.Tenants[p.Tenant]
+	return t, ok
+}
+
+// providersOf returns the providers of the package, the providers of its
+// tenant if it has its own.
+func (w *Worker) providersOf(p packagebug.Package) []provider.Provider {
+	if t, _ := w.tenantOf(p); t != nil && t.Providers != nil {
+		return t.Providers
+	}
+	return w.Providers
+}
+
+// rateLimitsOf returns the rate limits of the credentials of the package.
+func (w *Worker) rateLimitsOf(p packagebug.Package) *RateLimits {
+	if t, _ := w.tenantOf(p); t != nil && t.rateLimits != nil {
+		return t.rateLimits
+	}
+	return w.RateLimits
+}
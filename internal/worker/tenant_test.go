@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+func TestTenantBudget(t *testing.T) {
+	tenant := &Tenant{Name: "acme", PagesPerHour: 10}
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	if ok, _ := tenant.allow(now); !ok {
+		t.Fatalf("expected the new tenant allowed\n")
+	}
+	tenant.spend(now, 6)
+	tenant.spend(now.Add(time.Minute), 4)
+	ok, wait := tenant.allow(now.Add(20 * time.Minute))
+	if ok || wait != 40*time.Minute {
+		t.Errorf("expected spent budget to wait 40m got: %v %s\n", ok, wait)
+	}
+	// the budget is renewed every hour
+	if ok, _ := tenant.allow(now.Add(time.Hour)); !ok {
+		t.Errorf("expected budget of the next hour\n")
+	}
+
+	unlimited := &Tenant{Name: "globex"}
+	unlimited.spend(now, 1000)
+	if ok, _ := unlimited.allow(now); !ok {
+		t.Errorf("expected unlimited tenant allowed\n")
+	}
+}
+
+func TestWorkerTenants(t *testing.T) {
+	shared := github.NewClient(nil, 1)
+	own := github.NewClient([]string{"acme-token"}, 1)
+	w := &Worker{
+		GitHub:    shared,
+		Providers: []provider.Provider{shared},
+		Tenants: map[string]*Tenant{
+			"acme":   {Name: "acme", GitHub: own, Providers: []provider.Provider{own}},
+			"globex": {Name: "globex"},
+		},
+	}
+	p := packagebug.Package{Host: "github.com", Owner: "pyk", Repo: "byten"}
+
+	for _, tc := range []struct {
+		tenant string
+		client *github.Client
+	}{
+		{"", shared},
+		{"acme", own},
+		{"globex", shared},
+	} {
+		p.Tenant = tc.tenant
+		if c := w.githubOf(p); c != tc.client {
+			t.Errorf("unexpected client of tenant %q\n", tc.tenant)
+		}
+		prov := provider.Find(w.providersOf(p), p.Host)
+		if prov != tc.client {
+			t.Errorf("unexpected provider of tenant %q\n", tc.tenant)
+		}
+	}
+
+	p.Tenant = "initech"
+	if _, ok := w.tenantOf(p); ok {
+		t.Errorf("expected unknown tenant\n")
+	}
+	if len(w.githubClients()) != 2 {
+		t.Errorf("expected the clients of the tenants got: %d\n", len(w.githubClients()))
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/vanity"
+)
+
+// supported returns true if the bugs of the host can be fetched.
+func (w *Worker) supported(host string) bool {
+	return provider.Find(w.Providers, host) != nil
+}
+
+// resolveVanity sets the upstream of the package with vanity import path,
+// e.g. golang.org/x/net, to the first supported repository of its go-import
+// or go-source meta tags. The package is returned unchanged if it can't be
+// resolved.
+func (w *Worker) resolveVanity(ctx context.Context, p packagebug.Package) packagebug.Package {
+	if w.Resolver == nil || p.Upstream != nil || w.supported(p.Host) {
+		return p
+	}
+	logger := packagebug.Logger(ctx)
+	repos, err := w.Resolver.Resolve(ctx, p)
+	if err != nil {
+		logger.Warn("resolve vanity import path failed", "error", err)
+		return p
+	}
+	for _, repo := range repos {
+		if w.supported(repo.Host) {
+			logger.Debug("vanity import path resolved", "upstream_path", repo.Path())
+			p.Upstream = &repo
+			return p
+		}
+	}
+	return p
+}
+
+// resolveModule returns the package p of the message of the module path, see
+// packagebug.FormatModule. The module of a supported host is the path of
+// its repository, e.g. github.com/pyk/byten/v2, the other modules are
+// resolved to the first supported repository of the resolver. The module
+// with a vanity path of owner and repo keeps it and is fetched from its
+// repository like resolveVanity, e.g. golang.org/x/net, the others are the
+// package of their repository, e.g. gopkg.in/yaml.v3. The module without a
+// supported repository is packagebug.ErrNotFound, it never resolves.
+func (w *Worker) resolveModule(ctx context.Context, module string, p packagebug.Package) (packagebug.Package, error) {
+	resolved, perr := packagebug.ParsePath(vanity.TrimMajor(module))
+	if perr == nil && w.supported(resolved.Host) {
+		resolved.Id, resolved.Tenant, resolved.Cursor = p.Id, p.Tenant, p.Cursor
+		return resolved, nil
+	}
+	if w.Resolver == nil {
+		return p, fmt.Errorf("module %q of unsupported host: %w", module, packagebug.ErrNotFound)
+	}
+	repos, err := w.Resolver.ResolveModule(ctx, module)
+	if err != nil {
+		return p, err
+	}
+	for _, repo := range repos {
+		if !w.supported(repo.Host) {
+			continue
+		}
+		packagebug.Logger(ctx).Debug("module path resolved", "module", module,
+			"upstream_path", repo.Path())
+		if perr == nil {
+			upstream := repo
+			upstream.Subpath = ""
+			resolved.Upstream = &upstream
+		} else {
+			resolved = repo
+		}
+		resolved.Id, resolved.Tenant, resolved.Cursor = p.Id, p.Tenant, p.Cursor
+		return resolved, nil
+	}
+	return p, fmt.Errorf("module %q has no supported repository: %w", module, packagebug.ErrNotFound)
+}
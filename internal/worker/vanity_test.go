@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/vanity"
+)
+
+func TestWorkerResolveModule(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s/x/net git https://github.com/golang/net"></head></html>`, r.Host)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	client := github.NewClient(nil, 1)
+	w := &Worker{GitHub: client, Providers: []provider.Provider{client}}
+	ctx := context.Background()
+
+	// the module of a supported host needs no resolver
+	m, _ := packagebug.ParseMessage("1,github.com/pyk/byten/v2,0,,,acme")
+	p, err := w.resolveModule(ctx, m.Module, m.Package)
+	if err != nil || p.Path() != "github.com/pyk/byten" || p.Id != "1" || p.Tenant != "acme" {
+		t.Errorf("unexpected package: %+v %v\n", p, err)
+	}
+	m, _ = packagebug.ParseMessage("1," + u.Host + "/x/net/http2")
+	_, err = w.resolveModule(ctx, m.Module, m.Package)
+	if !errors.Is(err, packagebug.ErrNotFound) {
+		t.Errorf("expected not found of the vanity module without resolver got: %v\n", err)
+	}
+
+	// the vanity module keeps its path and is fetched from its repository
+	w.Resolver = vanity.New()
+	w.Resolver.Scheme = "http"
+	w.Resolver.Proxy = ""
+	p, err = w.resolveModule(ctx, m.Module, m.Package)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Path() != u.Host+"/x/net/http2" || p.Upstream == nil || p.Upstream.Path() != "github.com/golang/net" {
+		t.Errorf("unexpected package: %+v %+v\n", p, p.Upstream)
+	}
+}
+
+func TestWorkerRunModule(t *testing.T) {
+	// the module of the unsupported host never resolves without resolver
+	w, store, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}, "1,github.com/pyk/byten/v2,0,,,acme", "2,example.com/x/net")
+	w.Tenants = map[string]*Tenant{"acme": {Name: "acme"}}
+	run(w, q, 2)
+
+	if len(q.Deleted()) != 2 {
+		t.Fatalf("expected both messages deleted got: %+v\n", q.Deleted())
+	}
+	saved := store.Saved()
+	if len(saved) != 1 {
+		t.Fatalf("expected 1 saved result got: %d\n", len(saved))
+	}
+	p := saved[0].Package
+	if p.Path() != "github.com/pyk/byten" || p.Id != "1" || p.Tenant != "acme" {
+		t.Errorf("expected the package of the module got: %+v\n", p)
+	}
+}
@@ -0,0 +1,729 @@
+// Package worker consumes the package messages from the queue, fetches the
+// bugs of the packages and stores them.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+	"github.com/pyk/packagebug-worker/internal/queue"
+	"github.com/pyk/packagebug-worker/internal/vanity"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Store persists the packages, their settings and the fetched bugs.
+type Store interface {
+	packagebug.Store
+
+	// GetSettings get the custom settings of the package.
+	GetSettings(p packagebug.Package) (packagebug.Settings, error)
+	// SetVerified stores the ownership verification state of the package.
+	SetVerified(p packagebug.Package, verified bool) error
+	// GetCapabilities get the capabilities of the package limited by max.
+	GetCapabilities(p packagebug.Package, max packagebug.Capability) (packagebug.Capability, error)
+	// GetFork get the stored fork relationship of the package.
+	GetFork(p packagebug.Package) (packagebug.Fork, error)
+	// SetForkParent stores the parent repository of the package.
+	SetForkParent(p packagebug.Package, parent string) error
+	// FindPackage returns the package of the path crawled for the tenant,
+	// false if not a package.
+	FindPackage(tenant, path string) (packagebug.Package, bool, error)
+	// SetPackageRoot links the subpackage to the package of its repository.
+	SetPackageRoot(p, root packagebug.Package) error
+	// SaveIssues stores the issues without changing the sync state, e.g.
+	// the partial result of the sync that spilled over the budget.
+	SaveIssues(r *packagebug.Result) error
+	// SaveFetchLog stores the fetch log.
+	SaveFetchLog(l packagebug.FetchLog) error
+	// LoadRateStates & SaveRateStates persist the rate limit state of the
+	// tokens mapped by the token id.
+	LoadRateStates(ids []string) (map[string]packagebug.RateState, error)
+	SaveRateStates(states map[string]packagebug.RateState) error
+	// GetRepoEtag & SaveRepo persist the repository metadata of the
+	// package, see packagebug.Repo.
+	GetRepoEtag(p packagebug.Package) (string, error)
+	SaveRepo(p packagebug.Package, r packagebug.Repo) error
+	// GetRepo returns the stored repository metadata of the package, zero
+	// FetchedAt if never fetched.
+	GetRepo(p packagebug.Package) (packagebug.Repo, error)
+	// GetReleasesEtag & SaveReleases persist the releases of the package
+	// and the release windows of its issues, see packagebug.Releases.
+	GetReleasesEtag(p packagebug.Package) (string, error)
+	SaveReleases(p packagebug.Package, r packagebug.Releases) error
+	// SaveAdvisories replaces the stored security advisories of the
+	// package.
+	SaveAdvisories(p packagebug.Package, advisories []packagebug.Advisory) error
+	// DeleteIssues deletes the issues and the sync state of the package.
+	DeleteIssues(p packagebug.Package) error
+	// ResetSync clears the etag and the since of the package, so the next
+	// fetch is unconditional and full.
+	ResetSync(p packagebug.Package) error
+	// MovePackage moves the package whose repository moved to the path of
+	// to, the former path is kept as alias.
+	MovePackage(p, to packagebug.Package) error
+	// GetStatus returns the sync state of the package, see Worker.DedupeWindow.
+	GetStatus(p packagebug.Package) (packagebug.Status, error)
+	// Lock takes the lock of the package shared by all workers, so only
+	// one fetch of the package runs at a time. It returns false if the lock
+	// is held by other worker, otherwise unlock releases it.
+	Lock(ctx context.Context, p packagebug.Package) (unlock func(), ok bool, err error)
+	// Ping checks the store is available.
+	Ping(ctx context.Context) error
+}
+
+// Worker holds the dependencies shared by all worker processes.
+type Worker struct {
+	// GitHub is also used to verify the owners and resolve the forks
+	GitHub *github.Client
+	// Providers fetch the bugs, the first provider that has the host of the
+	// package is used
+	Providers []provider.Provider
+	Store     Store
+	Queue     queue.Queue
+	Acker     *Acker
+	// Buffer keeps the results while the database is unavailable, the
+	// results aren't buffered if nil
+	Buffer *Buffer
+	// Limiter bounds the concurrent processes, 10 if nil
+	Limiter *Limiter
+	// ConcurrencyCeiling bounds the concurrency set at runtime, see
+	// SetConcurrency. The lock of every process holds a connection of the
+	// Postgres pool, the pool of N connections allows N-PoolReserve
+	// processes. zero is unbounded.
+	ConcurrencyCeiling int
+	// RateLimits is the rate limit state of the hosts, checked once per
+	// poll cycle. It is created from Providers if nil.
+	RateLimits *RateLimits
+	// Tenants are the tenants by name, the messages of the packages of the
+	// unknown tenants are skipped, see Tenant
+	Tenants map[string]*Tenant
+	// Breakers are optional, the messages are delayed while the breaker of
+	// the host is open and the consumption is paused while the breaker of
+	// the database is open
+	Breakers  *Breakers
+	Verifiers []github.Verifier
+	// Capabilities is the maximum capabilities of every package
+	Capabilities packagebug.Capability
+	// Query is the issue list query of packages without their own, see
+	// packagebug.Query
+	Query packagebug.Query
+	// ForkPolicy is the fork policy of packages without their own
+	ForkPolicy packagebug.ForkPolicy
+	// Severity classifies the issues, packagebug.DefaultSeverityRules if
+	// nil
+	Severity packagebug.SeverityRules
+	// DryRun logs the upserts instead of storing the result and keeps the
+	// message in the queue
+	DryRun bool
+	// VisibilityTimeout is extended while the message is processed, see
+	// Heartbeat. DefaultVisibilityTimeout if zero.
+	VisibilityTimeout time.Duration
+	// Shard is the partition of the packages processed by the worker
+	Shard Shard
+	// Skip skips the archived repositories and the trivial forks
+	Skip SkipRules
+	// PullRequests stores the pull requests labeled as bug alongside the
+	// issues, they are excluded by default
+	PullRequests bool
+	// Resolver is optional, it resolves the vanity import paths to their
+	// repository
+	Resolver *vanity.Resolver
+	// Budget limits every sync, the sync that spills over it is continued
+	// by another message of the package
+	Budget packagebug.Budget
+	// Notifier is optional, it publishes the completed syncs
+	Notifier Notifier
+	// Advisories is optional, it fetches the security advisories of every
+	// synced package
+	Advisories AdvisoryFetcher
+	// Cache is optional, it stores the responses of the stored results, see
+	// packagebug.ResponseCache
+	Cache packagebug.ResponseCache
+	// ReceiveWait is the long poll of the receives, DefaultReceiveWait if
+	// zero
+	ReceiveWait time.Duration
+	// IdleBackoff is the maximum sleep between the empty receives, see
+	// IdleDelay
+	IdleBackoff time.Duration
+	// DedupeWindow skips the fetch of the package fetched successfully
+	// within it, e.g. enqueued several times within minutes. zero disables.
+	DedupeWindow time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]bool
+	// resume is closed by Resume, nil unless paused
+	resume chan struct{}
+}
+
+// Run consumes the messages until ctx is done. The acker, the buffer and the
+// rate limit state persistence run alongside the worker processes.
+func (w *Worker) Run(ctx context.Context) error {
+	// warm-start the rate limit state from the last run
+	for _, c := range w.githubClients() {
+		states, err := w.Store.LoadRateStates(c.TokenIds())
+		if err != nil {
+			slog.Warn("load rate limit state failed", "error", err)
+		}
+		c.SetRateStates(states)
+	}
+	if !w.DryRun {
+		go w.runRateState(ctx, 30*time.Second)
+		if w.Buffer != nil {
+			go w.Buffer.Run(ctx, w.Store, w.Queue, 30*time.Second)
+		}
+	}
+
+	// acknowledged messages are deleted in batches until the processes in
+	// flight are done, their acks would block otherwise
+	if w.Acker == nil {
+		w.Acker = NewAcker(w.Queue, time.Second)
+	}
+	wg := new(sync.WaitGroup)
+	actx, stopAcker := context.WithCancel(context.WithoutCancel(ctx))
+	acked := make(chan struct{})
+	go func() {
+		w.Acker.Run(actx)
+		close(acked)
+	}()
+	defer func() {
+		wg.Wait()
+		stopAcker()
+		<-acked
+	}()
+	if w.Limiter == nil {
+		w.Limiter = NewLimiter(10, 0)
+	}
+	if w.RateLimits == nil {
+		w.RateLimits = NewRateLimits(w.Providers)
+	}
+	if w.VisibilityTimeout <= 0 {
+		w.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	for _, t := range w.Tenants {
+		if t.Providers != nil {
+			t.rateLimits = NewRateLimits(t.Providers)
+		}
+	}
+
+	wait := w.ReceiveWait
+	if wait <= 0 {
+		wait = DefaultReceiveWait
+	}
+
+	empty := 0
+	for ctx.Err() == nil {
+		// the paused worker receives nothing until resumed, the processes
+		// in flight finish their packages, see Pause
+		if resume := w.paused(); resume != nil {
+			slog.Info("consumption paused")
+			select {
+			case <-resume:
+				slog.Info("consumption resumed")
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		// the messages would fail anyway while the database is down
+		if ok, wait := w.allow(BreakerDB); !ok {
+			slog.Warn("database circuit breaker open. consumption paused", "wait", wait)
+			pause(ctx, wait)
+			continue
+		}
+
+		// long poll until messages received, the processing of the messages
+		// is traced as children of the receive. The wake channel is taken
+		// before, so the message sent during the receive ends the idle
+		// sleep.
+		wake := w.waker()
+		rctx, span := packagebug.StartSpan(ctx, "queue.receive")
+		msgs, err := w.Queue.Receive(rctx, queue.MaxBatch, wait)
+		span.SetAttributes(attribute.Int("messages", len(msgs)))
+		packagebug.EndSpan(span, err)
+		if err != nil {
+			slog.Error("receive message failed", "error", err)
+			continue
+		}
+
+		// only process if message exists, otherwise retry the request
+		// after the idle backoff
+		if len(msgs) == 0 {
+			empty++
+			if empty == 1 {
+				slog.Debug("queue is empty. idle backoff started")
+			}
+			w.idle(ctx, empty, wake)
+			continue
+		}
+		if empty > 0 {
+			slog.Debug("queue is not empty. idle backoff ended", "empty_receives", empty)
+			empty = 0
+		}
+		// the rate limits are checked once per poll cycle
+		w.RateLimits.Reset()
+		for _, t := range w.Tenants {
+			if t.rateLimits != nil {
+				t.rateLimits.Reset()
+			}
+		}
+
+		// get package info from message body, the packages of higher
+		// priority are processed first
+		var jobs []Job
+		for _, m := range msgs {
+			pm, err := packagebug.ParseMessage(m.Body)
+			if err != nil {
+				slog.Warn("invalid message body", "message_id", m.Id,
+					"error", err)
+				continue
+			}
+			jobs = append(jobs, Job{Package: pm.Package, Priority: pm.Priority,
+				Action: pm.Action, Message: m, Module: pm.Module})
+		}
+		SortJobs(jobs)
+
+		// fan out the messages to the worker processes
+		for _, job := range jobs {
+			p, m := job.Package, job.Message
+			// every message has its own logger with correlation id
+			logger := slog.With("correlation_id", packagebug.NewCorrelationId(),
+				"message_id", m.Id, "package_path", job.Path(),
+				"priority", job.Priority, "action", job.Action)
+			ctx := packagebug.WithLogger(rctx, logger)
+
+			// release the packages of other shards right away, so their
+			// workers receive them
+			if !w.Shard.Owns(p.Id) {
+				logger.Debug("package of other shard released")
+				err = w.Queue.ChangeVisibility(ctx, m, 0)
+				if err != nil {
+					logger.Warn("release message failed", "error", err)
+				}
+				continue
+			}
+
+			// the packages of the unknown tenants have no credentials, the
+			// tenant that spent its pages of the hour waits for the next
+			t, ok := w.tenantOf(p)
+			if !ok {
+				logger.Warn("unknown tenant. message skipped", "tenant", p.Tenant)
+				continue
+			}
+			if t != nil {
+				if ok, wait := t.allow(time.Now()); !ok {
+					logger.Warn("tenant page budget spent. message delayed",
+						"tenant", t.Name, "wait", wait)
+					err = w.Queue.ChangeVisibility(ctx, m, wait)
+					if err != nil {
+						logger.Warn("release message failed", "error", err)
+					}
+					continue
+				}
+			}
+
+			// the module path is resolved to its repository by its own
+			// process, so the lookup doesn't hold up the other messages,
+			// see processModule
+			if job.Module != "" {
+				err = w.Limiter.Acquire(ctx)
+				if err != nil {
+					break
+				}
+				wg.Add(1)
+				go func() {
+					defer w.Limiter.Release()
+					w.processModule(ctx, wg, job)
+				}()
+				continue
+			}
+
+			p, ok = w.admit(ctx, job)
+			if !ok {
+				continue
+			}
+			// wait until the number of processes is below the adaptive
+			// limit, see Limiter
+			err = w.Limiter.Acquire(ctx)
+			if err != nil {
+				w.release(p)
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer w.Limiter.Release()
+				w.Process(ctx, wg, p, m)
+			}()
+		}
+	}
+	return ctx.Err()
+}
+
+// admit returns the package of the job to process and claims it. The
+// control messages are handled and the packages fetched recently skipped
+// first. The message is released if the breaker of the host is open or its
+// rate limit is exhausted, and delayed if the package is in flight already.
+// It returns false if the package isn't processed now.
+func (w *Worker) admit(ctx context.Context, job Job) (packagebug.Package, bool) {
+	logger := packagebug.Logger(ctx)
+	p, m := job.Package, job.Message
+
+	// the control messages are handled before the fetch, see control
+	if !w.control(ctx, job) {
+		return p, false
+	}
+	if w.recent(ctx, job) {
+		return p, false
+	}
+
+	// the subpackages are fetched once per repository and the vanity
+	// import paths from their repository
+	p = w.resolveRoot(ctx, p)
+	p = w.resolveVanity(ctx, p)
+
+	// delay the packages of the failing host until its breaker allows a
+	// probe
+	if ok, wait := w.allow(w.providerName(p)); !ok {
+		logger.Warn("host circuit breaker open. message delayed", "wait", wait)
+		err := w.Queue.ChangeVisibility(ctx, m, wait)
+		if err != nil {
+			logger.Warn("release message failed", "error", err)
+		}
+		return p, false
+	}
+
+	// check rate limit of the host before do the heavy task. the requests
+	// are throttled to spread the remaining limit until the reset, if the
+	// limit is exhausted anyway the message is released until the reset
+	// instead of pausing all workers.
+	rate, reset, err := w.rateLimitsOf(p).Check(ctx, p.Source().Host)
+	if err != nil {
+		logger.Error("check rate limit failed", "error", err)
+		return p, false
+	}
+	if rate == 0 {
+		wait := time.Until(time.Unix(reset, 0))
+		logger.Warn("rate limit exceed. release until reset", "wait", wait)
+		err = w.Queue.ChangeVisibility(ctx, m, wait)
+		if err != nil {
+			logger.Warn("release message failed", "error", err)
+		}
+		return p, false
+	}
+
+	// the same package may be enqueued twice, only one fetch of the
+	// package runs at a time
+	if !w.claim(p) {
+		w.delay(ctx, m, "inflight")
+		return p, false
+	}
+	return p, true
+}
+
+// processModule resolves the module path of the job to its repository and
+// processes its package. The module that has no supported repository is a
+// permanent failure, its message is dropped like the package not found; the
+// message of the failed lookup is redelivered.
+func (w *Worker) processModule(ctx context.Context, wg *sync.WaitGroup, job Job) {
+	var err error
+	job.Package, err = w.resolveModule(ctx, job.Module, job.Package)
+	if err != nil {
+		packagebug.Logger(ctx).Warn("resolve module path failed", "error", err)
+		w.retry(ctx, job.Message, err)
+		wg.Done()
+		return
+	}
+	job.Module = ""
+	p, ok := w.admit(ctx, job)
+	if !ok {
+		wg.Done()
+		return
+	}
+	w.Process(ctx, wg, p, job.Message)
+}
+
+// Process fetch bugs of the package and store the result to the database.
+// The message is deleted from the queue once the result is stored. If the
+// database is unavailable, the result is kept in the buffer until the database
+// is back. The message is delayed if other worker is fetching the same
+// package. Every process is recorded in the fetch history, which marks the
+// package whose repository is not found anymore as gone.
+func (w *Worker) Process(ctx context.Context, wg *sync.WaitGroup, p packagebug.Package, msg *queue.Message) {
+	defer wg.Done()
+	defer w.release(p)
+	logger := packagebug.Logger(ctx)
+	ctx, span := packagebug.StartSpan(ctx, "process",
+		attribute.String("package.path", p.Path()))
+
+	unlock, ok := w.lock(ctx, p)
+	if !ok {
+		w.delay(ctx, msg, "locked")
+		packagebug.EndSpan(span, nil)
+		return
+	}
+	defer unlock()
+
+	flog := packagebug.FetchLog{PackageId: p.Id, StartedAt: time.Now()}
+	stop := Heartbeat(ctx, w.Queue, msg, w.VisibilityTimeout)
+	ctx, stats := packagebug.WithFetchStats(ctx)
+	n, err := w.safeProcess(ctx, p, msg)
+	stop()
+	var skip *SkipError
+	if errors.As(err, &skip) {
+		flog.Skipped = skip.Reason
+		err = nil
+	}
+	flog.Duration = time.Since(flog.StartedAt)
+	flog.Issues = n
+	flog.Pages, flog.StatusCode = stats.Pages()
+	if t, _ := w.tenantOf(p); t != nil {
+		t.spend(time.Now(), flog.Pages)
+	}
+	w.record(p, err)
+	if w.Limiter != nil {
+		w.Limiter.Observe(err)
+	}
+	span.SetAttributes(attribute.Int("issues", n))
+	packagebug.EndSpan(span, err)
+	if err != nil {
+		logger.Error("process failed", "error", err,
+			"error_class", packagebug.ErrorClass(err))
+		flog.Error = err.Error()
+		flog.Gone = errors.Is(err, packagebug.ErrNotFound)
+		w.retry(ctx, msg, err)
+	} else {
+		logger.Info("processed", "duration", flog.Duration)
+	}
+
+	if w.DryRun {
+		return
+	}
+	// fetch history is best effort
+	err = w.Store.SaveFetchLog(flog)
+	if err != nil {
+		logger.Warn("save fetch log failed", "error", err)
+	}
+}
+
+// process returns the number of stored issues.
+func (w *Worker) process(ctx context.Context, p packagebug.Package, msg *queue.Message) (int, error) {
+	logger := packagebug.Logger(ctx)
+	started := time.Now()
+
+	// custom settings are optional, use the default if not available
+	p, err := w.applySettings(ctx, p)
+	if err != nil {
+		logger.Warn("apply package settings failed", "error", err)
+	}
+
+	p.Capabilities, err = w.Store.GetCapabilities(p, w.Capabilities)
+	if err != nil {
+		logger.Warn("get package capabilities failed", "error", err)
+	}
+
+	p.Budget = w.Budget
+	p, parent, ok := w.applyFork(ctx, p)
+	if !ok {
+		w.ack(msg)
+		return 0, &SkipError{Reason: SkipForkPolicy}
+	}
+	if reason := w.skipReason(ctx, p); reason != "" {
+		logger.Info("package skipped", "reason", reason)
+		w.ack(msg)
+		return 0, &SkipError{Reason: reason}
+	}
+	if parent != nil {
+		w.syncParent(ctx, *parent)
+	}
+
+	result, err := w.fetch(ctx, p)
+	var moved *packagebug.MovedError
+	if errors.As(err, &moved) {
+		p, err = w.move(ctx, p, moved)
+		if errors.Is(err, packagebug.ErrPackageExists) {
+			// retrying never moves it, the bugs of the repository are
+			// fetched by the package of the new path
+			logger.Warn("moved to the path of another package", "error", err)
+			w.ack(msg)
+			return 0, &SkipError{Reason: SkipMoved}
+		}
+		if err != nil {
+			return 0, err
+		}
+		result, err = w.fetch(ctx, p)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("fetch: %w", err)
+	}
+	w.syncRepo(ctx, p)
+	w.syncReleases(ctx, p)
+	w.syncAdvisories(ctx, p)
+
+	n := 0
+	if result != nil {
+		w.prepare(result)
+		n = len(result.Issues)
+		if w.DryRun {
+			LogUpserts(logger, result)
+			return n, nil
+		}
+		sctx, span := packagebug.StartSpan(ctx, "store.save",
+			attribute.Int("issues", n))
+		// the partial result keeps the sync state, the sync continues
+		// from the cursor
+		save := w.Store.Save
+		if result.Next != nil {
+			save = w.Store.SaveIssues
+		}
+		start := time.Now()
+		err = packagebug.Retry.Do(sctx, func() error {
+			return save(result)
+		})
+		if w.Limiter != nil {
+			w.Limiter.ObserveSave(time.Since(start))
+		}
+		if w.Breakers != nil {
+			w.Breakers.ObserveDB(err)
+		}
+		packagebug.EndSpan(span, err)
+		if err != nil {
+			// only buffer the result if the database is down, the
+			// partial result is fetched again instead
+			if w.Buffer == nil || result.Next != nil || w.Store.Ping(ctx) == nil {
+				return 0, fmt.Errorf("save: %w", err)
+			}
+			err = w.Buffer.Add(BufferEntry{
+				Result:  result,
+				Message: msg,
+			})
+			if err != nil {
+				return 0, fmt.Errorf("buffer: %w", err)
+			}
+			logger.Warn("database unavailable. result buffered")
+			return n, nil
+		}
+		w.cacheResponses(ctx, result)
+		// the continued sync completes with its last message
+		if result.Next == nil {
+			w.notifySync(ctx, result, time.Since(started))
+		}
+	}
+
+	// the continuation is sent before the message is acknowledged, so the
+	// sync isn't lost if the send fails
+	if result != nil && result.Next != nil {
+		err = w.continueSync(ctx, p, msg, *result.Next)
+		if err != nil {
+			return n, fmt.Errorf("continue: %w", err)
+		}
+	}
+
+	// process successful
+	w.ack(msg)
+	return n, nil
+}
+
+// cacheResponses stores the responses of the stored result. The pages are
+// only cached once their issues are stored, so the page not modified since
+// is never missing from the database. The failure is only logged.
+func (w *Worker) cacheResponses(ctx context.Context, r *packagebug.Result) {
+	if w.Cache == nil || len(r.Responses) == 0 {
+		return
+	}
+	err := w.Cache.SaveResponses(ctx, r.Responses)
+	if err != nil {
+		packagebug.Logger(ctx).Warn("cache responses failed", "error", err)
+	}
+}
+
+// prepare excludes the pull requests unless w.PullRequests is set, detects
+// the language and classifies the severity of the issues.
+func (w *Worker) prepare(r *packagebug.Result) {
+	if !w.PullRequests {
+		r.ExcludePullRequests()
+	}
+	r.DetectLanguage()
+	rules := w.Severity
+	if rules == nil {
+		rules = packagebug.DefaultSeverityRules
+	}
+	r.ClassifySeverity(rules)
+}
+
+// fetch fetches bugs of the package from the provider of its source host,
+// see packagebug.Package.Source. The fetch is incremental and conditional on
+// the sync state of the last fetch, it returns nil result if the bugs is not
+// modified since. The transient errors are retried according to Retry policy.
+// The complete result of the unconditional sync of every state without since
+// is full, see packagebug.Result.Full.
+func (w *Worker) fetch(ctx context.Context, p packagebug.Package) (*packagebug.Result, error) {
+	logger := packagebug.Logger(ctx)
+	prov := provider.Find(w.providersOf(p), p.Source().Host)
+	if prov == nil {
+		return nil, errors.New("host not supported")
+	}
+	// the sync state is optional, if the database is unavailable do
+	// unconditional and full request instead.
+	etag, err := w.Store.GetEtag(p)
+	if err != nil {
+		logger.Warn("failed to get etag", "error", err)
+		etag = ""
+	}
+	since, err := w.Store.GetSince(p)
+	if err != nil {
+		logger.Warn("failed to get since", "error", err)
+	}
+	full := err == nil && etag == "" && since.IsZero() && p.Cursor.IsZero() &&
+		p.Query.Complete()
+
+	ctx, span := packagebug.StartSpan(ctx, "fetch",
+		attribute.String("provider", prov.Name()))
+	var result *packagebug.Result
+	err = packagebug.Retry.Do(ctx, func() error {
+		var err error
+		result, err = prov.FetchIssues(ctx, p, since, etag)
+		return err
+	})
+	if w.Breakers != nil {
+		w.Breakers.ObserveHost(prov.Name(), err)
+	}
+	if result != nil && result.Next == nil {
+		result.Full = full
+	}
+	span.SetAttributes(attribute.Bool("modified", result != nil))
+	packagebug.EndSpan(span, err)
+	return result, err
+}
+
+// runRateState persists the rate limit state of the GitHub tokens every
+// interval until ctx is done.
+func (w *Worker) runRateState(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		for _, c := range w.githubClients() {
+			err := w.Store.SaveRateStates(c.RateStates())
+			if err != nil {
+				slog.Warn("save rate limit state failed", "error", err)
+			}
+		}
+	}
+}
+
+// ack acknowledges the message unless in dry run.
+func (w *Worker) ack(msg *queue.Message) {
+	if !w.DryRun {
+		w.Acker.Ack(msg)
+	}
+}
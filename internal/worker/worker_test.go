@@ -0,0 +1,323 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+	"github.com/pyk/packagebug-worker/internal/provider"
+	"github.com/pyk/packagebug-worker/internal/provider/bitbucket"
+	"github.com/pyk/packagebug-worker/internal/provider/github"
+)
+
+// newTestWorker returns the worker of a fake store and the queue of the
+// bodies. The worker fetches from the GitHub served by h, e.g. fake.GitHub,
+// with a known rate limit so it doesn't ask for it. The acker of the worker
+// runs while the worker runs, see process.
+func newTestWorker(t testing.TB, h http.Handler, bodies ...string) (*Worker, *fake.Store, *fake.Queue) {
+	store := fake.NewStore()
+	q := fake.NewQueue(bodies...)
+	w := &Worker{
+		Store:      store,
+		Queue:      q,
+		Acker:      NewAcker(q, time.Millisecond),
+		ForkPolicy: packagebug.ForkBoth,
+
+		VisibilityTimeout: time.Second,
+	}
+	if h == nil {
+		return w, store, q
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	client := github.NewClient(nil, 1)
+	client.Root = ts.URL
+	states := make(map[string]packagebug.RateState)
+	for _, id := range client.TokenIds() {
+		states[id] = packagebug.RateState{
+			Remaining: 5000,
+			Reset:     time.Now().Add(time.Hour),
+			Known:     true,
+		}
+	}
+	client.SetRateStates(states)
+	w.GitHub = client
+	w.Providers = []provider.Provider{client}
+	return w, store, q
+}
+
+// process receives n messages of the queue of the worker and processes them
+// in order as the messages of p. The acknowledged messages are deleted once
+// it returns.
+func process(t testing.TB, w *Worker, p packagebug.Package, n int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	acked := make(chan struct{})
+	go func() {
+		w.Acker.Run(ctx)
+		close(acked)
+	}()
+	defer func() {
+		cancel()
+		<-acked
+	}()
+	msgs, err := w.Queue.Receive(ctx, n, 0)
+	if err != nil || len(msgs) != n {
+		t.Fatalf("expected %d messages got: %d %v\n", n, len(msgs), err)
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(msgs))
+	for _, m := range msgs {
+		w.Process(ctx, wg, p, m)
+	}
+}
+
+// run runs the worker until n messages of q are deleted, at most 5s, and
+// returns the error of Run.
+func run(w *Worker, q *fake.Queue, n int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.Deleted()) < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	return <-done
+}
+
+func TestWorkerRun(t *testing.T) {
+	w, store, q := newTestWorker(t, &fake.GitHub{
+		Issues: []packagebug.Issue{
+			{Number: 1, Title: "crash", State: "open"},
+			// pull requests are excluded by default
+			{Number: 2, Title: "fix crash", State: "open",
+				PullRequest: &packagebug.PullRequestRef{}},
+		},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}, "1,github.com,pyk,byten")
+	w.Providers = append(w.Providers, bitbucket.New(w.GitHub.Root, "", ""))
+	buf, err := NewBuffer(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Buffer = buf
+
+	if err := run(w, q, 1); err != context.Canceled {
+		t.Errorf("expected canceled got: %v\n", err)
+	}
+	saved := store.Saved()
+	if len(saved) != 1 || len(saved[0].Issues) != 1 {
+		t.Fatalf("expected 1 saved result got: %d\n", len(saved))
+	}
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected 1 deleted message got: %d\n", len(q.Deleted()))
+	}
+	if logs := store.FetchLogs(); len(logs) != 1 || logs[0].Issues != 1 {
+		t.Errorf("expected 1 fetch log got: %+v\n", logs)
+	}
+}
+
+func TestWorkerRunDefaults(t *testing.T) {
+	w, _, q := newTestWorker(t, &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}, "1,github.com,pyk,byten")
+	// the worker without buffer, acker nor visibility timeout
+	w.Acker = nil
+	w.VisibilityTimeout = 0
+
+	if err := run(w, q, 1); err != context.Canceled {
+		t.Errorf("expected canceled got: %v\n", err)
+	}
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected 1 deleted message got: %d\n", len(q.Deleted()))
+	}
+	if w.VisibilityTimeout != DefaultVisibilityTimeout {
+		t.Errorf("expected default visibility timeout got: %s\n", w.VisibilityTimeout)
+	}
+}
+
+func TestWorkerProcessGone(t *testing.T) {
+	w, store, _ := newTestWorker(t, &fake.GitHub{PerPage: 10}, "1,github.com,pyk,gone")
+	p := packagebug.Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "gone"}
+	process(t, w, p, 1)
+	logs := store.FetchLogs()
+	if len(logs) != 1 || !logs[0].Gone {
+		t.Fatalf("expected the fetch log of the gone package got: %+v\n", logs)
+	}
+	st, _ := store.GetStatus(p)
+	if st.GoneAt == nil || !st.GoneAt.Equal(logs[0].StartedAt) {
+		t.Errorf("expected package marked gone got: %+v\n", st)
+	}
+}
+
+// movedGitHub serves the repository pyk/old moved to pyk/byten.
+func movedGitHub() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/repos/pyk/byten/issues", &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	})
+	mux.HandleFunc("/repos/pyk/byten", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"full_name": "pyk/byten"}`))
+	})
+	mux.Handle("/repos/pyk/old/", http.RedirectHandler("/repos/pyk/byten/issues", http.StatusMovedPermanently))
+	mux.Handle("/repos/pyk/old", http.RedirectHandler("/repos/pyk/byten", http.StatusMovedPermanently))
+	return mux
+}
+
+func TestWorkerProcessMoved(t *testing.T) {
+	w, store, _ := newTestWorker(t, movedGitHub(), "1,github.com,pyk,old")
+	old := packagebug.Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "old"}
+	store.AddPackage(old)
+	process(t, w, old, 1)
+	saved := store.Saved()
+	if len(saved) != 1 || saved[0].Package.Path() != "github.com/pyk/byten" {
+		t.Fatalf("expected result saved under the new location got: %+v\n", saved)
+	}
+	p, ok, _ := store.FindPackage("", "github.com/pyk/old")
+	if !ok || p.Path() != "github.com/pyk/byten" {
+		t.Errorf("expected package found by the former path got: %+v\n", p)
+	}
+}
+
+func TestWorkerProcessMovedExisting(t *testing.T) {
+	w, store, q := newTestWorker(t, movedGitHub(), "1,github.com,pyk,old")
+	old := packagebug.Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "old"}
+	store.AddPackage(old)
+	store.AddPackage(packagebug.Package{Id: "2", Host: "github.com", Owner: "pyk", Repo: "byten"})
+
+	// the package of the new path fetches the bugs, the message is done
+	process(t, w, old, 1)
+	if saved := store.Saved(); len(saved) != 0 {
+		t.Errorf("expected nothing saved got: %+v\n", saved)
+	}
+	logs := store.FetchLogs()
+	if len(logs) != 1 || logs[0].Skipped != SkipMoved || logs[0].Error != "" {
+		t.Errorf("expected the skip recorded got: %+v\n", logs)
+	}
+	if len(q.Deleted()) != 1 {
+		t.Errorf("expected the message deleted got: %d\n", len(q.Deleted()))
+	}
+	if p, ok, _ := store.FindPackage("", "github.com/pyk/old"); !ok || p.Id != "1" {
+		t.Errorf("expected the package kept at its path got: %+v\n", p)
+	}
+}
+
+// verifierTest confirms the ownership of every package.
+type verifierTest struct{}
+
+func (verifierTest) Verify(ctx context.Context, client *github.Client, p packagebug.Package, s packagebug.Settings) (bool, error) {
+	return true, nil
+}
+
+func TestWorkerProcessDryRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/repos/pyk/byten/issues", &fake.GitHub{
+		Issues:  []packagebug.Issue{{Number: 1, Title: "crash", State: "open"}},
+		PerPage: 10,
+	})
+	mux.HandleFunc("/repos/pyk/byten", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"full_name": "pyk/byten", "fork": true, "parent": {"full_name": "pyk/upstream"}}`))
+	})
+	w, store, q := newTestWorker(t, mux, "2,github.com,pyk,byten/sub")
+	w.Verifiers = []github.Verifier{verifierTest{}}
+	w.DryRun = true
+	p := packagebug.Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "byten"}
+	store.AddPackage(p)
+	store.SetSettings(p, packagebug.Settings{Labels: []string{"crash"}})
+
+	// the subpackage is fetched from its root without linking it
+	sub := packagebug.Package{Id: "2", Host: "github.com", Owner: "pyk",
+		Repo: "byten", Subpath: "sub"}
+	root := w.resolveRoot(context.Background(), sub)
+	if root.Id != "1" {
+		t.Fatalf("expected the root package got: %+v\n", root)
+	}
+	// the ownership is verified and the fork resolved without storing them
+	process(t, w, root, 1)
+	if n := store.Writes(); n != 0 {
+		t.Errorf("expected no write in dry run got: %d\n", n)
+	}
+	if len(q.Deleted()) != 0 {
+		t.Errorf("expected the message kept in dry run got: %+v\n", q.Deleted())
+	}
+}
+
+func TestWorkerProcessFull(t *testing.T) {
+	gh := &fake.GitHub{
+		Issues: []packagebug.Issue{{Number: 1, Title: "crash", State: "open",
+			UpdatedAt: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}
+	p := fake.Package
+	p.Id = "1"
+	w, store, _ := newTestWorker(t, gh, packagebug.FormatMessage(p, 0), packagebug.FormatMessage(p, 0))
+	store.AddPackage(p)
+	process(t, w, p, 1)
+
+	// the incremental sync is not full
+	gh.Etag = `"v2"`
+	process(t, w, p, 1)
+
+	saved := store.Saved()
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 saved results got: %+v\n", saved)
+	}
+	if !saved[0].Full {
+		t.Errorf("expected the first sync full\n")
+	}
+	if saved[1].Full {
+		t.Errorf("expected the incremental sync not full\n")
+	}
+}
+
+func TestWorkerProcessQuery(t *testing.T) {
+	gh := &fake.GitHub{
+		Issues: []packagebug.Issue{
+			{Number: 1, Title: "crash", State: "open"},
+			{Number: 2, Title: "leak", State: "closed"},
+		},
+		PerPage: 10,
+		Etag:    `"v1"`,
+	}
+	p := fake.Package
+	p.Id = "1"
+	w, store, _ := newTestWorker(t, gh, packagebug.FormatMessage(p, 0))
+	w.Query = packagebug.Query{State: "open"}
+	store.AddPackage(p)
+	process(t, w, p, 1)
+
+	saved := store.Saved()
+	if len(saved) != 1 {
+		t.Fatalf("expected 1 saved result got: %+v\n", saved)
+	}
+	if len(saved[0].Issues) != 1 || saved[0].Issues[0].Number != 1 {
+		t.Errorf("expected the open issue only got: %+v\n", saved[0].Issues)
+	}
+	// the closed issues are missing from the open list, they aren't removed
+	if saved[0].Full {
+		t.Errorf("expected the sync of the open issues not full\n")
+	}
+}
+
+func BenchmarkPrepare(b *testing.B) {
+	issues := fake.Issues(1000)
+	w := &Worker{}
+	r := &packagebug.Result{Package: fake.Package}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Issues = append(r.Issues[:0], issues...)
+		w.prepare(r)
+	}
+}
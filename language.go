@@ -0,0 +1,154 @@
+package packagebug
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// LanguageUnknown is returned when the language can't be detected.
+const LanguageUnknown = "und"
+
+// scripts maps the unicode scripts to the language that mostly written in it.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// stopwords of languages written in latin script.
+var stopwords = map[string][]string{
+	"en": {"the", "is", "and", "of", "to", "in", "it", "when", "not", "with", "this", "for", "does", "on"},
+	"es": {"el", "la", "de", "que", "y", "en", "los", "se", "del", "las", "por", "una", "con", "no", "cuando"},
+	"pt": {"o", "a", "de", "que", "e", "do", "da", "em", "um", "para", "com", "não", "uma", "os", "quando"},
+	"fr": {"le", "la", "de", "et", "les", "des", "est", "un", "une", "du", "pas", "en", "que", "quand", "ne"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "mit", "den", "zu", "wenn", "auf", "es"},
+	"it": {"il", "di", "che", "e", "la", "non", "un", "per", "una", "con", "sono", "quando", "del", "della"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "met", "wanneer", "voor", "als"},
+	"id": {"yang", "dan", "di", "tidak", "ini", "itu", "dengan", "untuk", "ada", "saat", "ke", "dari", "bisa"},
+	"tr": {"ve", "bir", "bu", "için", "ile", "değil", "olarak", "çok", "ama", "gibi", "zaman", "daha"},
+}
+
+// stopwordLangs maps every stopword to the languages it is a stopword of.
+var stopwordLangs = func() map[string][]string {
+	langs := make(map[string][]string)
+	for lang, sw := range stopwords {
+		for _, s := range sw {
+			if !slices.Contains(langs[s], lang) {
+				langs[s] = append(langs[s], lang)
+			}
+		}
+	}
+	return langs
+}()
+
+var (
+	codeBlock  = regexp.MustCompile("(?s)```.*?```|`[^`]*`")
+	urlPattern = regexp.MustCompile(`https?://\S+`)
+)
+
+// DetectLanguage returns ISO 639-1 code of the language of text using a fast
+// heuristic: the unicode script for non-latin text, otherwise the stopword
+// frequency. Code blocks and URLs are ignored. It returns LanguageUnknown if
+// the text is too short or no language matches.
+func DetectLanguage(text string) string {
+	// the replace copies the whole text even without a match
+	if strings.Contains(text, "`") {
+		text = codeBlock.ReplaceAllString(text, " ")
+	}
+	if strings.Contains(text, "http") {
+		text = urlPattern.ReplaceAllString(text, " ")
+	}
+
+	// count letters by script
+	counts := make(map[string]int)
+	letters, latin := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return LanguageUnknown
+	}
+
+	// japanese text also contains han, so kana wins over han
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] > letters/3 {
+		return "ja"
+	}
+	best, max := LanguageUnknown, 0
+	for lang, n := range counts {
+		if n > max || (n == max && lang < best) {
+			best, max = lang, n
+		}
+	}
+	if max > latin {
+		return best
+	}
+
+	// latin script, score by stopwords. the words are scanned in place
+	// instead of split into a slice.
+	text = strings.ToLower(text)
+	scores := make(map[string]int)
+	words, start := 0, -1
+	score := func(end int) {
+		words++
+		for _, lang := range stopwordLangs[text[start:end]] {
+			scores[lang]++
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			score(i)
+		}
+	}
+	if start >= 0 {
+		score(len(text))
+	}
+	if words < 3 {
+		return LanguageUnknown
+	}
+	best, max = LanguageUnknown, 0
+	for lang, n := range scores {
+		if n > max || (n == max && lang < best) {
+			best, max = lang, n
+		}
+	}
+	return best
+}
+
+// DetectLanguage sets the language of every issue in the result from its
+// title and body.
+func (r *Result) DetectLanguage() {
+	for i := range r.Issues {
+		issue := &r.Issues[i]
+		issue.Language = DetectLanguage(issue.Title + "\n" + issue.Body)
+	}
+}
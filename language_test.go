@@ -0,0 +1,27 @@
+package packagebug
+
+import (
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"panic when the config file is empty":                    "en",
+		"el programa falla cuando la configuración no existe":    "es",
+		"le programme plante quand le fichier est vide":          "fr",
+		"das Programm stürzt ab, wenn die Datei nicht existiert": "de",
+		"aplikasi crash saat file config tidak ada":              "id",
+		"配置文件为空时程序崩溃":                                            "zh",
+		"設定ファイルが空のときにパニックする":                                     "ja",
+		"설정 파일이 비어 있으면 패닉":                                       "ko",
+		"паника при пустом файле конфигурации":                   "ru",
+		"```go\nfunc main() {}\n```":                             LanguageUnknown,
+		"":                                                       LanguageUnknown,
+	}
+	for text, expected := range cases {
+		lang := DetectLanguage(text)
+		if lang != expected {
+			t.Errorf("%q expected: %s got: %s\n", text, expected, lang)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package packagebug
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of the issue links.
+const (
+	// LinkDuplicate links the issue to the canonical issue it duplicates
+	LinkDuplicate = "duplicate"
+	// LinkReference links the issue to the issue or pull request that
+	// referenced it
+	LinkReference = "cross_reference"
+)
+
+// IssueLink is the link of an issue to another issue, e.g. the canonical
+// issue of the duplicate.
+type IssueLink struct {
+	Kind string `json:"kind"`
+	// Path is the repository of the linked issue, e.g. github.com/pyk/byten
+	Path      string    `json:"path"`
+	Number    int       `json:"number"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// duplicateRe matches the "Duplicate of #12" and "Duplicate of owner/repo#12"
+// lines GitHub uses to close the duplicates.
+var duplicateRe = regexp.MustCompile(`(?im)^\s*duplicate of\s+([\w.-]+/[\w.-]+)?#(\d+)`)
+
+// DuplicateOf returns the link to the issue the comment body marks its issue
+// a duplicate of. repo is the repository path of the issue, e.g.
+// github.com/pyk/byten, the other repositories are on the same host.
+func DuplicateOf(body, repo string) (IssueLink, bool) {
+	m := duplicateRe.FindStringSubmatch(body)
+	if m == nil {
+		return IssueLink{}, false
+	}
+	number, err := strconv.Atoi(m[2])
+	if err != nil {
+		return IssueLink{}, false
+	}
+	path := repo
+	if m[1] != "" {
+		host, _, _ := strings.Cut(repo, "/")
+		path = host + "/" + m[1]
+	}
+	return IssueLink{Kind: LinkDuplicate, Path: path, Number: number}, true
+}
+
+// AddLink adds the link to the issue unless it links the issue to itself or
+// the issue already has it. repo is the repository path of the issue.
+func (i *Issue) AddLink(repo string, l IssueLink) {
+	if strings.EqualFold(l.Path, repo) && l.Number == i.Number {
+		return
+	}
+	for _, x := range i.Links {
+		if x.Kind == l.Kind && strings.EqualFold(x.Path, l.Path) && x.Number == l.Number {
+			return
+		}
+	}
+	i.Links = append(i.Links, l)
+}
+
+// DuplicateLinks adds the duplicate links of the comments of the issue, see
+// DuplicateOf.
+func (i *Issue) DuplicateLinks(repo string) {
+	for _, c := range i.Comments {
+		if l, ok := DuplicateOf(c.Body, repo); ok {
+			l.CreatedAt = c.CreatedAt
+			i.AddLink(repo, l)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package packagebug
+
+import (
+	"testing"
+)
+
+func TestDuplicateOf(t *testing.T) {
+	cases := []struct {
+		body   string
+		path   string
+		number int
+		ok     bool
+	}{
+		{"Duplicate of #12", "github.com/pyk/byten", 12, true},
+		{"Thanks!\n\nduplicate of pyk/other#3", "github.com/pyk/other", 3, true},
+		{"Not a duplicate of #12", "", 0, false},
+		{"Duplicate of 12", "", 0, false},
+	}
+	for _, c := range cases {
+		l, ok := DuplicateOf(c.body, "github.com/pyk/byten")
+		if ok != c.ok || l.Path != c.path || l.Number != c.number {
+			t.Errorf("%q: expected %s#%d got: %+v %v\n", c.body, c.path, c.number, l, ok)
+		}
+	}
+}
+
+func TestIssueDuplicateLinks(t *testing.T) {
+	issue := Issue{Number: 2, Comments: []Comment{
+		{Body: "Duplicate of #1"},
+		{Body: "Duplicate of #1"},
+		{Body: "Duplicate of #2"},
+	}}
+	issue.DuplicateLinks("github.com/pyk/byten")
+	if len(issue.Links) != 1 || issue.Links[0].Kind != LinkDuplicate || issue.Links[0].Number != 1 {
+		t.Errorf("expected the link to #1 got: %+v\n", issue.Links)
+	}
+}
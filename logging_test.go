@@ -0,0 +1,48 @@
+package packagebug
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"WARN":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for s, expected := range cases {
+		level, err := ParseLevel(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if level != expected {
+			t.Errorf("%q expected: %s got: %s\n", s, expected, level)
+		}
+	}
+	_, err := ParseLevel("verbose")
+	if err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, slog.LevelInfo).With("correlation_id", "abc")
+	ctx := WithLogger(context.Background(), l)
+	Logger(ctx).Debug("hidden")
+	Logger(ctx).Info("fetch", "package_path", pkgTest.Path())
+
+	var record map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["correlation_id"] != "abc" || record["package_path"] != "github.com/pyk/byten" {
+		t.Errorf("got: %v\n", record)
+	}
+}
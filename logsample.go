@@ -0,0 +1,163 @@
+package packagebug
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sampler is the slog.Handler that limits the repeated warnings and errors:
+// at most Burst records of the same message and error class are written per
+// Interval, the rest are dropped. The first record of the message after its
+// interval is preceded by the summary "previous message repeated" with the
+// count of the dropped records. The records below warn are never dropped,
+// the metrics keep their own exact counts.
+type Sampler struct {
+	Handler  slog.Handler
+	Interval time.Duration
+	Burst    int
+
+	state *sampleState
+}
+
+// sampleState is the state of the messages, shared by the handlers derived
+// by WithAttrs and WithGroup.
+type sampleState struct {
+	mu       sync.Mutex
+	messages map[sampleKey]*sample
+	dropped  int64
+}
+
+type sampleKey struct {
+	level slog.Level
+	msg   string
+	class string
+}
+
+// sample is the window of a message.
+type sample struct {
+	start   time.Time
+	written int
+	dropped int
+}
+
+// NewSampler creates the sampler of h, at most burst records per message
+// and error class are written per interval.
+func NewSampler(h slog.Handler, interval time.Duration, burst int) *Sampler {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Sampler{
+		Handler:  h,
+		Interval: interval,
+		Burst:    burst,
+		state:    &sampleState{messages: make(map[sampleKey]*sample)},
+	}
+}
+
+// Enabled implements slog.Handler.
+func (s *Sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.Handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (s *Sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn {
+		return s.Handler.Handle(ctx, r)
+	}
+	key := sampleKey{level: r.Level, msg: r.Message, class: recordClass(r)}
+	ok, repeated := s.state.allow(key, r.Time, s.Interval, s.Burst)
+	if !ok {
+		return nil
+	}
+	if repeated > 0 {
+		summary := slog.NewRecord(r.Time, r.Level, "previous message repeated", r.PC)
+		summary.AddAttrs(slog.String("repeated_msg", r.Message),
+			slog.Int("repeated", repeated))
+		if key.class != "" {
+			summary.AddAttrs(slog.String("error_class", key.class))
+		}
+		err := s.Handler.Handle(ctx, summary)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (s *Sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *s
+	c.Handler = s.Handler.WithAttrs(attrs)
+	return &c
+}
+
+// WithGroup implements slog.Handler.
+func (s *Sampler) WithGroup(name string) slog.Handler {
+	c := *s
+	c.Handler = s.Handler.WithGroup(name)
+	return &c
+}
+
+// Dropped returns the number of the dropped records.
+func (s *Sampler) Dropped() int64 {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	return s.state.dropped
+}
+
+// allow returns true if the record of key at t is written and the number of
+// the records dropped in the previous interval of key to report.
+func (st *sampleState) allow(key sampleKey, t time.Time, interval time.Duration, burst int) (bool, int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sm, ok := st.messages[key]
+	if !ok || t.Sub(sm.start) >= interval {
+		repeated := 0
+		if ok {
+			repeated = sm.dropped
+		}
+		st.messages[key] = &sample{start: t, written: 1}
+		st.prune(t, interval)
+		return true, repeated
+	}
+	if sm.written < burst {
+		sm.written++
+		return true, 0
+	}
+	sm.dropped++
+	st.dropped++
+	return false, 0
+}
+
+// prune forgets the messages whose interval passed without drops, so the
+// state doesn't grow with every distinct message.
+func (st *sampleState) prune(t time.Time, interval time.Duration) {
+	if len(st.messages) < 1000 {
+		return
+	}
+	for key, sm := range st.messages {
+		if sm.dropped == 0 && t.Sub(sm.start) >= interval {
+			delete(st.messages, key)
+		}
+	}
+}
+
+// recordClass returns the error class of the error attribute of the record,
+// empty if it has none.
+func recordClass(r slog.Record) string {
+	class := ""
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != "error" {
+			return true
+		}
+		if err, ok := a.Value.Any().(error); ok {
+			class = ErrorClass(err)
+		} else {
+			class = "other"
+		}
+		return false
+	})
+	return class
+}
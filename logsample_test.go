@@ -0,0 +1,43 @@
+package packagebug
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	s := NewSampler(h, time.Hour, 2)
+	logger := slog.New(s)
+
+	for i := 0; i < 5; i++ {
+		logger.With("message_id", i).Error("fetch failed", "error", ErrNotFound)
+	}
+	// other class and the debug records are not limited
+	logger.Error("fetch failed", "error", errors.New("boom"))
+	for i := 0; i < 3; i++ {
+		logger.Debug("empty receive")
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 lines got: %d\n%s", len(lines), buf.String())
+	}
+	if s.Dropped() != 3 {
+		t.Errorf("expected 3 dropped got: %d\n", s.Dropped())
+	}
+
+	// the next interval reports the dropped records first
+	buf.Reset()
+	s.Interval = 0
+	logger.Error("fetch failed", "error", ErrNotFound)
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"repeated":3`) ||
+		!strings.Contains(lines[0], `"error_class":"not_found"`) {
+		t.Errorf("expected the summary of 3 repeated got: %s\n", buf.String())
+	}
+}
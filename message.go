@@ -0,0 +1,169 @@
+package packagebug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Action is what the message asks the worker to do with the package.
+type Action string
+
+const (
+	// ActionFetch fetches the bugs of the package, the default.
+	ActionFetch Action = "fetch"
+	// ActionDelete deletes the stored issues and the sync state of the
+	// package.
+	ActionDelete Action = "delete"
+	// ActionRescan resets the sync state of the package, so the next fetch
+	// is unconditional and full, then fetches the package.
+	ActionRescan Action = "rescan_etag_reset"
+)
+
+// ParseAction parses the action of the message, empty string means
+// ActionFetch.
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case "":
+		return ActionFetch, nil
+	case ActionFetch, ActionDelete, ActionRescan:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown message action %q", s)
+}
+
+// Message represents the package message received from the queue.
+type Message struct {
+	Package  Package
+	Priority int
+	Action   Action
+	// Cursor is set by the continuation of the sync that spilled over the
+	// budget, see FormatContinuation
+	Cursor Cursor
+	// Module is the module path of the message of FormatModule, the
+	// package has only its id until the module is resolved
+	Module string
+}
+
+// FormatMessage returns the fetch message body of the package with priority.
+// The subpath of the subpackage follows the repo, e.g. aws-sdk-go/service/sqs.
+func FormatMessage(p Package, priority int) string {
+	return formatMessage(p, priority, "", "")
+}
+
+// FormatAction returns the message body of the action on the package with
+// priority.
+func FormatAction(p Package, priority int, action Action) string {
+	return formatMessage(p, priority, action, "")
+}
+
+// FormatContinuation returns the fetch message body of the package with
+// priority that continues the sync at the cursor.
+func FormatContinuation(p Package, priority int, c Cursor) string {
+	return formatMessage(p, priority, ActionFetch, c.String())
+}
+
+// FormatModule returns the message body of the action on the package of the
+// module path of the tenant with priority, e.g. 1,golang.org/x/net,0 for the
+// producers that don't know the repository of the module. The worker
+// resolves the module path to its repository before the fetch. The tenant
+// is the last field like formatMessage, e.g. 1,golang.org/x/net,0,,,acme.
+func FormatModule(id, module, tenant string, priority int, action Action) string {
+	fields := []string{id, module, strconv.Itoa(priority), string(action), "", tenant}
+	n := len(fields)
+	for n > 3 && fields[n-1] == "" {
+		n--
+	}
+	return strings.Join(fields[:n], ",")
+}
+
+// formatMessage returns the message body of the package. The tenant of the
+// package is the last field, the optional fields before it are left empty,
+// e.g. 1,github.com,pyk,byten,0,,,acme.
+func formatMessage(p Package, priority int, action Action, cursor string) string {
+	repo := p.Repo
+	if p.Subpath != "" {
+		repo += "/" + p.Subpath
+	}
+	fields := []string{p.Id, p.Host, p.Owner, repo, strconv.Itoa(priority),
+		string(action), cursor, p.Tenant}
+	n := len(fields)
+	for n > 5 && fields[n-1] == "" {
+		n--
+	}
+	return strings.Join(fields[:n], ",")
+}
+
+// Path returns the path of the package of the message, the module path of
+// the message of FormatModule.
+func (m Message) Path() string {
+	if m.Module != "" {
+		return m.Module
+	}
+	return m.Package.Path()
+}
+
+// DedupeKey returns the key shared by the messages asking the same of the
+// package: the action and the path of the package, followed by the cursor of
+// the continuation. The priority and the id aren't part of it.
+func (m Message) DedupeKey() string {
+	key := fmt.Sprintf("%s:%s", m.Action, m.Path())
+	if !m.Cursor.IsZero() {
+		key += ":" + m.Cursor.String()
+	}
+	return key
+}
+
+// ParseMessage parses the message body id,host,owner,repo with optional
+// priority, action, cursor and tenant, see Action, Cursor and
+// Package.Tenant. The cursor is set to the package too. The repo may be
+// followed by the subpath, see FormatMessage. The module path may replace
+// the host, the owner and the repo, see FormatModule.
+func ParseMessage(body string) (Message, error) {
+	var m Message
+	fields := strings.Split(body, ",")
+	// the host never contains a slash, the module path always does
+	if len(fields) >= 2 && strings.Contains(fields[1], "/") {
+		m.Module = strings.Trim(fields[1], "/")
+		fields = append([]string{fields[0], "", "", ""}, fields[2:]...)
+	}
+	if len(fields) < 4 || len(fields) > 8 {
+		return m, fmt.Errorf("invalid message body %q", body)
+	}
+	m.Package = Package{
+		Id:    fields[0],
+		Host:  fields[1],
+		Owner: fields[2],
+		Repo:  fields[3],
+	}
+	if repo, subpath, ok := strings.Cut(fields[3], "/"); ok {
+		m.Package.Repo, m.Package.Subpath = repo, subpath
+	}
+	if len(fields) >= 5 {
+		var err error
+		m.Priority, err = strconv.Atoi(fields[4])
+		if err != nil {
+			return m, fmt.Errorf("invalid message priority %q", fields[4])
+		}
+	}
+	action := ""
+	if len(fields) >= 6 {
+		action = fields[5]
+	}
+	var err error
+	m.Action, err = ParseAction(action)
+	if err != nil {
+		return m, err
+	}
+	if len(fields) >= 7 && fields[6] != "" {
+		m.Cursor, err = ParseCursor(fields[6])
+		if err != nil {
+			return m, err
+		}
+		m.Package.Cursor = m.Cursor
+	}
+	if len(fields) == 8 {
+		m.Package.Tenant = fields[7]
+	}
+	return m, nil
+}
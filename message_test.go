@@ -0,0 +1,148 @@
+package packagebug
+
+import (
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	m, err := ParseMessage("1,github.com,pyk,byten")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package.Path() != "github.com/pyk/byten" || m.Priority != 0 || m.Action != ActionFetch {
+		t.Errorf("unexpected message %+v\n", m)
+	}
+	p := m.Package
+	p.Id = "1"
+	m, err = ParseMessage(FormatMessage(p, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package.Id != "1" || m.Priority != 3 {
+		t.Errorf("unexpected package %s priority %d\n", m.Package.Id, m.Priority)
+	}
+	m, err = ParseMessage(FormatAction(p, 2, ActionDelete))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package.Id != "1" || m.Priority != 2 || m.Action != ActionDelete {
+		t.Errorf("unexpected message %+v\n", m)
+	}
+	m, err = ParseMessage(FormatContinuation(p, 2, Cursor{Query: 1, Page: 11}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Action != ActionFetch || m.Cursor != (Cursor{Query: 1, Page: 11}) || m.Package.Cursor != m.Cursor {
+		t.Errorf("unexpected continuation %+v\n", m)
+	}
+	for _, body := range []string{
+		"1,golang.org/x/net,0,fetch,,acme,extra",
+		"1,github.com,pyk",
+		"1,github.com,pyk,byten,high",
+		"1,github.com,pyk,byten,0,drop",
+		"1,github.com,pyk,byten,0,fetch,extra",
+		"1,github.com,pyk,byten,0,fetch,1.1,acme,extra",
+	} {
+		_, err = ParseMessage(body)
+		if err == nil {
+			t.Errorf("expected error for %q\n", body)
+		}
+	}
+}
+
+func TestMessageDedupeKey(t *testing.T) {
+	p := Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "byten"}
+	keys := make(map[string]string)
+	for _, body := range []string{
+		FormatMessage(p, 0),
+		FormatMessage(p, 3),
+		FormatAction(p, 0, ActionRescan),
+		FormatContinuation(p, 0, Cursor{Query: 1, Page: 11}),
+	} {
+		m, err := ParseMessage(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[body] = m.DedupeKey()
+	}
+	if keys[FormatMessage(p, 0)] != keys[FormatMessage(p, 3)] {
+		t.Errorf("expected the priority ignored got: %v\n", keys)
+	}
+	if keys[FormatMessage(p, 0)] == keys[FormatAction(p, 0, ActionRescan)] ||
+		keys[FormatMessage(p, 0)] == keys[FormatContinuation(p, 0, Cursor{Query: 1, Page: 11})] {
+		t.Errorf("expected the rescan and the continuation kept got: %v\n", keys)
+	}
+}
+
+func TestParseMessageSubpath(t *testing.T) {
+	p := Package{Id: "1", Host: "github.com", Owner: "aws", Repo: "aws-sdk-go",
+		Subpath: "service/sqs"}
+	body := FormatMessage(p, 0)
+	if body != "1,github.com,aws,aws-sdk-go/service/sqs,0" {
+		t.Errorf("unexpected message body %q\n", body)
+	}
+	m, err := ParseMessage(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Package.Repo != "aws-sdk-go" || m.Package.Subpath != "service/sqs" {
+		t.Errorf("unexpected package %+v\n", m.Package)
+	}
+}
+
+func TestParseMessageTenant(t *testing.T) {
+	p := Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "byten",
+		Tenant: "acme"}
+	cases := []struct {
+		body   string
+		action Action
+		cursor Cursor
+	}{
+		{FormatMessage(p, 0), ActionFetch, Cursor{}},
+		{FormatAction(p, 0, ActionRescan), ActionRescan, Cursor{}},
+		{FormatContinuation(p, 0, Cursor{Query: 1, Page: 3}), ActionFetch, Cursor{Query: 1, Page: 3}},
+	}
+	if cases[0].body != "1,github.com,pyk,byten,0,,,acme" {
+		t.Errorf("unexpected message body %q\n", cases[0].body)
+	}
+	for _, c := range cases {
+		m, err := ParseMessage(c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Package.Tenant != "acme" || m.Action != c.action || m.Cursor != c.cursor {
+			t.Errorf("%s: unexpected message %+v\n", c.body, m)
+		}
+	}
+
+	// the messages of the deployment have no tenant field
+	p.Tenant = ""
+	if body := FormatAction(p, 0, ActionRescan); body != "1,github.com,pyk,byten,0,rescan_etag_reset" {
+		t.Errorf("unexpected message body %q\n", body)
+	}
+}
+
+func TestParseMessageModule(t *testing.T) {
+	m, err := ParseMessage(FormatModule("1", "golang.org/x/net", "", 3, ActionRescan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Module != "golang.org/x/net" || m.Package.Id != "1" || m.Priority != 3 || m.Action != ActionRescan {
+		t.Errorf("unexpected message %+v\n", m)
+	}
+	if m.Package.Host != "" || m.Path() != "golang.org/x/net" || m.DedupeKey() != "rescan_etag_reset:golang.org/x/net" {
+		t.Errorf("expected package of the module unresolved got: %+v\n", m)
+	}
+
+	body := FormatModule("1", "gopkg.in/yaml.v3", "acme", 0, "")
+	if body != "1,gopkg.in/yaml.v3,0,,,acme" {
+		t.Errorf("unexpected message body %q\n", body)
+	}
+	m, err = ParseMessage(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Module != "gopkg.in/yaml.v3" || m.Action != ActionFetch || m.Package.Tenant != "acme" {
+		t.Errorf("unexpected message %+v\n", m)
+	}
+}
@@ -0,0 +1,79 @@
+package packagebug
+
+import "time"
+
+// The events of the outbox.
+const (
+	IssueCreated = "issue_created"
+	IssueClosed  = "issue_closed"
+)
+
+// OutboxEvent is the change of a bug written to the outbox in the
+// transaction that saves it, the publisher forwards it to the other
+// services at least once. The consumers deduplicate by Id.
+type OutboxEvent struct {
+	Id          int64     `json:"id"`
+	Event       string    `json:"event"`
+	PackageId   string    `json:"package_id"`
+	PackagePath string    `json:"package_path"`
+	IssueNumber int       `json:"issue_number"`
+	IssueTitle  string    `json:"issue_title"`
+	IssueUrl    string    `json:"issue_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SyncCompleted is the event of the sync of a package stored in full.
+const SyncCompleted = "sync_completed"
+
+// SyncEvent is published once the sync of a package is stored, so the
+// other services don't poll the store. Issues is the delta of the sync: the
+// issues created or updated since the last sync stored by the message that
+// completed it, of them Open are open and Closed closed.
+type SyncEvent struct {
+	Event       string    `json:"event"`
+	PackageId   string    `json:"package_id"`
+	PackagePath string    `json:"package_path"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Issues      int       `json:"issues"`
+	Open        int       `json:"open"`
+	Closed      int       `json:"closed"`
+	DurationMs  int64     `json:"duration_ms"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// NewSyncEvent returns the event of the sync of the result that took d.
+func NewSyncEvent(r *Result, d time.Duration) SyncEvent {
+	e := SyncEvent{
+		Event:       SyncCompleted,
+		PackageId:   r.Package.Id,
+		PackagePath: r.Package.Path(),
+		Tenant:      r.Package.Tenant,
+		Issues:      len(r.Issues),
+		DurationMs:  d.Milliseconds(),
+		CompletedAt: time.Now().UTC(),
+	}
+	for _, issue := range r.Issues {
+		if issue.State == "closed" {
+			e.Closed++
+		} else {
+			e.Open++
+		}
+	}
+	return e
+}
+
+// OutboxEvents returns the events of the issue saved over its stored state,
+// empty state if the issue is new. The pull requests have no events.
+func OutboxEvents(stored string, issue Issue) []string {
+	if issue.IsPullRequest() {
+		return nil
+	}
+	var events []string
+	if stored == "" {
+		events = append(events, IssueCreated)
+	}
+	if stored != "closed" && issue.State == "closed" {
+		events = append(events, IssueClosed)
+	}
+	return events
+}
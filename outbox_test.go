@@ -0,0 +1,46 @@
+package packagebug
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutboxEvents(t *testing.T) {
+	cases := []struct {
+		stored   string
+		state    string
+		expected string
+	}{
+		{"", "open", "issue_created"},
+		{"", "closed", "issue_created,issue_closed"},
+		{"open", "closed", "issue_closed"},
+		{"open", "open", ""},
+		{"closed", "closed", ""},
+		{"closed", "open", ""},
+	}
+	for _, c := range cases {
+		events := OutboxEvents(c.stored, Issue{State: c.state})
+		if got := strings.Join(events, ","); got != c.expected {
+			t.Errorf("%q to %q: expected %q got: %q\n", c.stored, c.state, c.expected, got)
+		}
+	}
+}
+
+func TestNewSyncEvent(t *testing.T) {
+	r := &Result{
+		Package: Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "byten", Tenant: "acme"},
+		Issues: []Issue{
+			{Number: 1, State: "open"},
+			{Number: 2, State: "closed"},
+			{Number: 3, State: "closed"},
+		},
+	}
+	e := NewSyncEvent(r, 1500*time.Millisecond)
+	if e.Event != SyncCompleted || e.PackagePath != "github.com/pyk/byten" || e.Tenant != "acme" {
+		t.Errorf("unexpected event: %+v\n", e)
+	}
+	if e.Issues != 3 || e.Open != 1 || e.Closed != 2 || e.DurationMs != 1500 {
+		t.Errorf("unexpected delta: %+v\n", e)
+	}
+}
@@ -0,0 +1,301 @@
+// Package packagebug contains the models shared by the packagebug worker:
+// the packages, their issues and the fetch results.
+package packagebug
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Package represents a Go package
+type Package struct {
+	Id    string
+	Host  string
+	Owner string
+	Repo  string
+	// Subpath is the path of the subpackage within the repository, e.g.
+	// service/sqs of github.com/aws/aws-sdk-go/service/sqs
+	Subpath string `json:",omitempty"`
+	// Tenant is the team or product the package is crawled for, fetched
+	// with the credentials and within the budget of the tenant. Empty is
+	// the deployment itself.
+	Tenant string `json:",omitempty"`
+
+	// custom settings of verified package owner
+	Labels []string `json:",omitempty"`
+	Token  string   `json:"-"`
+
+	// aliases of the bug label, e.g. kind/bug, fetched alongside bug unless
+	// the owner set custom labels
+	BugLabels []string `json:",omitempty"`
+
+	// the state filter, bug labels and sort order of the issue list, the
+	// query of the deployment overridden by the query of the package
+	Query Query `json:",omitempty"`
+
+	// optional data fetched and stored for every issue
+	Capabilities Capability `json:",omitempty"`
+
+	// only fetch issues updated after Since
+	Since time.Time `json:",omitempty"`
+
+	// the limits of the sync and where the sync continues, see Budget
+	Budget Budget `json:"-"`
+	Cursor Cursor `json:"-"`
+
+	// fetch the issues of Upstream instead if set, e.g. the parent of a fork
+	// or the repository behind a vanity import path
+	Upstream *Package `json:",omitempty"`
+}
+
+// Path returns valid import path of the package
+func (p Package) Path() string {
+	if p.Subpath != "" {
+		return fmt.Sprintf("%s/%s/%s/%s", p.Host, p.Owner, p.Repo, p.Subpath)
+	}
+	return fmt.Sprintf("%s/%s/%s", p.Host, p.Owner, p.Repo)
+}
+
+// Key returns the path of the package unique across the tenants: the path
+// prefixed by the tenant, e.g. acme:github.com/pyk/byten, the path itself for
+// the deployment. The paths have no colon.
+func (p Package) Key() string {
+	if p.Tenant == "" {
+		return p.Path()
+	}
+	return p.Tenant + ":" + p.Path()
+}
+
+// Repository returns the package of the repository of the subpackage, the
+// package itself without Id if it is not a subpackage.
+func (p Package) Repository() Package {
+	return Package{Host: p.Host, Owner: p.Owner, Repo: p.Repo}
+}
+
+// Source returns the package whose repository the issues are fetched from:
+// Upstream if set, otherwise the package itself.
+func (p Package) Source() Package {
+	if p.Upstream != nil {
+		return *p.Upstream
+	}
+	return p
+}
+
+// ParsePath returns the package of the path host/owner/repo, the rest of
+// the path is the subpath of the subpackage.
+func ParsePath(path string) (Package, error) {
+	parts := strings.SplitN(path, "/", 4)
+	if len(parts) < 3 {
+		return Package{}, fmt.Errorf("invalid package path %q", path)
+	}
+	p := Package{Host: parts[0], Owner: parts[1], Repo: parts[2]}
+	if len(parts) == 4 {
+		p.Subpath = strings.Trim(parts[3], "/")
+	}
+	return p, nil
+}
+
+// Settings represents the per-package settings requested by the package
+// owner. The settings are only honored once the ownership is verified.
+type Settings struct {
+	Labels []string
+	// BugLabels are the aliases of the bug label, they are set by the
+	// operator and don't need the ownership verification
+	BugLabels []string
+	// Query overrides the issue list query of the deployment, it is set by
+	// the operator too
+	Query       Query
+	Token       string
+	VerifyToken string
+	Verified    bool
+	VerifiedAt  time.Time
+}
+
+// Empty returns true if there is no custom settings.
+func (s Settings) Empty() bool {
+	return len(s.Labels) == 0 && s.Token == ""
+}
+
+// ParseTokens splits comma separated tokens and drops the empty ones.
+func ParseTokens(s string) []string {
+	var tokens []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// Issue represents the issue of package
+type Issue struct {
+	ApiUrl         string `json:"url"`
+	ApiLabelsUrl   string `json:"labels_url"`
+	ApiCommentsUrl string `json:"comments_url"`
+	ApiEventsUrl   string `json:"events_url"`
+	Url            string `json:"html_url"`
+	GithubId       int64  `json:"id"`
+	Id             string
+	Number         int            `json:"number"`
+	Title          string         `json:"title"`
+	State          string         `json:"state"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	ClosedAt       *time.Time     `json:"closed_at"`
+	User           IssueCreator   `json:"user"`
+	Labels         []Label        `json:"labels"`
+	Assignees      []IssueCreator `json:"assignees"`
+	Milestone      *Milestone     `json:"milestone"`
+	// Language is ISO 639-1 code of the title & body, see DetectLanguage
+	Language string `json:"language,omitempty"`
+	// Severity is inferred from the labels and the title, see
+	// SeverityRules
+	Severity Severity `json:"severity,omitempty"`
+	// PullRequest is only set if the issue is a pull request
+	PullRequest *PullRequestRef `json:"pull_request,omitempty"`
+
+	// optional data, see Capability
+	Body      string    `json:"body,omitempty"`
+	Reactions Reactions `json:"reactions"`
+	Comments  []Comment `json:"comments_data,omitempty"`
+	Events    []Event   `json:"events_data,omitempty"`
+	// Links are the duplicate and cross-referenced links of the issue, set
+	// from its comments and timeline
+	Links []IssueLink `json:"links,omitempty"`
+	// the bug timeline of the events, see BugTimeline
+	BugLabeledAt   *time.Time `json:"bug_labeled_at,omitempty"`
+	BugUnlabeledAt *time.Time `json:"bug_unlabeled_at,omitempty"`
+}
+
+// PullRequestRef represents the pull_request key of the GitHub issues that
+// are pull requests.
+type PullRequestRef struct {
+	Url string `json:"url"`
+}
+
+// IsPullRequest returns true if the issue is a pull request.
+func (i Issue) IsPullRequest() bool {
+	return i.PullRequest != nil
+}
+
+// IssueCreator represents the user who opened the issue.
+type IssueCreator struct {
+	Username        string `json:"login"`
+	GithubId        int64  `json:"id"`
+	AvatarUrl       string `json:"avatar_url"`
+	GravatarId      string `json:"gravatar_id"`
+	ApiProfileUrl   string `json:"url"`
+	ProfileUrl      string `json:"html_url"`
+	ApiFollowersUrl string `json:"followers_url"`
+	ApiFollowingUrl string `json:"following_url"`
+	ApiGistsUrl     string `json:"gists_url"`
+	ApiStarredUrl   string `json:"starred_url"`
+}
+
+// Milestone represents the milestone of the issue.
+type Milestone struct {
+	Number int        `json:"number"`
+	Title  string     `json:"title"`
+	State  string     `json:"state"`
+	DueOn  *time.Time `json:"due_on"`
+}
+
+// AssigneeNames returns the usernames of the assignees of the issue.
+func (i Issue) AssigneeNames() []string {
+	names := []string{}
+	for _, a := range i.Assignees {
+		names = append(names, a.Username)
+	}
+	return names
+}
+
+// Label represents a label of the issue.
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Result represents the bugs fetched from the package repository.
+type Result struct {
+	Package Package `json:"package"`
+	Etag    string  `json:"etag"`
+	Issues  []Issue `json:"issues"`
+	// Next is where the sync that spilled over the budget continues, nil
+	// if the sync is complete. The partial result has no etag.
+	Next *Cursor `json:"next,omitempty"`
+	// Full is set if the result has every issue of the package, the stored
+	// open issues missing from it were deleted or transferred on the host.
+	Full bool `json:"full,omitempty"`
+	// Responses are the validators of the fetched pages, they are cached
+	// once the result is stored, see ResponseCache
+	Responses []CachedResponse `json:"responses,omitempty"`
+}
+
+// Since returns the newest updated time of the issues.
+func (r *Result) Since() time.Time {
+	var since time.Time
+	for _, issue := range r.Issues {
+		if issue.UpdatedAt.After(since) {
+			since = issue.UpdatedAt
+		}
+	}
+	return since
+}
+
+// Numbers returns the numbers of the issues.
+func (r *Result) Numbers() []int {
+	numbers := make([]int, len(r.Issues))
+	for i, issue := range r.Issues {
+		numbers[i] = issue.Number
+	}
+	return numbers
+}
+
+// ExcludePullRequests removes the pull requests from the issues.
+func (r *Result) ExcludePullRequests() {
+	issues := r.Issues[:0]
+	for _, issue := range r.Issues {
+		if !issue.IsPullRequest() {
+			issues = append(issues, issue)
+		}
+	}
+	r.Issues = issues
+}
+
+// Store persists the sync state and the fetched bugs of the packages.
+type Store interface {
+	// GetEtag returns the etag of the last fetch, empty if never fetched.
+	GetEtag(p Package) (string, error)
+	// GetSince returns the newest updated time of the stored issues, zero
+	// if never synced.
+	GetSince(p Package) (time.Time, error)
+	// Save stores the result.
+	Save(r *Result) error
+}
+
+// RateState is the last known rate limit of an API token.
+type RateState struct {
+	Remaining int
+	Reset     time.Time
+	Known     bool
+}
+
+// FetchLog represents a single fetch of a package.
+type FetchLog struct {
+	PackageId string
+	StartedAt time.Time
+	Duration  time.Duration
+	// Issues is the number of upserted issues
+	Issues int
+	// Pages is the number of fetched pages and StatusCode is the status of
+	// the last page, see FetchStats
+	Pages      int
+	StatusCode int
+	// Error is empty if the fetch succeeded
+	Error string
+	// Gone is true if the repository is not found anymore, see ErrNotFound
+	Gone bool
+	// Skipped is the reason the package wasn't fetched, e.g. archived
+	Skipped string
+}
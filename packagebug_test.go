@@ -0,0 +1,92 @@
+package packagebug
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var pkgTest = Package{
+	Host:  "github.com",
+	Owner: "pyk",
+	Repo:  "byten",
+}
+
+func TestPackagePath(t *testing.T) {
+	expected := "github.com/pyk/byten"
+	path := pkgTest.Path()
+	if expected != path {
+		t.Errorf("got: %s\n", path)
+	}
+}
+
+func TestPackageKey(t *testing.T) {
+	if key := pkgTest.Key(); key != "github.com/pyk/byten" {
+		t.Errorf("expected the path got: %s\n", key)
+	}
+	p := pkgTest
+	p.Tenant = "acme"
+	if key := p.Key(); key != "acme:github.com/pyk/byten" {
+		t.Errorf("expected the path of acme got: %s\n", key)
+	}
+}
+
+func TestResultSince(t *testing.T) {
+	newest := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	r := &Result{Issues: []Issue{
+		{UpdatedAt: newest.Add(-time.Hour)},
+		{UpdatedAt: newest},
+	}}
+	if !r.Since().Equal(newest) {
+		t.Errorf("expected: %s got: %s\n", newest, r.Since())
+	}
+}
+
+func TestParseTokens(t *testing.T) {
+	expected := []string{"a", "b"}
+	tokens := ParseTokens(" a,,b ,")
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("expected: %v got: %v\n", expected, tokens)
+	}
+}
+
+func TestResultExcludePullRequests(t *testing.T) {
+	r := &Result{Issues: []Issue{
+		{Number: 1},
+		{Number: 2, PullRequest: &PullRequestRef{Url: "https://api.github.com/repos/pyk/byten/pulls/2"}},
+		{Number: 3},
+	}}
+	r.ExcludePullRequests()
+	if len(r.Issues) != 2 || r.Issues[1].Number != 3 {
+		t.Errorf("expected issues 1 & 3 got: %+v\n", r.Issues)
+	}
+}
+
+func TestIssueDecode(t *testing.T) {
+	var issue Issue
+	err := json.Unmarshal([]byte(`{"number": 1, "body": "panic",
+		"reactions": {"total_count": 5, "+1": 4},
+		"assignees": [{"login": "pyk", "id": 7}, {"login": "octocat"}],
+		"milestone": {"number": 2, "title": "v1.0", "state": "open",
+			"due_on": "2016-02-01T00:00:00Z"},
+		"created_at": "2016-01-01T00:00:00Z", "updated_at": "2016-01-02T00:00:00Z"}`), &issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Body != "panic" || issue.Reactions.PlusOne != 4 {
+		t.Errorf("expected body and reactions got: %+v\n", issue)
+	}
+	if names := issue.AssigneeNames(); !reflect.DeepEqual(names, []string{"pyk", "octocat"}) {
+		t.Errorf("unexpected assignees: %v\n", names)
+	}
+	if issue.Assignees[0].GithubId != 7 {
+		t.Errorf("expected assignee id got: %+v\n", issue.Assignees[0])
+	}
+	if issue.Milestone == nil || issue.Milestone.Title != "v1.0" || issue.Milestone.DueOn == nil {
+		t.Errorf("unexpected milestone: %+v\n", issue.Milestone)
+	}
+	if issue.CreatedAt.IsZero() || issue.UpdatedAt.IsZero() {
+		t.Errorf("expected timestamps got: %+v\n", issue)
+	}
+}
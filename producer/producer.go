@@ -0,0 +1,137 @@
+// Package producer enqueues the packages for the worker, so the other
+// services don't format the queue messages themselves, see
+// packagebug.ParseMessage.
+package producer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/queue"
+	"github.com/pyk/packagebug-worker/internal/queue/redis"
+	"github.com/pyk/packagebug-worker/internal/queue/sqs"
+)
+
+// Sender sends the message body to the queue with priority, every queue of
+// the worker that can send messages implements it.
+type Sender interface {
+	Send(ctx context.Context, body string, priority int) error
+}
+
+// Options are the options of the enqueued package.
+type Options struct {
+	// Priority of the message, the messages of higher priority are
+	// processed first
+	Priority int
+	// Action is what the worker does with the package, ActionFetch if empty
+	Action packagebug.Action
+	// Delay delays the message, e.g. the DelaySeconds of SQS, and Jitter
+	// adds a random delay up to it, so the packages enqueued at once are
+	// fetched spread. The delay is at most 15 minutes, the queues without
+	// delay send right away.
+	Delay  time.Duration
+	Jitter time.Duration
+	// Tenant is the tenant of the package of the module path, see
+	// EnqueueModule. The package of Enqueue has its own.
+	Tenant string
+}
+
+// Producer enqueues the packages to the queue of Sender.
+type Producer struct {
+	Sender Sender
+}
+
+// New creates the producer of the queue s.
+func New(s Sender) *Producer {
+	return &Producer{Sender: s}
+}
+
+// NewSQS creates the producer of the SQS queue at url, or of the queue
+// named url.
+func NewSQS(url, region string) (*Producer, error) {
+	q, err := sqs.New("", region, url)
+	if err != nil {
+		return nil, err
+	}
+	return New(q), nil
+}
+
+// NewRedis creates the producer of the Redis list key at url.
+func NewRedis(url, key string) (*Producer, error) {
+	q, err := redis.New(url, key)
+	if err != nil {
+		return nil, err
+	}
+	return New(q), nil
+}
+
+// Enqueue sends the message of the package. The package needs its id, host,
+// owner and repo.
+func (p *Producer) Enqueue(ctx context.Context, pkg packagebug.Package, opts Options) error {
+	err := Validate(pkg)
+	if err != nil {
+		return err
+	}
+	action, err := packagebug.ParseAction(string(opts.Action))
+	if err != nil {
+		return err
+	}
+	body := packagebug.FormatMessage(pkg, opts.Priority)
+	if action != packagebug.ActionFetch {
+		body = packagebug.FormatAction(pkg, opts.Priority, action)
+	}
+	delay := opts.Delay + queue.Jitter(opts.Jitter)
+	return queue.SendDelayed(ctx, p.Sender, body, opts.Priority, delay)
+}
+
+// EnqueueModule sends the message of the package of the module path, e.g.
+// golang.org/x/net, for the producers that only know the module of the
+// package. The worker resolves the module to its repository, see
+// packagebug.FormatModule.
+func (p *Producer) EnqueueModule(ctx context.Context, id, module string, opts Options) error {
+	if id == "" || strings.ContainsAny(id, ", \n") {
+		return fmt.Errorf("invalid package id %q", id)
+	}
+	host, _, ok := strings.Cut(module, "/")
+	if !ok || !strings.Contains(host, ".") || strings.ContainsAny(module, ", \n") {
+		return fmt.Errorf("invalid module path %q", module)
+	}
+	if strings.ContainsAny(opts.Tenant, ", \n") {
+		return fmt.Errorf("invalid tenant %q", opts.Tenant)
+	}
+	action, err := packagebug.ParseAction(string(opts.Action))
+	if err != nil {
+		return err
+	}
+	if action == packagebug.ActionFetch {
+		action = ""
+	}
+	body := packagebug.FormatModule(id, module, opts.Tenant, opts.Priority, action)
+	delay := opts.Delay + queue.Jitter(opts.Jitter)
+	return queue.SendDelayed(ctx, p.Sender, body, opts.Priority, delay)
+}
+
+// Validate returns an error if the package can't be enqueued: a field is
+// empty or contains the comma of the message format.
+func Validate(pkg packagebug.Package) error {
+	fields := []struct {
+		name, value string
+	}{
+		{"id", pkg.Id},
+		{"host", pkg.Host},
+		{"owner", pkg.Owner},
+		{"repo", pkg.Repo},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			return fmt.Errorf("package %s required", f.name)
+		}
+		if strings.ContainsAny(f.value, ", \n") {
+			return fmt.Errorf("invalid package %s %q", f.name, f.value)
+		}
+	}
+	return nil
+}
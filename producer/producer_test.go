@@ -0,0 +1,94 @@
+package producer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pyk/packagebug-worker"
+	"github.com/pyk/packagebug-worker/internal/fake"
+)
+
+func TestProducerEnqueue(t *testing.T) {
+	q := fake.NewQueue()
+	p := New(q)
+	pkg := fake.Package
+	pkg.Id = "1"
+	ctx := context.Background()
+	err := p.Enqueue(ctx, pkg, Options{Priority: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Enqueue(ctx, pkg, Options{Action: packagebug.ActionDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 2 || msgs[0] != "1,github.com,pyk,byten,5" || msgs[1] != "1,github.com,pyk,byten,0,delete" {
+		t.Errorf("unexpected messages: %v\n", msgs)
+	}
+	for _, m := range msgs {
+		_, err = packagebug.ParseMessage(m)
+		if err != nil {
+			t.Errorf("expected valid message %q got: %s\n", m, err)
+		}
+	}
+}
+
+func TestProducerEnqueueInvalid(t *testing.T) {
+	q := fake.NewQueue()
+	p := New(q)
+	ctx := context.Background()
+	pkg := fake.Package
+	pkg.Id = "1"
+	cases := []struct {
+		pkg  packagebug.Package
+		opts Options
+	}{
+		{fake.Package, Options{}},
+		{packagebug.Package{Id: "1", Host: "github.com", Owner: "pyk", Repo: "a,b"}, Options{}},
+		{pkg, Options{Action: "drop"}},
+	}
+	for _, c := range cases {
+		err := p.Enqueue(ctx, c.pkg, c.opts)
+		if err == nil {
+			t.Errorf("expected error of %+v %+v\n", c.pkg, c.opts)
+		}
+	}
+	if len(q.Messages()) != 0 {
+		t.Errorf("expected no message got: %v\n", q.Messages())
+	}
+}
+
+func TestProducerEnqueueModule(t *testing.T) {
+	q := fake.NewQueue()
+	p := New(q)
+	ctx := context.Background()
+	err := p.EnqueueModule(ctx, "1", "golang.org/x/net", Options{Priority: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := q.Messages()
+	if len(msgs) != 1 || msgs[0] != "1,golang.org/x/net,2" {
+		t.Fatalf("unexpected messages: %v\n", msgs)
+	}
+	m, err := packagebug.ParseMessage(msgs[0])
+	if err != nil || m.Module != "golang.org/x/net" {
+		t.Errorf("expected module message got: %+v %v\n", m, err)
+	}
+
+	// the tenant follows the module
+	err = p.EnqueueModule(ctx, "2", "gopkg.in/yaml.v3", Options{Tenant: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = packagebug.ParseMessage(q.Messages()[1])
+	if err != nil || m.Module != "gopkg.in/yaml.v3" || m.Package.Tenant != "acme" {
+		t.Errorf("expected module message of the tenant got: %+v %v\n", m, err)
+	}
+	for _, module := range []string{"", "net", "localhost/net", "golang.org/x/a,b"} {
+		err = p.EnqueueModule(ctx, "1", module, Options{})
+		if err == nil {
+			t.Errorf("expected error for %q\n", module)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package packagebug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Query is the issue list query of the package: the state filter, the bug
+// labels and the sort order. The empty fields are the defaults, every state
+// of the bug label in the order of the host.
+type Query struct {
+	// State is all, open or closed
+	State string `json:",omitempty"`
+	// Labels replace the bug label, each of them is queried separately like
+	// the aliases of the bug label
+	Labels []string `json:",omitempty"`
+	// Sort is created, updated or comments and Direction is asc or desc
+	Sort      string `json:",omitempty"`
+	Direction string `json:",omitempty"`
+}
+
+// ParseQuery parses the state filter, the comma separated labels, the sort
+// field and the sort direction of the query, the empty ones are the
+// defaults.
+func ParseQuery(state, labels, sort, direction string) (Query, error) {
+	q := Query{
+		State:     strings.ToLower(strings.TrimSpace(state)),
+		Labels:    ParseTokens(labels),
+		Sort:      strings.ToLower(strings.TrimSpace(sort)),
+		Direction: strings.ToLower(strings.TrimSpace(direction)),
+	}
+	switch q.State {
+	case "", "all", "open", "closed":
+	default:
+		return Query{}, fmt.Errorf("unknown issue state %q", state)
+	}
+	switch q.Sort {
+	case "", "created", "updated", "comments":
+	default:
+		return Query{}, fmt.Errorf("unknown issue sort %q", sort)
+	}
+	switch q.Direction {
+	case "", "asc", "desc":
+	default:
+		return Query{}, fmt.Errorf("unknown sort direction %q", direction)
+	}
+	return q, nil
+}
+
+// Override returns q with the fields set by o replaced, e.g. the query of
+// the deployment overridden by the query of the package.
+func (q Query) Override(o Query) Query {
+	if o.State != "" {
+		q.State = o.State
+	}
+	if len(o.Labels) > 0 {
+		q.Labels = o.Labels
+	}
+	if o.Sort != "" {
+		q.Sort = o.Sort
+	}
+	if o.Direction != "" {
+		q.Direction = o.Direction
+	}
+	return q
+}
+
+// IssueState returns the state filter, all by default.
+func (q Query) IssueState() string {
+	if q.State == "" {
+		return "all"
+	}
+	return q.State
+}
+
+// BugLabels returns the labels that mark the bugs, bug by default.
+func (q Query) BugLabels() []string {
+	if len(q.Labels) == 0 {
+		return []string{"bug"}
+	}
+	return q.Labels
+}
+
+// Complete returns true if the query lists the issues of every state, so
+// the issues missing from the complete list were removed, see Result.Full.
+func (q Query) Complete() bool {
+	return q.IssueState() == "all"
+}
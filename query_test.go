@@ -0,0 +1,53 @@
+package packagebug
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery("", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.IssueState() != "all" {
+		t.Errorf("expected state all got: %q\n", q.IssueState())
+	}
+	if !reflect.DeepEqual(q.BugLabels(), []string{"bug"}) {
+		t.Errorf("expected labels [bug] got: %v\n", q.BugLabels())
+	}
+	q, err = ParseQuery(" Open", "bug, defect", "updated", "asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Query{State: "open", Labels: []string{"bug", "defect"}, Sort: "updated", Direction: "asc"}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("expected %+v got: %+v\n", expected, q)
+	}
+	if q.Complete() {
+		t.Errorf("expected the open query not complete\n")
+	}
+	for _, args := range [][]string{
+		{"draft", "", "", ""},
+		{"", "", "votes", ""},
+		{"", "", "", "up"},
+	} {
+		_, err = ParseQuery(args[0], args[1], args[2], args[3])
+		if err == nil {
+			t.Errorf("expected error for %q\n", args)
+		}
+	}
+}
+
+func TestQueryOverride(t *testing.T) {
+	deployment := Query{State: "open", Labels: []string{"bug"}, Sort: "updated"}
+	q := deployment.Override(Query{State: "all", Labels: []string{"kind/bug"}})
+	expected := Query{State: "all", Labels: []string{"kind/bug"}, Sort: "updated"}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("expected %+v got: %+v\n", expected, q)
+	}
+	q = deployment.Override(Query{})
+	if !reflect.DeepEqual(q, deployment) {
+		t.Errorf("expected %+v got: %+v\n", deployment, q)
+	}
+}
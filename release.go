@@ -0,0 +1,18 @@
+package packagebug
+
+import "time"
+
+// Release represents the published release of the package repository.
+type Release struct {
+	Tag         string
+	Name        string
+	Prerelease  bool
+	PublishedAt time.Time
+}
+
+// Releases are the releases of the package repository.
+type Releases struct {
+	Releases []Release
+	// Etag of the release list, the next fetch is conditional
+	Etag string
+}
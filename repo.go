@@ -0,0 +1,18 @@
+package packagebug
+
+import "time"
+
+// Repo represents the metadata of the package repository used to rank the
+// packages.
+type Repo struct {
+	Stars         int
+	Forks         int
+	Archived      bool
+	DefaultBranch string
+	Description   string
+	PushedAt      time.Time
+	// Etag of the metadata, the next fetch is conditional
+	Etag string
+	// FetchedAt is when the stored metadata was fetched, zero if never
+	FetchedAt time.Time
+}
@@ -0,0 +1,144 @@
+package packagebug
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Retry is the retry policy used by the fetches and the database writes.
+var Retry = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// StatusError is returned when the API responds with unexpected status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the host asks to wait before the next request,
+	// zero if the host didn't ask, see ParseRetryAfter
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// NewStatusError creates StatusError from the response.
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: ParseRetryAfter(resp.Header, time.Now()),
+	}
+}
+
+// ParseRetryAfter returns how long the host asks to wait from the response
+// headers: the Retry-After header in seconds or HTTP date, e.g. of the
+// GitHub secondary rate limit, otherwise the time until X-RateLimit-Reset if
+// no request is remaining. It returns zero if the host didn't ask to wait.
+func ParseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	if h.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil && time.Unix(reset, 0).After(now) {
+			return time.Unix(reset, 0).Sub(now)
+		}
+	}
+	return 0
+}
+
+// RetryAfter returns the RetryAfter of the StatusError of err, zero if err
+// has none.
+func RetryAfter(err error) time.Duration {
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.RetryAfter
+	}
+	return 0
+}
+
+// Retryable returns true if err is transient: server errors, timeouts,
+// dropped connections and database serialization failures or deadlocks.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode >= 500
+	}
+
+	var pqerr *pq.Error
+	if errors.As(err, &pqerr) {
+		// serialization_failure & deadlock_detected
+		return pqerr.Code == "40001" || pqerr.Code == "40P01"
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// RetryPolicy retries transient errors with exponential backoff and jitter.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Backoff returns the delay before the next attempt. The delay is chosen
+// randomly between 0 and BaseDelay*2^attempt, capped by MaxDelay.
+func (r RetryPolicy) Backoff(attempt int) time.Duration {
+	d := r.BaseDelay << uint(attempt)
+	if d <= 0 || d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Do calls fn until it succeeds, returns a permanent error or MaxAttempts is
+// reached. It returns the last error.
+func (r RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if !Retryable(err) || attempt+1 >= r.MaxAttempts {
+			return err
+		}
+
+		wait := r.Backoff(attempt)
+		Logger(ctx).Warn("transient error. retrying", "error", err,
+			"attempt", attempt+1, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package packagebug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("host not supported"), false},
+		{&StatusError{StatusCode: http.StatusBadGateway}, true},
+		{&StatusError{StatusCode: http.StatusNotFound}, false},
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "23505"}, false},
+	}
+	for _, c := range cases {
+		if Retryable(c.err) != c.retryable {
+			t.Errorf("%v expected retryable: %v\n", c.err, c.retryable)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	r := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	n := 0
+	err := r.Do(context.Background(), func() error {
+		n++
+		return &StatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	if err == nil || n != 3 {
+		t.Errorf("expected 3 attempts got: %d %v\n", n, err)
+	}
+
+	n = 0
+	err = r.Do(context.Background(), func() error {
+		n++
+		return &StatusError{StatusCode: http.StatusNotFound}
+	})
+	if err == nil || n != 1 {
+		t.Errorf("expected permanent error not retried got: %d %v\n", n, err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	r := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		d := r.Backoff(attempt)
+		if d < 0 || d > r.MaxDelay {
+			t.Errorf("attempt %d: backoff out of range: %s\n", attempt, d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		header map[string]string
+		wait   time.Duration
+	}{
+		{map[string]string{}, 0},
+		{map[string]string{"Retry-After": "60"}, time.Minute},
+		{map[string]string{"Retry-After": "Fri, 01 Jan 2016 00:02:00 GMT"}, 2 * time.Minute},
+		{map[string]string{"Retry-After": "soon"}, 0},
+		{map[string]string{"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset": fmt.Sprint(now.Add(time.Hour).Unix())}, time.Hour},
+		{map[string]string{"X-RateLimit-Remaining": "10",
+			"X-RateLimit-Reset": fmt.Sprint(now.Add(time.Hour).Unix())}, 0},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		for k, v := range c.header {
+			h.Set(k, v)
+		}
+		if wait := ParseRetryAfter(h, now); wait != c.wait {
+			t.Errorf("expected wait %s of %v got: %s\n", c.wait, c.header, wait)
+		}
+	}
+	err := fmt.Errorf("fetch: %w", &StatusError{StatusCode: 403, RetryAfter: time.Minute})
+	if RetryAfter(err) != time.Minute {
+		t.Errorf("expected retry after of the wrapped error got: %s\n", RetryAfter(err))
+	}
+}
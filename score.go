@@ -0,0 +1,87 @@
+package packagebug
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ScoreWindow is the window of the close rate of the score.
+const ScoreWindow = 90 * 24 * time.Hour
+
+// ScoredIssue is the stored issue the score is computed from.
+type ScoredIssue struct {
+	State     string
+	CreatedAt time.Time
+	ClosedAt  *time.Time
+	// RespondedAt is the first comment by someone other than the author,
+	// nil if there is none or the comments are not fetched
+	RespondedAt *time.Time
+}
+
+// Score is the bug-health score of a package, computed at sync time from its
+// stored issues.
+type Score struct {
+	// WeightedOpenBugs counts the open bugs weighted by age: a new bug is 1
+	// and every ScoreWindow of age adds 1
+	WeightedOpenBugs float64
+	// CloseRate is the bugs closed within ScoreWindow over themselves and
+	// the open bugs, 1 without bugs
+	CloseRate float64
+	// MedianResponse is the median time to the first response, zero if no
+	// bug has a response
+	MedianResponse time.Duration
+	// Value is the score from 0 to 100, higher is healthier
+	Value float64
+}
+
+// ComputeScore returns the score of the issues at now. The value weighs the
+// backlog of the weighted open bugs and the close rate 40% each and the
+// responsiveness 20%, the weights are shared by the backlog and the close
+// rate if no bug has a response.
+func ComputeScore(issues []ScoredIssue, now time.Time) Score {
+	var s Score
+	var open, closed int
+	var responses []time.Duration
+	for _, i := range issues {
+		switch {
+		case i.State == "open":
+			open++
+			age := now.Sub(i.CreatedAt)
+			if i.CreatedAt.IsZero() || age < 0 {
+				age = 0
+			}
+			s.WeightedOpenBugs += 1 + float64(age)/float64(ScoreWindow)
+		case i.ClosedAt != nil && now.Sub(*i.ClosedAt) <= ScoreWindow:
+			closed++
+		}
+		if i.RespondedAt != nil && !i.CreatedAt.IsZero() && i.RespondedAt.After(i.CreatedAt) {
+			responses = append(responses, i.RespondedAt.Sub(i.CreatedAt))
+		}
+	}
+	s.CloseRate = 1
+	if open+closed > 0 {
+		s.CloseRate = float64(closed) / float64(open+closed)
+	}
+	// the backlog of 10 new bugs is half healthy
+	backlog := 1 / (1 + s.WeightedOpenBugs/10)
+	if len(responses) == 0 {
+		s.Value = 100 * (backlog + s.CloseRate) / 2
+		return s.round()
+	}
+	sort.Slice(responses, func(a, b int) bool { return responses[a] < responses[b] })
+	s.MedianResponse = responses[len(responses)/2]
+	// the response within a week is half healthy
+	response := 1 / (1 + float64(s.MedianResponse)/float64(7*24*time.Hour))
+	s.Value = 100 * (0.4*backlog + 0.4*s.CloseRate + 0.2*response)
+	return s.round()
+}
+
+// round rounds the value and the rates to two decimals.
+func (s Score) round() Score {
+	round := func(f float64) float64 { return math.Round(f*100) / 100 }
+	s.WeightedOpenBugs = round(s.WeightedOpenBugs)
+	s.CloseRate = round(s.CloseRate)
+	s.Value = round(s.Value)
+	return s
+}
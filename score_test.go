@@ -0,0 +1,40 @@
+package packagebug
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeScore(t *testing.T) {
+	now := time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := ComputeScore(nil, now)
+	if s.Value != 100 || s.CloseRate != 1 {
+		t.Errorf("expected healthy score without bugs got: %+v\n", s)
+	}
+
+	closedAt := now.Add(-24 * time.Hour)
+	respondedAt := now.Add(-ScoreWindow + 24*time.Hour)
+	issues := []ScoredIssue{
+		// 90 days old weighs 2
+		{State: "open", CreatedAt: now.Add(-ScoreWindow), RespondedAt: &respondedAt},
+		{State: "closed", CreatedAt: now.Add(-48 * time.Hour), ClosedAt: &closedAt},
+	}
+	s = ComputeScore(issues, now)
+	if s.WeightedOpenBugs != 2 || s.CloseRate != 0.5 {
+		t.Errorf("unexpected backlog and close rate: %+v\n", s)
+	}
+	if s.MedianResponse != 24*time.Hour {
+		t.Errorf("expected 24h median response got: %s\n", s.MedianResponse)
+	}
+	// 100 * (0.4/1.2 + 0.4*0.5 + 0.2/(1+1/7))
+	if s.Value != 70.83 {
+		t.Errorf("expected score 70.83 got: %v\n", s.Value)
+	}
+
+	// the closes before the window don't count
+	closedAt = now.Add(-2 * ScoreWindow)
+	s = ComputeScore(issues, now)
+	if s.CloseRate != 0 {
+		t.Errorf("expected no recent close got: %+v\n", s)
+	}
+}
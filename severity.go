@@ -0,0 +1,203 @@
+package packagebug
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Severity is the normalized impact of the bug inferred from its labels and
+// title, see SeverityRules. The higher severity is more severe, so the bugs
+// sort by impact by their severity.
+type Severity int
+
+const (
+	// SeverityUnknown is the severity of the bug no rule matches.
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"", "low", "medium", "high", "critical"}
+
+// String returns the name of the severity, empty if unknown.
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return ""
+	}
+	return severityNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(b []byte) error {
+	v, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// ParseSeverity parses the name of the severity, empty is SeverityUnknown.
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q", name)
+}
+
+// SeverityRule maps the labels and the title keywords to the severity.
+type SeverityRule struct {
+	Severity Severity
+	// Labels match the labels case-insensitively, either the whole label
+	// or its last segment after / or :, e.g. p0 matches priority/P0
+	Labels []string
+	// Keywords match the words or phrases of the title case-insensitively
+	Keywords []string
+
+	// labels are the lower case Labels and keywords the pattern of
+	// Keywords, both set by NewSeverityRule
+	labels   []string
+	keywords *regexp.Regexp
+}
+
+// NewSeverityRule returns the rule of the labels and the keywords of the
+// severity with its labels lowered and its keywords compiled once.
+func NewSeverityRule(s Severity, labels, keywords []string) SeverityRule {
+	return SeverityRule{Severity: s, Labels: labels, Keywords: keywords,
+		labels: lowerLabels(labels), keywords: keywordPattern(keywords)}
+}
+
+// lowerLabels returns the lower case labels.
+func lowerLabels(labels []string) []string {
+	lower := make([]string, len(labels))
+	for i, l := range labels {
+		lower[i] = strings.ToLower(l)
+	}
+	return lower
+}
+
+// keywordPattern returns the pattern that matches any of the keywords as
+// whole words of the lower case text, nil if there are no keywords.
+func keywordPattern(keywords []string) *regexp.Regexp {
+	if len(keywords) == 0 {
+		return nil
+	}
+	words := make([]string, len(keywords))
+	for i, k := range keywords {
+		words[i] = regexp.QuoteMeta(strings.ToLower(k))
+	}
+	return regexp.MustCompile(`\b(` + strings.Join(words, "|") + `)\b`)
+}
+
+// SeverityRules are the rules of every severity, the most severe rule that
+// matches the bug is its severity.
+type SeverityRules []SeverityRule
+
+// DefaultSeverityRules are the rules of the severities not configured by
+// the deployment.
+var DefaultSeverityRules = SeverityRules{
+	NewSeverityRule(SeverityCritical,
+		[]string{"critical", "p0", "security", "blocker"},
+		[]string{"data loss", "corruption", "security", "vulnerability", "cve"}),
+	NewSeverityRule(SeverityHigh,
+		[]string{"high", "p1", "major", "regression"},
+		[]string{"panic", "crash", "deadlock", "segfault", "memory leak", "race condition", "regression"}),
+	NewSeverityRule(SeverityMedium,
+		[]string{"medium", "p2"},
+		[]string{"leak", "hang", "hangs", "wrong", "incorrect"}),
+	NewSeverityRule(SeverityLow,
+		[]string{"low", "p3", "minor", "trivial"},
+		[]string{"typo", "docs", "documentation"}),
+}
+
+// ParseSeverityRule parses the comma separated labels and keywords of the
+// severity, the labels are prefixed by label:, e.g.
+// "label:p0,label:security,data loss".
+func ParseSeverityRule(s Severity, rule string) (SeverityRule, error) {
+	var labels, keywords []string
+	for _, t := range ParseTokens(rule) {
+		if label, ok := strings.CutPrefix(t, "label:"); ok {
+			label = strings.TrimSpace(label)
+			if label == "" {
+				return SeverityRule{}, fmt.Errorf("empty label of severity %s", s)
+			}
+			labels = append(labels, label)
+		} else {
+			keywords = append(keywords, t)
+		}
+	}
+	return NewSeverityRule(s, labels, keywords), nil
+}
+
+// Classify returns the severity of the issue, SeverityUnknown if no rule
+// matches it.
+func (rules SeverityRules) Classify(issue Issue) Severity {
+	severity, _ := rules.classify(issue, nil)
+	return severity
+}
+
+// classify returns the severity of the issue and the buffer names the
+// labels of the issue are lowered into once, so the issues of a result
+// share it.
+func (rules SeverityRules) classify(issue Issue, names []string) (Severity, []string) {
+	// every label matches by its whole name and its last segment
+	names = names[:0]
+	for _, l := range issue.Labels {
+		name := strings.ToLower(l.Name)
+		names = append(names, name)
+		if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+			names = append(names, strings.TrimSpace(name[i+1:]))
+		}
+	}
+	title := strings.ToLower(issue.Title)
+	severity := SeverityUnknown
+	for _, r := range rules {
+		if r.Severity > severity && r.match(names, title) {
+			severity = r.Severity
+		}
+	}
+	return severity, names
+}
+
+// match returns true if a label or a keyword of the rule matches the lower
+// case label names or title of the issue.
+func (r SeverityRule) match(names []string, title string) bool {
+	// the rules not created by NewSeverityRule lower their labels and
+	// compile their keywords
+	labels := r.labels
+	if labels == nil {
+		labels = lowerLabels(r.Labels)
+	}
+	for _, name := range names {
+		if slices.Contains(labels, name) {
+			return true
+		}
+	}
+	re := r.keywords
+	if re == nil {
+		re = keywordPattern(r.Keywords)
+	}
+	return re != nil && re.MatchString(title)
+}
+
+// ClassifySeverity sets the severity of every issue by the rules, see
+// SeverityRules.Classify.
+func (r *Result) ClassifySeverity(rules SeverityRules) {
+	var names []string
+	for i := range r.Issues {
+		issue := &r.Issues[i]
+		issue.Severity, names = rules.classify(*issue, names)
+	}
+}
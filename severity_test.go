@@ -0,0 +1,68 @@
+package packagebug
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSeverityRulesClassify(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  Severity
+	}{
+		{Issue{Title: "add an example"}, SeverityUnknown},
+		{Issue{Title: "Panic on empty input"}, SeverityHigh},
+		{Issue{Title: "typo in the readme"}, SeverityLow},
+		{Issue{Title: "possible data loss on restart"}, SeverityCritical},
+		// whole words only
+		{Issue{Title: "crashes"}, SeverityUnknown},
+		{Issue{Title: "typo", Labels: []Label{{Name: "priority/P0"}}}, SeverityCritical},
+		{Issue{Title: "slow", Labels: []Label{{Name: "Severity: Medium"}}}, SeverityMedium},
+		{Issue{Title: "slow", Labels: []Label{{Name: "p00"}}}, SeverityUnknown},
+	}
+	for _, tt := range tests {
+		if got := DefaultSeverityRules.Classify(tt.issue); got != tt.want {
+			t.Errorf("expected %q of %q %v got: %q\n", tt.want, tt.issue.Title, tt.issue.Labels, got)
+		}
+	}
+}
+
+func TestParseSeverityRule(t *testing.T) {
+	r, err := ParseSeverityRule(SeverityHigh, "label:urgent, timeout ,out of memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Labels) != 1 || r.Labels[0] != "urgent" || len(r.Keywords) != 2 {
+		t.Errorf("unexpected rule: %+v\n", r)
+	}
+	rules := SeverityRules{r}
+	if rules.Classify(Issue{Title: "Out of memory when parsing"}) != SeverityHigh {
+		t.Error("expected the keyword matched")
+	}
+	if rules.Classify(Issue{Title: "panic"}) != SeverityUnknown {
+		t.Error("expected the default keywords replaced")
+	}
+	_, err = ParseSeverityRule(SeverityHigh, "label: ")
+	if err == nil {
+		t.Error("expected error of the empty label")
+	}
+}
+
+func TestSeverityJSON(t *testing.T) {
+	data, err := json.Marshal(Issue{Severity: SeverityCritical})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issue Issue
+	err = json.Unmarshal(data, &issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Severity != SeverityCritical {
+		t.Errorf("expected critical got: %q from %s\n", issue.Severity, data)
+	}
+	_, err = ParseSeverity("urgent")
+	if err == nil {
+		t.Error("expected error of the unknown severity")
+	}
+}
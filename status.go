@@ -0,0 +1,22 @@
+package packagebug
+
+import "time"
+
+// Status represents the sync state of a package reported by the admin API.
+type Status struct {
+	Path  string    `json:"path"`
+	Etag  string    `json:"etag"`
+	Since time.Time `json:"since"`
+	// LastFetchAt is zero if the package was never fetched
+	LastFetchAt time.Time `json:"last_fetch_at"`
+	// LastError is the error of the last fetch, empty if it succeeded
+	LastError  string `json:"last_error"`
+	OpenBugs   int    `json:"open_bugs"`
+	ClosedBugs int    `json:"closed_bugs"`
+	// GoneAt is the time the repository was first found gone, nil unless
+	// the package is gone
+	GoneAt *time.Time `json:"gone_at,omitempty"`
+	// EmptySince is the time since the package has no bugs, nil if it has
+	// any
+	EmptySince *time.Time `json:"empty_since,omitempty"`
+}
@@ -0,0 +1,49 @@
+package packagebug
+
+import (
+	"sort"
+)
+
+// Timeline reports whether the event is part of the bug timeline of the
+// issue: the labels applied and removed and the issue closed and reopened.
+// The other events, e.g. mentioned or subscribed, are not stored.
+func (e Event) Timeline() bool {
+	switch e.Event {
+	case "labeled", "unlabeled", "closed", "reopened":
+		return true
+	}
+	return false
+}
+
+// BugTimeline sets when the bug label was first applied to the issue and
+// when the last bug label was removed from it, replaying its events in the
+// order of creation. BugUnlabeledAt stays nil while any bug label remains.
+// isBug reports whether the label marks the bugs of the package.
+func (i *Issue) BugTimeline(isBug func(label string) bool) {
+	events := make([]Event, len(i.Events))
+	copy(events, i.Events)
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].CreatedAt.Before(events[b].CreatedAt)
+	})
+	i.BugLabeledAt, i.BugUnlabeledAt = nil, nil
+	labels := make(map[string]bool)
+	for _, e := range events {
+		if !isBug(e.Label.Name) {
+			continue
+		}
+		at := e.CreatedAt
+		switch e.Event {
+		case "labeled":
+			labels[e.Label.Name] = true
+			if i.BugLabeledAt == nil {
+				i.BugLabeledAt = &at
+			}
+			i.BugUnlabeledAt = nil
+		case "unlabeled":
+			delete(labels, e.Label.Name)
+			if len(labels) == 0 {
+				i.BugUnlabeledAt = &at
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package packagebug
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueBugTimeline(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2016, 1, day, 0, 0, 0, 0, time.UTC) }
+	event := func(name, label string, day int) Event {
+		e := Event{Event: name, CreatedAt: at(day)}
+		e.Label.Name = label
+		return e
+	}
+	isBug := func(label string) bool { return strings.EqualFold(label, "bug") || label == "kind/bug" }
+
+	issue := Issue{Events: []Event{
+		event("unlabeled", "bug", 4),
+		event("labeled", "bug", 2),
+		event("labeled", "docs", 1),
+		event("labeled", "kind/bug", 3),
+		event("closed", "", 5),
+	}}
+	issue.BugTimeline(isBug)
+	if issue.BugLabeledAt == nil || !issue.BugLabeledAt.Equal(at(2)) {
+		t.Errorf("expected labeled at %s got: %v\n", at(2), issue.BugLabeledAt)
+	}
+	// kind/bug remains
+	if issue.BugUnlabeledAt != nil {
+		t.Errorf("expected the bug labeled got: %v\n", issue.BugUnlabeledAt)
+	}
+
+	issue.Events = append(issue.Events, event("unlabeled", "kind/bug", 6))
+	issue.BugTimeline(isBug)
+	if issue.BugUnlabeledAt == nil || !issue.BugUnlabeledAt.Equal(at(6)) {
+		t.Errorf("expected unlabeled at %s got: %v\n", at(6), issue.BugUnlabeledAt)
+	}
+	if !issue.Events[4].Timeline() || event("mentioned", "", 1).Timeline() {
+		t.Errorf("unexpected timeline events\n")
+	}
+}